@@ -0,0 +1,266 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vmware-tanzu/crash-diagnostics/bundle"
+)
+
+// Severity indicates how important a finding is
+type Severity string
+
+const (
+	Critical Severity = "critical"
+	Warning  Severity = "warning"
+	Info     Severity = "info"
+)
+
+var severityRanks = map[Severity]int{Critical: 0, Warning: 1, Info: 2}
+
+// ReportFileName is the base name of report files saved in an analyzed
+// directory. These files are not analyzed.
+const ReportFileName = "analysis"
+
+// Finding is a problem reported by a rule
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	// Resource identifies the object or host the finding is about
+	Resource string `json:"resource"`
+	Message  string `json:"message"`
+	// Evidence lists the files, relative to the analyzed root, that support the finding
+	Evidence []string `json:"evidence,omitempty"`
+}
+
+// Input is the data available to rules
+type Input struct {
+	// Root is the directory containing the collected files
+	Root    string
+	Objects *bundle.ObjectIndex
+}
+
+// NewInput prepares the input used to analyze bundle b
+func NewInput(b *bundle.Bundle) (*Input, error) {
+	objects, err := b.KubeObjects()
+	if err != nil {
+		return nil, err
+	}
+	return &Input{Root: b.Root(), Objects: objects}, nil
+}
+
+// Select returns objects matching q, ignoring invalid queries
+func (in *Input) Select(q bundle.Query) []*bundle.Object {
+	if in.Objects == nil {
+		return nil
+	}
+	objs, err := in.Objects.Select(q)
+	if err != nil {
+		logrus.Debugf("analyzer: invalid query %v: %s", q, err)
+		return nil
+	}
+	return objs
+}
+
+// Exists returns true if path, relative to the root, is a regular file
+func (in *Input) Exists(path string) bool {
+	if in.Root == "" {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(in.Root, path))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// Files returns the paths, relative to the root, of collected files that are
+// not Kubernetes objects, archives, etcd snapshots or reports (i.e. container logs and
+// host command outputs)
+func (in *Input) Files() []string {
+	if in.Root == "" {
+		return nil
+	}
+	var files []string
+	err := filepath.Walk(in.Root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		ext := filepath.Ext(path)
+		switch ext {
+		case ".json", ".yaml", ".html", ".gz", ".tgz", ".tar", ".zip", ".db":
+			return nil
+		}
+		if strings.TrimSuffix(info.Name(), ext) == ReportFileName {
+			return nil
+		}
+		rel, err := filepath.Rel(in.Root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		logrus.Debugf("analyzer: failed to list files: %s", err)
+	}
+	return files
+}
+
+// Rule analyzes its input and returns findings
+type Rule interface {
+	Name() string
+	Description() string
+	Analyze(in *Input) ([]Finding, error)
+}
+
+type ruleFunc struct {
+	name        string
+	description string
+	fn          func(in *Input) ([]Finding, error)
+}
+
+// NewRule returns a Rule that uses fn to analyze its input
+func NewRule(name, description string, fn func(in *Input) ([]Finding, error)) Rule {
+	return &ruleFunc{name: name, description: description, fn: fn}
+}
+
+func (r *ruleFunc) Name() string        { return r.name }
+func (r *ruleFunc) Description() string { return r.description }
+func (r *ruleFunc) Analyze(in *Input) ([]Finding, error) {
+	return r.fn(in)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []Rule
+)
+
+// Register adds rule r to the rules applied by default
+func Register(r Rule) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, r)
+}
+
+// Rules returns the registered rules. When names are provided, only
+// the rules with matching names are returned.
+func Rules(names ...string) ([]Rule, error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return Select(registry, names...)
+}
+
+// Select returns the rules with matching names, in the order of names.
+// When no names are provided, all rules are returned.
+func Select(rules []Rule, names ...string) ([]Rule, error) {
+	if len(names) == 0 {
+		return append([]Rule{}, rules...), nil
+	}
+
+	var result []Rule
+	for _, name := range names {
+		var found Rule
+		for _, r := range rules {
+			if r.Name() == name {
+				found = r
+				break
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("unknown analyzer rule: %s", name)
+		}
+		result = append(result, found)
+	}
+	return result, nil
+}
+
+// Report contains the findings of all rules applied to an input
+type Report struct {
+	Source   string    `json:"source"`
+	Findings []Finding `json:"findings"`
+	Errors   []string  `json:"errors,omitempty"`
+}
+
+// Analyze applies rules to input in and returns the findings sorted by severity.
+// Rule failures are recorded in the report and do not stop the analysis.
+func Analyze(source string, in *Input, rules ...Rule) *Report {
+	report := &Report{Source: source, Findings: []Finding{}}
+	for _, rule := range rules {
+		logrus.Debugf("analyzer: applying rule %s", rule.Name())
+		findings, err := rule.Analyze(in)
+		if err != nil {
+			logrus.Errorf("analyzer: rule %s failed: %s", rule.Name(), err)
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", rule.Name(), err))
+			continue
+		}
+		for _, f := range findings {
+			if f.Rule == "" {
+				f.Rule = rule.Name()
+			}
+			if _, ok := severityRanks[f.Severity]; !ok {
+				f.Severity = Info
+			}
+			report.Findings = append(report.Findings, f)
+		}
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if severityRanks[a.Severity] != severityRanks[b.Severity] {
+			return severityRanks[a.Severity] < severityRanks[b.Severity]
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Resource < b.Resource
+	})
+	return report
+}
+
+// Count returns the number of findings with severity s
+func (r *Report) Count(s Severity) int {
+	count := 0
+	for _, f := range r.Findings {
+		if f.Severity == s {
+			count++
+		}
+	}
+	return count
+}
+
+// WriteText writes a human readable version of the report to w
+func (r *Report) WriteText(w io.Writer) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Analyzed %s: %d finding(s) (%d critical, %d warning, %d info)\n",
+		r.Source, len(r.Findings), r.Count(Critical), r.Count(Warning), r.Count(Info))
+
+	for _, f := range r.Findings {
+		fmt.Fprintf(&buf, "\n[%s] %s: %s\n", strings.ToUpper(string(f.Severity)), f.Rule, f.Resource)
+		fmt.Fprintf(&buf, "    %s\n", f.Message)
+		for _, e := range f.Evidence {
+			fmt.Fprintf(&buf, "    evidence: %s\n", e)
+		}
+	}
+
+	if len(r.Errors) > 0 {
+		buf.WriteString("\nErrors:\n")
+		for _, e := range r.Errors {
+			fmt.Fprintf(&buf, "    %s\n", e)
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
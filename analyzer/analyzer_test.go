@@ -0,0 +1,231 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package analyzer
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware-tanzu/crash-diagnostics/bundle"
+)
+
+const (
+	testPodList = `{"apiVersion":"v1","kind":"PodList","metadata":{},"items":[
+{"apiVersion":"v1","kind":"Pod","metadata":{"name":"etcd","namespace":"kube-system"},
+ "spec":{"containers":[{"name":"etcd"}]},
+ "status":{"phase":"Running","containerStatuses":[{"name":"etcd","ready":true,"restartCount":0,"state":{"running":{}}}]}},
+{"apiVersion":"v1","kind":"Pod","metadata":{"name":"coredns","namespace":"kube-system"},
+ "spec":{"containers":[{"name":"coredns","resources":{"limits":{"memory":"170Mi"}}}]},
+ "status":{"phase":"Running","containerStatuses":[{"name":"coredns","ready":false,"restartCount":7,
+   "state":{"waiting":{"reason":"CrashLoopBackOff"}},"lastState":{"terminated":{"reason":"OOMKilled"}}}]}},
+{"apiVersion":"v1","kind":"Pod","metadata":{"name":"web","namespace":"kube-system"},
+ "spec":{"containers":[{"name":"nginx"}]},
+ "status":{"phase":"Pending","containerStatuses":[{"name":"nginx","image":"ngnix:latest","ready":false,"restartCount":0,
+   "state":{"waiting":{"reason":"ImagePullBackOff"}}}]}}
+]}`
+
+	testNodeList = `{"apiVersion":"v1","kind":"NodeList","metadata":{},"items":[
+{"apiVersion":"v1","kind":"Node","metadata":{"name":"node-0"},"status":{"conditions":[{"type":"Ready","status":"True"}]}},
+{"apiVersion":"v1","kind":"Node","metadata":{"name":"node-1"},"status":{"conditions":[{"type":"Ready","status":"Unknown","reason":"NodeStatusUnknown"}]}}
+]}`
+
+	testPVCList = `{"apiVersion":"v1","kind":"PersistentVolumeClaimList","metadata":{},"items":[
+{"apiVersion":"v1","kind":"PersistentVolumeClaim","metadata":{"name":"data","namespace":"default"},"spec":{"storageClassName":"fast"},"status":{"phase":"Pending"}},
+{"apiVersion":"v1","kind":"PersistentVolumeClaim","metadata":{"name":"logs","namespace":"default"},"status":{"phase":"Bound"}}
+]}`
+
+	testMachineList = `{"apiVersion":"cluster.x-k8s.io/v1beta1","kind":"MachineList","metadata":{},"items":[
+{"apiVersion":"cluster.x-k8s.io/v1beta1","kind":"Machine","metadata":{"name":"md-0-abc","namespace":"default"},
+ "status":{"phase":"Failed","failureReason":"CreateError","failureMessage":"instance quota exceeded"}},
+{"apiVersion":"cluster.x-k8s.io/v1beta1","kind":"Machine","metadata":{"name":"md-0-def","namespace":"default"},"status":{"phase":"Running"}}
+]}`
+)
+
+func makeTestBundle(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	capturedAt := time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC)
+	files := map[string]string{
+		"kubecapture/core_v1/kube-system/pods-2020-01-01T01-00-00Z.0000.json":                  testPodList,
+		"kubecapture/core_v1/kube-system/coredns/coredns/coredns.log":                          "panic: out of memory",
+		"kubecapture/core_v1/nodes-2020-01-01T01-00-00Z.0000.json":                             testNodeList,
+		"kubecapture/core_v1/default/persistentvolumeclaims-2020-01-01T01-00-00Z.0000.json":    testPVCList,
+		"kubecapture/cluster.x-k8s.io_v1beta1/default/machines-2020-01-01T01-00-00Z.0000.json": testMachineList,
+		"kubecapture/core_v1/default/secrets-2020-01-01T01-00-00Z.0000.json":                   testSecretList(t, capturedAt.Add(-time.Hour), capturedAt.Add(24*time.Hour)),
+		"127_0_0_1/journalctl_-u_kubelet.txt":                                                  "ok\nE0101 x509: certificate has expired or is not yet valid\nok\n",
+		"127_0_0_1/uptime.txt":                                                                 "up 2 days",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0744); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// testSecretList returns a list of TLS secrets with certificates expiring at the given times
+func testSecretList(t *testing.T, expirations ...time.Time) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []string
+	for i, notAfter := range expirations {
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 1)),
+			Subject:      pkix.Name{CommonName: fmt.Sprintf("cert-%d", i)},
+			NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+			NotAfter:     notAfter,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crt := base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+		items = append(items, fmt.Sprintf(
+			`{"apiVersion":"v1","kind":"Secret","type":"kubernetes.io/tls","metadata":{"name":"tls-%d","namespace":"default"},"data":{"tls.crt":%q}}`,
+			i, crt,
+		))
+	}
+	return fmt.Sprintf(`{"apiVersion":"v1","kind":"SecretList","metadata":{},"items":[%s]}`, strings.Join(items, ","))
+}
+
+func TestAnalyze(t *testing.T) {
+	b, err := bundle.Open(makeTestBundle(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, err := NewInput(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := Rules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := Analyze(b.Source(), in, rules...)
+	if len(report.Errors) > 0 {
+		t.Fatalf("unexpected rule errors: %v", report.Errors)
+	}
+
+	found := make(map[string]Finding)
+	for _, f := range report.Findings {
+		found[f.Rule+" "+f.Resource] = f
+	}
+	tests := []struct {
+		key      string
+		severity Severity
+		evidence int
+	}{
+		{key: "pod-crashloop-backoff pods kube-system/coredns", severity: Critical, evidence: 2},
+		{key: "pod-oom-killed pods kube-system/coredns", severity: Warning, evidence: 2},
+		{key: "pod-image-pull-backoff pods kube-system/web", severity: Critical, evidence: 1},
+		{key: "node-not-ready nodes node-1", severity: Critical, evidence: 1},
+		{key: "pvc-pending persistentvolumeclaims default/data", severity: Warning, evidence: 1},
+		{key: "capi-machine-failed machines default/md-0-abc", severity: Critical, evidence: 1},
+		{key: "expired-certificates secrets default/tls-0", severity: Critical, evidence: 1},
+		{key: "expired-certificates secrets default/tls-1", severity: Warning, evidence: 1},
+		{key: "expired-certificates " + filepath.Join("127_0_0_1", "journalctl_-u_kubelet.txt"), severity: Warning, evidence: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			f, ok := found[test.key]
+			if !ok {
+				t.Fatalf("finding not reported, got: %+v", report.Findings)
+			}
+			if f.Severity != test.severity {
+				t.Errorf("expected severity %s, got %s", test.severity, f.Severity)
+			}
+			if len(f.Evidence) != test.evidence {
+				t.Errorf("expected %d evidence file(s), got %v", test.evidence, f.Evidence)
+			}
+			for _, e := range f.Evidence {
+				if !in.Exists(e) {
+					t.Errorf("evidence file %s not found", e)
+				}
+			}
+		})
+	}
+	if len(report.Findings) != len(tests) {
+		t.Errorf("expected %d findings, got %d: %+v", len(tests), len(report.Findings), report.Findings)
+	}
+	if report.Findings[0].Severity != Critical || report.Findings[len(report.Findings)-1].Severity != Warning {
+		t.Errorf("findings not sorted by severity: %+v", report.Findings)
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "9 finding(s) (5 critical, 4 warning, 0 info)") {
+		t.Errorf("unexpected text report:\n%s", out.String())
+	}
+}
+
+func TestRules(t *testing.T) {
+	rules, err := Rules("node-not-ready", "pvc-pending")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].Name() != "node-not-ready" || rules[1].Name() != "pvc-pending" {
+		t.Errorf("unexpected rules: %v", rules)
+	}
+	if _, err := Rules("unknown"); err == nil {
+		t.Error("expected error for unknown rule")
+	}
+}
+
+func TestFindLineAfterLongLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubelet.log")
+	data := strings.Repeat("x", 2*1024*1024) + "\ncertificate has expired\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	line, count, err := findLine(path, expiredCertPatterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || line != "certificate has expired" {
+		t.Errorf("unexpected match: %d %q", count, line)
+	}
+}
+
+func TestExpiredCertificatesSkipsUnreadableFiles(t *testing.T) {
+	root := t.TempDir()
+	// a single line longer than maxLineSize fails the scan
+	if err := os.WriteFile(filepath.Join(root, "blob.bin"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(filepath.Join(root, "blob.bin"), maxLineSize+1); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "kubelet.log"), []byte("x509: certificate has expired or is not yet valid\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	findings, err := expiredCertificates(&Input{Root: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || findings[0].Resource != "kubelet.log" {
+		t.Errorf("unexpected findings: %+v", findings)
+	}
+}
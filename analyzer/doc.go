@@ -0,0 +1,6 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package analyzer applies rules to the objects and files collected by crashd
+// to report common failures.
+package analyzer
@@ -0,0 +1,387 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package analyzer
+
+import (
+	"bufio"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/vmware-tanzu/crash-diagnostics/bundle"
+)
+
+// certExpiryWarning is how close to expiration a certificate is reported
+const certExpiryWarning = 30 * 24 * time.Hour
+
+// maxLineSize is the longest line scanned in capture files, i.e. single-line JSON logs
+const maxLineSize = 64 * 1024 * 1024
+
+// expiredCertPatterns are log messages reported by Go and OpenSSL clients
+// when a peer presents an expired certificate
+var expiredCertPatterns = []string{
+	"certificate has expired",
+	"certificate expired",
+}
+
+func init() {
+	Register(NewRule("pod-crashloop-backoff", "containers restarting in CrashLoopBackOff", crashLoopBackOff))
+	Register(NewRule("pod-oom-killed", "containers terminated because they ran out of memory", oomKilled))
+	Register(NewRule("pod-image-pull-backoff", "containers whose image cannot be pulled", imagePullBackOff))
+	Register(NewRule("node-not-ready", "nodes that are not Ready", nodeNotReady))
+	Register(NewRule("pvc-pending", "persistent volume claims that are not bound", pvcPending))
+	Register(NewRule("expired-certificates", "expired TLS certificates in secrets and logs", expiredCertificates))
+	Register(NewRule("capi-machine-failed", "Cluster API machines that failed to provision", capiMachineFailed))
+}
+
+// containerStatus is a container status of a pod along with the name of the pod
+type containerStatus struct {
+	pod    *bundle.Object
+	name   string
+	status map[string]interface{}
+}
+
+func containerStatuses(in *Input) []containerStatus {
+	var result []containerStatus
+	for _, pod := range in.Select(bundle.Query{Resource: "pods"}) {
+		for _, field := range []string{"initContainerStatuses", "containerStatuses"} {
+			statuses, _, _ := unstructured.NestedSlice(pod.Object.Object, "status", field)
+			for _, s := range statuses {
+				status, ok := s.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _, _ := unstructured.NestedString(status, "name")
+				result = append(result, containerStatus{pod: pod, name: name, status: status})
+			}
+		}
+	}
+	return result
+}
+
+func crashLoopBackOff(in *Input) ([]Finding, error) {
+	var findings []Finding
+	for _, c := range containerStatuses(in) {
+		reason, _, _ := unstructured.NestedString(c.status, "state", "waiting", "reason")
+		if reason != "CrashLoopBackOff" {
+			continue
+		}
+		restarts, _, _ := unstructured.NestedInt64(c.status, "restartCount")
+		findings = append(findings, Finding{
+			Severity: Critical,
+			Resource: objectResource(c.pod),
+			Message:  fmt.Sprintf("container %s is in CrashLoopBackOff (%d restarts)", c.name, restarts),
+			Evidence: containerEvidence(in, c.pod, c.name),
+		})
+	}
+	return findings, nil
+}
+
+func oomKilled(in *Input) ([]Finding, error) {
+	var findings []Finding
+	for _, c := range containerStatuses(in) {
+		current, _, _ := unstructured.NestedString(c.status, "state", "terminated", "reason")
+		last, _, _ := unstructured.NestedString(c.status, "lastState", "terminated", "reason")
+		if current != "OOMKilled" && last != "OOMKilled" {
+			continue
+		}
+		message := fmt.Sprintf("container %s was OOMKilled", c.name)
+		if limit := containerMemoryLimit(c.pod.Object, c.name); limit != "" {
+			message = fmt.Sprintf("%s (memory limit %s)", message, limit)
+		}
+		findings = append(findings, Finding{
+			Severity: Warning,
+			Resource: objectResource(c.pod),
+			Message:  message,
+			Evidence: containerEvidence(in, c.pod, c.name),
+		})
+	}
+	return findings, nil
+}
+
+func imagePullBackOff(in *Input) ([]Finding, error) {
+	var findings []Finding
+	for _, c := range containerStatuses(in) {
+		reason, _, _ := unstructured.NestedString(c.status, "state", "waiting", "reason")
+		if reason != "ImagePullBackOff" && reason != "ErrImagePull" && reason != "InvalidImageName" {
+			continue
+		}
+		image, _, _ := unstructured.NestedString(c.status, "image")
+		message := fmt.Sprintf("container %s cannot pull image %s: %s", c.name, image, reason)
+		if msg, _, _ := unstructured.NestedString(c.status, "state", "waiting", "message"); msg != "" {
+			message = fmt.Sprintf("%s: %s", message, msg)
+		}
+		findings = append(findings, Finding{
+			Severity: Critical,
+			Resource: objectResource(c.pod),
+			Message:  message,
+			Evidence: objectEvidence(c.pod),
+		})
+	}
+	return findings, nil
+}
+
+func nodeNotReady(in *Input) ([]Finding, error) {
+	var findings []Finding
+	for _, node := range in.Select(bundle.Query{Resource: "nodes"}) {
+		status := bundle.NodeStatus(node.Object)
+		if strings.HasPrefix(status, "Ready") {
+			continue
+		}
+		message := fmt.Sprintf("node is %s", status)
+		if cond := findCondition(node.Object, "Ready"); cond != nil {
+			if reason, _ := cond["reason"].(string); reason != "" {
+				message = fmt.Sprintf("%s: %s", message, reason)
+			}
+			if msg, _ := cond["message"].(string); msg != "" {
+				message = fmt.Sprintf("%s (%s)", message, msg)
+			}
+		}
+		findings = append(findings, Finding{
+			Severity: Critical,
+			Resource: objectResource(node),
+			Message:  message,
+			Evidence: objectEvidence(node),
+		})
+	}
+	return findings, nil
+}
+
+func pvcPending(in *Input) ([]Finding, error) {
+	var findings []Finding
+	for _, pvc := range in.Select(bundle.Query{Resource: "persistentvolumeclaims"}) {
+		phase, _, _ := unstructured.NestedString(pvc.Object.Object, "status", "phase")
+		if phase != "Pending" && phase != "Lost" {
+			continue
+		}
+		message := fmt.Sprintf("claim is %s", phase)
+		if class, _, _ := unstructured.NestedString(pvc.Object.Object, "spec", "storageClassName"); class != "" {
+			message = fmt.Sprintf("%s (storage class %s)", message, class)
+		}
+		severity := Warning
+		if phase == "Lost" {
+			severity = Critical
+		}
+		findings = append(findings, Finding{
+			Severity: severity,
+			Resource: objectResource(pvc),
+			Message:  message,
+			Evidence: objectEvidence(pvc),
+		})
+	}
+	return findings, nil
+}
+
+func expiredCertificates(in *Input) ([]Finding, error) {
+	var findings []Finding
+	for _, secret := range in.Select(bundle.Query{Resource: "secrets"}) {
+		data, _, _ := unstructured.NestedStringMap(secret.Object.Object, "data")
+		for _, key := range []string{"tls.crt", "ca.crt"} {
+			encoded, ok := data[key]
+			if !ok {
+				continue
+			}
+			certs, err := parseCertificates(encoded)
+			if err != nil {
+				continue
+			}
+			now := referenceTime(secret)
+			for _, cert := range certs {
+				var severity Severity
+				var message string
+				switch {
+				case now.After(cert.NotAfter):
+					severity = Critical
+					message = fmt.Sprintf("%s certificate %q expired on %s", key, cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339))
+				case cert.NotAfter.Sub(now) < certExpiryWarning:
+					severity = Warning
+					message = fmt.Sprintf("%s certificate %q expires on %s", key, cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339))
+				default:
+					continue
+				}
+				findings = append(findings, Finding{
+					Severity: severity,
+					Resource: objectResource(secret),
+					Message:  message,
+					Evidence: objectEvidence(secret),
+				})
+			}
+		}
+	}
+
+	for _, file := range in.Files() {
+		line, count, err := findLine(filepath.Join(in.Root, file), expiredCertPatterns)
+		if err != nil {
+			logrus.Debugf("analyzer: skipping %s: %s", file, err)
+			continue
+		}
+		if count == 0 {
+			continue
+		}
+		findings = append(findings, Finding{
+			Severity: Warning,
+			Resource: file,
+			Message:  fmt.Sprintf("%d log line(s) report an expired certificate, first: %s", count, line),
+			Evidence: []string{file},
+		})
+	}
+	return findings, nil
+}
+
+func capiMachineFailed(in *Input) ([]Finding, error) {
+	var findings []Finding
+	for _, machine := range in.Select(bundle.Query{Resource: "machines.cluster.x-k8s.io"}) {
+		phase, _, _ := unstructured.NestedString(machine.Object.Object, "status", "phase")
+		reason, _, _ := unstructured.NestedString(machine.Object.Object, "status", "failureReason")
+		msg, _, _ := unstructured.NestedString(machine.Object.Object, "status", "failureMessage")
+		if phase != "Failed" && reason == "" && msg == "" {
+			continue
+		}
+		message := fmt.Sprintf("machine is %s", phase)
+		if phase == "" {
+			message = "machine failed"
+		}
+		if reason != "" {
+			message = fmt.Sprintf("%s: %s", message, reason)
+		}
+		if msg != "" {
+			message = fmt.Sprintf("%s (%s)", message, msg)
+		}
+		findings = append(findings, Finding{
+			Severity: Critical,
+			Resource: objectResource(machine),
+			Message:  message,
+			Evidence: objectEvidence(machine),
+		})
+	}
+	return findings, nil
+}
+
+// objectResource formats obj as <resource> [<namespace>/]<name>
+func objectResource(obj *bundle.Object) string {
+	name := obj.Key.Name
+	if obj.Key.Namespace != "" {
+		name = fmt.Sprintf("%s/%s", obj.Key.Namespace, obj.Key.Name)
+	}
+	return fmt.Sprintf("%s %s", obj.GroupVersionResource.Resource, name)
+}
+
+// objectEvidence returns the file obj was read from, if any. Objects
+// fetched from a live cluster have no evidence file.
+func objectEvidence(obj *bundle.Object) []string {
+	if obj.Path == "" {
+		return nil
+	}
+	return []string{obj.Path}
+}
+
+// containerEvidence returns the pod object file and, if it was captured, the container log.
+// kube_capture saves container logs in <pod>/<container>/<container>.log next to the pod file.
+func containerEvidence(in *Input, pod *bundle.Object, container string) []string {
+	evidence := objectEvidence(pod)
+	if pod.Path == "" {
+		return evidence
+	}
+	log := filepath.Join(filepath.Dir(pod.Path), pod.Key.Name, container, container+".log")
+	if in.Exists(log) {
+		evidence = append(evidence, log)
+	}
+	return evidence
+}
+
+func containerMemoryLimit(pod *unstructured.Unstructured, container string) string {
+	for _, field := range []string{"initContainers", "containers"} {
+		containers, _, _ := unstructured.NestedSlice(pod.Object, "spec", field)
+		for _, c := range containers {
+			spec, ok := c.(map[string]interface{})
+			if !ok || spec["name"] != container {
+				continue
+			}
+			limit, _, _ := unstructured.NestedString(spec, "resources", "limits", "memory")
+			return limit
+		}
+	}
+	return ""
+}
+
+func findCondition(obj *unstructured.Unstructured, condType string) map[string]interface{} {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if ok && cond["type"] == condType {
+			return cond
+		}
+	}
+	return nil
+}
+
+// referenceTime returns the time at which obj was captured, or the current time if unknown
+func referenceTime(obj *bundle.Object) time.Time {
+	if obj.CapturedAt.IsZero() {
+		return time.Now()
+	}
+	return obj.CapturedAt
+}
+
+func parseCertificates(encoded string) ([]*x509.Certificate, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// findLine returns the first line of file containing one of patterns and the number of matching lines
+func findLine(path string, patterns []string) (string, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	var first string
+	count := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		for _, p := range patterns {
+			if strings.Contains(line, p) {
+				if count == 0 {
+					first = strings.TrimSpace(line)
+				}
+				count++
+				break
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", 0, fmt.Errorf("%s: %w", path, err)
+	}
+	return first, count, nil
+}
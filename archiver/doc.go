@@ -1,5 +1,6 @@
 // Copyright (c) 2019 VMware, Inc. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
-// Package archiver provides functionality to create tar files.
+// Package archiver provides functionality to create and extract tar (optionally compressed
+// with gzip, zstd, or xz) and zip archives, split into volumes or encrypted.
 package archiver
@@ -0,0 +1,222 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package archiver
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+)
+
+// EncryptedExt is the extension of the encrypted archives
+const EncryptedExt = ".age"
+
+// encryptedMagic starts the header of an age encrypted file
+var encryptedMagic = []byte("age-encryption.org/")
+
+// Encryption configures the age encryption of an archive, for a list of
+// recipients or with a passphrase
+type Encryption struct {
+	// Recipients are age (age1...) or SSH (ssh-ed25519, ssh-rsa) public keys,
+	// or paths of files listing such keys, one per line
+	Recipients []string
+	// Passphrase encrypts the archive with a passphrase instead of recipients
+	Passphrase string
+}
+
+// Decryption configures the identities that can decrypt an archive
+type Decryption struct {
+	// IdentityFiles are files of age secret keys (AGE-SECRET-KEY-1...) or unencrypted SSH private keys
+	IdentityFiles []string
+	// Passphrase decrypts an archive encrypted with a passphrase
+	Passphrase string
+}
+
+// Encrypt returns a writer encrypting the data written to dst. The writer must
+// be closed to flush the last chunk of data; dst is not closed.
+func Encrypt(dst io.Writer, enc Encryption) (io.WriteCloser, error) {
+	recipients, err := enc.recipients()
+	if err != nil {
+		return nil, err
+	}
+	w, err := age.Encrypt(dst, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: %w", err)
+	}
+	return w, nil
+}
+
+// Decrypt returns a reader decrypting the data read from src
+func Decrypt(src io.Reader, dec Decryption) (io.Reader, error) {
+	identities, err := dec.identities()
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("decryption: %w", err)
+	}
+	return r, nil
+}
+
+// DecryptFile decrypts file encrypted into file output
+func DecryptFile(encrypted, output string, dec Decryption) (err error) {
+	src, err := os.Open(encrypted)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	r, err := Decrypt(src, dec)
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := dst.Close(); err == nil {
+			err = closeErr
+		}
+		// do not leave a partially decrypted archive
+		if err != nil {
+			os.Remove(output)
+		}
+	}()
+	if _, err := io.Copy(dst, r); err != nil {
+		return fmt.Errorf("decryption: %w", err)
+	}
+	return nil
+}
+
+// IsEncrypted returns true if header, the first bytes of a file, is the header of an encrypted file
+func IsEncrypted(header []byte) bool {
+	return bytes.HasPrefix(header, encryptedMagic)
+}
+
+func (e Encryption) recipients() ([]age.Recipient, error) {
+	if e.Passphrase != "" {
+		if len(e.Recipients) > 0 {
+			return nil, errors.New("encryption: passphrase cannot be used with recipients")
+		}
+		r, err := age.NewScryptRecipient(e.Passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("encryption: %w", err)
+		}
+		return []age.Recipient{r}, nil
+	}
+
+	var recipients []age.Recipient
+	for _, value := range e.Recipients {
+		if isPublicKey(value) {
+			r, err := parseRecipient(value)
+			if err != nil {
+				return nil, err
+			}
+			recipients = append(recipients, r)
+			continue
+		}
+		fileRecipients, err := readRecipientsFile(value)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, fileRecipients...)
+	}
+	if len(recipients) == 0 {
+		return nil, errors.New("encryption: recipients or passphrase required")
+	}
+	return recipients, nil
+}
+
+func isPublicKey(value string) bool {
+	return strings.HasPrefix(value, "age1") || strings.HasPrefix(value, "ssh-")
+}
+
+func parseRecipient(key string) (age.Recipient, error) {
+	if strings.HasPrefix(key, "age1") {
+		r, err := age.ParseX25519Recipient(key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption: invalid recipient %s: %w", key, err)
+		}
+		return r, nil
+	}
+	r, err := agessh.ParseRecipient(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: invalid recipient %s: %w", key, err)
+	}
+	return r, nil
+}
+
+// readRecipientsFile parses the public keys listed in file, skipping comments and empty lines
+func readRecipientsFile(file string) ([]age.Recipient, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: %w", err)
+	}
+	defer f.Close()
+
+	var recipients []age.Recipient
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := parseRecipient(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		recipients = append(recipients, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("encryption: %s: %w", file, err)
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("encryption: no recipient found in %s", file)
+	}
+	return recipients, nil
+}
+
+func (d Decryption) identities() ([]age.Identity, error) {
+	var identities []age.Identity
+	if d.Passphrase != "" {
+		id, err := age.NewScryptIdentity(d.Passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("decryption: %w", err)
+		}
+		identities = append(identities, id)
+	}
+	for _, file := range d.IdentityFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("decryption: %w", err)
+		}
+		if bytes.Contains(data, []byte("AGE-SECRET-KEY-")) {
+			ids, err := age.ParseIdentities(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("decryption: %s: %w", file, err)
+			}
+			identities = append(identities, ids...)
+			continue
+		}
+		id, err := agessh.ParseIdentity(data)
+		if err != nil {
+			return nil, fmt.Errorf("decryption: %s: %w", file, err)
+		}
+		identities = append(identities, id)
+	}
+	if len(identities) == 0 {
+		return nil, errors.New("decryption: identity files or passphrase required")
+	}
+	return identities, nil
+}
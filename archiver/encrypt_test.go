@@ -0,0 +1,124 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package archiver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestEncryptedTar(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	recipientsFile := filepath.Join(dir, "vendor.pub")
+	identityFile := filepath.Join(dir, "vendor.key")
+	if err := os.WriteFile(recipientsFile, []byte("# vendor\n"+identity.Recipient().String()+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	source := filepath.Join(dir, "source")
+	if err := os.MkdirAll(source, 0744); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "kubelet.log"), []byte("node logs"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		encryption Encryption
+		decryption Decryption
+		err        string
+	}{
+		{
+			name:       "recipients file",
+			encryption: Encryption{Recipients: []string{recipientsFile}},
+			decryption: Decryption{IdentityFiles: []string{identityFile}},
+		},
+		{
+			name:       "recipient key",
+			encryption: Encryption{Recipients: []string{identity.Recipient().String()}},
+			decryption: Decryption{IdentityFiles: []string{identityFile}},
+		},
+		{
+			name:       "passphrase",
+			encryption: Encryption{Passphrase: "secret"},
+			decryption: Decryption{Passphrase: "secret"},
+		},
+		{
+			name:       "wrong passphrase",
+			encryption: Encryption{Passphrase: "secret"},
+			decryption: Decryption{Passphrase: "other"},
+			err:        "no identity matched",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encrypted := filepath.Join(t.TempDir(), "crashd.tar.gz"+EncryptedExt)
+			if err := TarWithOptions(encrypted, Options{Encryption: &test.encryption}, source); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(encrypted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !IsEncrypted(data) || strings.Contains(string(data), "node logs") {
+				t.Fatal("archive is not encrypted")
+			}
+			if err := Untar(encrypted, t.TempDir()); err == nil || !strings.Contains(err.Error(), "encrypted") {
+				t.Errorf("expected encrypted archive error, got %v", err)
+			}
+
+			decrypted := strings.TrimSuffix(encrypted, EncryptedExt)
+			err = DecryptFile(encrypted, decrypted, test.decryption)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				if _, err := os.Stat(decrypted); !os.IsNotExist(err) {
+					t.Error("partially decrypted archive not removed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dest := t.TempDir()
+			if err := Untar(decrypted, dest); err != nil {
+				t.Fatal(err)
+			}
+			content, err := os.ReadFile(filepath.Join(dest, source, "kubelet.log"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != "node logs" {
+				t.Errorf("unexpected content: %s", content)
+			}
+		})
+	}
+}
+
+func TestEncryptionArgs(t *testing.T) {
+	if _, err := Encrypt(nil, Encryption{}); err == nil {
+		t.Error("expected error without recipients or passphrase")
+	}
+	if _, err := Encrypt(nil, Encryption{Passphrase: "secret", Recipients: []string{"age1x"}}); err == nil {
+		t.Error("expected error with passphrase and recipients")
+	}
+	if _, err := Encrypt(nil, Encryption{Recipients: []string{"age1invalid"}}); err == nil {
+		t.Error("expected error with invalid recipient")
+	}
+}
@@ -4,9 +4,7 @@
 package archiver
 
 import (
-	"archive/tar"
-	"compress/gzip"
-	"io"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,33 +12,132 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// Format is the format of an archive
+type Format string
+
+const (
+	FormatTar     Format = "tar"
+	FormatTarGzip Format = "tar.gz"
+	FormatTarZstd Format = "tar.zst"
+	FormatTarXz   Format = "tar.xz"
+	FormatZip     Format = "zip"
+)
+
+// Formats lists the supported archive formats
+var Formats = []Format{FormatTar, FormatTarGzip, FormatTarZstd, FormatTarXz, FormatZip}
+
+// ParseFormat returns the format named s, i.e. tar.gz, also accepting tgz, gzip, zstd, and xz
+func ParseFormat(s string) (Format, error) {
+	switch strings.TrimPrefix(strings.ToLower(s), ".") {
+	case "tar":
+		return FormatTar, nil
+	case "tar.gz", "tgz", "gz", "gzip":
+		return FormatTarGzip, nil
+	case "tar.zst", "zst", "zstd":
+		return FormatTarZstd, nil
+	case "tar.xz", "xz":
+		return FormatTarXz, nil
+	case "zip":
+		return FormatZip, nil
+	}
+	return "", fmt.Errorf("unsupported archive format %s (supported: %v)", s, Formats)
+}
+
+// FormatFromName returns the format matching the extension of file name, ignoring the
+// extension of encrypted files (.age). Names without a known extension are plain tar files.
+func FormatFromName(name string) Format {
+	name = strings.ToLower(strings.TrimSuffix(name, EncryptedExt))
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return FormatZip
+	case strings.HasSuffix(name, ".zst"), strings.HasSuffix(name, ".zstd"):
+		return FormatTarZstd
+	case strings.HasSuffix(name, ".xz"):
+		return FormatTarXz
+	case strings.HasSuffix(name, ".gz"), strings.HasSuffix(name, ".gzip"), strings.HasSuffix(name, ".tgz"):
+		return FormatTarGzip
+	}
+	return FormatTar
+}
+
+// Ext returns the file extension of format f, i.e. .tar.gz
+func (f Format) Ext() string {
+	return "." + string(f)
+}
+
+// Options configures the archives created by Archive
+type Options struct {
+	// Format of the archive, default selected by the name of the archive
+	Format Format
+	// Level is the compression level, 0 for the default of the format: 1-9 for
+	// tar.gz, tar.xz, and zip, 1-22 for tar.zst. Plain tar is not compressed.
+	Level int
+	// MaxVolumeSize splits the archive into volumes of at most this size in bytes,
+	// named <archive>.001, <archive>.002, etc., if greater than 0
+	MaxVolumeSize int64
+	// Encryption encrypts the archive, if not nil. The content is encrypted
+	// as it is written, so it is never stored unencrypted.
+	Encryption *Encryption
+}
+
 // Tar compresses the file sources specified by paths into a single
 // tarball specified by tarName.
-func Tar(tarName string, paths ...string) (err error) {
-	logrus.Debugf("Archiving %v in %s", paths, tarName)
-	tarFile, err := os.Create(tarName)
-	if err != nil {
-		return err
+func Tar(tarName string, paths ...string) error {
+	_, err := Archive(tarName, Options{}, paths...)
+	return err
+}
+
+// TarWithOptions compresses the file sources specified by paths into a single
+// tarball specified by tarName, configured by opts.
+func TarWithOptions(tarName string, opts Options, paths ...string) error {
+	_, err := Archive(tarName, opts, paths...)
+	return err
+}
+
+// Archive bundles the file sources specified by paths into archive name, configured by
+// opts, and returns the files written: name, or its volumes. Symbolic links are stored
+// as links, and file modes are preserved. Sources that cannot be read are logged and skipped.
+func Archive(name string, opts Options, paths ...string) (files []string, err error) {
+	logrus.Debugf("Archiving %v in %s", paths, name)
+	if opts.Format == "" {
+		opts.Format = FormatFromName(name)
+	}
+	if err := validateLevel(opts.Format, opts.Level); err != nil {
+		return nil, err
+	}
+	if opts.MaxVolumeSize < 0 {
+		return nil, fmt.Errorf("invalid volume size %d", opts.MaxVolumeSize)
+	}
+	// validate the recipients before creating the archive
+	if opts.Encryption != nil {
+		if _, err := opts.Encryption.recipients(); err != nil {
+			return nil, err
+		}
 	}
-	defer func() {
-		err = tarFile.Close()
-	}()
 
-	absTar, err := filepath.Abs(tarName)
+	absName, err := filepath.Abs(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// enable compression if file ends in .gz
-	tw := tar.NewWriter(tarFile)
-	if strings.HasSuffix(tarName, ".gz") || strings.HasSuffix(tarName, ".gzip") {
-		gz := gzip.NewWriter(tarFile)
-		defer gz.Close()
-		tw = tar.NewWriter(gz)
+	w, err := newArchiveWriter(name, opts)
+	if err != nil {
+		return nil, err
 	}
-	defer tw.Close()
+	defer func() {
+		// closing flushes the archive, compression, and encryption, in this order
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			w.volumes.remove()
+			return
+		}
+		// the volumes are complete once the chain is flushed
+		files = w.volumes.files
+	}()
 
-	// walk each path and add encountered file to tar
+	// walk each path and add encountered file to the archive
 	for _, path := range paths {
 		// validate path
 		path = filepath.Clean(path)
@@ -49,16 +146,16 @@ func Tar(tarName string, paths ...string) (err error) {
 			logrus.Error(err)
 			continue
 		}
-		if absPath == absTar {
-			logrus.Errorf("Tar file %s cannot be the source, skipping path", tarName)
+		if absPath == absName {
+			logrus.Errorf("Archive %s cannot be the source, skipping path", name)
 			continue
 		}
-		if absPath == filepath.Dir(absTar) {
-			logrus.Errorf("Tar file %s cannot be in source %s, skipping path", tarName, absPath)
+		if absPath == filepath.Dir(absName) {
+			logrus.Errorf("Archive %s cannot be in source %s, skipping path", name, absPath)
 			continue
 		}
 
-		// build tar
+		// build archive; Walk does not follow symbolic links
 		err = filepath.Walk(path, func(file string, finfo os.FileInfo, err error) error {
 			if err != nil {
 				return err
@@ -71,36 +168,15 @@ func Tar(tarName string, paths ...string) (err error) {
 					return err
 				}
 			}
-
-			hdr, err := tar.FileInfoHeader(finfo, finfo.Name())
-			if err != nil {
-				return err
-			}
-			// ensure header has relative file path
-			hdr.Name = relFilePath
-			if err := tw.WriteHeader(hdr); err != nil {
-				return err
-			}
-			if finfo.Mode().IsDir() {
-				return nil
-			}
-
-			// add file to tar
-			srcFile, err := os.Open(file)
-			if err != nil {
-				return err
-			}
-			defer srcFile.Close()
-			_, err = io.Copy(tw, srcFile)
-			if err != nil {
+			if err := w.add(file, relFilePath, finfo); err != nil {
 				return err
 			}
 			logrus.Debugf("Archived %s", file)
 			return nil
 		})
 		if err != nil {
-			logrus.Errorf("failed to add %s to archive %s: %v", path, tarName, err)
+			logrus.Errorf("failed to add %s to archive %s: %v", path, name, err)
 		}
 	}
-	return nil
+	return nil, nil
 }
@@ -0,0 +1,296 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package archiver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// makeTestSource creates a directory with a file, an executable, and a symbolic link
+func makeTestSource(t *testing.T) string {
+	t.Helper()
+	source := filepath.Join(t.TempDir(), "source")
+	if err := os.MkdirAll(filepath.Join(source, "logs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "logs", "kubelet.log"), bytes.Repeat([]byte("kubelet started\n"), 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "collect.sh"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("logs", "kubelet.log"), filepath.Join(source, "latest.log")); err != nil {
+		t.Fatal(err)
+	}
+	return source
+}
+
+func checkExtracted(t *testing.T, dest, source string) {
+	t.Helper()
+	root := filepath.Join(dest, source)
+	data, err := os.ReadFile(filepath.Join(root, "logs", "kubelet.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("kubelet started")) {
+		t.Errorf("unexpected content: %.20s", data)
+	}
+	info, err := os.Stat(filepath.Join(root, "collect.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("expected mode 0755, got %s", info.Mode().Perm())
+	}
+	link, err := os.Readlink(filepath.Join(root, "latest.log"))
+	if err != nil {
+		t.Fatalf("symbolic link not preserved: %s", err)
+	}
+	if link != filepath.Join("logs", "kubelet.log") {
+		t.Errorf("unexpected link target: %s", link)
+	}
+}
+
+func TestArchiveFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		format Format
+		level  int
+	}{
+		{name: "tar", file: "out.tar", format: FormatTar},
+		{name: "gzip by name", file: "out.tar.gz", format: FormatTarGzip},
+		{name: "gzip level", file: "out.tgz", format: FormatTarGzip, level: 9},
+		{name: "zstd", file: "out.tar.zst", format: FormatTarZstd, level: 19},
+		{name: "xz", file: "out.tar.xz", format: FormatTarXz, level: 1},
+		{name: "zip", file: "out.zip", format: FormatZip, level: 1},
+	}
+
+	source := makeTestSource(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), test.file)
+			if FormatFromName(name) != test.format {
+				t.Errorf("expected format %s from name, got %s", test.format, FormatFromName(name))
+			}
+			files, err := Archive(name, Options{Level: test.level}, source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 || files[0] != name {
+				t.Errorf("unexpected files: %v", files)
+			}
+
+			dest := t.TempDir()
+			if err := Untar(name, dest); err != nil {
+				t.Fatal(err)
+			}
+			checkExtracted(t, dest, source)
+		})
+	}
+}
+
+func TestArchiveVolumes(t *testing.T) {
+	source := makeTestSource(t)
+	name := filepath.Join(t.TempDir(), "out.tar")
+	files, err := Archive(name, Options{MaxVolumeSize: 4096}, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) < 2 || files[0] != name+".001" {
+		t.Fatalf("expected volumes, got %v", files)
+	}
+
+	// the volumes concatenated are the archive
+	var readers []io.Reader
+	for i, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		info, _ := f.Stat()
+		if info.Size() > 4096 || (i < len(files)-1 && info.Size() != 4096) {
+			t.Errorf("unexpected size of volume %s: %d", file, info.Size())
+		}
+		readers = append(readers, f)
+	}
+	dest := t.TempDir()
+	if err := UntarStream(io.MultiReader(readers...), dest); err != nil {
+		t.Fatal(err)
+	}
+	checkExtracted(t, dest, source)
+}
+
+func TestArchiveOptionsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		opts Options
+		err  string
+	}{
+		{name: "tar level", file: "out.tar", opts: Options{Level: 3}, err: "not supported"},
+		{name: "gzip level", file: "out.tar.gz", opts: Options{Level: 10}, err: "invalid compression level"},
+		{name: "volume size", file: "out.tar.gz", opts: Options{MaxVolumeSize: -1}, err: "invalid volume size"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), test.file)
+			_, err := Archive(name, test.opts, t.TempDir())
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error %q, got %v", test.err, err)
+			}
+			if _, err := os.Stat(name); !os.IsNotExist(err) {
+				t.Error("archive should not be created")
+			}
+		})
+	}
+
+	if _, err := ParseFormat("rar"); err == nil {
+		t.Error("expected unsupported format error")
+	}
+}
+
+func TestUntarSymlinkOutside(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "source")
+	if err := os.MkdirAll(source, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/etc/passwd", filepath.Join(source, "passwd")); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "out.tar.gz")
+	if err := Tar(name, source); err != nil {
+		t.Fatal(err)
+	}
+	dest := t.TempDir()
+	if err := Untar(name, dest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(dest, source, "passwd")); !os.IsNotExist(err) {
+		t.Error("symbolic link outside of the destination should not be extracted")
+	}
+}
+
+// chainedLinkEntry is an entry of an archive escaping its destination through chained symbolic links
+type chainedLinkEntry struct {
+	name string
+	link string
+	data string
+}
+
+var chainedLinkEntries = []chainedLinkEntry{
+	{name: "b", link: "."},
+	{name: "a", link: "b/.."},
+	{name: "a/evil", data: "escaped"},
+}
+
+func writeChainedLinkTar(t *testing.T, name string) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range chainedLinkEntries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.data))}
+		if e.link != "" {
+			hdr = &tar.Header{Name: e.name, Mode: 0777, Typeflag: tar.TypeSymlink, Linkname: e.link}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, e.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeChainedLinkZip(t *testing.T, name string) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range chainedLinkEntries {
+		hdr := &zip.FileHeader{Name: e.name}
+		hdr.SetMode(0644)
+		data := e.data
+		if e.link != "" {
+			hdr.SetMode(os.ModeSymlink | 0777)
+			data = e.link
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUntarChainedSymlinks(t *testing.T) {
+	for format, write := range map[string]func(*testing.T, string){"tar": writeChainedLinkTar, "zip": writeChainedLinkZip} {
+		t.Run(format, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "chained."+format)
+			write(t, name)
+			parent := t.TempDir()
+			dest := filepath.Join(parent, "dest")
+			if err := Untar(name, dest); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Lstat(filepath.Join(parent, "evil")); !os.IsNotExist(err) {
+				t.Fatal("archive entry extracted outside of the destination")
+			}
+			if info, err := os.Lstat(filepath.Join(dest, "a")); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				t.Error("symbolic link resolving outside of the destination should be removed")
+			}
+		})
+	}
+}
+
+func TestUntarUnderExistingSymlink(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "out.tar")
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "logs/evil", Mode: 0644, Typeflag: tar.TypeReg, Size: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(tw, "escaped"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outside := t.TempDir()
+	dest := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dest, "logs")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Untar(name, dest); err == nil || !strings.Contains(err.Error(), "symbolic link") {
+		t.Errorf("expected symbolic link error, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "evil")); !os.IsNotExist(err) {
+		t.Error("archive entry extracted outside of the destination")
+	}
+}
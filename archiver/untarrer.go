@@ -0,0 +1,316 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package archiver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sirupsen/logrus"
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	xzMagic   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zipMagic  = []byte{'P', 'K', 0x03, 0x04}
+)
+
+// Untar extracts the archive specified by tarName into directory destDir.
+// The format, tar (optionally compressed with gzip, zstd, or xz) or zip, is
+// detected from the content of the file, not its name.
+func Untar(tarName, destDir string) error {
+	logrus.Debugf("Extracting %s in %s", tarName, destDir)
+	tarFile, err := os.Open(tarName)
+	if err != nil {
+		return err
+	}
+	defer tarFile.Close()
+
+	magic := make([]byte, len(zipMagic))
+	if _, err := io.ReadFull(tarFile, magic); err == nil && bytes.Equal(magic, zipMagic) {
+		return unzip(tarName, destDir)
+	}
+	if _, err := tarFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return UntarStream(tarFile, destDir)
+}
+
+// UntarStream extracts the tar stream read from tarStream into directory destDir.
+// Gzip, zstd, or xz compression is detected from the content of the stream.
+func UntarStream(tarStream io.Reader, destDir string) error {
+	absDest, err := filepath.Abs(destDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(absDest, 0744); err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	reader := bufio.NewReader(tarStream)
+	if header, err := reader.Peek(len(encryptedMagic)); err == nil && IsEncrypted(header) {
+		return errors.New("archive is encrypted, decrypt it first (i.e. crashd decrypt)")
+	}
+	source, err := decompress(reader)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	// symbolic links are created after the other entries, so no entry is written through them
+	var links []symlink
+	tr := tar.NewReader(source)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return createSymlinks(absDest, links)
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := extractPath(absDest, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := makeDir(absDest, target); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := untarFile(absDest, tr, target, hdr.FileInfo().Mode()); err != nil {
+				return err
+			}
+			logrus.Debugf("Extracted %s", target)
+		case tar.TypeSymlink:
+			links = append(links, symlink{target: target, link: hdr.Linkname})
+		default:
+			logrus.Debugf("Skipping tar entry %s of type %c", hdr.Name, hdr.Typeflag)
+		}
+	}
+}
+
+// decompress returns a reader of the content of reader, decompressed if its
+// first bytes match the magic number of gzip, zstd, or xz
+func decompress(reader *bufio.Reader) (io.ReadCloser, error) {
+	magic, _ := reader.Peek(len(xzMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(reader)
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	case bytes.HasPrefix(magic, xzMagic):
+		xr, err := xz.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xr), nil
+	}
+	return io.NopCloser(reader), nil
+}
+
+// unzip extracts the zip file zipName into directory destDir
+func unzip(zipName, destDir string) error {
+	absDest, err := filepath.Abs(destDir)
+	if err != nil {
+		return err
+	}
+	zr, err := zip.OpenReader(zipName)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	var links []symlink
+	for _, entry := range zr.File {
+		target, err := extractPath(absDest, entry.Name)
+		if err != nil {
+			return err
+		}
+		mode := entry.Mode()
+		switch {
+		case mode.IsDir():
+			if err := makeDir(absDest, target); err != nil {
+				return err
+			}
+		case mode&os.ModeSymlink != 0:
+			link, err := readZipEntry(entry)
+			if err != nil {
+				return err
+			}
+			links = append(links, symlink{target: target, link: link})
+		case mode.IsRegular():
+			src, err := entry.Open()
+			if err != nil {
+				return err
+			}
+			err = untarFile(absDest, src, target, mode)
+			src.Close()
+			if err != nil {
+				return err
+			}
+			logrus.Debugf("Extracted %s", target)
+		default:
+			logrus.Debugf("Skipping zip entry %s of type %s", entry.Name, mode.Type())
+		}
+	}
+	return createSymlinks(absDest, links)
+}
+
+func readZipEntry(entry *zip.File) (string, error) {
+	src, err := entry.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	data, err := io.ReadAll(io.LimitReader(src, 4096))
+	return string(data), err
+}
+
+// extractPath returns the path of entry name in absDest, ensuring entries
+// cannot be written outside of the destination
+func extractPath(absDest, name string) (string, error) {
+	target := filepath.Join(absDest, filepath.Clean("/"+name))
+	if target != absDest && !strings.HasPrefix(target, absDest+string(os.PathSeparator)) {
+		return "", fmt.Errorf("archive entry %s is outside of %s", name, absDest)
+	}
+	return target, nil
+}
+
+// checkParents returns an error if a directory of target below absDest is a symbolic link,
+// which could redirect the entry outside of the destination
+func checkParents(absDest, target string) error {
+	if target == absDest {
+		return nil
+	}
+	rel, err := filepath.Rel(absDest, filepath.Dir(target))
+	if err != nil || rel == "." {
+		return err
+	}
+	dir := absDest
+	for _, elem := range strings.Split(rel, string(os.PathSeparator)) {
+		dir = filepath.Join(dir, elem)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("archive entry %s is under symbolic link %s", target, dir)
+		}
+	}
+	return nil
+}
+
+// makeDir creates directory target, and its parents, in absDest
+func makeDir(absDest, target string) error {
+	if err := checkParents(absDest, target); err != nil {
+		return err
+	}
+	if info, err := os.Lstat(target); err == nil && target != absDest && info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("archive entry %s is a symbolic link", target)
+	}
+	if err := os.MkdirAll(target, 0744); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return nil
+}
+
+// symlink is a symbolic link entry of an archive, created after the other entries
+type symlink struct {
+	target string
+	link   string
+}
+
+// createSymlinks creates the symbolic links that resolve inside absDest. Once all are
+// created, the links resolving outside through other links (i.e. a -> b/.. with b -> .)
+// are removed.
+func createSymlinks(absDest string, links []symlink) error {
+	var created []symlink
+	for _, l := range links {
+		resolved := l.link
+		if !filepath.IsAbs(l.link) {
+			resolved = filepath.Join(filepath.Dir(l.target), l.link)
+		}
+		if resolved != absDest && !strings.HasPrefix(resolved, absDest+string(os.PathSeparator)) {
+			logrus.Debugf("Skipping symbolic link %s to %s outside of %s", l.target, l.link, absDest)
+			continue
+		}
+		if err := makeDir(absDest, filepath.Dir(l.target)); err != nil {
+			return err
+		}
+		if info, err := os.Lstat(l.target); err == nil {
+			if info.IsDir() {
+				logrus.Debugf("Skipping symbolic link %s over directory", l.target)
+				continue
+			}
+			if err := os.Remove(l.target); err != nil {
+				return err
+			}
+		}
+		if err := os.Symlink(l.link, l.target); err != nil {
+			return err
+		}
+		created = append(created, l)
+	}
+
+	realDest, err := filepath.EvalSymlinks(absDest)
+	if err != nil {
+		return err
+	}
+	for _, l := range created {
+		real, err := filepath.EvalSymlinks(l.target)
+		if err != nil {
+			// dangling links point nowhere
+			continue
+		}
+		if real != realDest && !strings.HasPrefix(real, realDest+string(os.PathSeparator)) {
+			logrus.Debugf("Removing symbolic link %s to %s resolved outside of %s", l.target, l.link, absDest)
+			if err := os.Remove(l.target); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func untarFile(absDest string, source io.Reader, target string, mode os.FileMode) error {
+	if err := makeDir(absDest, filepath.Dir(target)); err != nil {
+		return err
+	}
+	// replace an existing link rather than write through it
+	if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, source)
+	return err
+}
@@ -0,0 +1,292 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package archiver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sirupsen/logrus"
+	"github.com/ulikunitz/xz"
+)
+
+// xzDictCaps are the dictionary sizes of the xz presets 1 to 9
+var xzDictCaps = []int{1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20}
+
+func validateLevel(format Format, level int) error {
+	max := 9
+	switch format {
+	case FormatTar:
+		max = 0
+	case FormatTarZstd:
+		max = 22
+	}
+	if level < 0 || level > max {
+		if max == 0 {
+			return fmt.Errorf("compression level not supported by format %s", format)
+		}
+		return fmt.Errorf("invalid compression level %d for format %s (1-%d)", level, format, max)
+	}
+	return nil
+}
+
+// archiveWriter writes files to an archive through a chain of writers:
+// archive format, compression, encryption, and volumes
+type archiveWriter struct {
+	tw      *tar.Writer
+	zw      *zip.Writer
+	volumes *volumeWriter
+	// closers of the chain, from the archive format to the volumes
+	closers []io.Closer
+}
+
+func newArchiveWriter(name string, opts Options) (*archiveWriter, error) {
+	volumes, err := newVolumeWriter(name, opts.MaxVolumeSize)
+	if err != nil {
+		return nil, err
+	}
+	w := &archiveWriter{volumes: volumes, closers: []io.Closer{volumes}}
+	var out io.Writer = volumes
+
+	push := func(c io.WriteCloser) {
+		w.closers = append([]io.Closer{c}, w.closers...)
+		out = c
+	}
+	fail := func(err error) (*archiveWriter, error) {
+		w.Close()
+		volumes.remove()
+		return nil, err
+	}
+
+	if opts.Encryption != nil {
+		enc, err := Encrypt(out, *opts.Encryption)
+		if err != nil {
+			return fail(err)
+		}
+		push(enc)
+	}
+
+	switch opts.Format {
+	case FormatTarGzip:
+		level := gzip.DefaultCompression
+		if opts.Level > 0 {
+			level = opts.Level
+		}
+		gz, err := gzip.NewWriterLevel(out, level)
+		if err != nil {
+			return fail(err)
+		}
+		push(gz)
+	case FormatTarZstd:
+		level := zstd.SpeedDefault
+		if opts.Level > 0 {
+			level = zstd.EncoderLevelFromZstd(opts.Level)
+		}
+		zw, err := zstd.NewWriter(out, zstd.WithEncoderLevel(level))
+		if err != nil {
+			return fail(err)
+		}
+		push(zw)
+	case FormatTarXz:
+		config := xz.WriterConfig{}
+		if opts.Level > 0 {
+			config.DictCap = xzDictCaps[opts.Level-1]
+		}
+		xw, err := config.NewWriter(out)
+		if err != nil {
+			return fail(err)
+		}
+		push(xw)
+	}
+
+	if opts.Format == FormatZip {
+		w.zw = zip.NewWriter(out)
+		if opts.Level > 0 {
+			level := opts.Level
+			w.zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+				return flate.NewWriter(out, level)
+			})
+		}
+		w.closers = append([]io.Closer{w.zw}, w.closers...)
+		return w, nil
+	}
+	w.tw = tar.NewWriter(out)
+	w.closers = append([]io.Closer{w.tw}, w.closers...)
+	return w, nil
+}
+
+// add writes file, named name in the archive. Directories, regular files, and
+// symbolic links are archived; other types of files are skipped.
+func (w *archiveWriter) add(file, name string, info os.FileInfo) error {
+	link := ""
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(file)
+		if err != nil {
+			return err
+		}
+		link = target
+	case info.Mode().IsRegular(), info.IsDir():
+	default:
+		logrus.Debugf("Skipping %s: unsupported file type %s", file, info.Mode().Type())
+		return nil
+	}
+
+	if w.zw != nil {
+		return w.addZip(file, name, link, info)
+	}
+	return w.addTar(file, name, link, info)
+}
+
+func (w *archiveWriter) addTar(file, name, link string, info os.FileInfo) error {
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	// ensure header has relative file path
+	hdr.Name = filepath.ToSlash(name)
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	return copyFile(w.tw, file)
+}
+
+func (w *archiveWriter) addZip(file, name, link string, info os.FileInfo) error {
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = filepath.ToSlash(name)
+	hdr.Method = zip.Deflate
+	if info.IsDir() {
+		hdr.Name += "/"
+		hdr.Method = zip.Store
+	}
+	entry, err := w.zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	switch {
+	case link != "":
+		// as Info-ZIP, a symbolic link is stored with its target as content
+		_, err = io.WriteString(entry, link)
+		return err
+	case info.Mode().IsRegular():
+		return copyFile(entry, file)
+	}
+	return nil
+}
+
+func copyFile(dst io.Writer, file string) error {
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// Close closes the writers of the chain in order, returning the first error
+func (w *archiveWriter) Close() error {
+	var errs []error
+	for _, c := range w.closers {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	w.closers = nil
+	return errors.Join(errs...)
+}
+
+// volumeWriter writes to file name or, with a maximum size, to volumes
+// name.001, name.002, etc. of at most this size
+type volumeWriter struct {
+	name    string
+	maxSize int64
+	file    *os.File
+	written int64
+	files   []string
+}
+
+func newVolumeWriter(name string, maxSize int64) (*volumeWriter, error) {
+	w := &volumeWriter{name: name, maxSize: maxSize}
+	if err := w.next(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *volumeWriter) next() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+	}
+	name := w.name
+	if w.maxSize > 0 {
+		name = fmt.Sprintf("%s.%03d", w.name, len(w.files)+1)
+	}
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.written = 0
+	w.files = append(w.files, name)
+	return nil
+}
+
+func (w *volumeWriter) Write(p []byte) (int, error) {
+	total := 0
+	for len(p) > 0 {
+		if w.maxSize > 0 && w.written == w.maxSize {
+			if err := w.next(); err != nil {
+				return total, err
+			}
+		}
+		chunk := p
+		if w.maxSize > 0 && int64(len(chunk)) > w.maxSize-w.written {
+			chunk = chunk[:w.maxSize-w.written]
+		}
+		n, err := w.file.Write(chunk)
+		total += n
+		w.written += int64(n)
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+	}
+	return total, nil
+}
+
+func (w *volumeWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// remove deletes the files written
+func (w *volumeWriter) remove() {
+	for _, file := range w.files {
+		os.Remove(file)
+	}
+}
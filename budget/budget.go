@@ -0,0 +1,302 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package budget
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Policy is how the content of a file exceeding its budget is kept
+type Policy string
+
+const (
+	// Head keeps the beginning of the file
+	Head Policy = "head"
+	// HeadTail keeps the beginning and the end of the file, half of the budget each
+	HeadTail Policy = "head_tail"
+)
+
+// RecordFile is the name of the file, in the record directory, listing the truncated files as JSON lines
+const RecordFile = "crashd-truncated.jsonl"
+
+// Options configures a budget, zero values mean no limit
+type Options struct {
+	// MaxTotalBytes is the number of bytes that can be written in total
+	MaxTotalBytes int64
+	// MaxFileBytes is the number of bytes that can be written in a single file
+	MaxFileBytes int64
+	// Policy is how truncated files are cut, defaults to Head
+	Policy Policy
+	// Functions are the byte budgets of named functions (i.e. capture), counted in MaxTotalBytes
+	Functions map[string]int64
+	// RecordDir is the directory where RecordFile is written, none if empty
+	RecordDir string
+}
+
+// Truncation records a file that was cut to stay within the budget
+type Truncation struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+	Size   int64  `json:"size"`
+	Kept   int64  `json:"kept"`
+}
+
+// Budget tracks the bytes written against the configured limits. It is safe
+// for concurrent use and a nil *Budget allows everything.
+type Budget struct {
+	name    string
+	limit   int64
+	maxFile int64
+	policy  Policy
+	parent  *Budget
+
+	mu   sync.Mutex
+	used int64
+
+	// set on the root budget only
+	scopes      map[string]*Budget
+	recordDir   string
+	recordMu    sync.Mutex
+	truncations []Truncation
+}
+
+// New returns the budget configured by opts
+func New(opts Options) (*Budget, error) {
+	switch opts.Policy {
+	case "":
+		opts.Policy = Head
+	case Head, HeadTail:
+	default:
+		return nil, fmt.Errorf("unsupported truncation policy %q: expecting %s or %s", opts.Policy, Head, HeadTail)
+	}
+	if opts.MaxTotalBytes < 0 || opts.MaxFileBytes < 0 {
+		return nil, fmt.Errorf("invalid budget: byte limits must be positive")
+	}
+
+	root := &Budget{
+		limit:     opts.MaxTotalBytes,
+		maxFile:   opts.MaxFileBytes,
+		policy:    opts.Policy,
+		scopes:    make(map[string]*Budget),
+		recordDir: opts.RecordDir,
+	}
+	for name, limit := range opts.Functions {
+		if limit < 0 {
+			return nil, fmt.Errorf("invalid budget for %s: byte limits must be positive", name)
+		}
+		root.scopes[name] = &Budget{name: name, limit: limit, maxFile: root.maxFile, policy: root.policy, parent: root}
+	}
+	return root, nil
+}
+
+// For returns the budget of the named function, which also counts against
+// the total budget, or the budget itself if the function has none
+func (b *Budget) For(function string) *Budget {
+	if b == nil {
+		return nil
+	}
+	if scope, ok := b.root().scopes[function]; ok {
+		return scope
+	}
+	return b
+}
+
+// Limited returns true if the budget, or the total budget it counts against, limits the bytes written
+func (b *Budget) Limited() bool {
+	if b == nil {
+		return false
+	}
+	if b.maxFile > 0 {
+		return true
+	}
+	for l := b; l != nil; l = l.parent {
+		if l.limit > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Used returns the number of bytes written against the budget
+func (b *Budget) Used() int64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// Truncations returns the files truncated so far
+func (b *Budget) Truncations() []Truncation {
+	if b == nil {
+		return nil
+	}
+	root := b.root()
+	root.recordMu.Lock()
+	defer root.recordMu.Unlock()
+	return append([]Truncation(nil), root.truncations...)
+}
+
+func (b *Budget) root() *Budget {
+	for b.parent != nil {
+		b = b.parent
+	}
+	return b
+}
+
+// reserve takes up to n bytes from the budget and its parents, returning the
+// granted bytes and, if less than n, the reason why
+func (b *Budget) reserve(n int64) (int64, string) {
+	if b == nil || n <= 0 {
+		return n, ""
+	}
+	granted, reason := n, ""
+	for l := b; l != nil; l = l.parent {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.limit > 0 && l.limit-l.used < granted {
+			granted = max(l.limit-l.used, 0)
+			reason = l.reason()
+		}
+	}
+	for l := b; l != nil; l = l.parent {
+		l.used += granted
+	}
+	return granted, reason
+}
+
+func (b *Budget) reason() string {
+	if b.parent == nil {
+		return fmt.Sprintf("max_total_bytes=%d", b.limit)
+	}
+	return fmt.Sprintf("%s budget=%d", b.name, b.limit)
+}
+
+func (b *Budget) fileReason() string {
+	return fmt.Sprintf("max_file_bytes=%d", b.maxFile)
+}
+
+// record saves the truncation, logging it and appending it to the record file
+func (b *Budget) record(t Truncation) {
+	logrus.Warnf("truncated %s to %d of %d bytes: %s exceeded", t.File, t.Kept, t.Size, t.Reason)
+
+	root := b.root()
+	root.recordMu.Lock()
+	defer root.recordMu.Unlock()
+	root.truncations = append(root.truncations, t)
+	if root.recordDir == "" {
+		return
+	}
+
+	data, err := json.Marshal(t)
+	if err != nil {
+		logrus.Errorf("failed to record truncation of %s: %s", t.File, err)
+		return
+	}
+	file, err := os.OpenFile(filepath.Join(root.recordDir, RecordFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logrus.Errorf("failed to record truncation of %s: %s", t.File, err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		logrus.Errorf("failed to record truncation of %s: %s", t.File, err)
+	}
+}
+
+// split returns the bytes kept at the beginning and at the end of a truncated file
+func (b *Budget) split(keep int64) (head, tail int64) {
+	if b.policy == HeadTail {
+		return keep - keep/2, keep / 2
+	}
+	return keep, 0
+}
+
+// marker returns the text replacing the bytes cut from a file
+func marker(cut int64, reason string) string {
+	return fmt.Sprintf("\n... [crashd: truncated %d bytes, %s exceeded] ...\n", cut, reason)
+}
+
+// Snapshot is the state of the files of a directory tree, see Stat
+type Snapshot map[string]os.FileInfo
+
+// Stat returns the snapshot of the regular files at path, a file or a directory
+func Stat(path string) Snapshot {
+	snapshot := make(Snapshot)
+	_ = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			snapshot[file] = info
+		}
+		return nil
+	})
+	return snapshot
+}
+
+// EnforceChanged applies the budget to the regular files at path, a file or a directory,
+// that were created or modified since snapshot before. Files over budget are truncated in place.
+func (b *Budget) EnforceChanged(path string, before Snapshot) error {
+	if b == nil {
+		return nil
+	}
+	after := Stat(path)
+	files := make([]string, 0, len(after))
+	for file, info := range after {
+		if prev, ok := before[file]; ok && prev.Size() == info.Size() && prev.ModTime().Equal(info.ModTime()) {
+			continue
+		}
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		if err := b.enforceFile(file, after[file].Size()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enforceFile reserves the size of file, truncating it if over budget
+func (b *Budget) enforceFile(path string, size int64) error {
+	keep, reason := size, ""
+	if b.maxFile > 0 && keep > b.maxFile {
+		keep, reason = b.maxFile, b.fileReason()
+	}
+	granted, r := b.reserve(keep)
+	if granted < keep {
+		keep, reason = granted, r
+	}
+	if keep == size {
+		return nil
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("failed to truncate %s: %w", path, err)
+	}
+	defer file.Close()
+
+	head, tailSize := b.split(keep)
+	tail := make([]byte, tailSize)
+	if _, err := file.ReadAt(tail, size-tailSize); err != nil {
+		return fmt.Errorf("failed to truncate %s: %w", path, err)
+	}
+	if err := file.Truncate(head); err != nil {
+		return fmt.Errorf("failed to truncate %s: %w", path, err)
+	}
+	if _, err := file.WriteAt(append([]byte(marker(size-keep, reason)), tail...), head); err != nil {
+		return fmt.Errorf("failed to truncate %s: %w", path, err)
+	}
+
+	b.record(Truncation{File: path, Reason: reason, Size: size, Kept: keep})
+	return nil
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package budget
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      Options
+		input     string
+		expected  string
+		truncated bool
+	}{
+		{
+			name:     "no limit",
+			opts:     Options{},
+			input:    "0123456789",
+			expected: "0123456789",
+		},
+		{
+			name:     "within limit",
+			opts:     Options{MaxFileBytes: 10},
+			input:    "0123456789",
+			expected: "0123456789",
+		},
+		{
+			name:      "head",
+			opts:      Options{MaxFileBytes: 4},
+			input:     "0123456789",
+			expected:  "0123" + marker(6, "max_file_bytes=4"),
+			truncated: true,
+		},
+		{
+			name:      "head and tail",
+			opts:      Options{MaxFileBytes: 4, Policy: HeadTail},
+			input:     "0123456789",
+			expected:  "01" + marker(6, "max_file_bytes=4") + "89",
+			truncated: true,
+		},
+		{
+			name:      "total",
+			opts:      Options{MaxTotalBytes: 3, MaxFileBytes: 4, Policy: HeadTail},
+			input:     "0123456789",
+			expected:  "01" + marker(7, "max_total_bytes=3") + "9",
+			truncated: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := New(test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			w := b.NewWriter("test.txt", &buf)
+			// write byte per byte to exercise the tail buffer
+			for _, c := range []byte(test.input) {
+				if _, err := w.Write([]byte{c}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != test.expected {
+				t.Errorf("unexpected content %q, expecting %q", buf.String(), test.expected)
+			}
+			if w.Truncated() != test.truncated {
+				t.Errorf("unexpected truncated %t", w.Truncated())
+			}
+			if test.truncated && len(b.Truncations()) != 1 {
+				t.Errorf("unexpected truncations: %v", b.Truncations())
+			}
+		})
+	}
+}
+
+func TestFunctionBudget(t *testing.T) {
+	recordDir := t.TempDir()
+	b, err := New(Options{MaxTotalBytes: 10, Functions: map[string]int64{"capture": 4}, RecordDir: recordDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var capture, other bytes.Buffer
+	if _, err := b.For("capture").Copy("capture.txt", &capture, strings.NewReader("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.For("copy_from").Copy("copy.txt", &other, strings.NewReader("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(capture.String(), "0123\n") || !strings.Contains(capture.String(), "capture budget=4") {
+		t.Errorf("unexpected capture content %q", capture.String())
+	}
+	if !strings.HasPrefix(other.String(), "012345\n") || !strings.Contains(other.String(), "max_total_bytes=10") {
+		t.Errorf("unexpected content %q", other.String())
+	}
+	if b.Used() != 10 {
+		t.Errorf("unexpected used bytes %d", b.Used())
+	}
+
+	file, err := os.Open(filepath.Join(recordDir, RecordFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var records []Truncation
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Truncation
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 || records[0].File != "capture.txt" || records[0].Kept != 4 || records[1].Size != 10 || records[1].Kept != 6 {
+		t.Errorf("unexpected records: %+v", records)
+	}
+}
+
+func TestEnforceChanged(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.log")
+	if err := os.WriteFile(old, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	before := Stat(dir)
+	copied := filepath.Join(dir, "sub", "new.log")
+	if err := os.MkdirAll(filepath.Dir(copied), 0744); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(copied, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := New(Options{MaxFileBytes: 4, Policy: HeadTail})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.EnforceChanged(dir, before); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(copied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "01" + marker(6, "max_file_bytes=4") + "89"; string(data) != expected {
+		t.Errorf("unexpected content %q, expecting %q", data, expected)
+	}
+	if data, _ := os.ReadFile(old); string(data) != "0123456789" {
+		t.Errorf("unchanged file was truncated: %q", data)
+	}
+	if truncations := b.Truncations(); len(truncations) != 1 || truncations[0].File != copied {
+		t.Errorf("unexpected truncations: %+v", truncations)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	if _, err := New(Options{Policy: "tail"}); err == nil {
+		t.Error("expecting error for unsupported policy")
+	}
+	if _, err := New(Options{MaxFileBytes: -1}); err == nil {
+		t.Error("expecting error for negative limit")
+	}
+}
+
+func TestNilBudget(t *testing.T) {
+	var b *Budget
+	var buf bytes.Buffer
+	if _, err := b.For("capture").Copy("test.txt", &buf, strings.NewReader("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "0123456789" {
+		t.Errorf("unexpected content %q", buf.String())
+	}
+	if err := b.EnforceChanged(t.TempDir(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if b.Limited() {
+		t.Error("nil budget reported limited")
+	}
+}
+
+func TestLimited(t *testing.T) {
+	for _, test := range []struct {
+		opts     Options
+		function string
+		limited  bool
+	}{
+		{opts: Options{}, function: "capture"},
+		{opts: Options{MaxFileBytes: 10}, function: "capture", limited: true},
+		{opts: Options{MaxTotalBytes: 10}, function: "capture", limited: true},
+		{opts: Options{Functions: map[string]int64{"capture": 10}}, function: "capture", limited: true},
+		{opts: Options{Functions: map[string]int64{"capture": 10}}, function: "copy_from"},
+	} {
+		b, err := New(test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if limited := b.For(test.function).Limited(); limited != test.limited {
+			t.Errorf("%+v: expected limited %t for %s, got %t", test.opts, test.limited, test.function, limited)
+		}
+	}
+}
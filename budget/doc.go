@@ -0,0 +1,6 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package budget limits the number of bytes written by the collection functions,
+// truncating the data exceeding the budget and recording every truncation.
+package budget
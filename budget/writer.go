@@ -0,0 +1,122 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package budget
+
+import (
+	"io"
+)
+
+// Writer writes to an underlying writer within a budget. The bytes over budget
+// are dropped, or kept for the end of the file with the HeadTail policy, and the
+// truncation marker is written by Close.
+type Writer struct {
+	budget   *Budget
+	file     string
+	w        io.Writer
+	head     int64 // bytes kept at the beginning, -1 for no file limit
+	tailSize int64
+	tail     []byte
+	written  int64
+	size     int64
+	reason   string
+	spent    bool // no more bytes can be written
+}
+
+// NewWriter returns a writer to w, the content of file, within the budget
+func (b *Budget) NewWriter(file string, w io.Writer) *Writer {
+	writer := &Writer{budget: b, file: file, w: w, head: -1}
+	if b != nil && b.maxFile > 0 {
+		writer.head, writer.tailSize = b.split(b.maxFile)
+	}
+	return writer
+}
+
+// Write writes the part of p within the budget. It never returns a short
+// write so that the source can be read to its end.
+func (w *Writer) Write(p []byte) (int, error) {
+	n := len(p)
+	w.size += int64(n)
+	if w.spent {
+		return n, nil
+	}
+
+	keep := int64(n)
+	if w.head >= 0 && w.head-w.written < keep {
+		keep = w.head - w.written
+		if w.reason == "" {
+			w.reason = w.budget.fileReason()
+		}
+	}
+	if keep > 0 {
+		granted, reason := w.budget.reserve(keep)
+		if granted < keep {
+			w.reason, w.spent = reason, true
+		}
+		written, err := w.w.Write(p[:granted])
+		w.written += int64(written)
+		if err != nil {
+			return written, err
+		}
+		if w.spent {
+			return n, nil
+		}
+	}
+
+	if rest := p[keep:]; len(rest) > 0 && w.tailSize > 0 {
+		w.tail = append(w.tail, rest...)
+		if int64(len(w.tail)) > 2*w.tailSize {
+			w.tail = append(w.tail[:0], w.tail[int64(len(w.tail))-w.tailSize:]...)
+		}
+	}
+	return n, nil
+}
+
+// Truncated returns true if bytes were cut from the content
+func (w *Writer) Truncated() bool {
+	return w.reason != ""
+}
+
+// Close writes the truncation marker and the kept end of the content, if
+// truncated, and records the truncation. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if !w.Truncated() {
+		return nil
+	}
+
+	tail := w.tail
+	if int64(len(tail)) > w.tailSize {
+		tail = tail[int64(len(tail))-w.tailSize:]
+	}
+	if !w.spent {
+		granted, reason := w.budget.reserve(int64(len(tail)))
+		if granted < int64(len(tail)) {
+			w.reason = reason
+		}
+		tail = tail[int64(len(tail))-granted:]
+	} else {
+		tail = nil
+	}
+	w.tail = nil
+
+	kept := w.written + int64(len(tail))
+	if _, err := io.WriteString(w.w, marker(w.size-kept, w.reason)); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(tail); err != nil {
+		return err
+	}
+	w.budget.record(Truncation{File: w.file, Reason: w.reason, Size: w.size, Kept: kept})
+	return nil
+}
+
+// Copy copies src to dst, the content of file, within the budget and
+// returns the number of bytes read from src
+func (b *Budget) Copy(file string, dst io.Writer, src io.Reader) (int64, error) {
+	w := b.NewWriter(file, dst)
+	n, err := io.Copy(w, src)
+	if err != nil {
+		return n, err
+	}
+	return n, w.Close()
+}
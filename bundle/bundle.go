@@ -0,0 +1,199 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+)
+
+// Bundle represents the content collected by a crashd script. The content
+// is read from a directory or from an archive extracted in a temporary directory.
+type Bundle struct {
+	source  string
+	root    string
+	tempDir string
+	workdir string
+}
+
+// Open returns a *Bundle for path which can either be a directory or
+// an archive (tar or tar.gz) created by crashd. Callers must call Close
+// to release temporary resources. The content of an incremental collection
+// held by its base bundles, listed in its manifest, is restored in a
+// temporary directory so the bundle reads as a complete collection.
+func Open(path string) (*Bundle, error) {
+	b, err := openRaw(path)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := b.Manifest()
+	if errors.Is(err, ErrNoManifest) {
+		return b, nil
+	}
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	if files, objects := manifest.references(); len(files) == 0 && len(objects) == 0 {
+		return b, nil
+	}
+
+	if b.tempDir == "" {
+		// restore the referenced content in a copy of the directory
+		tempDir, err := os.MkdirTemp("", "crashd-bundle")
+		if err != nil {
+			return nil, fmt.Errorf("bundle: failed to create temp dir: %w", err)
+		}
+		if err := linkTree(path, tempDir); err != nil {
+			os.RemoveAll(tempDir)
+			return nil, fmt.Errorf("bundle: failed to copy %s: %w", path, err)
+		}
+		b = &Bundle{source: path, root: tempDir, tempDir: tempDir, workdir: tempDir}
+	}
+	if err := b.restore(manifest); err != nil {
+		b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// openRaw returns a *Bundle for path without restoring referenced content
+func openRaw(path string) (*Bundle, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+
+	if info.IsDir() {
+		return &Bundle{source: path, root: path}, nil
+	}
+
+	tempDir, err := extract(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Bundle{source: path, root: tempDir, tempDir: tempDir}, nil
+}
+
+// Source returns the path used to open the bundle
+func (b *Bundle) Source() string {
+	return b.source
+}
+
+// Root returns the local directory where the content of the bundle can be read
+func (b *Bundle) Root() string {
+	return b.root
+}
+
+// Rel returns path relative to the root of the bundle
+func (b *Bundle) Rel(path string) string {
+	rel, err := filepath.Rel(b.root, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// Workdir returns the crashd working directory found in the bundle. For a
+// directory, it is the directory itself. For an archive, it is the directory of
+// the manifest or the parent of the kube_capture output, if any, or else the
+// deepest directory common to all files.
+func (b *Bundle) Workdir() string {
+	if b.workdir != "" {
+		return b.workdir
+	}
+	if b.tempDir == "" {
+		return b.root
+	}
+
+	var common []string
+	var workdir string
+	err := filepath.Walk(b.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == k8s.BaseDirname {
+				workdir = filepath.Dir(path)
+				return filepath.SkipAll
+			}
+			return nil
+		}
+		if info.Name() == ManifestFile {
+			workdir = filepath.Dir(path)
+			return filepath.SkipAll
+		}
+		dirs := strings.Split(filepath.Dir(b.Rel(path)), string(os.PathSeparator))
+		if common == nil {
+			common = dirs
+			return nil
+		}
+		i := 0
+		for i < len(common) && i < len(dirs) && common[i] == dirs[i] {
+			i++
+		}
+		common = common[:i]
+		return nil
+	})
+	if err != nil {
+		logrus.Debugf("bundle: failed to find workdir: %s", err)
+	}
+	if workdir != "" {
+		return workdir
+	}
+	return filepath.Join(append([]string{b.root}, common...)...)
+}
+
+// CaptureFiles returns the paths, relative to the bundle workdir, of all
+// files saved by the host commands (i.e. capture, copy_from). Files written
+// by kube_capture are not included.
+func (b *Bundle) CaptureFiles() ([]string, error) {
+	return captureFiles(b.Workdir())
+}
+
+// captureFiles returns the paths of the capture files in workdir, relative to workdir
+func captureFiles(workdir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(workdir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == k8s.BaseDirname {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || info.Name() == ManifestFile {
+			return nil
+		}
+		rel, err := filepath.Rel(workdir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bundle: failed to read files: %w", err)
+	}
+	return files, nil
+}
+
+// Close removes any temporary content created when the bundle was opened
+func (b *Bundle) Close() error {
+	if b.tempDir == "" {
+		return nil
+	}
+	logrus.Debugf("bundle: removing %s", b.tempDir)
+	return os.RemoveAll(b.tempDir)
+}
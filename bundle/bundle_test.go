@@ -0,0 +1,183 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vmware-tanzu/crash-diagnostics/archiver"
+)
+
+const (
+	testPodList = `{"apiVersion":"v1","kind":"PodList","metadata":{},"items":[
+{"apiVersion":"v1","kind":"Pod","metadata":{"name":"etcd","namespace":"kube-system","labels":{"tier":"control-plane"},"creationTimestamp":"2020-01-01T00:00:00Z"},
+ "spec":{"nodeName":"node-0","containers":[{"name":"etcd"}]},
+ "status":{"phase":"Running","podIP":"10.0.0.1","containerStatuses":[{"name":"etcd","ready":true,"restartCount":2,"state":{"running":{}}}]}},
+{"apiVersion":"v1","kind":"Pod","metadata":{"name":"coredns","namespace":"kube-system","labels":{"k8s-app":"kube-dns"},"creationTimestamp":"2020-01-01T00:00:00Z"},
+ "spec":{"containers":[{"name":"coredns"}]},
+ "status":{"phase":"Running","containerStatuses":[{"name":"coredns","ready":false,"restartCount":7,"state":{"waiting":{"reason":"CrashLoopBackOff"}}}]}}
+]}`
+
+	testNodeList = `{"apiVersion":"v1","kind":"NodeList","metadata":{},"items":[
+{"apiVersion":"v1","kind":"Node","metadata":{"name":"node-0","labels":{"node-role.kubernetes.io/control-plane":""}},
+ "status":{"conditions":[{"type":"Ready","status":"False"}],"nodeInfo":{"kubeletVersion":"v1.32.1"}}}
+]}`
+
+	testDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+spec:
+  replicas: 2
+status:
+  readyReplicas: 1
+`
+)
+
+// makeTestBundle creates a workdir with the layout written by kube_capture
+func makeTestBundle(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"kubecapture/core_v1/kube-system/pods-2020-01-01T01-00-00Z.0000.json":           testPodList,
+		"kubecapture/core_v1/kube-system/etcd/etcd/etcd.log":                            "etcd log",
+		"kubecapture/core_v1/nodes-2020-01-01T01-00-00Z.0000.json":                      testNodeList,
+		"kubecapture/apps_v1/default/deployments/nginx-2020-01-01T01-00-00Z.0000.yaml":  testDeployment,
+		"kubecapture/apps_v1/default/deployments/broken-2020-01-01T01-00-00Z.0000.yaml": "not: [valid",
+		"127_0_0_1/uptime.txt": "up 2 days",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0744); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name string
+		path func(t *testing.T) string
+	}{
+		{
+			name: "from directory",
+			path: makeTestBundle,
+		},
+		{
+			name: "from tar.gz archive",
+			path: func(t *testing.T) string {
+				dir := makeTestBundle(t)
+				tarFile := filepath.Join(t.TempDir(), "out.tar.gz")
+				if err := archiver.Tar(tarFile, dir); err != nil {
+					t.Fatal(err)
+				}
+				return tarFile
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := Open(test.path(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.Close()
+
+			index, err := b.KubeObjects()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if index.Len() != 4 {
+				t.Errorf("expected 4 objects, got %d", index.Len())
+			}
+		})
+	}
+}
+
+func TestObjectIndexSelect(t *testing.T) {
+	b, err := Open(makeTestBundle(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := b.KubeObjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected []string
+	}{
+		{name: "by resource", query: Query{Resource: "pods"}, expected: []string{"coredns", "etcd"}},
+		{name: "by singular resource", query: Query{Resource: "pod"}, expected: []string{"coredns", "etcd"}},
+		{name: "by kind and namespace", query: Query{Resource: "Pod", Namespaces: []string{"default"}}},
+		{name: "by qualified resource", query: Query{Resource: "deployments.apps"}, expected: []string{"nginx"}},
+		{name: "cluster scoped ignores namespaces", query: Query{Resource: "nodes", Namespaces: []string{"default"}}, expected: []string{"node-0"}},
+		{name: "by labels", query: Query{Resource: "pods", Labels: []string{"tier=control-plane"}}, expected: []string{"etcd"}},
+		{name: "by names", query: Query{Resource: "pods", Names: []string{"coredns"}}, expected: []string{"coredns"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objs, err := index.Select(test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(objs) != len(test.expected) {
+				t.Fatalf("expected %d objects, got %d", len(test.expected), len(objs))
+			}
+			for i, obj := range objs {
+				if obj.Key.Name != test.expected[i] {
+					t.Errorf("expected object %s, got %s", test.expected[i], obj.Key.Name)
+				}
+			}
+		})
+	}
+
+	if _, err := index.Select(Query{Labels: []string{"a in (b"}}); err == nil {
+		t.Error("expected error for invalid label selector")
+	}
+}
+
+func TestTable(t *testing.T) {
+	b, err := Open(makeTestBundle(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := b.KubeObjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pods, err := index.Select(Query{Resource: "pods"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := Table(pods)
+	if len(table.Rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(table.Rows))
+	}
+	coredns := table.Rows[0].Cells
+	if coredns[1] != "0/1" || coredns[2] != "CrashLoopBackOff" || coredns[3] != int64(7) || coredns[4] != "60m" {
+		t.Errorf("unexpected coredns row: %v", coredns)
+	}
+
+	nodes, err := index.Select(Query{Resource: "nodes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := Table(nodes).Rows[0].Cells
+	if row[1] != "NotReady" || row[2] != "control-plane" {
+		t.Errorf("unexpected node row: %v", row)
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ChangeType describes how an object or file differs between two bundles
+type ChangeType string
+
+const (
+	Added   ChangeType = "added"
+	Removed ChangeType = "removed"
+	Changed ChangeType = "changed"
+)
+
+var changeSymbols = map[ChangeType]string{Added: "+", Removed: "-", Changed: "~"}
+
+// DefaultIgnoredFields lists the object fields that change on every update
+// and are not compared by Diff
+var DefaultIgnoredFields = []string{
+	"metadata.resourceVersion",
+	"metadata.managedFields",
+	"metadata.selfLink",
+}
+
+const (
+	// maxLineDiffSize is the largest file size (in bytes) for which line diffs are computed
+	maxLineDiffSize = 1 << 20
+	// maxLineDiffCells bounds the size of the table used to compare lines
+	maxLineDiffCells = 1 << 22
+)
+
+// DiffOptions configures the comparison of two bundles
+type DiffOptions struct {
+	// IgnoredFields are dot-separated object field paths excluded from comparison
+	IgnoredFields []string
+}
+
+// FieldDiff is a single field that differs between two versions of an object
+type FieldDiff struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// ObjectDiff reports an object that was added, removed, or changed
+type ObjectDiff struct {
+	Change    ChangeType  `json:"change"`
+	Resource  string      `json:"resource"`
+	Namespace string      `json:"namespace,omitempty"`
+	Name      string      `json:"name"`
+	Fields    []FieldDiff `json:"fields,omitempty"`
+}
+
+// FileDiff reports a capture file that was added, removed, or changed
+type FileDiff struct {
+	Change ChangeType `json:"change"`
+	Path   string     `json:"path"`
+	// Lines contains removed (-) and added (+) lines for changed text files
+	Lines []string `json:"lines,omitempty"`
+}
+
+// DiffReport contains all differences found between two bundles
+type DiffReport struct {
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Objects []ObjectDiff `json:"objects"`
+	Files   []FileDiff   `json:"files"`
+}
+
+// Empty returns true when no difference was found
+func (r *DiffReport) Empty() bool {
+	return len(r.Objects) == 0 && len(r.Files) == 0
+}
+
+// WriteText writes a human readable version of the report to w
+func (r *DiffReport) WriteText(w io.Writer) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", r.From, r.To)
+	if r.Empty() {
+		buf.WriteString("no differences found\n")
+	}
+
+	if len(r.Objects) > 0 {
+		buf.WriteString("\nObjects:\n")
+	}
+	for _, obj := range r.Objects {
+		name := obj.Name
+		if obj.Namespace != "" {
+			name = fmt.Sprintf("%s/%s", obj.Namespace, obj.Name)
+		}
+		fmt.Fprintf(&buf, "%s %s %s\n", changeSymbols[obj.Change], obj.Resource, name)
+		for _, field := range obj.Fields {
+			fmt.Fprintf(&buf, "    %s: %s -> %s\n", field.Path, formatValue(field.Old), formatValue(field.New))
+		}
+	}
+
+	if len(r.Files) > 0 {
+		buf.WriteString("\nFiles:\n")
+	}
+	for _, file := range r.Files {
+		fmt.Fprintf(&buf, "%s %s\n", changeSymbols[file.Change], file.Path)
+		for _, line := range file.Lines {
+			fmt.Fprintf(&buf, "    %s\n", line)
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func formatValue(val interface{}) string {
+	if val == nil {
+		return "<none>"
+	}
+	if str, ok := val.(string); ok {
+		return fmt.Sprintf("%q", str)
+	}
+	return fmt.Sprint(val)
+}
+
+// objectID matches objects across bundles by resource, namespace, and name
+type objectID struct {
+	resource  schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+// Diff compares the kube_capture objects and the capture files of bundles from and to
+func Diff(from, to *Bundle, opts DiffOptions) (*DiffReport, error) {
+	report := &DiffReport{From: from.Source(), To: to.Source()}
+	ignored := append(append([]string{}, DefaultIgnoredFields...), opts.IgnoredFields...)
+
+	fromObjs, err := objectsByID(from)
+	if err != nil {
+		return nil, err
+	}
+	toObjs, err := objectsByID(to)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, fromObj := range fromObjs {
+		toObj, ok := toObjs[id]
+		if !ok {
+			report.Objects = append(report.Objects, newObjectDiff(Removed, id, nil))
+			continue
+		}
+		fields := diffFields(flatten(fromObj.Object.Object, ignored), flatten(toObj.Object.Object, ignored))
+		if len(fields) > 0 {
+			report.Objects = append(report.Objects, newObjectDiff(Changed, id, fields))
+		}
+	}
+	for id := range toObjs {
+		if _, ok := fromObjs[id]; !ok {
+			report.Objects = append(report.Objects, newObjectDiff(Added, id, nil))
+		}
+	}
+	sort.Slice(report.Objects, func(i, j int) bool {
+		a, b := report.Objects[i], report.Objects[j]
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	files, err := diffFiles(from, to)
+	if err != nil {
+		return nil, err
+	}
+	report.Files = files
+
+	return report, nil
+}
+
+func objectsByID(b *Bundle) (map[objectID]*Object, error) {
+	index, err := b.KubeObjects()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[objectID]*Object)
+	for _, obj := range index.Objects() {
+		result[objectID{resource: obj.GroupVersionResource, namespace: obj.Key.Namespace, name: obj.Key.Name}] = obj
+	}
+	return result, nil
+}
+
+func newObjectDiff(change ChangeType, id objectID, fields []FieldDiff) ObjectDiff {
+	resource := id.resource.Resource
+	if gv := id.resource.GroupVersion().String(); gv != "" {
+		resource = fmt.Sprintf("%s/%s", gv, id.resource.Resource)
+	}
+	return ObjectDiff{Change: change, Resource: resource, Namespace: id.namespace, Name: id.name, Fields: fields}
+}
+
+// flatten returns the leaf values of obj keyed by their field path
+// (i.e. spec.containers[0].image), skipping ignored paths
+func flatten(obj map[string]interface{}, ignored []string) map[string]interface{} {
+	result := make(map[string]interface{})
+	var walk func(path string, val interface{})
+	walk = func(path string, val interface{}) {
+		if isIgnored(path, ignored) {
+			return
+		}
+		switch v := val.(type) {
+		case map[string]interface{}:
+			if len(v) == 0 {
+				result[path] = v
+			}
+			for key, elem := range v {
+				walk(joinFieldPath(path, key), elem)
+			}
+		case []interface{}:
+			if len(v) == 0 {
+				result[path] = v
+			}
+			for i, elem := range v {
+				walk(fmt.Sprintf("%s[%d]", path, i), elem)
+			}
+		default:
+			result[path] = v
+		}
+	}
+	for key, val := range obj {
+		walk(joinFieldPath("", key), val)
+	}
+	return result
+}
+
+func joinFieldPath(path, key string) string {
+	if strings.ContainsAny(key, "./[]") {
+		return fmt.Sprintf("%s[%q]", path, key)
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func isIgnored(path string, ignored []string) bool {
+	for _, ign := range ignored {
+		if path == ign || strings.HasPrefix(path, ign+".") || strings.HasPrefix(path, ign+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+func diffFields(from, to map[string]interface{}) []FieldDiff {
+	var result []FieldDiff
+	for path, oldVal := range from {
+		newVal, ok := to[path]
+		if !ok {
+			result = append(result, FieldDiff{Path: path, Old: oldVal})
+			continue
+		}
+		if !reflect.DeepEqual(oldVal, newVal) {
+			result = append(result, FieldDiff{Path: path, Old: oldVal, New: newVal})
+		}
+	}
+	for path, newVal := range to {
+		if _, ok := from[path]; !ok {
+			result = append(result, FieldDiff{Path: path, New: newVal})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result
+}
+
+// diffFiles compares capture files with the same path, relative to each bundle workdir
+func diffFiles(from, to *Bundle) ([]FileDiff, error) {
+	fromDir, toDir := from.Workdir(), to.Workdir()
+	fromFiles, err := captureFileSet(fromDir)
+	if err != nil {
+		return nil, err
+	}
+	toFiles, err := captureFileSet(toDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []FileDiff
+	for f := range fromFiles {
+		if !toFiles[f] {
+			result = append(result, FileDiff{Change: Removed, Path: f})
+			continue
+		}
+		fromData, err := os.ReadFile(filepath.Join(fromDir, f))
+		if err != nil {
+			return nil, err
+		}
+		toData, err := os.ReadFile(filepath.Join(toDir, f))
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(fromData, toData) {
+			continue
+		}
+		diff := FileDiff{Change: Changed, Path: f}
+		if len(fromData) <= maxLineDiffSize && len(toData) <= maxLineDiffSize {
+			diff.Lines = diffLines(splitLines(fromData), splitLines(toData))
+		}
+		result = append(result, diff)
+	}
+	for f := range toFiles {
+		if !fromFiles[f] {
+			result = append(result, FileDiff{Change: Added, Path: f})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result, nil
+}
+
+// captureFileSet returns the set of the capture files of workdir, walked once
+func captureFileSet(workdir string) (map[string]bool, error) {
+	files, err := captureFiles(workdir)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(files))
+	for _, f := range files {
+		set[f] = true
+	}
+	return set, nil
+}
+
+func splitLines(data []byte) []string {
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// diffLines returns the lines removed from a (prefixed with -) and added
+// in b (prefixed with +), based on their longest common subsequence
+func diffLines(a, b []string) []string {
+	// skip common prefix and suffix to keep the LCS table small
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		start++
+	}
+	endA, endB := len(a), len(b)
+	for endA > start && endB > start && a[endA-1] == b[endB-1] {
+		endA--
+		endB--
+	}
+	a, b = a[start:endA], b[start:endB]
+
+	if len(a)*len(b) > maxLineDiffCells {
+		return []string{fmt.Sprintf("(%d lines removed, %d lines added; too large to compare)", len(a), len(b))}
+	}
+
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var result []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			result = append(result, "- "+a[i])
+			i++
+		default:
+			result = append(result, "+ "+b[j])
+			j++
+		}
+	}
+	return result
+}
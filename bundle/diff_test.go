@@ -0,0 +1,116 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vmware-tanzu/crash-diagnostics/archiver"
+)
+
+func TestDiff(t *testing.T) {
+	fromDir := makeTestBundle(t)
+	toDir := makeTestBundle(t)
+
+	// change the deployment, remove the node list, add a capture file, and change another
+	deployment := strings.Replace(testDeployment, "replicas: 2", "replicas: 3", 1)
+	deployment = strings.Replace(deployment, "name: nginx", "name: nginx\n  resourceVersion: \"42\"", 1)
+	writeTestFile(t, toDir, "kubecapture/apps_v1/default/deployments/nginx-2020-01-02T01-00-00Z.0000.yaml", deployment)
+	if err := os.Remove(filepath.Join(toDir, "kubecapture/core_v1/nodes-2020-01-01T01-00-00Z.0000.json")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, toDir, "127_0_0_1/uptime.txt", "up 3 days")
+	writeTestFile(t, toDir, "127_0_0_1/df.txt", "/dev/sda1 100%")
+
+	// compare the directory with an archive of the changed directory
+	tarFile := filepath.Join(t.TempDir(), "to.tar.gz")
+	if err := archiver.Tar(tarFile, toDir); err != nil {
+		t.Fatal(err)
+	}
+
+	from, err := Open(fromDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := Open(tarFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer to.Close()
+
+	report, err := Diff(from, to, DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Objects) != 2 {
+		t.Fatalf("expected 2 object differences, got %d: %+v", len(report.Objects), report.Objects)
+	}
+	changed := report.Objects[0]
+	if changed.Change != Changed || changed.Resource != "apps/v1/deployments" || changed.Name != "nginx" {
+		t.Errorf("unexpected object diff: %+v", changed)
+	}
+	if len(changed.Fields) != 1 || changed.Fields[0].Path != "spec.replicas" {
+		t.Errorf("unexpected field diffs: %+v", changed.Fields)
+	}
+	removed := report.Objects[1]
+	if removed.Change != Removed || removed.Resource != "v1/nodes" || removed.Name != "node-0" {
+		t.Errorf("unexpected object diff: %+v", removed)
+	}
+
+	if len(report.Files) != 2 {
+		t.Fatalf("expected 2 file differences, got %d: %+v", len(report.Files), report.Files)
+	}
+	if report.Files[0].Change != Added || report.Files[0].Path != filepath.Join("127_0_0_1", "df.txt") {
+		t.Errorf("unexpected file diff: %+v", report.Files[0])
+	}
+	uptime := report.Files[1]
+	if uptime.Change != Changed || strings.Join(uptime.Lines, "|") != "- up 2 days|+ up 3 days" {
+		t.Errorf("unexpected file diff: %+v", uptime)
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "spec.replicas: 2 -> 3") {
+		t.Errorf("unexpected text report:\n%s", out.String())
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     []string
+		expected []string
+	}{
+		{name: "equal", a: []string{"a", "b"}, b: []string{"a", "b"}},
+		{name: "added", a: []string{"a", "c"}, b: []string{"a", "b", "c"}, expected: []string{"+ b"}},
+		{name: "removed", a: []string{"a", "b", "c"}, b: []string{"a", "c"}, expected: []string{"- b"}},
+		{name: "replaced", a: []string{"a", "b", "c"}, b: []string{"a", "x", "c"}, expected: []string{"- b", "+ x"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := diffLines(test.a, test.b)
+			if strings.Join(result, "|") != strings.Join(test.expected, "|") {
+				t.Errorf("expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func writeTestFile(t *testing.T, root, name, content string) {
+	t.Helper()
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0744); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,6 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package bundle provides offline access to the files collected by crashd,
+// either from a working directory or from an archive created by archive().
+package bundle
@@ -0,0 +1,314 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/vmware-tanzu/crash-diagnostics/archiver"
+)
+
+// ManifestFile is the name of the manifest saved in the working directory by an incremental collection
+const ManifestFile = "crashd-manifest.json"
+
+// ErrNoManifest is returned when a bundle has no manifest
+var ErrNoManifest = errors.New("bundle: no manifest found")
+
+// Manifest lists the content of an incremental collection. Content unchanged since
+// the base bundle is not saved again, its entry references the bundle holding it.
+type Manifest struct {
+	Created time.Time `json:"created"`
+	// Base is the bundle the collection was compared to, if any
+	Base string `json:"base,omitempty"`
+	// Files are the files copied from the hosts, by path relative to the working directory
+	Files map[string]FileEntry `json:"files,omitempty"`
+	// Objects are the Kubernetes objects captured, by ObjectID
+	Objects map[string]ObjectEntry `json:"objects,omitempty"`
+}
+
+// FileEntry identifies the content of a file copied from a host
+type FileEntry struct {
+	SHA256 string `json:"sha256"`
+	// ModTime is the modification time of the file on its host, in Unix seconds
+	ModTime int64 `json:"mtime"`
+	Size    int64 `json:"size"`
+	// Ref is the bundle holding the file, empty if the file is in this bundle
+	Ref string `json:"ref,omitempty"`
+}
+
+// ObjectEntry identifies the version of a captured Kubernetes object
+type ObjectEntry struct {
+	ResourceVersion string `json:"resourceVersion"`
+	// Path is the file holding the object, relative to the working directory
+	Path string `json:"path"`
+	// Ref is the bundle holding the object, empty if the object is in this bundle
+	Ref string `json:"ref,omitempty"`
+}
+
+// NewManifest returns an empty manifest of a collection compared to bundle base
+func NewManifest(base string) *Manifest {
+	return &Manifest{
+		Created: time.Now().UTC(),
+		Base:    base,
+		Files:   make(map[string]FileEntry),
+		Objects: make(map[string]ObjectEntry),
+	}
+}
+
+// ObjectID returns the identifier of a Kubernetes object in a manifest
+func ObjectID(gvr schema.GroupVersionResource, namespace, name string) string {
+	group := gvr.Group
+	if group == "" {
+		group = "core"
+	}
+	if namespace == "" {
+		return fmt.Sprintf("%s/%s/%s/%s", group, gvr.Version, gvr.Resource, name)
+	}
+	return fmt.Sprintf("%s/%s/%s/%s/%s", group, gvr.Version, gvr.Resource, namespace, name)
+}
+
+// ReadManifest reads the manifest saved in working directory workdir
+func ReadManifest(workdir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(workdir, ManifestFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoManifest
+		}
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	manifest := NewManifest("")
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("bundle: invalid manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// Write saves the manifest in working directory workdir
+func (m *Manifest) Write(workdir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(workdir, ManifestFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(workdir, ManifestFile))
+}
+
+// LoadManifest reads the manifest of the bundle at path, a directory or an
+// archive, without restoring the content it references
+func LoadManifest(path string) (*Manifest, error) {
+	b, err := openRaw(path)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+	return b.Manifest()
+}
+
+// Manifest returns the manifest of the bundle, or ErrNoManifest
+func (b *Bundle) Manifest() (*Manifest, error) {
+	return ReadManifest(b.Workdir())
+}
+
+// references returns the entries of the manifest held by other bundles
+func (m *Manifest) references() (map[string][]string, map[string]map[string][]string) {
+	files := make(map[string][]string)
+	objects := make(map[string]map[string][]string)
+	for path, entry := range m.Files {
+		if entry.Ref != "" {
+			files[entry.Ref] = append(files[entry.Ref], path)
+		}
+	}
+	for id, entry := range m.Objects {
+		if entry.Ref == "" {
+			continue
+		}
+		if objects[entry.Ref] == nil {
+			objects[entry.Ref] = make(map[string][]string)
+		}
+		objects[entry.Ref][entry.Path] = append(objects[entry.Ref][entry.Path], id)
+	}
+	return files, objects
+}
+
+// restore copies the content referenced by the manifest of the bundle from the
+// bundles holding it, so the bundle can be read as a complete collection
+func (b *Bundle) restore(manifest *Manifest) error {
+	files, objects := manifest.references()
+	refs := make(map[string]bool)
+	for ref := range files {
+		refs[ref] = true
+	}
+	for ref := range objects {
+		refs[ref] = true
+	}
+
+	workdir := b.Workdir()
+	for ref := range refs {
+		refBundle, err := openRaw(b.resolveRef(ref))
+		if err != nil {
+			return fmt.Errorf("bundle: failed to open referenced bundle: %w", err)
+		}
+		err = restoreFrom(refBundle, workdir, files[ref], objects[ref])
+		refBundle.Close()
+		if err != nil {
+			return err
+		}
+	}
+	logrus.Debugf("bundle: restored content of %d referenced bundle(s)", len(refs))
+	return nil
+}
+
+// resolveRef returns the path of a referenced bundle, looked up next to
+// the bundle when it was moved from its recorded location
+func (b *Bundle) resolveRef(ref string) string {
+	if _, err := os.Stat(ref); err == nil {
+		return ref
+	}
+	return filepath.Join(filepath.Dir(b.source), filepath.Base(ref))
+}
+
+func restoreFrom(ref *Bundle, workdir string, files []string, objects map[string][]string) error {
+	refWorkdir := ref.Workdir()
+	for _, path := range files {
+		if err := copyFile(filepath.Join(refWorkdir, path), filepath.Join(workdir, path)); err != nil {
+			return fmt.Errorf("bundle: failed to restore %s: %w", path, err)
+		}
+	}
+
+	for path, ids := range objects {
+		wanted := make(map[string]bool)
+		for _, id := range ids {
+			wanted[id] = true
+		}
+		source := filepath.Join(refWorkdir, path)
+		objs, err := readObjectFile(source, findCaptureDir(source))
+		if err != nil {
+			return fmt.Errorf("bundle: failed to restore %s: %w", path, err)
+		}
+		if len(objs) == len(wanted) {
+			// all the objects of the file are referenced
+			if err := copyFile(source, filepath.Join(workdir, path)); err != nil {
+				return fmt.Errorf("bundle: failed to restore %s: %w", path, err)
+			}
+			continue
+		}
+		list := &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "List"}}
+		for _, obj := range objs {
+			if wanted[ObjectID(obj.GroupVersionResource, obj.Key.Namespace, obj.Key.Name)] {
+				list.Items = append(list.Items, *obj.Object)
+			}
+		}
+		if err := writeObjectList(list, filepath.Join(workdir, path)); err != nil {
+			return fmt.Errorf("bundle: failed to restore %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func writeObjectList(list *unstructured.UnstructuredList, path string) error {
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].GetNamespace()+"/"+list.Items[i].GetName() < list.Items[j].GetNamespace()+"/"+list.Items[j].GetName()
+	})
+	data, err := list.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	if err := removeFile(path); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// copyFile copies file src to dst, preserving its modification time
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := removeFile(dst); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// removeFile prepares path to be written: its directory is created and the file,
+// which may be linked to the file of another bundle, is removed
+func removeFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0744); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// linkTree recreates directory src in dst with hard links to its files,
+// copying the files that cannot be linked (i.e. on another filesystem)
+func linkTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, 0744)
+		case info.Mode().IsRegular():
+			if err := os.Link(path, target); err == nil {
+				return nil
+			}
+			return copyFile(path, target)
+		}
+		return nil
+	})
+}
+
+// extract extracts archive path in a new temporary directory
+func extract(path string) (string, error) {
+	tempDir, err := os.MkdirTemp("", "crashd-bundle")
+	if err != nil {
+		return "", fmt.Errorf("bundle: failed to create temp dir: %w", err)
+	}
+	if err := archiver.Untar(path, tempDir); err != nil {
+		os.RemoveAll(tempDir)
+		return "", fmt.Errorf("bundle: failed to extract %s: %w", path, err)
+	}
+	logrus.Debugf("bundle: extracted %s into %s", path, tempDir)
+	return tempDir, nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/vmware-tanzu/crash-diagnostics/archiver"
+)
+
+// makeIncrementalBundle creates a collection referencing the uptime file and the etcd pod of base
+func makeIncrementalBundle(t *testing.T, base string) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "127_0_0_1/dmesg.txt", "booted")
+
+	manifest := NewManifest(base)
+	manifest.Files["127_0_0_1/uptime.txt"] = FileEntry{SHA256: "abc", Ref: base}
+	manifest.Files["127_0_0_1/dmesg.txt"] = FileEntry{SHA256: "def"}
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	manifest.Objects[ObjectID(pods, "kube-system", "etcd")] = ObjectEntry{
+		ResourceVersion: "1",
+		Path:            "kubecapture/core_v1/kube-system/pods-2020-01-01T01-00-00Z.0000.json",
+		Ref:             base,
+	}
+	if err := manifest.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestOpenIncremental(t *testing.T) {
+	tests := []struct {
+		name string
+		path func(t *testing.T) string
+	}{
+		{
+			name: "from directory",
+			path: func(t *testing.T) string {
+				return makeIncrementalBundle(t, makeTestBundle(t))
+			},
+		},
+		{
+			name: "from archive next to the archived base",
+			path: func(t *testing.T) string {
+				// the manifest references the base where it was collected
+				outDir := t.TempDir()
+				base := filepath.Join(t.TempDir(), "base.tar.gz")
+				dir := makeIncrementalBundle(t, base)
+				if err := archiver.Tar(filepath.Join(outDir, "base.tar.gz"), makeTestBundle(t)); err != nil {
+					t.Fatal(err)
+				}
+				tarFile := filepath.Join(outDir, "out.tar.gz")
+				if err := archiver.Tar(tarFile, dir); err != nil {
+					t.Fatal(err)
+				}
+				return tarFile
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := test.path(t)
+			b, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.Close()
+
+			files, err := b.CaptureFiles()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 2 {
+				t.Errorf("unexpected files: %v", files)
+			}
+			data, err := os.ReadFile(filepath.Join(b.Workdir(), "127_0_0_1", "uptime.txt"))
+			if err != nil || string(data) != "up 2 days" {
+				t.Errorf("unexpected restored file %q: %v", data, err)
+			}
+
+			index, err := b.KubeObjects()
+			if err != nil {
+				t.Fatal(err)
+			}
+			objs := index.Objects()
+			if len(objs) != 1 || objs[0].Key.Name != "etcd" {
+				t.Errorf("unexpected restored objects: %v", objs)
+			}
+
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				if _, err := os.Stat(filepath.Join(path, "127_0_0_1", "uptime.txt")); !os.IsNotExist(err) {
+					t.Error("restored content written in the opened directory")
+				}
+			}
+		})
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	if _, err := LoadManifest(makeTestBundle(t)); !errors.Is(err, ErrNoManifest) {
+		t.Errorf("expecting ErrNoManifest, got %v", err)
+	}
+
+	base := makeTestBundle(t)
+	manifest, err := LoadManifest(makeIncrementalBundle(t, base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Base != base || len(manifest.Files) != 2 || len(manifest.Objects) != 1 {
+		t.Errorf("unexpected manifest: %+v", manifest)
+	}
+}
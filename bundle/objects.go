@@ -0,0 +1,320 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+)
+
+// captureTimestamp matches the timestamp suffix added to file names by k8s.ObjectWriter
+var captureTimestamp = regexp.MustCompile(`-(\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}Z\.\d{4})$`)
+
+const captureTimeLayout = "2006-01-02T15-04-05Z.0000"
+
+// shortNames maps well known kubectl short names to their resource names
+var shortNames = map[string]string{
+	"cm":     "configmaps",
+	"cs":     "componentstatuses",
+	"deploy": "deployments",
+	"ds":     "daemonsets",
+	"ep":     "endpoints",
+	"ev":     "events",
+	"ing":    "ingresses",
+	"no":     "nodes",
+	"ns":     "namespaces",
+	"po":     "pods",
+	"pv":     "persistentvolumes",
+	"pvc":    "persistentvolumeclaims",
+	"rs":     "replicasets",
+	"sa":     "serviceaccounts",
+	"sts":    "statefulsets",
+	"svc":    "services",
+}
+
+// ObjectKey uniquely identifies a captured Kubernetes object
+type ObjectKey struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+}
+
+func (k ObjectKey) String() string {
+	gvk := k.GroupVersionKind
+	id := fmt.Sprintf("%s/%s", gvk.GroupVersion().String(), gvk.Kind)
+	if k.Namespace != "" {
+		return fmt.Sprintf("%s %s/%s", id, k.Namespace, k.Name)
+	}
+	return fmt.Sprintf("%s %s", id, k.Name)
+}
+
+// Object is a Kubernetes object read from the kube_capture output of a bundle
+type Object struct {
+	Key                  ObjectKey
+	GroupVersionResource schema.GroupVersionResource
+	// Path is the file, relative to the bundle root, the object was read from
+	Path       string
+	CapturedAt time.Time
+	Object     *unstructured.Unstructured
+}
+
+// ObjectIndex indexes captured objects by GVK, namespace, and name
+type ObjectIndex struct {
+	objects map[ObjectKey]*Object
+}
+
+// KubeObjects walks the bundle and indexes every object saved by kube_capture.
+// When the same object was captured more than once, the latest capture is kept.
+func (b *Bundle) KubeObjects() (*ObjectIndex, error) {
+	index := &ObjectIndex{objects: make(map[ObjectKey]*Object)}
+
+	err := filepath.Walk(b.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isObjectFile(path) {
+			return nil
+		}
+		captureDir := findCaptureDir(path)
+		if captureDir == "" {
+			return nil
+		}
+
+		objs, err := readObjectFile(path, captureDir)
+		if err != nil {
+			logrus.Debugf("bundle: skipping %s: %s", path, err)
+			return nil
+		}
+		for _, obj := range objs {
+			obj.Path = b.Rel(path)
+			index.add(obj)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bundle: failed to read objects: %w", err)
+	}
+
+	logrus.Debugf("bundle: indexed %d objects from %s", index.Len(), b.source)
+	return index, nil
+}
+
+func (idx *ObjectIndex) add(obj *Object) {
+	if prev, ok := idx.objects[obj.Key]; ok && prev.CapturedAt.After(obj.CapturedAt) {
+		return
+	}
+	idx.objects[obj.Key] = obj
+}
+
+// Len returns the number of indexed objects
+func (idx *ObjectIndex) Len() int {
+	return len(idx.objects)
+}
+
+// Get returns the object stored with key, if any
+func (idx *ObjectIndex) Get(key ObjectKey) (*Object, bool) {
+	obj, ok := idx.objects[key]
+	return obj, ok
+}
+
+// Objects returns all indexed objects sorted by group, kind, namespace, and name
+func (idx *ObjectIndex) Objects() []*Object {
+	result := make([]*Object, 0, len(idx.objects))
+	for _, obj := range idx.objects {
+		result = append(result, obj)
+	}
+	sortObjects(result)
+	return result
+}
+
+// Query selects indexed objects. Empty fields match everything.
+type Query struct {
+	// Resource is matched against the resource name (i.e. pods), the kind,
+	// or the resource qualified with its group (i.e. deployments.apps)
+	Resource   string
+	Namespaces []string
+	Names      []string
+	// Labels are label selector expressions, as used in k8s.SearchParams
+	Labels []string
+}
+
+// Select returns the sorted list of objects matching query q
+func (idx *ObjectIndex) Select(q Query) ([]*Object, error) {
+	selector := labels.Everything()
+	if len(q.Labels) > 0 {
+		sel, err := labels.Parse(strings.Join(q.Labels, ","))
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector: %w", err)
+		}
+		selector = sel
+	}
+
+	var result []*Object
+	for _, obj := range idx.objects {
+		if q.Resource != "" && !obj.MatchesResource(q.Resource) {
+			continue
+		}
+		if len(q.Namespaces) > 0 && obj.Key.Namespace != "" && !containsFold(q.Namespaces, obj.Key.Namespace) {
+			continue
+		}
+		if len(q.Names) > 0 && !containsFold(q.Names, obj.Key.Name) {
+			continue
+		}
+		if !selector.Matches(labels.Set(obj.Object.GetLabels())) {
+			continue
+		}
+		result = append(result, obj)
+	}
+	sortObjects(result)
+	return result, nil
+}
+
+// MatchesResource returns true if res names the resource, short name, kind,
+// or group-qualified resource of the object
+func (o *Object) MatchesResource(res string) bool {
+	res = strings.ToLower(res)
+	name, group, _ := strings.Cut(res, ".")
+	if long, ok := shortNames[name]; ok {
+		name = long
+	}
+	if group != "" && !strings.EqualFold(group, o.Key.GroupVersionKind.Group) {
+		return false
+	}
+	kind := strings.ToLower(o.Key.GroupVersionKind.Kind)
+	resource := strings.ToLower(o.GroupVersionResource.Resource)
+	return name == kind || name == resource || name+"s" == resource
+}
+
+func sortObjects(objs []*Object) {
+	sort.Slice(objs, func(i, j int) bool {
+		a, b := objs[i].Key, objs[j].Key
+		if a.GroupVersionKind.Group != b.GroupVersionKind.Group {
+			return a.GroupVersionKind.Group < b.GroupVersionKind.Group
+		}
+		if a.GroupVersionKind.Kind != b.GroupVersionKind.Kind {
+			return a.GroupVersionKind.Kind < b.GroupVersionKind.Kind
+		}
+		if a.GroupVersionKind.Version != b.GroupVersionKind.Version {
+			return a.GroupVersionKind.Version < b.GroupVersionKind.Version
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+func isObjectFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".json", ".yaml":
+		return true
+	}
+	return false
+}
+
+// findCaptureDir returns the kube_capture base directory containing path
+func findCaptureDir(path string) string {
+	for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if filepath.Base(dir) == k8s.BaseDirname {
+			return dir
+		}
+	}
+	return ""
+}
+
+// readObjectFile decodes a file saved by k8s.ObjectWriter. The file either
+// contains a list of objects (single_file mode) named <resource>-<timestamp>
+// or a single object (multiple_files mode) stored in directory <resource>.
+func readObjectFile(path, captureDir string) ([]*Object, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err = utilyaml.ToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := runtime.Decode(unstructured.UnstructuredJSONScheme, data)
+	if err != nil {
+		return nil, err
+	}
+
+	baseName := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	capturedAt := time.Time{}
+	if match := captureTimestamp.FindStringSubmatch(baseName); match != nil {
+		if t, err := time.Parse(captureTimeLayout, match[1]); err == nil {
+			capturedAt = t
+		}
+		baseName = strings.TrimSuffix(baseName, match[0])
+	}
+
+	var items []unstructured.Unstructured
+	var resource string
+	switch val := decoded.(type) {
+	case *unstructured.UnstructuredList:
+		items = val.Items
+		resource = baseName
+	case *unstructured.Unstructured:
+		items = []unstructured.Unstructured{*val}
+		resource = filepath.Base(filepath.Dir(path))
+	default:
+		return nil, fmt.Errorf("unexpected content %T", decoded)
+	}
+
+	// fall back to the group/version directory name when items lack apiVersion
+	rel, _ := filepath.Rel(captureDir, path)
+	dirGV := schema.GroupVersion{}
+	if grp, ver, ok := strings.Cut(strings.Split(filepath.ToSlash(rel), "/")[0], "_"); ok {
+		dirGV = schema.GroupVersion{Group: grp, Version: ver}
+		if grp == "core" {
+			dirGV.Group = ""
+		}
+	}
+
+	var result []*Object
+	for i := range items {
+		item := &items[i]
+		gvk := item.GroupVersionKind()
+		if gvk.Version == "" {
+			gvk.Group, gvk.Version = dirGV.Group, dirGV.Version
+		}
+		if gvk.Kind == "" || item.GetName() == "" {
+			continue
+		}
+		result = append(result, &Object{
+			Key: ObjectKey{
+				GroupVersionKind: gvk,
+				Namespace:        item.GetNamespace(),
+				Name:             item.GetName(),
+			},
+			GroupVersionResource: gvk.GroupVersion().WithResource(resource),
+			CapturedAt:           capturedAt,
+			Object:               item,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+)
+
+// NewObjectIndex indexes the objects of search results, such as the
+// results returned by k8s.Client.Search for a live cluster
+func NewObjectIndex(results []k8s.SearchResult) *ObjectIndex {
+	index := &ObjectIndex{objects: make(map[ObjectKey]*Object)}
+	for _, sr := range results {
+		if sr.List == nil {
+			continue
+		}
+		for i := range sr.List.Items {
+			item := sr.List.Items[i].DeepCopy()
+			gvk := item.GroupVersionKind()
+			if gvk.Version == "" {
+				gvk = sr.GroupVersionResource.GroupVersion().WithKind(sr.ResourceKind)
+			}
+			if gvk.Kind == "" || item.GetName() == "" {
+				continue
+			}
+			index.add(&Object{
+				Key: ObjectKey{
+					GroupVersionKind: gvk,
+					Namespace:        item.GetNamespace(),
+					Name:             item.GetName(),
+				},
+				GroupVersionResource: sr.GroupVersionResource,
+				Object:               item,
+			})
+		}
+	}
+	return index
+}
+
+// SearchResults returns the indexed objects grouped by resource and namespace,
+// in the same form as the results of k8s.Client.Search
+func (idx *ObjectIndex) SearchResults() []k8s.SearchResult {
+	type groupID struct {
+		resource  schema.GroupVersionResource
+		namespace string
+	}
+	var results []k8s.SearchResult
+	groups := make(map[groupID]int)
+	for _, obj := range idx.Objects() {
+		id := groupID{resource: obj.GroupVersionResource, namespace: obj.Key.Namespace}
+		i, ok := groups[id]
+		if !ok {
+			listKind := obj.Key.GroupVersionKind.Kind + "List"
+			list := &unstructured.UnstructuredList{Object: map[string]interface{}{
+				"apiVersion": obj.GroupVersionResource.GroupVersion().String(),
+				"kind":       listKind,
+			}}
+			results = append(results, k8s.SearchResult{
+				ListKind:             listKind,
+				ResourceName:         obj.GroupVersionResource.Resource,
+				ResourceKind:         obj.Key.GroupVersionKind.Kind,
+				GroupVersionResource: obj.GroupVersionResource,
+				List:                 list,
+				Namespaced:           obj.Key.Namespace != "",
+				Namespace:            obj.Key.Namespace,
+			})
+			i = len(results) - 1
+			groups[id] = i
+		}
+		results[i].List.Items = append(results[i].List.Items, *obj.Object.DeepCopy())
+	}
+	return results
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"testing"
+)
+
+func TestSearchResults(t *testing.T) {
+	b, err := Open(makeTestBundle(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := b.KubeObjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := index.SearchResults()
+	// nodes, pods in kube-system, and deployments in default
+	if len(results) != 3 {
+		t.Fatalf("expected 3 search results, got %d", len(results))
+	}
+	pods := results[1]
+	if pods.ResourceName != "pods" || pods.ListKind != "PodList" || pods.Namespace != "kube-system" || !pods.Namespaced {
+		t.Errorf("unexpected search result: %+v", pods)
+	}
+	if len(pods.List.Items) != 2 {
+		t.Errorf("expected 2 pods, got %d", len(pods.List.Items))
+	}
+
+	rebuilt := NewObjectIndex(results)
+	if rebuilt.Len() != index.Len() {
+		t.Fatalf("expected %d objects, got %d", index.Len(), rebuilt.Len())
+	}
+	for _, obj := range index.Objects() {
+		found, ok := rebuilt.Get(obj.Key)
+		if !ok {
+			t.Errorf("object %s not found", obj.Key)
+			continue
+		}
+		if found.GroupVersionResource != obj.GroupVersionResource || found.Path != "" {
+			t.Errorf("unexpected object %s: %+v", obj.Key, found)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// tableColumn describes a column and how its cell is computed from an object
+type tableColumn struct {
+	name     string
+	priority int32
+	cell     func(obj *unstructured.Unstructured) interface{}
+}
+
+var (
+	nameColumn = tableColumn{name: "Name", cell: func(obj *unstructured.Unstructured) interface{} {
+		return obj.GetName()
+	}}
+
+	// columns used for well known kinds, mirroring the defaults of kubectl.
+	// Columns with priority > 0 are only displayed for wide output.
+	kindColumns = map[string][]tableColumn{
+		"Pod": {
+			nameColumn,
+			{name: "Ready", cell: podReady},
+			{name: "Status", cell: func(obj *unstructured.Unstructured) interface{} { return PodStatus(obj) }},
+			{name: "Restarts", cell: func(obj *unstructured.Unstructured) interface{} { return PodRestarts(obj) }},
+			{name: "Age"},
+			{name: "IP", priority: 1, cell: nestedString("status", "podIP")},
+			{name: "Node", priority: 1, cell: nestedString("spec", "nodeName")},
+		},
+		"Node": {
+			nameColumn,
+			{name: "Status", cell: func(obj *unstructured.Unstructured) interface{} { return NodeStatus(obj) }},
+			{name: "Roles", cell: nodeRoles},
+			{name: "Age"},
+			{name: "Version", cell: nestedString("status", "nodeInfo", "kubeletVersion")},
+			{name: "Internal-IP", priority: 1, cell: nodeInternalIP},
+			{name: "OS-Image", priority: 1, cell: nestedString("status", "nodeInfo", "osImage")},
+			{name: "Kernel-Version", priority: 1, cell: nestedString("status", "nodeInfo", "kernelVersion")},
+			{name: "Container-Runtime", priority: 1, cell: nestedString("status", "nodeInfo", "containerRuntimeVersion")},
+		},
+		"Deployment": {
+			nameColumn,
+			{name: "Ready", cell: func(obj *unstructured.Unstructured) interface{} {
+				ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+				desired, _, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+				return fmt.Sprintf("%d/%d", ready, desired)
+			}},
+			{name: "Up-to-date", cell: nestedInt("status", "updatedReplicas")},
+			{name: "Available", cell: nestedInt("status", "availableReplicas")},
+			{name: "Age"},
+		},
+		"Service": {
+			nameColumn,
+			{name: "Type", cell: nestedString("spec", "type")},
+			{name: "Cluster-IP", cell: nestedString("spec", "clusterIP")},
+			{name: "External-IP", cell: serviceExternalIP},
+			{name: "Port(s)", cell: servicePorts},
+			{name: "Age"},
+		},
+		"Event": {
+			{name: "Last Seen", cell: nestedString("lastTimestamp")},
+			{name: "Type", cell: nestedString("type")},
+			{name: "Reason", cell: nestedString("reason")},
+			{name: "Object", cell: func(obj *unstructured.Unstructured) interface{} {
+				kind, _, _ := unstructured.NestedString(obj.Object, "involvedObject", "kind")
+				name, _, _ := unstructured.NestedString(obj.Object, "involvedObject", "name")
+				return fmt.Sprintf("%s/%s", strings.ToLower(kind), name)
+			}},
+			{name: "Message", cell: nestedString("message")},
+		},
+	}
+
+	defaultColumns = []tableColumn{nameColumn, {name: "Age"}}
+)
+
+// Table converts objs, expected to share the same kind, into a table that can
+// be displayed with a printer created by printers.NewTablePrinter.
+// Ages are computed relative to the time each object was captured.
+func Table(objs []*Object) *metav1.Table {
+	table := &metav1.Table{}
+	if len(objs) == 0 {
+		return table
+	}
+
+	columns, ok := kindColumns[objs[0].Key.GroupVersionKind.Kind]
+	if !ok {
+		columns = defaultColumns
+	}
+	for _, col := range columns {
+		format := ""
+		if col.name == nameColumn.name {
+			format = "name"
+		}
+		table.ColumnDefinitions = append(table.ColumnDefinitions, metav1.TableColumnDefinition{
+			Name:     col.name,
+			Type:     "string",
+			Format:   format,
+			Priority: col.priority,
+		})
+	}
+
+	for _, obj := range objs {
+		row := metav1.TableRow{Object: runtime.RawExtension{Object: obj.Object}}
+		for _, col := range columns {
+			if col.cell == nil {
+				row.Cells = append(row.Cells, age(obj))
+				continue
+			}
+			row.Cells = append(row.Cells, col.cell(obj.Object))
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table
+}
+
+func age(obj *Object) string {
+	created := obj.Object.GetCreationTimestamp()
+	if created.IsZero() {
+		return "<unknown>"
+	}
+	now := obj.CapturedAt
+	if now.IsZero() {
+		now = time.Now()
+	}
+	return duration.HumanDuration(now.Sub(created.Time))
+}
+
+func nestedString(fields ...string) func(*unstructured.Unstructured) interface{} {
+	return func(obj *unstructured.Unstructured) interface{} {
+		val, found, err := unstructured.NestedFieldNoCopy(obj.Object, fields...)
+		if !found || err != nil || val == nil {
+			return "<none>"
+		}
+		return fmt.Sprint(val)
+	}
+}
+
+func nestedInt(fields ...string) func(*unstructured.Unstructured) interface{} {
+	return func(obj *unstructured.Unstructured) interface{} {
+		val, _, _ := unstructured.NestedInt64(obj.Object, fields...)
+		return val
+	}
+}
+
+func podReady(obj *unstructured.Unstructured) interface{} {
+	statuses, _, _ := unstructured.NestedSlice(obj.Object, "status", "containerStatuses")
+	containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "containers")
+	ready := 0
+	for _, s := range statuses {
+		if status, ok := s.(map[string]interface{}); ok {
+			if r, _, _ := unstructured.NestedBool(status, "ready"); r {
+				ready++
+			}
+		}
+	}
+	return fmt.Sprintf("%d/%d", ready, len(containers))
+}
+
+// PodStatus returns the status of a pod object as displayed by kubectl,
+// which favors container waiting or termination reasons over the pod phase
+func PodStatus(obj *unstructured.Unstructured) string {
+	status, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	if reason, _, _ := unstructured.NestedString(obj.Object, "status", "reason"); reason != "" {
+		status = reason
+	}
+
+	statuses, _, _ := unstructured.NestedSlice(obj.Object, "status", "containerStatuses")
+	for _, s := range statuses {
+		cs, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if reason, _, _ := unstructured.NestedString(cs, "state", "waiting", "reason"); reason != "" {
+			status = reason
+		} else if reason, _, _ := unstructured.NestedString(cs, "state", "terminated", "reason"); reason != "" {
+			status = reason
+		}
+	}
+
+	if obj.GetDeletionTimestamp() != nil {
+		status = "Terminating"
+	}
+	return status
+}
+
+// PodRestarts returns the sum of container restarts for a pod object
+func PodRestarts(obj *unstructured.Unstructured) int64 {
+	var restarts int64
+	statuses, _, _ := unstructured.NestedSlice(obj.Object, "status", "containerStatuses")
+	for _, s := range statuses {
+		if cs, ok := s.(map[string]interface{}); ok {
+			count, _, _ := unstructured.NestedInt64(cs, "restartCount")
+			restarts += count
+		}
+	}
+	return restarts
+}
+
+// NodeStatus returns the readiness of a node object as displayed by kubectl
+func NodeStatus(obj *unstructured.Unstructured) string {
+	status := "Unknown"
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok || cond["type"] != "Ready" {
+			continue
+		}
+		switch cond["status"] {
+		case "True":
+			status = "Ready"
+		default:
+			status = "NotReady"
+		}
+	}
+	if unschedulable, _, _ := unstructured.NestedBool(obj.Object, "spec", "unschedulable"); unschedulable {
+		status += ",SchedulingDisabled"
+	}
+	return status
+}
+
+func nodeRoles(obj *unstructured.Unstructured) interface{} {
+	var roles []string
+	for label := range obj.GetLabels() {
+		if role, ok := strings.CutPrefix(label, "node-role.kubernetes.io/"); ok && role != "" {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 {
+		return "<none>"
+	}
+	sort.Strings(roles)
+	return strings.Join(roles, ",")
+}
+
+func nodeInternalIP(obj *unstructured.Unstructured) interface{} {
+	addresses, _, _ := unstructured.NestedSlice(obj.Object, "status", "addresses")
+	for _, a := range addresses {
+		if addr, ok := a.(map[string]interface{}); ok && addr["type"] == "InternalIP" {
+			return fmt.Sprint(addr["address"])
+		}
+	}
+	return "<none>"
+}
+
+func serviceExternalIP(obj *unstructured.Unstructured) interface{} {
+	var ips []string
+	ingresses, _, _ := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
+	for _, i := range ingresses {
+		if ingress, ok := i.(map[string]interface{}); ok {
+			if ip, ok := ingress["ip"].(string); ok {
+				ips = append(ips, ip)
+			} else if host, ok := ingress["hostname"].(string); ok {
+				ips = append(ips, host)
+			}
+		}
+	}
+	externals, _, _ := unstructured.NestedStringSlice(obj.Object, "spec", "externalIPs")
+	ips = append(ips, externals...)
+	if len(ips) == 0 {
+		return "<none>"
+	}
+	return strings.Join(ips, ",")
+}
+
+func servicePorts(obj *unstructured.Unstructured) interface{} {
+	var ports []string
+	specPorts, _, _ := unstructured.NestedSlice(obj.Object, "spec", "ports")
+	for _, p := range specPorts {
+		port, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		str := fmt.Sprintf("%v", port["port"])
+		if nodePort, ok := port["nodePort"]; ok {
+			str = fmt.Sprintf("%s:%v", str, nodePort)
+		}
+		ports = append(ports, fmt.Sprintf("%s/%v", str, port["protocol"]))
+	}
+	if len(ports) == 0 {
+		return "<none>"
+	}
+	return strings.Join(ports, ",")
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package certs inspects the X.509 certificates of a cluster, found in kubeconfigs, TLS
+// secrets, or the files of its nodes, and reports their expiration.
+package certs
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware-tanzu/crash-diagnostics/ssh"
+)
+
+// Certificate describes a certificate and where it was found
+type Certificate struct {
+	// Source locates the certificate, i.e. secret:kube-system/etcd:tls.crt
+	Source        string
+	Subject       string
+	Issuer        string
+	SANs          []string
+	NotBefore     time.Time
+	NotAfter      time.Time
+	DaysRemaining int
+}
+
+// Expired returns true if c is expired
+func (c Certificate) Expired() bool {
+	return c.DaysRemaining < 0
+}
+
+// Parse returns the certificates of the PEM blocks of data, found at source, with their
+// days remaining at now. Blocks of other types, i.e. private keys, are ignored.
+func Parse(source string, data []byte, now time.Time) ([]Certificate, error) {
+	var certs []Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return certs, fmt.Errorf("%s: %w", source, err)
+		}
+		certs = append(certs, newCertificate(source, cert, now))
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s: no PEM certificate found", source)
+	}
+	return certs, nil
+}
+
+func newCertificate(source string, cert *x509.Certificate, now time.Time) Certificate {
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return Certificate{
+		Source:        source,
+		Subject:       cert.Subject.String(),
+		Issuer:        cert.Issuer.String(),
+		SANs:          sans,
+		NotBefore:     cert.NotBefore,
+		NotAfter:      cert.NotAfter,
+		DaysRemaining: int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24)),
+	}
+}
+
+// Sort orders certs by expiration, the first to expire first
+func Sort(certs []Certificate) {
+	sort.SliceStable(certs, func(i, j int) bool {
+		return certs[i].NotAfter.Before(certs[j].NotAfter)
+	})
+}
+
+// fileMarker precedes the content of each file in the output of ReadCmd
+const fileMarker = "--crashd-cert-file--"
+
+// ReadCmd returns the shell command printing the certificates of the files in paths, or
+// under paths if they are directories, each preceded by a line with the path of the file.
+// Only the PEM certificate blocks are printed, leaving out the private keys of the files.
+func ReadCmd(paths []string) string {
+	quoted := make([]string, len(paths))
+	for i, path := range paths {
+		quoted[i] = ssh.Quote(path)
+	}
+	return fmt.Sprintf(`find %s -type f \( -name '*.crt' -o -name '*.pem' -o -name '*.cert' \) 2>/dev/null | sort | while read -r f; do echo "%s $f"; sed -n '/-----BEGIN CERTIFICATE-----/,/-----END CERTIFICATE-----/p' "$f"; done`,
+		strings.Join(quoted, " "), fileMarker)
+}
+
+// File is a file read by ReadCmd
+type File struct {
+	Path string
+	Data []byte
+}
+
+// ParseFiles returns the files of the output of ReadCmd
+func ParseFiles(output string) ([]File, error) {
+	var files []File
+	for _, line := range strings.Split(output, "\n") {
+		if path, ok := strings.CutPrefix(line, fileMarker+" "); ok {
+			files = append(files, File{Path: path})
+			continue
+		}
+		if len(files) == 0 {
+			if strings.TrimSpace(line) != "" {
+				return nil, errors.New("unexpected output, missing file path")
+			}
+			continue
+		}
+		last := &files[len(files)-1]
+		last.Data = append(last.Data, line+"\n"...)
+	}
+	return files, nil
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package certs
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestCert returns a PEM certificate for cn expiring at notAfter, and its PEM private key
+func newTestCert(t *testing.T, cn string, notAfter time.Time) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn, "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+		NotBefore:    notAfter.AddDate(-1, 0, 0),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func TestParse(t *testing.T) {
+	apiserver, key := newTestCert(t, "kube-apiserver", testNow.Add(10*24*time.Hour+time.Hour))
+	expired, _ := newTestCert(t, "etcd", testNow.Add(-36*time.Hour))
+
+	certs, err := Parse("node:pki", append(append(key, apiserver...), expired...), testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 2 {
+		t.Fatalf("unexpected certificates: %d", len(certs))
+	}
+	if certs[0].Subject != "CN=kube-apiserver" || certs[0].Issuer != "CN=kube-apiserver" || certs[0].Source != "node:pki" {
+		t.Errorf("unexpected certificate: %+v", certs[0])
+	}
+	if strings.Join(certs[0].SANs, ",") != "kube-apiserver,localhost,10.0.0.1" {
+		t.Errorf("unexpected SANs: %v", certs[0].SANs)
+	}
+	if certs[0].DaysRemaining != 10 || certs[0].Expired() {
+		t.Errorf("unexpected days remaining: %d", certs[0].DaysRemaining)
+	}
+	if certs[1].DaysRemaining != -2 || !certs[1].Expired() {
+		t.Errorf("unexpected days remaining: %d", certs[1].DaysRemaining)
+	}
+
+	Sort(certs)
+	if certs[0].Subject != "CN=etcd" {
+		t.Errorf("unexpected order: %s first", certs[0].Subject)
+	}
+
+	if _, err := Parse("key", key, testNow); err == nil {
+		t.Error("expected error without certificate")
+	}
+	invalid := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("invalid")})
+	if _, err := Parse("invalid", invalid, testNow); err == nil {
+		t.Error("expected error for invalid certificate")
+	}
+}
+
+func TestReadCmd(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := newTestCert(t, "kubelet", testNow)
+	if err := os.MkdirAll(filepath.Join(dir, "etcd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{
+		"ca.crt":                     cert,
+		"ca.key":                     key,
+		"etcd/server.crt":            cert,
+		"kubelet-client-current.pem": append(append([]byte{}, key...), cert...),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := exec.Command("sh", "-c", ReadCmd([]string{dir, filepath.Join(dir, "missing")})).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s: %s", err, out)
+	}
+	if strings.Contains(string(out), "PRIVATE KEY") {
+		t.Errorf("private key read:\n%s", out)
+	}
+	files, err := ParseFiles(strings.TrimSpace(string(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, strings.TrimPrefix(file.Path, dir+"/"))
+		if !bytes.Equal(file.Data, cert) {
+			t.Errorf("%s: unexpected content:\n%s", file.Path, file.Data)
+		}
+	}
+	if strings.Join(paths, ",") != "ca.crt,etcd/server.crt,kubelet-client-current.pem" {
+		t.Errorf("unexpected files: %v", paths)
+	}
+
+	if _, err := ParseFiles("garbage"); err == nil {
+		t.Error("expected error for output without file path")
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	cert, _ := newTestCert(t, "front-proxy-client", testNow.Add(100*24*time.Hour))
+	expiring, _ := newTestCert(t, "apiserver", testNow.Add(20*24*time.Hour))
+	expired, _ := newTestCert(t, "etcd", testNow.Add(-24*time.Hour))
+	certs, err := Parse("pki", append(append(cert, expiring...), expired...), testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSummary(&buf, certs, []error{errors.New("secret:default/web: no PEM certificate found")}, 30); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	for i, prefix := range []string{"STATUS", "EXPIRED", "EXPIRING", "OK"} {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("line %d: expecting %s, got %q", i, prefix, lines[i])
+		}
+	}
+	for _, expected := range []string{"3 certificate(s): 1 expired, 1 expiring within 30 days", "1 error(s):", "secret:default/web"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("summary missing %q:\n%s", expected, buf.String())
+		}
+	}
+}
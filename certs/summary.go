@@ -0,0 +1,49 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package certs
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// SummaryFileName is the name of the summary file saved in the working directory
+const SummaryFileName = "cert_check.txt"
+
+// WriteSummary writes a table of certs, expired first, flagging those expiring within
+// warnDays days, followed by errs, the errors of the certificates that could not be read
+func WriteSummary(w io.Writer, certs []Certificate, errs []error, warnDays int) error {
+	sorted := append([]Certificate{}, certs...)
+	Sort(sorted)
+
+	expired, expiring := 0, 0
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tDAYS\tNOT AFTER\tSUBJECT\tSOURCE")
+	for _, cert := range sorted {
+		status := "OK"
+		switch {
+		case cert.Expired():
+			status = "EXPIRED"
+			expired++
+		case cert.DaysRemaining <= warnDays:
+			status = "EXPIRING"
+			expiring++
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", status, cert.DaysRemaining, cert.NotAfter.UTC().Format(time.RFC3339), cert.Subject, cert.Source)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\n%d certificate(s): %d expired, %d expiring within %d days\n", len(certs), expired, expiring, warnDays)
+	if len(errs) > 0 {
+		fmt.Fprintf(w, "\n%d error(s):\n", len(errs))
+		for _, err := range errs {
+			fmt.Fprintf(w, "  %s\n", err)
+		}
+	}
+	return nil
+}
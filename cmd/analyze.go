@@ -0,0 +1,118 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vmware-tanzu/crash-diagnostics/analyzer"
+	"github.com/vmware-tanzu/crash-diagnostics/bundle"
+	"github.com/vmware-tanzu/crash-diagnostics/starlark"
+)
+
+type analyzeFlags struct {
+	output    string
+	rules     []string
+	rulesDir  string
+	listRules bool
+}
+
+func defaultAnalyzeFlags() *analyzeFlags {
+	return &analyzeFlags{
+		output: "text",
+	}
+}
+
+// newAnalyzeCommand creates a command to report common failures found in a bundle
+func newAnalyzeCommand() *cobra.Command {
+	flags := defaultAnalyzeFlags()
+
+	cmd := &cobra.Command{
+		Args:  cobra.MaximumNArgs(1),
+		Use:   "analyze <archive.tar.gz|dir>",
+		Short: "reports failures found in a bundle",
+		Long:  "Applies analyzer rules to the Kubernetes objects, logs, and command outputs of a crashd archive or working directory and reports the findings",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flags.listRules {
+				return listRules(flags, cmd.OutOrStdout())
+			}
+			if len(args) != 1 {
+				return fmt.Errorf("analyze: a bundle path is required")
+			}
+			return analyze(flags, cmd.OutOrStdout(), args[0])
+		},
+	}
+	cmd.Flags().StringVarP(&flags.output, "output", "o", flags.output, "output format: text or json")
+	cmd.Flags().StringSliceVar(&flags.rules, "rules", flags.rules, "names of the rules to apply (default all)")
+	cmd.Flags().StringVar(&flags.rulesDir, "rules-dir", flags.rulesDir, "directory of Starlark files (*.star) defining additional rules with analyzer_rule()")
+	cmd.Flags().BoolVar(&flags.listRules, "list-rules", flags.listRules, "list the available rules")
+	return cmd
+}
+
+func analyze(flags *analyzeFlags, out io.Writer, path string) error {
+	if flags.output != "text" && flags.output != "json" {
+		return fmt.Errorf("unsupported output format: %s", flags.output)
+	}
+
+	rules, err := loadRules(flags)
+	if err != nil {
+		return err
+	}
+	if rules, err = analyzer.Select(rules, flags.rules...); err != nil {
+		return err
+	}
+
+	b, err := bundle.Open(path)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	input, err := analyzer.NewInput(b)
+	if err != nil {
+		return fmt.Errorf("analyze failed: %w", err)
+	}
+	report := analyzer.Analyze(b.Source(), input, rules...)
+
+	if flags.output == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return report.WriteText(out)
+}
+
+// loadRules returns the built-in rules and the rules loaded from the rules directory, if any
+func loadRules(flags *analyzeFlags) ([]analyzer.Rule, error) {
+	rules, err := analyzer.Rules()
+	if err != nil {
+		return nil, err
+	}
+	if len(flags.rulesDir) == 0 {
+		return rules, nil
+	}
+	custom, err := starlark.LoadAnalyzerRules(flags.rulesDir)
+	if err != nil {
+		return nil, err
+	}
+	return append(rules, custom...), nil
+}
+
+func listRules(flags *analyzeFlags, out io.Writer) error {
+	rules, err := loadRules(flags)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDESCRIPTION")
+	for _, r := range rules {
+		fmt.Fprintf(w, "%s\t%s\n", r.Name(), r.Description())
+	}
+	return w.Flush()
+}
@@ -54,6 +54,13 @@ func crashDiagnosticsCommand() *cobra.Command {
 	)
 
 	cmd.AddCommand(newRunCommand())
+	cmd.AddCommand(newInspectCommand())
+	cmd.AddCommand(newDiffCommand())
+	cmd.AddCommand(newAnalyzeCommand())
+	cmd.AddCommand(newServeCommand())
+	cmd.AddCommand(newScheduleCommand())
+	cmd.AddCommand(newTriggerCommand())
+	cmd.AddCommand(newDecryptCommand())
 	cmd.AddCommand(newBuildinfoCommand())
 	return cmd
 }
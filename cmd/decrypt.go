@@ -0,0 +1,106 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/vmware-tanzu/crash-diagnostics/archiver"
+)
+
+// passphraseEnv is the environment variable of the passphrase of the archives
+const passphraseEnv = "CRASHD_PASSPHRASE"
+
+type decryptFlags struct {
+	identities []string
+	output     string
+	extract    string
+}
+
+func defaultDecryptFlags() *decryptFlags {
+	return &decryptFlags{}
+}
+
+// newDecryptCommand creates a command to decrypt an encrypted archive
+func newDecryptCommand() *cobra.Command {
+	flags := defaultDecryptFlags()
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "decrypt <archive>",
+		Short: "decrypts an encrypted archive",
+		Long: "Decrypts an archive encrypted by archive(recipients=...) with the identity files of its recipients, " +
+			"or by archive(passphrase=...) with the passphrase of " + passphraseEnv + " or prompted",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return decrypt(flags, cmd.InOrStdin(), cmd.ErrOrStderr(), args[0])
+		},
+	}
+	cmd.Flags().StringSliceVarP(&flags.identities, "identity", "i", flags.identities, "file of an age secret key or an unencrypted SSH private key (repeatable)")
+	cmd.Flags().StringVarP(&flags.output, "output", "o", flags.output, "path of the decrypted archive (default the archive path without .age)")
+	cmd.Flags().StringVarP(&flags.extract, "extract", "x", flags.extract, "extracts the decrypted archive into this directory instead of saving it")
+	return cmd
+}
+
+func decrypt(flags *decryptFlags, in io.Reader, prompt io.Writer, path string) error {
+	dec := archiver.Decryption{IdentityFiles: flags.identities, Passphrase: os.Getenv(passphraseEnv)}
+	if len(dec.IdentityFiles) == 0 && dec.Passphrase == "" {
+		passphrase, err := readPassphrase(in, prompt)
+		if err != nil {
+			return err
+		}
+		dec.Passphrase = passphrase
+	}
+
+	if flags.extract != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader, err := archiver.Decrypt(file, dec)
+		if err != nil {
+			return err
+		}
+		if err := archiver.UntarStream(reader, flags.extract); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", path, err)
+		}
+		logrus.Infof("Extracted %s in %s", path, flags.extract)
+		return nil
+	}
+
+	output := flags.output
+	if output == "" {
+		output = strings.TrimSuffix(path, archiver.EncryptedExt)
+		if output == path {
+			return fmt.Errorf("%s does not end with %s, output path required", path, archiver.EncryptedExt)
+		}
+	}
+	if err := archiver.DecryptFile(path, output, dec); err != nil {
+		return err
+	}
+	logrus.Infof("Decrypted %s to %s", path, output)
+	return nil
+}
+
+// readPassphrase prompts for the passphrase when in is a terminal
+func readPassphrase(in io.Reader, prompt io.Writer) (string, error) {
+	file, ok := in.(*os.File)
+	if !ok || !term.IsTerminal(int(file.Fd())) {
+		return "", fmt.Errorf("identity files or passphrase (%s) required", passphraseEnv)
+	}
+	fmt.Fprint(prompt, "Passphrase: ")
+	passphrase, err := term.ReadPassword(int(file.Fd()))
+	fmt.Fprintln(prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return string(passphrase), nil
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vmware-tanzu/crash-diagnostics/bundle"
+)
+
+type diffFlags struct {
+	output        string
+	ignoredFields []string
+}
+
+func defaultDiffFlags() *diffFlags {
+	return &diffFlags{
+		output: "text",
+	}
+}
+
+// newDiffCommand creates a command to compare two bundles
+func newDiffCommand() *cobra.Command {
+	flags := defaultDiffFlags()
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(2),
+		Use:   "diff <bundleA> <bundleB>",
+		Short: "compares two bundles",
+		Long:  "Reports the Kubernetes objects and capture files added, removed, or changed between two crashd archives or working directories",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return diff(flags, cmd.OutOrStdout(), args[0], args[1])
+		},
+	}
+	cmd.Flags().StringVarP(&flags.output, "output", "o", flags.output, "output format: text or json")
+	cmd.Flags().StringSliceVar(&flags.ignoredFields, "ignore-field", flags.ignoredFields, "additional object field paths to ignore (i.e. --ignore-field status.conditions)")
+	return cmd
+}
+
+func diff(flags *diffFlags, out io.Writer, pathA, pathB string) error {
+	if flags.output != "text" && flags.output != "json" {
+		return fmt.Errorf("unsupported output format: %s", flags.output)
+	}
+
+	from, err := bundle.Open(pathA)
+	if err != nil {
+		return err
+	}
+	defer from.Close()
+
+	to, err := bundle.Open(pathB)
+	if err != nil {
+		return err
+	}
+	defer to.Close()
+
+	report, err := bundle.Diff(from, to, bundle.DiffOptions{IgnoredFields: flags.ignoredFields})
+	if err != nil {
+		return fmt.Errorf("diff failed: %w", err)
+	}
+
+	if flags.output == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return report.WriteText(out)
+}
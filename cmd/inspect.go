@@ -0,0 +1,148 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/printers"
+
+	"github.com/vmware-tanzu/crash-diagnostics/bundle"
+)
+
+type inspectFlags struct {
+	namespace     string
+	allNamespaces bool
+	labels        []string
+	output        string
+	noHeaders     bool
+}
+
+func defaultInspectFlags() *inspectFlags {
+	return &inspectFlags{
+		namespace: "default",
+	}
+}
+
+// newInspectCommand creates a command to query the Kubernetes objects saved in a bundle
+func newInspectCommand() *cobra.Command {
+	flags := defaultInspectFlags()
+
+	cmd := &cobra.Command{
+		Args:  cobra.MinimumNArgs(3),
+		Use:   "inspect <archive.tar.gz|dir> get <resource> [name...]",
+		Short: "queries objects captured in a bundle",
+		Long:  "Displays the Kubernetes objects saved by kube_capture in a crashd archive or working directory, similar to kubectl get",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if args[1] != "get" {
+				return fmt.Errorf("unsupported inspect operation: %s", args[1])
+			}
+			return inspect(flags, cmd.OutOrStdout(), cmd.ErrOrStderr(), args[0], args[2], args[3:])
+		},
+	}
+	cmd.Flags().StringVarP(&flags.namespace, "namespace", "n", flags.namespace, "namespace of the objects to display")
+	cmd.Flags().BoolVarP(&flags.allNamespaces, "all-namespaces", "A", flags.allNamespaces, "display objects from all namespaces")
+	cmd.Flags().StringSliceVarP(&flags.labels, "selector", "l", flags.labels, "label selector expressions used to filter objects (i.e. -l 'app=nginx,tier!=db')")
+	cmd.Flags().StringVarP(&flags.output, "output", "o", flags.output, "output format: wide, yaml, json, or name")
+	cmd.Flags().BoolVar(&flags.noHeaders, "no-headers", flags.noHeaders, "do not print table headers")
+	return cmd
+}
+
+func inspect(flags *inspectFlags, out, errOut io.Writer, path, resource string, names []string) error {
+	b, err := bundle.Open(path)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	index, err := b.KubeObjects()
+	if err != nil {
+		return err
+	}
+
+	query := bundle.Query{Resource: resource, Names: names, Labels: flags.labels}
+	if !flags.allNamespaces {
+		query.Namespaces = []string{flags.namespace}
+	}
+	objs, err := index.Select(query)
+	if err != nil {
+		return err
+	}
+	if len(objs) == 0 {
+		fmt.Fprintf(errOut, "No %s found in %s\n", resource, path)
+		return nil
+	}
+
+	switch flags.output {
+	case "", "wide":
+		return printObjectTables(objs, out, printers.PrintOptions{
+			Wide:          flags.output == "wide",
+			WithNamespace: flags.allNamespaces,
+			NoHeaders:     flags.noHeaders,
+		})
+	case "json":
+		return (&printers.JSONPrinter{}).PrintObj(toPrintable(objs), out)
+	case "yaml":
+		return (&printers.YAMLPrinter{}).PrintObj(toPrintable(objs), out)
+	case "name":
+		printer := &printers.NamePrinter{}
+		for _, obj := range objs {
+			if err := printer.PrintObj(obj.Object, out); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format: %s", flags.output)
+	}
+}
+
+// printObjectTables prints one table for each kind found in objs
+func printObjectTables(objs []*bundle.Object, out io.Writer, opts printers.PrintOptions) error {
+	var kinds []schema.GroupKind
+	groups := make(map[schema.GroupKind][]*bundle.Object)
+	for _, obj := range objs {
+		gk := obj.Key.GroupVersionKind.GroupKind()
+		if _, ok := groups[gk]; !ok {
+			kinds = append(kinds, gk)
+		}
+		groups[gk] = append(groups[gk], obj)
+	}
+
+	for i, gk := range kinds {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		kindOpts := opts
+		if len(kinds) > 1 {
+			kindOpts.WithKind = true
+			kindOpts.Kind = gk
+		}
+		if err := printers.NewTablePrinter(kindOpts).PrintObj(bundle.Table(groups[gk]), out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toPrintable returns the single object, or a list, for structured output
+func toPrintable(objs []*bundle.Object) runtime.Object {
+	if len(objs) == 1 {
+		return objs[0].Object
+	}
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"metadata":   map[string]interface{}{},
+	}}
+	for _, obj := range objs {
+		list.Items = append(list.Items, *obj.Object)
+	}
+	return list
+}
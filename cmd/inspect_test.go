@@ -0,0 +1,29 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Inspect", func() {
+	It("reports missing objects on the error output", func() {
+		dir, err := os.MkdirTemp("", "crashd-inspect")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		var out, errOut bytes.Buffer
+		command := newInspectCommand()
+		command.SetOut(&out)
+		command.SetErr(&errOut)
+		command.SetArgs([]string{dir, "get", "pods"})
+		Expect(command.Execute()).To(Succeed())
+		Expect(out.String()).To(BeEmpty())
+		Expect(errOut.String()).To(Equal("No pods found in " + dir + "\n"))
+	})
+})
@@ -0,0 +1,302 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/vmware-tanzu/crash-diagnostics/exec"
+	"github.com/vmware-tanzu/crash-diagnostics/starlark"
+)
+
+// runTimeLayout is the timestamp in the names of the runs of a collection
+const runTimeLayout = "20060102T150405Z"
+
+type scheduleFlags struct {
+	runFlags
+	collectionFlags
+	every   time.Duration
+	maxRuns int
+}
+
+// collectionFlags configures the runs of a script repeated by schedule or trigger
+type collectionFlags struct {
+	workdir   string
+	outputDir string
+	keep      int
+	stopWhen  string
+}
+
+func defaultCollectionFlags() collectionFlags {
+	return collectionFlags{workdir: filepath.Join(CrashdDir, "runs")}
+}
+
+func (f *collectionFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.workdir, "workdir", f.workdir, "parent directory of the working directories of the runs")
+	cmd.Flags().StringVar(&f.outputDir, "output-dir", f.outputDir, "directory of the archives of the runs (default --workdir)")
+	cmd.Flags().IntVar(&f.keep, "keep", f.keep, "number of most recent runs kept, older archives and working directories are deleted (default keep all)")
+	cmd.Flags().StringVar(&f.stopWhen, "stop-when", f.stopWhen, "Starlark expression evaluated after each run with the global values of the script, and those of the previous run as `previous`; stops when true")
+}
+
+func defaultScheduleFlags() *scheduleFlags {
+	return &scheduleFlags{
+		runFlags:        *defaultRunFlags(),
+		collectionFlags: defaultCollectionFlags(),
+	}
+}
+
+// newScheduleCommand creates a command to run a script repeatedly
+func newScheduleCommand() *cobra.Command {
+	flags := defaultScheduleFlags()
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "schedule --every <duration> <file-name>",
+		Short: "runs a script file repeatedly",
+		Long: "Runs the specified script file at a fixed interval, each run in a new timestamped working directory. " +
+			"The script receives the paths of its working directory and archive as args.workdir and args.output_file.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return schedule(ctx, flags, args[0])
+		},
+	}
+	cmd.Flags().StringToStringVar(&flags.args, "args", flags.args, "comma-separated key=value pairs passed to the script (i.e. --args 'key0=val0,key1=val1')")
+	cmd.Flags().StringVar(&flags.argsFile, "args-file", flags.argsFile, "path to a file containing key=value argument pairs that are passed to the script file")
+	cmd.Flags().BoolVar(&flags.restrictedMode, "restrictedMode", flags.restrictedMode, "run the script in a restricted mode that prevents usage of certain grammar functions")
+	cmd.Flags().DurationVar(&flags.every, "every", flags.every, "interval between the starts of the runs (i.e. 15m)")
+	cmd.Flags().IntVar(&flags.maxRuns, "max-runs", flags.maxRuns, "number of runs after which the schedule stops (default no limit)")
+	flags.collectionFlags.addFlags(cmd)
+	return cmd
+}
+
+func schedule(ctx context.Context, flags *scheduleFlags, path string) error {
+	if flags.every <= 0 {
+		return errors.New("--every must be a positive duration")
+	}
+	c, err := newCollection(&flags.runFlags, flags.collectionFlags, path)
+	if err != nil {
+		return err
+	}
+
+	for {
+		started := time.Now()
+		stop, err := c.run(ctx, nil)
+		if ctx.Err() != nil {
+			logrus.Infof("schedule: stopped after %d run(s)", c.runs)
+			return nil
+		}
+		if err != nil {
+			// a failed run does not end the schedule, the next run may succeed
+			logrus.Errorf("schedule: %s", err)
+		}
+		if stop {
+			logrus.Infof("schedule: stop condition met after %d run(s)", c.runs)
+			return nil
+		}
+		if flags.maxRuns > 0 && c.runs >= flags.maxRuns {
+			return nil
+		}
+
+		// a run longer than the interval delays the next one
+		timer := time.NewTimer(time.Until(started.Add(flags.every)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logrus.Infof("schedule: stopped after %d run(s)", c.runs)
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// collection runs a script repeatedly, each run with a new timestamped working directory
+// and archive, passed to the script as args.workdir and args.output_file
+type collection struct {
+	name           string // name of the runs, the script file name without extension
+	scriptName     string
+	script         []byte
+	args           exec.ArgMap
+	restrictedMode bool
+	flags          collectionFlags
+
+	runs     int
+	previous *exec.Result
+}
+
+func newCollection(runFlags *runFlags, flags collectionFlags, path string) (*collection, error) {
+	script, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script file: %w", err)
+	}
+	if flags.stopWhen != "" {
+		if err := starlark.CheckPredicate(flags.stopWhen); err != nil {
+			return nil, err
+		}
+	}
+	if flags.keep < 0 {
+		return nil, errors.New("--keep cannot be negative")
+	}
+	if flags.outputDir == "" {
+		flags.outputDir = flags.workdir
+	}
+	args, err := processScriptArguments(runFlags)
+	if err != nil {
+		return nil, err
+	}
+
+	base := filepath.Base(path)
+	return &collection{
+		name:           strings.TrimSuffix(base, filepath.Ext(base)),
+		scriptName:     path,
+		script:         script,
+		args:           args,
+		restrictedMode: runFlags.restrictedMode,
+		flags:          flags,
+	}, nil
+}
+
+// run executes the script once, with the args of the collection and extra, prunes the
+// old runs, and returns true if the stop predicate is true for the run. The script
+// stops when ctx is done.
+func (c *collection) run(ctx context.Context, extra map[string]string) (bool, error) {
+	c.runs++
+	name := c.runName(time.Now())
+	workdir := filepath.Join(c.flags.workdir, name)
+	outputFile := filepath.Join(c.flags.outputDir, name+".tar.gz")
+	if err := os.MkdirAll(workdir, 0744); err != nil {
+		return false, fmt.Errorf("failed to create workdir: %w", err)
+	}
+	if err := os.MkdirAll(c.flags.outputDir, 0744); err != nil {
+		return false, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	args := exec.ArgMap{}
+	for k, v := range c.args {
+		args[k] = v
+	}
+	for k, v := range extra {
+		args[k] = v
+	}
+	args["workdir"] = workdir
+	args["output_file"] = outputFile
+
+	logrus.Infof("run %d: executing %s in %s", c.runs, c.scriptName, workdir)
+	result, runErr := exec.ExecuteForResult(ctx, c.scriptName, bytes.NewReader(c.script), args, c.restrictedMode)
+	if err := c.prune(); err != nil {
+		logrus.Warnf("failed to delete old runs: %s", err)
+	}
+	if runErr != nil {
+		return false, fmt.Errorf("run %d failed: %w", c.runs, runErr)
+	}
+
+	previous := c.previous
+	c.previous = result
+	if c.flags.stopWhen == "" {
+		return false, nil
+	}
+	stop, err := result.Eval(c.flags.stopWhen, previous)
+	if err != nil {
+		return false, fmt.Errorf("run %d: failed to evaluate --stop-when: %w", c.runs, err)
+	}
+	return stop, nil
+}
+
+// runName returns the name of a run started at t, unique in the working directory
+func (c *collection) runName(t time.Time) string {
+	name := fmt.Sprintf("%s-%s", c.name, t.UTC().Format(runTimeLayout))
+	unique := name
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(c.flags.workdir, unique)); os.IsNotExist(err) {
+			return unique
+		}
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+}
+
+// runID identifies a run of a collection by its start time and its sequence
+// number among the runs started at the same time (1 for the first run)
+type runID struct {
+	started  time.Time
+	sequence int
+}
+
+func (r runID) before(other runID) bool {
+	if !r.started.Equal(other.started) {
+		return r.started.Before(other.started)
+	}
+	return r.sequence < other.sequence
+}
+
+// prune deletes the working directories and archives of the runs older than the flags.keep most recent
+func (c *collection) prune() error {
+	if c.flags.keep == 0 {
+		return nil
+	}
+	runName := regexp.MustCompile(`^` + regexp.QuoteMeta(c.name) + `-(\d{8}T\d{6}Z)(?:-(\d+))?`)
+
+	// entries by run, an archive is named after its run followed by its extensions
+	runs := make(map[string][]string)
+	ids := make(map[string]runID)
+	for _, dir := range []string{c.flags.workdir, c.flags.outputDir} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			match := runName.FindStringSubmatch(entry.Name())
+			if match == nil || (entry.Name() != match[0] && !strings.HasPrefix(entry.Name(), match[0]+".")) {
+				continue
+			}
+			started, err := time.Parse(runTimeLayout, match[1])
+			if err != nil {
+				continue
+			}
+			id := runID{started: started, sequence: 1}
+			if match[2] != "" {
+				if id.sequence, err = strconv.Atoi(match[2]); err != nil {
+					continue
+				}
+			}
+			path := filepath.Join(dir, entry.Name())
+			if !slices.Contains(runs[match[0]], path) {
+				runs[match[0]] = append(runs[match[0]], path)
+			}
+			ids[match[0]] = id
+		}
+	}
+
+	var names []string
+	for name := range runs {
+		names = append(names, name)
+	}
+	// runs started in the same second are ordered by their sequence number, i.e. -2 before -10
+	sort.Slice(names, func(i, j int) bool { return ids[names[i]].before(ids[names[j]]) })
+	var errs []error
+	for i := 0; i < len(names)-c.flags.keep; i++ {
+		for _, path := range runs[names[i]] {
+			logrus.Debugf("deleting %s", path)
+			if err := os.RemoveAll(path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
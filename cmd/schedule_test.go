@@ -0,0 +1,123 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const testScheduleScript = `
+crashd_config(workdir=args.workdir)
+capture_local(cmd="echo run", file_name="out.txt")
+archive(output_file=args.output_file, source_paths=[args.workdir])
+label = args.label
+`
+
+var _ = Describe("Schedule", func() {
+	var dir, script string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "crashd-schedule")
+		Expect(err).NotTo(HaveOccurred())
+		script = filepath.Join(dir, "collect.crsh")
+		Expect(os.WriteFile(script, []byte(testScheduleScript), 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	newFlags := func() *scheduleFlags {
+		flags := defaultScheduleFlags()
+		flags.args = map[string]string{"label": "test"}
+		flags.every = 10 * time.Millisecond
+		flags.workdir = filepath.Join(dir, "runs")
+		flags.outputDir = filepath.Join(dir, "archives")
+		return flags
+	}
+
+	It("keeps the most recent runs", func() {
+		flags := newFlags()
+		flags.maxRuns = 3
+		flags.keep = 2
+		Expect(schedule(context.TODO(), flags, script)).To(Succeed())
+
+		workdirs, err := filepath.Glob(filepath.Join(dir, "runs", "collect-*"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(workdirs).To(HaveLen(2))
+		archives, err := filepath.Glob(filepath.Join(dir, "archives", "collect-*.tar.gz"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(archives).To(HaveLen(2))
+		for _, workdir := range workdirs {
+			Expect(filepath.Join(workdir, "out.txt")).To(BeAnExistingFile())
+		}
+	})
+
+	It("stops when the predicate is true", func() {
+		flags := newFlags()
+		flags.maxRuns = 10
+		flags.stopWhen = `previous != None and previous.label == label`
+		Expect(schedule(context.TODO(), flags, script)).To(Succeed())
+
+		archives, err := filepath.Glob(filepath.Join(dir, "archives", "collect-*.tar.gz"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(archives).To(HaveLen(2))
+	})
+
+	It("stops when cancelled", func() {
+		flags := newFlags()
+		flags.every = time.Hour
+		ctx, cancel := context.WithCancel(context.TODO())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		Expect(schedule(ctx, flags, script)).To(Succeed())
+	})
+
+	It("stops a running script when cancelled", func() {
+		Expect(os.WriteFile(script, []byte("def loop():\n    for i in range(1000000000):\n        pass\nloop()\n"), 0644)).To(Succeed())
+		flags := newFlags()
+		ctx, cancel := context.WithCancel(context.TODO())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		done := make(chan error)
+		go func() { done <- schedule(ctx, flags, script) }()
+		Eventually(done, 10*time.Second).Should(Receive(BeNil()))
+	})
+
+	It("prunes the runs in the order of their timestamps and sequence numbers", func() {
+		flags := newFlags()
+		flags.keep = 2
+		c, err := newCollection(&flags.runFlags, flags.collectionFlags, script)
+		Expect(err).NotTo(HaveOccurred())
+		names := []string{"collect-20200101T000000Z", "collect-20200101T000001Z", "collect-20200101T000001Z-2", "collect-20200101T000001Z-3", "collect-20200101T000001Z-10", "collect-20191231T235959Z-3"}
+		for _, name := range names {
+			Expect(os.MkdirAll(filepath.Join(dir, "runs", name), 0744)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(dir, "archives"), 0744)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "archives", name+".tar.gz"), nil, 0644)).To(Succeed())
+		}
+		Expect(c.prune()).To(Succeed())
+
+		workdirs, err := filepath.Glob(filepath.Join(dir, "runs", "collect-*"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(workdirs).To(ConsistOf(filepath.Join(dir, "runs", "collect-20200101T000001Z-3"), filepath.Join(dir, "runs", "collect-20200101T000001Z-10")))
+		archives, err := filepath.Glob(filepath.Join(dir, "archives", "collect-*"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(archives).To(HaveLen(2))
+	})
+
+	It("rejects invalid flags", func() {
+		flags := newFlags()
+		flags.every = 0
+		Expect(schedule(context.TODO(), flags, script)).NotTo(Succeed())
+
+		flags = newFlags()
+		flags.stopWhen = "previous =="
+		Expect(schedule(context.TODO(), flags, script)).NotTo(Succeed())
+	})
+})
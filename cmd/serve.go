@@ -0,0 +1,82 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vmware-tanzu/crash-diagnostics/controller"
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+)
+
+type serveFlags struct {
+	kubeconfig  string
+	namespace   string
+	workdir     string
+	outputDir   string
+	workers     int
+	printCRD    bool
+	printDeploy bool
+}
+
+func defaultServeFlags() *serveFlags {
+	return &serveFlags{
+		workers: 1,
+	}
+}
+
+// newServeCommand creates a command to run the DiagnosticRun controller
+func newServeCommand() *cobra.Command {
+	flags := defaultServeFlags()
+
+	cmd := &cobra.Command{
+		Args:  cobra.NoArgs,
+		Use:   "serve",
+		Short: "runs the DiagnosticRun controller",
+		Long:  "Watches DiagnosticRun resources and executes their scripts, recording the outcome in the resource status",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flags.printCRD {
+				_, err := io.WriteString(cmd.OutOrStdout(), controller.CRD)
+				return err
+			}
+			if flags.printDeploy {
+				_, err := io.WriteString(cmd.OutOrStdout(), controller.Deployment)
+				return err
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return serve(ctx, flags)
+		},
+	}
+	cmd.Flags().StringVar(&flags.kubeconfig, "kubeconfig", flags.kubeconfig, "kubeconfig of the cluster of the DiagnosticRun resources (default in-cluster service account)")
+	cmd.Flags().StringVarP(&flags.namespace, "namespace", "n", flags.namespace, "namespace watched (default all namespaces)")
+	cmd.Flags().StringVar(&flags.workdir, "workdir", flags.workdir, "parent directory of the working directories of the runs")
+	cmd.Flags().StringVar(&flags.outputDir, "output-dir", flags.outputDir, "directory of the archives of the runs without an output path (default --workdir)")
+	cmd.Flags().IntVar(&flags.workers, "workers", flags.workers, "number of runs executed at the same time")
+	cmd.Flags().BoolVar(&flags.printCRD, "print-crd", flags.printCRD, "prints the DiagnosticRun CustomResourceDefinition and exits")
+	cmd.Flags().BoolVar(&flags.printDeploy, "print-deploy", flags.printDeploy, "prints the RBAC rules and Deployment running the controller in namespace crashd-system and exits")
+	return cmd
+}
+
+func serve(ctx context.Context, flags *serveFlags) error {
+	client, err := k8s.NewForOptions(k8s.ConfigOptions{Path: flags.kubeconfig, InCluster: flags.kubeconfig == ""})
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	c := controller.New(client.Client, client.Typed, controller.Options{
+		Namespace: flags.namespace,
+		WorkDir:   flags.workdir,
+		OutputDir: flags.outputDir,
+		Workers:   flags.workers,
+	})
+	return c.Run(ctx)
+}
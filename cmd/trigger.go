@@ -0,0 +1,178 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+	"github.com/vmware-tanzu/crash-diagnostics/trigger"
+)
+
+type triggerFlags struct {
+	runFlags
+	collectionFlags
+	on         []string
+	kubeconfig string
+	namespace  string
+	debounce   time.Duration
+	maxRuns    int
+}
+
+func defaultTriggerFlags() *triggerFlags {
+	return &triggerFlags{
+		runFlags:        *defaultRunFlags(),
+		collectionFlags: defaultCollectionFlags(),
+		kubeconfig:      defaultKubeConfig(),
+		debounce:        5 * time.Minute,
+	}
+}
+
+// newTriggerCommand creates a command to run a script when a condition fires in a cluster
+func newTriggerCommand() *cobra.Command {
+	flags := defaultTriggerFlags()
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "trigger --on <condition> <file-name>",
+		Short: "runs a script file when a condition fires in a cluster",
+		Long: "Watches the objects of a cluster and runs the specified script file when an object starts matching a condition, " +
+			"each run in a new timestamped working directory. The script receives the paths of its working directory and archive " +
+			"as args.workdir and args.output_file, and the triggering object as args.trigger_kind, args.trigger_namespace, " +
+			"args.trigger_name, args.trigger_condition and args.trigger_object (JSON).",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			client, err := k8s.NewForOptions(kubeConfigOptions(flags.kubeconfig))
+			if err != nil {
+				return fmt.Errorf("failed to create client: %w", err)
+			}
+			return runTrigger(ctx, client, flags, args[0])
+		},
+	}
+	cmd.Flags().StringToStringVar(&flags.args, "args", flags.args, "comma-separated key=value pairs passed to the script (i.e. --args 'key0=val0,key1=val1')")
+	cmd.Flags().StringVar(&flags.argsFile, "args-file", flags.argsFile, "path to a file containing key=value argument pairs that are passed to the script file")
+	cmd.Flags().BoolVar(&flags.restrictedMode, "restrictedMode", flags.restrictedMode, "run the script in a restricted mode that prevents usage of certain grammar functions")
+	cmd.Flags().StringArrayVar(&flags.on, "on", flags.on, "condition firing a run, as a field path (i.e. 'pod.status.phase=Failed') or a Starlark predicate of obj (i.e. 'pod: obj.status.phase == \"Failed\"'); can be repeated")
+	cmd.Flags().StringVar(&flags.kubeconfig, "kubeconfig", flags.kubeconfig, "kubeconfig of the cluster watched, $KUBECONFIG or ~/.kube/config by default like the kube_config() of the scripts")
+	cmd.Flags().StringVarP(&flags.namespace, "namespace", "n", flags.namespace, "namespace watched (default all namespaces)")
+	cmd.Flags().DurationVar(&flags.debounce, "debounce", flags.debounce, "minimum interval between the starts of the runs, conditions firing sooner are ignored")
+	cmd.Flags().IntVar(&flags.maxRuns, "max-runs", flags.maxRuns, "number of runs after which the trigger stops (default no limit)")
+	flags.collectionFlags.addFlags(cmd)
+	return cmd
+}
+
+// defaultKubeConfig returns the kubeconfig read by default by crashd, $KUBECONFIG or ~/.kube/config
+func defaultKubeConfig() string {
+	if path := os.Getenv("KUBECONFIG"); path != "" {
+		return path
+	}
+	return filepath.Join(os.Getenv("HOME"), ".kube", "config")
+}
+
+// kubeConfigOptions returns the options of the kubeconfig at path or, inside a pod
+// without the file, of the pod service account, like the default kube_config() of the scripts
+func kubeConfigOptions(path string) k8s.ConfigOptions {
+	if _, err := os.Stat(path); os.IsNotExist(err) && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return k8s.ConfigOptions{InCluster: true}
+	}
+	return k8s.ConfigOptions{Path: path}
+}
+
+func runTrigger(ctx context.Context, client *k8s.Client, flags *triggerFlags, path string) error {
+	if len(flags.on) == 0 {
+		return errors.New("at least one --on condition is required")
+	}
+	if flags.debounce < 0 {
+		return errors.New("--debounce cannot be negative")
+	}
+	var conditions []*trigger.Condition
+	for _, on := range flags.on {
+		cond, err := trigger.ParseCondition(on)
+		if err != nil {
+			return err
+		}
+		conditions = append(conditions, cond)
+	}
+	c, err := newCollection(&flags.runFlags, flags.collectionFlags, path)
+	if err != nil {
+		return err
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// a condition firing while a run is executing is kept until the run ends
+	events := make(chan trigger.Event, 1)
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- trigger.Watch(watchCtx, client, conditions, flags.namespace, func(e trigger.Event) {
+			select {
+			case events <- e:
+			default:
+				logrus.Debugf("trigger: %s fired for %s/%s while a run is pending", e.Condition, e.Object.GetNamespace(), e.Object.GetName())
+			}
+		})
+	}()
+
+	var lastRun time.Time
+	for {
+		select {
+		case err := <-watchErr:
+			if err != nil {
+				return err
+			}
+			logrus.Infof("trigger: stopped after %d run(s)", c.runs)
+			return nil
+		case e := <-events:
+			if !lastRun.IsZero() && e.Time.Sub(lastRun) < flags.debounce {
+				logrus.Infof("trigger: %s fired for %s/%s within --debounce of the last run, ignored", e.Condition, e.Object.GetNamespace(), e.Object.GetName())
+				continue
+			}
+			logrus.Infof("trigger: %s fired for %s/%s", e.Condition, e.Object.GetNamespace(), e.Object.GetName())
+			lastRun = time.Now()
+
+			extra, err := triggerArgs(e)
+			if err != nil {
+				return err
+			}
+			stop, err := c.run(ctx, extra)
+			if err != nil {
+				// a failed run does not stop the trigger, the next run may succeed
+				logrus.Errorf("trigger: %s", err)
+			}
+			if stop {
+				logrus.Infof("trigger: stop condition met after %d run(s)", c.runs)
+				return nil
+			}
+			if flags.maxRuns > 0 && c.runs >= flags.maxRuns {
+				return nil
+			}
+		}
+	}
+}
+
+// triggerArgs returns the script arguments describing the triggering object of e
+func triggerArgs(e trigger.Event) (map[string]string, error) {
+	obj, err := e.Object.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("trigger: failed to encode %s: %w", e.Object.GetName(), err)
+	}
+	return map[string]string{
+		"trigger_kind":      e.Object.GetKind(),
+		"trigger_namespace": e.Object.GetNamespace(),
+		"trigger_name":      e.Object.GetName(),
+		"trigger_condition": e.Condition.String(),
+		"trigger_object":    string(obj),
+	}, nil
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+)
+
+const testTriggerScript = `
+crashd_config(workdir=args.workdir)
+capture_local(cmd="echo " + args.trigger_namespace + "/" + args.trigger_name, file_name="trigger.txt")
+archive(output_file=args.output_file, source_paths=[args.workdir])
+`
+
+var _ = Describe("Trigger", func() {
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	var dir, script string
+	var client *k8s.Client
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "crashd-trigger")
+		Expect(err).NotTo(HaveOccurred())
+		script = filepath.Join(dir, "collect.crsh")
+		Expect(os.WriteFile(script, []byte(testTriggerScript), 0644)).To(Succeed())
+
+		mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})
+		mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, meta.RESTScopeNamespace)
+		dynamic := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+			podsGVR: "PodList",
+		})
+		client = &k8s.Client{Client: dynamic, Mapper: mapper}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	newFlags := func() *triggerFlags {
+		flags := defaultTriggerFlags()
+		flags.on = []string{"pod.status.phase=Failed"}
+		flags.workdir = filepath.Join(dir, "runs")
+		flags.outputDir = filepath.Join(dir, "archives")
+		return flags
+	}
+
+	createPod := func(name, phase string) {
+		pod := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"metadata":   map[string]interface{}{"namespace": "default", "name": name},
+			"status":     map[string]interface{}{"phase": phase},
+		}}
+		_, err := client.Client.Resource(podsGVR).Namespace("default").Create(context.TODO(), pod, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	It("runs the script with the triggering object", func() {
+		flags := newFlags()
+		flags.maxRuns = 1
+		time.AfterFunc(200*time.Millisecond, func() {
+			createPod("running", "Running")
+			createPod("failed", "Failed")
+		})
+		ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+		defer cancel()
+		Expect(runTrigger(ctx, client, flags, script)).To(Succeed())
+		Expect(ctx.Err()).NotTo(HaveOccurred())
+
+		outputs, err := filepath.Glob(filepath.Join(dir, "runs", "collect-*", "trigger.txt"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outputs).To(HaveLen(1))
+		output, err := os.ReadFile(outputs[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(output)).To(ContainSubstring("default/failed"))
+		archives, err := filepath.Glob(filepath.Join(dir, "archives", "collect-*.tar.gz"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(archives).To(HaveLen(1))
+	})
+
+	It("ignores conditions firing within the debounce interval", func() {
+		flags := newFlags()
+		flags.debounce = time.Hour
+		time.AfterFunc(200*time.Millisecond, func() {
+			createPod("failed-0", "Failed")
+		})
+		time.AfterFunc(time.Second, func() {
+			createPod("failed-1", "Failed")
+		})
+		ctx, cancel := context.WithTimeout(context.TODO(), 2*time.Second)
+		defer cancel()
+		Expect(runTrigger(ctx, client, flags, script)).To(Succeed())
+
+		archives, err := filepath.Glob(filepath.Join(dir, "archives", "collect-*.tar.gz"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(archives).To(HaveLen(1))
+	})
+
+	It("reads the default kubeconfig of crashd", func() {
+		home, kubeconfig, serviceHost := os.Getenv("HOME"), os.Getenv("KUBECONFIG"), os.Getenv("KUBERNETES_SERVICE_HOST")
+		defer func() {
+			os.Setenv("HOME", home)
+			os.Setenv("KUBECONFIG", kubeconfig)
+			os.Setenv("KUBERNETES_SERVICE_HOST", serviceHost)
+		}()
+		Expect(os.Setenv("HOME", dir)).To(Succeed())
+		Expect(os.Setenv("KUBECONFIG", "")).To(Succeed())
+		Expect(os.Setenv("KUBERNETES_SERVICE_HOST", "")).To(Succeed())
+		path := filepath.Join(dir, ".kube", "config")
+		Expect(defaultTriggerFlags().kubeconfig).To(Equal(path))
+		Expect(kubeConfigOptions(path)).To(Equal(k8s.ConfigOptions{Path: path}))
+
+		Expect(os.Setenv("KUBECONFIG", "/etc/kubeconfig")).To(Succeed())
+		Expect(defaultTriggerFlags().kubeconfig).To(Equal("/etc/kubeconfig"))
+
+		// inside a pod without the kubeconfig file
+		Expect(os.Setenv("KUBERNETES_SERVICE_HOST", "10.96.0.1")).To(Succeed())
+		Expect(kubeConfigOptions(path)).To(Equal(k8s.ConfigOptions{InCluster: true}))
+		Expect(os.MkdirAll(filepath.Dir(path), 0744)).To(Succeed())
+		Expect(os.WriteFile(path, nil, 0600)).To(Succeed())
+		Expect(kubeConfigOptions(path)).To(Equal(k8s.ConfigOptions{Path: path}))
+	})
+
+	It("rejects invalid flags", func() {
+		flags := newFlags()
+		flags.on = nil
+		Expect(runTrigger(context.TODO(), client, flags, script)).NotTo(Succeed())
+
+		flags = newFlags()
+		flags.on = []string{"pod.status.phase"}
+		Expect(runTrigger(context.TODO(), client, flags, script)).NotTo(Succeed())
+
+		flags = newFlags()
+		flags.on = []string{"widgets.status.phase=Failed"}
+		Expect(runTrigger(context.TODO(), client, flags, script)).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,315 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/vmware-tanzu/crash-diagnostics/exec"
+)
+
+// ScriptRunner executes a script with its arguments, stopping when ctx is done
+type ScriptRunner func(ctx context.Context, name string, source io.Reader, args exec.ArgMap) error
+
+// Options configures a Controller
+type Options struct {
+	// Namespace watched by the controller, default all namespaces
+	Namespace string
+	// WorkDir is the parent of the working directories of the runs, passed to the scripts as args.workdir
+	WorkDir string
+	// OutputDir is the directory of the archives of the runs that do not set an output path
+	OutputDir string
+	// Workers is the number of runs executed at the same time, default 1
+	Workers int
+	// Runner executes the scripts, default exec.ExecuteContext
+	Runner ScriptRunner
+}
+
+// Controller executes the scripts of DiagnosticRun resources. Each generation of
+// a run is executed once, and its status records the phase, artifact, and error.
+type Controller struct {
+	client dynamic.Interface
+	typed  kubernetes.Interface
+	opts   Options
+	queue  workqueue.TypedRateLimitingInterface[string]
+}
+
+// New returns a Controller using the dynamic client to watch the runs
+// and the typed client to read their scripts
+func New(client dynamic.Interface, typed kubernetes.Interface, opts Options) *Controller {
+	if opts.WorkDir == "" {
+		opts.WorkDir = filepath.Join(os.TempDir(), "crashd")
+	}
+	if opts.OutputDir == "" {
+		opts.OutputDir = opts.WorkDir
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.Runner == nil {
+		opts.Runner = func(ctx context.Context, name string, source io.Reader, args exec.ArgMap) error {
+			return exec.ExecuteContext(ctx, name, source, args, false)
+		}
+	}
+	return &Controller{
+		client: client,
+		typed:  typed,
+		opts:   opts,
+		queue:  workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]()),
+	}
+}
+
+// Run watches the runs and executes their scripts until ctx is done
+func (c *Controller) Run(ctx context.Context) error {
+	defer c.queue.ShutDown()
+
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(c.client, 0, c.opts.Namespace, nil)
+	informer := factory.ForResource(DiagnosticRunResource).Informer()
+	enqueue := func(obj interface{}) {
+		if key, err := cache.MetaNamespaceKeyFunc(obj); err == nil {
+			c.queue.Add(key)
+		}
+	}
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(_, obj interface{}) { enqueue(obj) },
+	}); err != nil {
+		return fmt.Errorf("controller: %w", err)
+	}
+
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return fmt.Errorf("controller: failed to sync %s", DiagnosticRunResource.Resource)
+	}
+	logrus.Infof("controller: watching %s", DiagnosticRunResource.GroupResource())
+
+	for i := 0; i < c.opts.Workers; i++ {
+		go func() {
+			for c.processNext(ctx) {
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+func (c *Controller) processNext(ctx context.Context) bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.reconcile(ctx, key); err != nil {
+		logrus.Errorf("controller: %s: %s", key, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	c.queue.Forget(key)
+	return true
+}
+
+// reconcile executes the script of run key, namespace/name, unless its current generation completed
+func (c *Controller) reconcile(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	run, err := c.getRun(ctx, namespace, name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if run.done() {
+		return nil
+	}
+
+	generation := run.Generation
+	logrus.Infof("controller: starting run %s (generation %d)", key, generation)
+	if err := c.updateStatus(ctx, namespace, name, func(status *DiagnosticRunStatus) {
+		now := metav1.Now()
+		*status = DiagnosticRunStatus{Phase: PhaseRunning, ObservedGeneration: generation, StartTime: &now, Conditions: status.Conditions}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               ConditionCompleted,
+			Status:             metav1.ConditionUnknown,
+			ObservedGeneration: generation,
+			Reason:             "Running",
+			Message:            "the script is running",
+		})
+	}); err != nil {
+		return err
+	}
+
+	// each generation has its own workdir and archive, and keeps the artifacts of the previous ones
+	workdir := filepath.Join(c.opts.WorkDir, namespace, fmt.Sprintf("%s-%d", name, generation))
+	outputFile, runErr := c.outputFile(run)
+	if runErr == nil {
+		runErr = c.execute(ctx, run, workdir, outputFile)
+	}
+	// a run stopped by the shutdown of the controller stays running, and runs again at the next start
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	artifact := ""
+	if _, err := os.Stat(outputFile); err == nil {
+		artifact = outputFile
+	} else if _, err := os.Stat(workdir); err == nil {
+		artifact = workdir
+	}
+
+	return c.updateStatus(ctx, namespace, name, func(status *DiagnosticRunStatus) {
+		now := metav1.Now()
+		status.CompletionTime = &now
+		status.Artifact = artifact
+		condition := metav1.Condition{
+			Type:               ConditionCompleted,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: generation,
+			Reason:             "Succeeded",
+			Message:            "the script completed",
+		}
+		status.Phase, status.Error = PhaseSucceeded, ""
+		if runErr != nil {
+			logrus.Errorf("controller: run %s failed: %s", key, runErr)
+			status.Phase, status.Error = PhaseFailed, runErr.Error()
+			condition.Status, condition.Reason, condition.Message = metav1.ConditionFalse, "Failed", runErr.Error()
+		} else {
+			logrus.Infof("controller: run %s succeeded: %s", key, artifact)
+		}
+		meta.SetStatusCondition(&status.Conditions, condition)
+	})
+}
+
+// outputFile returns the path of the archive of run, spec.output.path or <namespace>-<name>-<generation>.tar.gz
+// in the output directory. Absolute paths and paths out of the output directory are rejected.
+func (c *Controller) outputFile(run *DiagnosticRun) (string, error) {
+	path := run.Spec.Output.Path
+	if path == "" {
+		path = fmt.Sprintf("%s-%s-%d.tar.gz", run.Namespace, run.Name, run.Generation)
+	}
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("spec.output.path %s must be relative to the output directory", path)
+	}
+	for _, elem := range strings.Split(filepath.ToSlash(path), "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("spec.output.path %s must not contain ..", path)
+		}
+	}
+	return filepath.Join(c.opts.OutputDir, path), nil
+}
+
+// execute runs the script of run with its arguments, and args workdir and output_file.
+// The files left by an interrupted or previous run are removed first.
+func (c *Controller) execute(ctx context.Context, run *DiagnosticRun, workdir, outputFile string) error {
+	if err := os.RemoveAll(workdir); err != nil {
+		return fmt.Errorf("failed to clear workdir: %w", err)
+	}
+	if err := os.Remove(outputFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove previous output file: %w", err)
+	}
+	name, source, err := c.getScript(ctx, run)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(workdir, 0744); err != nil {
+		return fmt.Errorf("failed to create workdir: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(outputFile), 0744); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	args := exec.ArgMap{}
+	for k, v := range run.Spec.Args {
+		args[k] = v
+	}
+	args["workdir"] = workdir
+	args["output_file"] = outputFile
+	return c.opts.Runner(ctx, name, strings.NewReader(source), args)
+}
+
+// getScript returns the name and source of the script of run
+func (c *Controller) getScript(ctx context.Context, run *DiagnosticRun) (string, string, error) {
+	ref := run.Spec.Script
+	if ref.ConfigMap == "" {
+		return "", "", fmt.Errorf("spec.script.configMap is required")
+	}
+	cm, err := c.typed.CoreV1().ConfigMaps(run.Namespace).Get(ctx, ref.ConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get script: %w", err)
+	}
+
+	key := ref.Key
+	if key == "" {
+		key = DefaultScriptKey
+		if _, ok := cm.Data[key]; !ok && len(cm.Data) == 1 {
+			for k := range cm.Data {
+				key = k
+			}
+		}
+	}
+	source, ok := cm.Data[key]
+	if !ok {
+		keys := make([]string, 0, len(cm.Data))
+		for k := range cm.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return "", "", fmt.Errorf("script %s not found in ConfigMap %s/%s (keys: %s)", key, run.Namespace, ref.ConfigMap, strings.Join(keys, ", "))
+	}
+	return fmt.Sprintf("%s/%s/%s", run.Namespace, ref.ConfigMap, key), source, nil
+}
+
+func (c *Controller) getRun(ctx context.Context, namespace, name string) (*DiagnosticRun, error) {
+	obj, err := c.client.Resource(DiagnosticRunResource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	run := new(DiagnosticRun)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, run); err != nil {
+		return nil, fmt.Errorf("invalid %s %s/%s: %w", Kind, namespace, name, err)
+	}
+	return run, nil
+}
+
+// updateStatus applies mutate to the status of the latest version of run namespace/name
+func (c *Controller) updateStatus(ctx context.Context, namespace, name string, mutate func(*DiagnosticRunStatus)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		run, err := c.getRun(ctx, namespace, name)
+		if err != nil {
+			return err
+		}
+		mutate(&run.Status)
+
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(run)
+		if err != nil {
+			return err
+		}
+		_, err = c.client.Resource(DiagnosticRunResource).Namespace(namespace).UpdateStatus(ctx, &unstructured.Unstructured{Object: obj}, metav1.UpdateOptions{})
+		return err
+	})
+}
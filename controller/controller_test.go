@@ -0,0 +1,268 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vmware-tanzu/crash-diagnostics/exec"
+)
+
+func newTestRun(t *testing.T, name string, spec DiagnosticRunSpec) *unstructured.Unstructured {
+	t.Helper()
+	run := &DiagnosticRun{
+		TypeMeta:   metav1.TypeMeta{APIVersion: Group + "/" + Version, Kind: Kind},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Generation: 1},
+		Spec:       spec,
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &unstructured.Unstructured{Object: obj}
+}
+
+func TestReconcile(t *testing.T) {
+	script := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "scripts", Namespace: "default"},
+		Data:       map[string]string{DefaultScriptKey: "capture_local(cmd='uname')", "fail.crsh": "fail"},
+	}
+
+	tests := []struct {
+		name      string
+		spec      DiagnosticRunSpec
+		phase     RunPhase
+		err       string
+		artifact  string
+		condition metav1.ConditionStatus
+	}{
+		{
+			name:      "succeeded",
+			spec:      DiagnosticRunSpec{Script: ScriptSource{ConfigMap: "scripts"}, Args: map[string]string{"ns": "kube-system"}},
+			phase:     PhaseSucceeded,
+			artifact:  "default-run-1.tar.gz",
+			condition: metav1.ConditionTrue,
+		},
+		{
+			name:      "output path",
+			spec:      DiagnosticRunSpec{Script: ScriptSource{ConfigMap: "scripts"}, Args: map[string]string{"ns": "kube-system"}, Output: OutputTarget{Path: "out/run.tar.gz"}},
+			phase:     PhaseSucceeded,
+			artifact:  "out/run.tar.gz",
+			condition: metav1.ConditionTrue,
+		},
+		{
+			name:      "absolute output path",
+			spec:      DiagnosticRunSpec{Script: ScriptSource{ConfigMap: "scripts"}, Output: OutputTarget{Path: "/etc/run.tar.gz"}},
+			phase:     PhaseFailed,
+			err:       "must be relative to the output directory",
+			condition: metav1.ConditionFalse,
+		},
+		{
+			name:      "output path out of output directory",
+			spec:      DiagnosticRunSpec{Script: ScriptSource{ConfigMap: "scripts"}, Output: OutputTarget{Path: "out/../../run.tar.gz"}},
+			phase:     PhaseFailed,
+			err:       "must not contain ..",
+			condition: metav1.ConditionFalse,
+		},
+		{
+			name:      "script failed",
+			spec:      DiagnosticRunSpec{Script: ScriptSource{ConfigMap: "scripts", Key: "fail.crsh"}},
+			phase:     PhaseFailed,
+			err:       "script failed",
+			condition: metav1.ConditionFalse,
+		},
+		{
+			name:      "missing configmap",
+			spec:      DiagnosticRunSpec{Script: ScriptSource{ConfigMap: "missing"}},
+			phase:     PhaseFailed,
+			err:       "failed to get script",
+			condition: metav1.ConditionFalse,
+		},
+		{
+			name:      "missing key",
+			spec:      DiagnosticRunSpec{Script: ScriptSource{ConfigMap: "scripts", Key: "other.crsh"}},
+			phase:     PhaseFailed,
+			err:       "script other.crsh not found",
+			condition: metav1.ConditionFalse,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{DiagnosticRunResource: Kind + "List"},
+				newTestRun(t, "run", test.spec),
+			)
+
+			var runs int
+			c := New(client, kubefake.NewSimpleClientset(script), Options{
+				WorkDir: dir,
+				Runner: func(_ context.Context, name string, source io.Reader, args exec.ArgMap) error {
+					runs++
+					data, err := io.ReadAll(source)
+					if err != nil {
+						return err
+					}
+					if string(data) == "fail" {
+						return errors.New("script failed")
+					}
+					if args["workdir"] != filepath.Join(dir, "default", "run-1") {
+						t.Errorf("unexpected workdir: %s", args["workdir"])
+					}
+					if args["ns"] != "kube-system" {
+						t.Errorf("unexpected ns arg: %s", args["ns"])
+					}
+					return os.WriteFile(args["output_file"], []byte("archive"), 0644)
+				},
+			})
+
+			// the second reconcile finds the generation completed
+			for i := 0; i < 2; i++ {
+				if err := c.reconcile(ctx, "default/run"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if test.phase == PhaseSucceeded && runs != 1 {
+				t.Errorf("expected 1 run, got %d", runs)
+			}
+
+			run, err := c.getRun(ctx, "default", "run")
+			if err != nil {
+				t.Fatal(err)
+			}
+			status := run.Status
+			if status.Phase != test.phase {
+				t.Errorf("expected phase %s, got %s", test.phase, status.Phase)
+			}
+			if status.ObservedGeneration != 1 || status.StartTime == nil || status.CompletionTime == nil {
+				t.Errorf("unexpected status: %+v", status)
+			}
+			if !strings.Contains(status.Error, test.err) || (test.err == "") != (status.Error == "") {
+				t.Errorf("expected error %q, got %q", test.err, status.Error)
+			}
+			if test.artifact != "" && status.Artifact != filepath.Join(dir, test.artifact) {
+				t.Errorf("expected artifact %s, got %s", test.artifact, status.Artifact)
+			}
+			if test.phase == PhaseFailed && test.spec.Output.Path != "" && runs != 0 {
+				t.Errorf("expected no run with output path %s, got %d", test.spec.Output.Path, runs)
+			}
+			condition := meta.FindStatusCondition(status.Conditions, ConditionCompleted)
+			if condition == nil || condition.Status != test.condition {
+				t.Errorf("expected condition %s, got %+v", test.condition, condition)
+			}
+		})
+	}
+}
+
+func TestReconcileNewGeneration(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{DiagnosticRunResource: Kind + "List"},
+		newTestRun(t, "run", DiagnosticRunSpec{Script: ScriptSource{ConfigMap: "scripts"}}),
+	)
+	script := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "scripts", Namespace: "default"},
+		Data:       map[string]string{DefaultScriptKey: "capture_local(cmd='uname')"},
+	}
+
+	var generation int
+	c := New(client, kubefake.NewSimpleClientset(script), Options{
+		WorkDir: dir,
+		Runner: func(_ context.Context, _ string, _ io.Reader, args exec.ArgMap) error {
+			generation++
+			entries, err := os.ReadDir(args["workdir"])
+			if err != nil {
+				return err
+			}
+			if len(entries) != 0 {
+				t.Errorf("generation %d: unexpected files in workdir: %v", generation, entries)
+			}
+			if err := os.WriteFile(filepath.Join(args["workdir"], "stale.txt"), nil, 0644); err != nil {
+				return err
+			}
+			return os.WriteFile(args["output_file"], []byte("archive"), 0644)
+		},
+	})
+	if err := c.reconcile(ctx, "default/run"); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := client.Resource(DiagnosticRunResource).Namespace("default").Get(ctx, "run", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj.SetGeneration(2)
+	if _, err := client.Resource(DiagnosticRunResource).Namespace("default").Update(ctx, obj, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.reconcile(ctx, "default/run"); err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := c.getRun(ctx, "default", "run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if generation != 2 || run.Status.ObservedGeneration != 2 || run.Status.Artifact != filepath.Join(dir, "default-run-2.tar.gz") {
+		t.Errorf("unexpected status after %d runs: %+v", generation, run.Status)
+	}
+	// the archive of the first generation is kept
+	if _, err := os.Stat(filepath.Join(dir, "default-run-1.tar.gz")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReconcileDeleted(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{DiagnosticRunResource: Kind + "List"},
+	)
+	c := New(client, kubefake.NewSimpleClientset(), Options{WorkDir: t.TempDir()})
+	if err := c.reconcile(context.Background(), "default/gone"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconcileCancelled(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{DiagnosticRunResource: Kind + "List"},
+		newTestRun(t, "run", DiagnosticRunSpec{Script: ScriptSource{ConfigMap: "scripts"}}),
+	)
+	script := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "scripts", Namespace: "default"},
+		Data:       map[string]string{DefaultScriptKey: "capture_local(cmd='uname')"},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := New(client, kubefake.NewSimpleClientset(script), Options{
+		WorkDir: t.TempDir(),
+		Runner: func(ctx context.Context, _ string, _ io.Reader, _ exec.ArgMap) error {
+			// the controller shuts down while the script runs
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+	if err := c.reconcile(ctx, "default/run"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled, got %v", err)
+	}
+}
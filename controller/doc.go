@@ -0,0 +1,6 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package controller runs crashd scripts declared by DiagnosticRun custom
+// resources and records their outcome in the resource status.
+package controller
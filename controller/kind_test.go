@@ -0,0 +1,121 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+	testcrashd "github.com/vmware-tanzu/crash-diagnostics/testing"
+)
+
+var crdResource = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// installCRD creates the DiagnosticRun CRD in the kind cluster and waits until its resources are served
+func installCRD(ctx context.Context, t *testing.T, client *k8s.Client) {
+	t.Helper()
+	crd := new(unstructured.Unstructured)
+	if err := yaml.NewYAMLOrJSONDecoder(strings.NewReader(CRD), 4096).Decode(&crd.Object); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Client.Resource(crdResource).Create(ctx, crd, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		t.Fatal(err)
+	}
+	if err := wait.PollUntilContextTimeout(ctx, time.Second, time.Minute, true, func(ctx context.Context) (bool, error) {
+		_, err := client.Client.Resource(DiagnosticRunResource).Namespace("default").List(ctx, metav1.ListOptions{})
+		return err == nil, nil
+	}); err != nil {
+		t.Fatalf("%s not served: %s", DiagnosticRunResource.Resource, err)
+	}
+}
+
+func TestControllerKind(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+
+	client, err := k8s.New(support.KindKubeConfigFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	installCRD(ctx, t, client)
+
+	name := testcrashd.NextResourceName()
+	script := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Data: map[string]string{DefaultScriptKey: `
+capture_local(cmd="echo " + args.message, workdir=args.workdir)
+archive(output_file=args.output_file, source_paths=[args.workdir])
+`},
+	}
+	if _, err := client.Typed.CoreV1().ConfigMaps("default").Create(ctx, script, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Typed.CoreV1().ConfigMaps("default").Delete(context.Background(), name, metav1.DeleteOptions{})
+
+	runs := map[string]DiagnosticRunSpec{
+		name + "-ok":     {Script: ScriptSource{ConfigMap: name}, Args: map[string]string{"message": "hello"}, Output: OutputTarget{Path: "kind/run.tar.gz"}},
+		name + "-escape": {Script: ScriptSource{ConfigMap: name}, Args: map[string]string{"message": "hello"}, Output: OutputTarget{Path: "../run.tar.gz"}},
+	}
+	for runName, spec := range runs {
+		if _, err := client.Client.Resource(DiagnosticRunResource).Namespace("default").Create(ctx, newTestRun(t, runName, spec), metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		defer client.Client.Resource(DiagnosticRunResource).Namespace("default").Delete(context.Background(), runName, metav1.DeleteOptions{})
+	}
+
+	dir := t.TempDir()
+	c := New(client.Client, client.Typed, Options{Namespace: "default", WorkDir: filepath.Join(dir, "work"), OutputDir: filepath.Join(dir, "out")})
+	runCtx, stop := context.WithCancel(ctx)
+	stopped := make(chan error)
+	go func() { stopped <- c.Run(runCtx) }()
+
+	statuses := map[string]DiagnosticRunStatus{}
+	if err := wait.PollUntilContextTimeout(ctx, time.Second, 2*time.Minute, true, func(ctx context.Context) (bool, error) {
+		for runName := range runs {
+			run, err := c.getRun(ctx, "default", runName)
+			if err != nil {
+				return false, err
+			}
+			if !run.done() {
+				return false, nil
+			}
+			statuses[runName] = run.Status
+		}
+		return true, nil
+	}); err != nil {
+		t.Fatalf("runs not completed: %s", err)
+	}
+	stop()
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+
+	ok := statuses[name+"-ok"]
+	if ok.Phase != PhaseSucceeded || ok.Artifact != filepath.Join(dir, "out", "kind", "run.tar.gz") {
+		t.Errorf("unexpected status: %+v", ok)
+	}
+	if _, err := os.Stat(ok.Artifact); err != nil {
+		t.Error(err)
+	}
+	escape := statuses[name+"-escape"]
+	if escape.Phase != PhaseFailed || !strings.Contains(escape.Error, "must not contain ..") {
+		t.Errorf("unexpected status: %+v", escape)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "run.tar.gz")); err == nil {
+		t.Error("archive written out of the output directory")
+	}
+}
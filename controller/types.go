@@ -0,0 +1,98 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	_ "embed"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// Group is the API group of the DiagnosticRun resource
+	Group = "crashd.vmware-tanzu.io"
+	// Version is the API version of the DiagnosticRun resource
+	Version = "v1alpha1"
+	// Kind is the kind of the DiagnosticRun resource
+	Kind = "DiagnosticRun"
+
+	// DefaultScriptKey is the ConfigMap key read when the script reference does not name one
+	DefaultScriptKey = "script.crsh"
+
+	// ConditionCompleted reports whether the script of a run completed successfully
+	ConditionCompleted = "Completed"
+)
+
+// DiagnosticRunResource is the resource of the DiagnosticRun custom resource definition
+var DiagnosticRunResource = schema.GroupVersionResource{Group: Group, Version: Version, Resource: "diagnosticruns"}
+
+// CRD is the manifest of the DiagnosticRun custom resource definition
+//
+//go:embed crd.yaml
+var CRD string
+
+// Deployment is the manifest of the namespace, service account, RBAC rules, and
+// Deployment running a single instance of the controller
+//
+//go:embed deploy.yaml
+var Deployment string
+
+// RunPhase is the phase of a DiagnosticRun
+type RunPhase string
+
+const (
+	PhasePending   RunPhase = "Pending"
+	PhaseRunning   RunPhase = "Running"
+	PhaseSucceeded RunPhase = "Succeeded"
+	PhaseFailed    RunPhase = "Failed"
+)
+
+// DiagnosticRun declares the execution of a crashd script
+type DiagnosticRun struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DiagnosticRunSpec   `json:"spec"`
+	Status DiagnosticRunStatus `json:"status,omitempty"`
+}
+
+// DiagnosticRunSpec is the script, with its arguments, and where its output is saved
+type DiagnosticRunSpec struct {
+	Script ScriptSource      `json:"script"`
+	Args   map[string]string `json:"args,omitempty"`
+	Output OutputTarget      `json:"output,omitempty"`
+}
+
+// ScriptSource references the ConfigMap, in the namespace of the run, holding the script
+type ScriptSource struct {
+	ConfigMap string `json:"configMap"`
+	// Key of the script in the ConfigMap, default DefaultScriptKey or the only key of the ConfigMap
+	Key string `json:"key,omitempty"`
+}
+
+// OutputTarget is where the script saves its archive. The path, relative to the output
+// directory of the controller, is passed to the script as args.output_file and defaults
+// to <namespace>-<name>-<generation>.tar.gz
+type OutputTarget struct {
+	Path string `json:"path,omitempty"`
+}
+
+// DiagnosticRunStatus records the execution of the script
+type DiagnosticRunStatus struct {
+	Phase              RunPhase     `json:"phase,omitempty"`
+	ObservedGeneration int64        `json:"observedGeneration,omitempty"`
+	StartTime          *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime     *metav1.Time `json:"completionTime,omitempty"`
+	// Artifact is the location of the archive, or of the working directory if the script did not archive it
+	Artifact   string             `json:"artifact,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// done returns true if the current generation of the run completed
+func (r *DiagnosticRun) done() bool {
+	return r.Status.ObservedGeneration == r.Generation &&
+		(r.Status.Phase == PhaseSucceeded || r.Status.Phase == PhaseFailed)
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package etcd collects the state of the etcd cluster of a kubeadm control plane with
+// etcdctl on its nodes, which works when the API server is down.
+package etcd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/vmware-tanzu/crash-diagnostics/ssh"
+)
+
+const (
+	// Dirname is the directory, in the directory of a host, where the etcd files are saved
+	Dirname = "etcd"
+	// StatusFileName is the name of the file saving the Status of a host
+	StatusFileName = "etcd_capture.json"
+
+	// DefaultEndpoints is the client endpoint of the etcd member of a kubeadm control plane node
+	DefaultEndpoints = "https://127.0.0.1:2379"
+	// DefaultPKIDir is the directory of the etcd certificates of kubeadm
+	DefaultPKIDir = "/etc/kubernetes/pki/etcd"
+
+	// SnapshotDirCmd creates the private directory, mode 0700, where the snapshot is saved
+	// on the node before it is copied, and prints its path
+	SnapshotDirCmd = "mktemp -d /tmp/crashd-etcd.XXXXXX"
+	// SnapshotFileName is the name of the snapshot file
+	SnapshotFileName = "snapshot.db"
+	// snapshotDataDir is where etcdctl saves the snapshot, the data directory of etcd,
+	// so that the file is also on the node when etcdctl runs in the etcd container
+	snapshotDataDir = "/var/lib/etcd"
+)
+
+// Command is an etcdctl command whose JSON output is saved in <Name>.json
+type Command struct {
+	Name string
+	Args string
+}
+
+// Commands are the etcdctl commands collected
+var Commands = []Command{
+	{Name: "member_list", Args: "member list -w json"},
+	{Name: "endpoint_status", Args: "endpoint status --cluster -w json"},
+	{Name: "endpoint_health", Args: "endpoint health --cluster -w json"},
+	{Name: "alarm_list", Args: "alarm list -w json"},
+}
+
+// Options locate the etcd member of a node and its client certificate
+type Options struct {
+	// Endpoints are the comma-separated client URLs, DefaultEndpoints if empty
+	Endpoints string
+	// CACert, Cert, and Key are the paths of the client certificate files on the node. If
+	// Cert is empty, the healthcheck-client, server, or peer certificate of kubeadm is used.
+	CACert string
+	Cert   string
+	Key    string
+}
+
+// Script returns the shell script running etcdctl with args on a node. It runs the
+// etcdctl program of the node or, if missing, the one of the etcd container with crictl.
+func (o Options) Script(args string) string {
+	return o.etcdctlFunc() + "run_etcdctl " + args
+}
+
+// SnapshotScript returns the shell script saving a snapshot of etcd in dir/SnapshotFileName,
+// owned by the user running sudo so that it can be copied. Dir is created by SnapshotDirCmd.
+func (o Options) SnapshotScript(dir string) string {
+	dataPath := ssh.Quote(path.Join(snapshotDataDir, path.Base(dir)+".db"))
+	snapshotPath := ssh.Quote(path.Join(dir, SnapshotFileName))
+	return o.etcdctlFunc() + fmt.Sprintf(`if run_etcdctl snapshot save %[1]s && mv %[1]s %[2]s; then chown "${SUDO_UID:-0}" %[2]s; else rm -f %[1]s; exit 1; fi`, dataPath, snapshotPath)
+}
+
+// etcdctlFunc returns the definition of shell function run_etcdctl
+func (o Options) etcdctlFunc() string {
+	endpoints := o.Endpoints
+	if endpoints == "" {
+		endpoints = DefaultEndpoints
+	}
+	caCert := o.CACert
+	if caCert == "" {
+		caCert = DefaultPKIDir + "/ca.crt"
+	}
+
+	var script strings.Builder
+	script.WriteString("ETCDCTL_API=3; export ETCDCTL_API; ")
+	if o.Cert != "" {
+		fmt.Fprintf(&script, "cert=%s; key=%s; ", ssh.Quote(o.Cert), ssh.Quote(o.Key))
+	} else {
+		fmt.Fprintf(&script, `cert=; for c in healthcheck-client server peer; do if [ -f "%[1]s/$c.crt" ]; then cert="%[1]s/$c.crt"; key="%[1]s/$c.key"; break; fi; done; `, DefaultPKIDir)
+		fmt.Fprintf(&script, `if [ -z "$cert" ]; then echo "etcd client certificate not found in %s" >&2; exit 1; fi; `, DefaultPKIDir)
+	}
+	flags := fmt.Sprintf(`--endpoints=%s --cacert=%s --cert="$cert" --key="$key"`, ssh.Quote(endpoints), ssh.Quote(caCert))
+	fmt.Fprintf(&script, `run_etcdctl() { `+
+		`if command -v etcdctl >/dev/null 2>&1; then etcdctl %[1]s "$@"; `+
+		`elif command -v crictl >/dev/null 2>&1 && id=$(crictl ps --name '^etcd$' -q | head -n 1) && [ -n "$id" ]; then crictl exec "$id" etcdctl %[1]s "$@"; `+
+		`else echo "etcdctl not found, and no etcd container found with crictl" >&2; return 1; fi; }; `, flags)
+	return script.String()
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package etcd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestProgram writes an executable shell script name in dir
+func writeTestProgram(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runTestScript runs script with the programs of dir first in the PATH
+func runTestScript(t *testing.T, dir, script string) (string, error) {
+	t.Helper()
+	cmd := exec.Command("sh", "-c", script)
+	cmd.Env = append(os.Environ(), "PATH="+dir+":/usr/bin:/bin")
+	out, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+func TestScript(t *testing.T) {
+	opts := Options{CACert: "/pki/ca.crt", Cert: "/pki/client.crt", Key: "/pki/client.key"}
+
+	t.Run("etcdctl", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestProgram(t, dir, "etcdctl", `echo "etcdctl $ETCDCTL_API $*"`)
+		out, err := runTestScript(t, dir, opts.Script("member list -w json"))
+		if err != nil {
+			t.Fatalf("%s: %s", err, out)
+		}
+		expected := "etcdctl 3 --endpoints=https://127.0.0.1:2379 --cacert=/pki/ca.crt --cert=/pki/client.crt --key=/pki/client.key member list -w json"
+		if out != expected {
+			t.Errorf("unexpected command:\n%s", out)
+		}
+	})
+
+	t.Run("etcd container", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestProgram(t, dir, "crictl", `if [ "$1" = ps ]; then echo abc123; else echo "crictl $*"; fi`)
+		out, err := runTestScript(t, dir, Options{Endpoints: "https://10.0.0.1:2379", Cert: "/c.crt", Key: "/c.key"}.Script("alarm list -w json"))
+		if err != nil {
+			t.Fatalf("%s: %s", err, out)
+		}
+		expected := "crictl exec abc123 etcdctl --endpoints=https://10.0.0.1:2379 --cacert=/etc/kubernetes/pki/etcd/ca.crt --cert=/c.crt --key=/c.key alarm list -w json"
+		if out != expected {
+			t.Errorf("unexpected command:\n%s", out)
+		}
+	})
+
+	t.Run("no etcdctl", func(t *testing.T) {
+		out, err := runTestScript(t, t.TempDir(), opts.Script("member list -w json"))
+		if err == nil || !strings.Contains(out, "etcdctl not found") {
+			t.Errorf("expected error, got %v: %s", err, out)
+		}
+	})
+
+	t.Run("no kubeadm certificate", func(t *testing.T) {
+		if _, err := os.Stat(DefaultPKIDir); err == nil {
+			t.Skip("kubeadm certificates installed")
+		}
+		dir := t.TempDir()
+		writeTestProgram(t, dir, "etcdctl", `echo "$*"`)
+		out, err := runTestScript(t, dir, Options{}.Script("member list -w json"))
+		if err == nil || !strings.Contains(out, "etcd client certificate not found") {
+			t.Errorf("expected error, got %v: %s", err, out)
+		}
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		script := opts.SnapshotScript("/tmp/crashd-etcd.Ab12Cd")
+		for _, expected := range []string{
+			"run_etcdctl snapshot save '/var/lib/etcd/crashd-etcd.Ab12Cd.db'",
+			"mv '/var/lib/etcd/crashd-etcd.Ab12Cd.db' '/tmp/crashd-etcd.Ab12Cd/snapshot.db'",
+			`chown "${SUDO_UID:-0}" '/tmp/crashd-etcd.Ab12Cd/snapshot.db'`,
+			"rm -f '/var/lib/etcd/crashd-etcd.Ab12Cd.db'",
+		} {
+			if !strings.Contains(script, expected) {
+				t.Errorf("snapshot script missing %q:\n%s", expected, script)
+			}
+		}
+	})
+
+	t.Run("snapshot dir", func(t *testing.T) {
+		out, err := runTestScript(t, "", SnapshotDirCmd)
+		if err != nil {
+			t.Fatalf("%s: %s", err, out)
+		}
+		defer os.RemoveAll(out)
+		info, err := os.Stat(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.IsDir() || info.Mode().Perm() != 0700 || !strings.HasPrefix(out, "/tmp/crashd-etcd.") {
+			t.Errorf("unexpected snapshot dir %s: %s", out, info.Mode())
+		}
+	})
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package etcd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Status is the state of the etcd cluster seen from a node
+type Status struct {
+	Resource  string           `json:"resource"`
+	Members   []Member         `json:"members"`
+	Endpoints []EndpointStatus `json:"endpoints"`
+	Health    []EndpointHealth `json:"health"`
+	Alarms    []Alarm          `json:"alarms"`
+	// Snapshot is the local path of the snapshot, if any
+	Snapshot string   `json:"snapshot,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// Healthy returns true if every endpoint is healthy and no alarm is raised
+func (s Status) Healthy() bool {
+	if len(s.Health) == 0 || len(s.Alarms) > 0 {
+		return false
+	}
+	for _, health := range s.Health {
+		if !health.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// Member is a member of the etcd cluster, with its ID in hexadecimal as printed by etcdctl
+type Member struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	PeerURLs   []string `json:"peer_urls"`
+	ClientURLs []string `json:"client_urls"`
+	IsLearner  bool     `json:"is_learner"`
+}
+
+// EndpointStatus is the status of the member of an endpoint
+type EndpointStatus struct {
+	Endpoint    string   `json:"endpoint"`
+	MemberID    string   `json:"member_id"`
+	Version     string   `json:"version"`
+	DBSize      int64    `json:"db_size"`
+	DBSizeInUse int64    `json:"db_size_in_use"`
+	IsLeader    bool     `json:"is_leader"`
+	RaftTerm    uint64   `json:"raft_term"`
+	RaftIndex   uint64   `json:"raft_index"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// EndpointHealth is the health of an endpoint
+type EndpointHealth struct {
+	Endpoint string `json:"endpoint"`
+	Healthy  bool   `json:"healthy"`
+	Took     string `json:"took"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Alarm is an alarm raised by a member, i.e. NOSPACE when its database is full
+type Alarm struct {
+	MemberID string `json:"member_id"`
+	Alarm    string `json:"alarm"`
+}
+
+// alarmTypes are the names of the etcd alarm types
+var alarmTypes = map[int]string{0: "NONE", 1: "NOSPACE", 2: "CORRUPT"}
+
+// SplitOutput returns the JSON line of the output of an etcdctl command, and the other
+// lines, i.e. the error messages of etcdctl for unreachable endpoints
+func SplitOutput(output string) (string, []string) {
+	var data string
+	var messages []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case data == "" && (strings.HasPrefix(line, "{") || strings.HasPrefix(line, "[")):
+			data = line
+		default:
+			messages = append(messages, line)
+		}
+	}
+	return data, messages
+}
+
+// Parse sets the field of s from data, the JSON output of etcdctl command name
+func (s *Status) Parse(name string, data []byte) error {
+	if len(data) == 0 {
+		return errors.New("no JSON output")
+	}
+	var err error
+	switch name {
+	case "member_list":
+		s.Members, err = parseMembers(data)
+	case "endpoint_status":
+		s.Endpoints, err = parseEndpointStatus(data)
+	case "endpoint_health":
+		s.Health, err = parseEndpointHealth(data)
+	case "alarm_list":
+		s.Alarms, err = parseAlarms(data)
+	default:
+		err = fmt.Errorf("unknown command %s", name)
+	}
+	return err
+}
+
+func parseMembers(data []byte) ([]Member, error) {
+	var list struct {
+		Members []struct {
+			ID         uint64   `json:"ID"`
+			Name       string   `json:"name"`
+			PeerURLs   []string `json:"peerURLs"`
+			ClientURLs []string `json:"clientURLs"`
+			IsLearner  bool     `json:"isLearner"`
+		} `json:"members"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	members := make([]Member, len(list.Members))
+	for i, m := range list.Members {
+		members[i] = Member{ID: fmt.Sprintf("%x", m.ID), Name: m.Name, PeerURLs: m.PeerURLs, ClientURLs: m.ClientURLs, IsLearner: m.IsLearner}
+	}
+	return members, nil
+}
+
+func parseEndpointStatus(data []byte) ([]EndpointStatus, error) {
+	var list []struct {
+		Endpoint string `json:"Endpoint"`
+		Status   struct {
+			Header struct {
+				MemberID uint64 `json:"member_id"`
+			} `json:"header"`
+			Version     string   `json:"version"`
+			DBSize      int64    `json:"dbSize"`
+			DBSizeInUse int64    `json:"dbSizeInUse"`
+			Leader      uint64   `json:"leader"`
+			RaftIndex   uint64   `json:"raftIndex"`
+			RaftTerm    uint64   `json:"raftTerm"`
+			Errors      []string `json:"errors"`
+		} `json:"Status"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	endpoints := make([]EndpointStatus, len(list))
+	for i, ep := range list {
+		endpoints[i] = EndpointStatus{
+			Endpoint:    ep.Endpoint,
+			MemberID:    fmt.Sprintf("%x", ep.Status.Header.MemberID),
+			Version:     ep.Status.Version,
+			DBSize:      ep.Status.DBSize,
+			DBSizeInUse: ep.Status.DBSizeInUse,
+			IsLeader:    ep.Status.Leader != 0 && ep.Status.Leader == ep.Status.Header.MemberID,
+			RaftTerm:    ep.Status.RaftTerm,
+			RaftIndex:   ep.Status.RaftIndex,
+			Errors:      ep.Status.Errors,
+		}
+	}
+	return endpoints, nil
+}
+
+func parseEndpointHealth(data []byte) ([]EndpointHealth, error) {
+	var list []struct {
+		Endpoint string `json:"endpoint"`
+		Health   bool   `json:"health"`
+		Took     string `json:"took"`
+		Error    string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	health := make([]EndpointHealth, len(list))
+	for i, ep := range list {
+		health[i] = EndpointHealth{Endpoint: ep.Endpoint, Healthy: ep.Health, Took: ep.Took, Error: ep.Error}
+	}
+	return health, nil
+}
+
+func parseAlarms(data []byte) ([]Alarm, error) {
+	var list struct {
+		Alarms []struct {
+			MemberID uint64 `json:"memberID"`
+			Alarm    int    `json:"alarm"`
+		} `json:"alarms"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	alarms := make([]Alarm, len(list.Alarms))
+	for i, a := range list.Alarms {
+		name, ok := alarmTypes[a.Alarm]
+		if !ok {
+			name = fmt.Sprintf("%d", a.Alarm)
+		}
+		alarms[i] = Alarm{MemberID: fmt.Sprintf("%x", a.MemberID), Alarm: name}
+	}
+	return alarms, nil
+}
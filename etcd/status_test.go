@@ -0,0 +1,103 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package etcd
+
+import (
+	"strings"
+	"testing"
+)
+
+// outputs of etcdctl 3.5 for a cluster of 2 members, one unreachable
+const (
+	testMemberList     = `{"header":{"cluster_id":14841639068965178418,"member_id":10276657743932975437,"raft_term":3},"members":[{"ID":10276657743932975437,"name":"cp-0","peerURLs":["https://10.0.0.10:2380"],"clientURLs":["https://10.0.0.10:2379"]},{"ID":1311290287187186052,"name":"cp-1","peerURLs":["https://10.0.0.11:2380"],"clientURLs":["https://10.0.0.11:2379"],"isLearner":true}]}`
+	testEndpointStatus = `[{"Endpoint":"https://10.0.0.10:2379","Status":{"header":{"cluster_id":14841639068965178418,"member_id":10276657743932975437,"revision":1234,"raft_term":3},"version":"3.5.9","dbSize":2097152,"leader":10276657743932975437,"raftIndex":5678,"raftTerm":3,"raftAppliedIndex":5678,"dbSizeInUse":1048576}}]`
+	testEndpointHealth = `[{"endpoint":"https://10.0.0.10:2379","health":true,"took":"8.1ms"},{"endpoint":"https://10.0.0.11:2379","health":false,"took":"5s","error":"context deadline exceeded"}]`
+	testAlarmList      = `{"header":{"cluster_id":14841639068965178418,"member_id":10276657743932975437,"revision":1234,"raft_term":3},"alarms":[{"memberID":10276657743932975437,"alarm":1}]}`
+)
+
+func TestSplitOutput(t *testing.T) {
+	output := "{\"Endpoint\":\"a\"} is not JSON\n" +
+		"[{\"endpoint\":\"b\"}]\n" +
+		"Error: unhealthy cluster\n"
+	data, messages := SplitOutput(output)
+	if data != `{"Endpoint":"a"} is not JSON` {
+		t.Errorf("unexpected data: %s", data)
+	}
+	if len(messages) != 2 || messages[1] != "Error: unhealthy cluster" {
+		t.Errorf("unexpected messages: %v", messages)
+	}
+
+	data, messages = SplitOutput("Error: context deadline exceeded\n" + testEndpointHealth + "\nError: unhealthy cluster")
+	if data != testEndpointHealth || strings.Join(messages, ";") != "Error: context deadline exceeded;Error: unhealthy cluster" {
+		t.Errorf("unexpected split: %s, %v", data, messages)
+	}
+}
+
+func TestStatusParse(t *testing.T) {
+	var status Status
+	for name, output := range map[string]string{
+		"member_list":     testMemberList,
+		"endpoint_status": testEndpointStatus,
+		"endpoint_health": testEndpointHealth,
+		"alarm_list":      testAlarmList,
+	} {
+		if err := status.Parse(name, []byte(output)); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+	}
+
+	if len(status.Members) != 2 {
+		t.Fatalf("unexpected members: %+v", status.Members)
+	}
+	if m := status.Members[0]; m.ID != "8e9e05c52164694d" || m.Name != "cp-0" || m.ClientURLs[0] != "https://10.0.0.10:2379" || m.IsLearner {
+		t.Errorf("unexpected member: %+v", m)
+	}
+	if !status.Members[1].IsLearner {
+		t.Errorf("unexpected member: %+v", status.Members[1])
+	}
+
+	if len(status.Endpoints) != 1 {
+		t.Fatalf("unexpected endpoints: %+v", status.Endpoints)
+	}
+	if ep := status.Endpoints[0]; !ep.IsLeader || ep.MemberID != "8e9e05c52164694d" || ep.Version != "3.5.9" || ep.DBSize != 2097152 || ep.RaftIndex != 5678 {
+		t.Errorf("unexpected endpoint status: %+v", ep)
+	}
+
+	if len(status.Health) != 2 || !status.Health[0].Healthy || status.Health[1].Healthy || status.Health[1].Error != "context deadline exceeded" {
+		t.Errorf("unexpected health: %+v", status.Health)
+	}
+	if len(status.Alarms) != 1 || status.Alarms[0].Alarm != "NOSPACE" || status.Alarms[0].MemberID != "8e9e05c52164694d" {
+		t.Errorf("unexpected alarms: %+v", status.Alarms)
+	}
+	if status.Healthy() {
+		t.Error("unhealthy cluster reported healthy")
+	}
+}
+
+func TestStatusHealthy(t *testing.T) {
+	healthy := Status{Health: []EndpointHealth{{Endpoint: "a", Healthy: true}}}
+	if !healthy.Healthy() {
+		t.Error("healthy cluster reported unhealthy")
+	}
+	if (Status{}).Healthy() {
+		t.Error("cluster without health reported healthy")
+	}
+	alarmed := Status{Health: healthy.Health, Alarms: []Alarm{{MemberID: "1", Alarm: "CORRUPT"}}}
+	if alarmed.Healthy() {
+		t.Error("cluster with alarm reported healthy")
+	}
+}
+
+func TestStatusParseErrors(t *testing.T) {
+	var status Status
+	if err := status.Parse("member_list", nil); err == nil {
+		t.Error("expected error without output")
+	}
+	if err := status.Parse("endpoint_health", []byte("{")); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+	if err := status.Parse("defrag", []byte("{}")); err == nil {
+		t.Error("expected error for unknown command")
+	}
+}
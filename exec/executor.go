@@ -4,6 +4,7 @@
 package exec
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -14,12 +15,44 @@ import (
 type ArgMap map[string]string
 
 func Execute(name string, source io.Reader, args ArgMap, restrictedMode bool) error {
+	return ExecuteContext(context.Background(), name, source, args, restrictedMode)
+}
+
+// ExecuteContext executes a script like Execute, stopping it when ctx is done
+func ExecuteContext(ctx context.Context, name string, source io.Reader, args ArgMap, restrictedMode bool) error {
 	star, err := newExecutor(args, restrictedMode)
 	if err != nil {
 		return err
 	}
 
-	return execute(star, name, source)
+	return execute(ctx, star, name, source)
+}
+
+// Result is the outcome of a script executed by ExecuteForResult
+type Result struct {
+	star *starlark.Executor
+}
+
+// ExecuteForResult executes a script like ExecuteContext and returns its result, to evaluate predicates on its global values
+func ExecuteForResult(ctx context.Context, name string, source io.Reader, args ArgMap, restrictedMode bool) (*Result, error) {
+	star, err := newExecutor(args, restrictedMode)
+	if err != nil {
+		return nil, err
+	}
+	if err := execute(ctx, star, name, source); err != nil {
+		return nil, err
+	}
+	return &Result{star: star}, nil
+}
+
+// Eval returns the truth of Starlark expression expr, evaluated with the global values of
+// the script. The global values of the previous result, if any, are bound to `previous`.
+func (r *Result) Eval(expr string, previous *Result) (bool, error) {
+	var prev *starlark.Executor
+	if previous != nil {
+		prev = previous.star
+	}
+	return r.star.EvalPredicate(expr, prev)
 }
 
 func ExecuteFile(file *os.File, args ArgMap, restrictedMode bool) error {
@@ -44,7 +77,7 @@ func ExecuteWithModules(name string, source io.Reader, args ArgMap, restrictedMo
 		}
 	}
 
-	return execute(star, name, source)
+	return execute(context.Background(), star, name, source)
 }
 
 func newExecutor(args ArgMap, restrictedMode bool) (*starlark.Executor, error) {
@@ -62,8 +95,8 @@ func newExecutor(args ArgMap, restrictedMode bool) (*starlark.Executor, error) {
 	return star, nil
 }
 
-func execute(star *starlark.Executor, name string, source io.Reader) error {
-	if err := star.Exec(name, source); err != nil {
+func execute(ctx context.Context, star *starlark.Executor, name string, source io.Reader) error {
+	if err := star.ExecContext(ctx, name, source); err != nil {
 		return fmt.Errorf("exec failed: %w", err)
 	}
 
@@ -5,14 +5,18 @@ go 1.23.0
 toolchain go1.23.4
 
 require (
+	filippo.io/age v1.2.1
 	github.com/kcp-dev/kcp/cli v0.27.1
 	github.com/kcp-dev/kcp/sdk v0.27.1
+	github.com/klauspost/compress v1.17.11
 	github.com/onsi/ginkgo v1.16.5
 	github.com/onsi/gomega v1.36.2
 	github.com/sirupsen/logrus v1.9.3
 	github.com/spf13/cobra v1.8.1
+	github.com/ulikunitz/xz v0.5.12
 	github.com/vladimirvivien/gexe v0.4.0
 	go.starlark.net v0.0.0-20241226192728-8dfa5b98479f
+	golang.org/x/term v0.29.0
 	k8s.io/api v0.32.1
 	k8s.io/apimachinery v0.32.1
 	k8s.io/cli-runtime v0.32.1
@@ -21,7 +25,9 @@ require (
 )
 
 require (
+	filippo.io/edwards25519 v1.1.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
+	golang.org/x/crypto v0.35.0 // indirect
 	gopkg.in/evanphx/json-patch.v4 v4.12.0 // indirect
 )
 
@@ -63,7 +69,6 @@ require (
 	golang.org/x/net v0.36.0 // indirect
 	golang.org/x/oauth2 v0.23.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
-	golang.org/x/term v0.29.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
 	golang.org/x/time v0.7.0 // indirect
 	google.golang.org/protobuf v1.36.1 // indirect
@@ -0,0 +1,239 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package incremental
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/vmware-tanzu/crash-diagnostics/bundle"
+)
+
+// Collector compares the content collected in a working directory with a base
+// bundle and records it in the manifest of the working directory. It is safe
+// for concurrent use.
+type Collector struct {
+	workdir string
+	// base is the content of the base bundle, each entry referencing the bundle holding it
+	base *bundle.Manifest
+
+	mu       sync.Mutex
+	manifest *bundle.Manifest
+}
+
+// New returns a collector recording the manifest of workdir. Content is compared
+// with bundle base, a directory or an archive, when it exists. Otherwise
+// everything is collected and the manifest serves as base to the next collection.
+func New(base, workdir string) (*Collector, error) {
+	c := &Collector{workdir: workdir, base: bundle.NewManifest("")}
+	if base == "" {
+		c.manifest = bundle.NewManifest("")
+		return c, nil
+	}
+
+	base, err := filepath.Abs(base)
+	if err != nil {
+		return nil, err
+	}
+	c.manifest = bundle.NewManifest(base)
+	if _, err := os.Stat(base); os.IsNotExist(err) {
+		logrus.Warnf("incremental: base %s not found, collecting everything", base)
+		return c, nil
+	}
+
+	if c.base, err = loadBase(base); err != nil {
+		return nil, err
+	}
+	logrus.Debugf("incremental: base %s has %d files and %d objects", base, len(c.base.Files), len(c.base.Objects))
+	return c, nil
+}
+
+// loadBase returns the content of bundle path, read from its manifest or, if it
+// has none, from the files and objects it contains
+func loadBase(path string) (*bundle.Manifest, error) {
+	manifest, err := bundle.LoadManifest(path)
+	if err == nil {
+		for name, entry := range manifest.Files {
+			if entry.Ref == "" {
+				entry.Ref = path
+				manifest.Files[name] = entry
+			}
+		}
+		for id, entry := range manifest.Objects {
+			if entry.Ref == "" {
+				entry.Ref = path
+				manifest.Objects[id] = entry
+			}
+		}
+		return manifest, nil
+	}
+	if !errors.Is(err, bundle.ErrNoManifest) {
+		return nil, err
+	}
+
+	b, err := bundle.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+	return scan(b, path)
+}
+
+// scan returns the manifest of the content of bundle b, opened from path
+func scan(b *bundle.Bundle, path string) (*bundle.Manifest, error) {
+	manifest := bundle.NewManifest("")
+	workdir := b.Workdir()
+
+	files, err := b.CaptureFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range files {
+		entry, err := fileEntry(filepath.Join(workdir, name))
+		if err != nil {
+			return nil, err
+		}
+		entry.Ref = path
+		manifest.Files[name] = entry
+	}
+
+	index, err := b.KubeObjects()
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range index.Objects() {
+		rv := obj.Object.GetResourceVersion()
+		rel, err := filepath.Rel(workdir, filepath.Join(b.Root(), obj.Path))
+		if rv == "" || err != nil {
+			continue
+		}
+		id := bundle.ObjectID(obj.GroupVersionResource, obj.Key.Namespace, obj.Key.Name)
+		manifest.Objects[id] = bundle.ObjectEntry{ResourceVersion: rv, Path: rel, Ref: path}
+	}
+	return manifest, nil
+}
+
+// fileEntry returns the entry of local file path
+func fileEntry(path string) (bundle.FileEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return bundle.FileEntry{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return bundle.FileEntry{}, err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return bundle.FileEntry{}, err
+	}
+	return bundle.FileEntry{SHA256: hex.EncodeToString(hash.Sum(nil)), ModTime: info.ModTime().Unix(), Size: info.Size()}, nil
+}
+
+// Rel returns path relative to the working directory, false if path is outside of it
+func (c *Collector) Rel(path string) (string, bool) {
+	rel, err := filepath.Rel(c.workdir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// UnchangedFile returns true if the file at path, in the working directory, has the same
+// checksum and modification time as in the base. The file is then recorded as a reference.
+func (c *Collector) UnchangedFile(path string, entry bundle.FileEntry) bool {
+	rel, ok := c.Rel(path)
+	if !ok {
+		return false
+	}
+	prev, ok := c.base.Files[rel]
+	if !ok || prev.SHA256 != entry.SHA256 || prev.ModTime != entry.ModTime {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.manifest.Files[rel] = prev
+	return true
+}
+
+// AddFile records the file at path, in the working directory, as collected
+func (c *Collector) AddFile(path string, entry bundle.FileEntry) {
+	rel, ok := c.Rel(path)
+	if !ok {
+		return
+	}
+	entry.Ref = ""
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.manifest.Files[rel] = entry
+}
+
+// Skip returns true if obj has the same resourceVersion as in the base.
+// The object is then recorded as a reference. It implements k8s.ObjectFilter.
+func (c *Collector) Skip(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) bool {
+	id := bundle.ObjectID(gvr, obj.GetNamespace(), obj.GetName())
+	prev, ok := c.base.Objects[id]
+	if !ok || obj.GetResourceVersion() == "" || prev.ResourceVersion != obj.GetResourceVersion() {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.manifest.Objects[id] = prev
+	return true
+}
+
+// Saved records obj, saved in file path of the working directory, as collected.
+// It implements k8s.ObjectFilter.
+func (c *Collector) Saved(gvr schema.GroupVersionResource, obj *unstructured.Unstructured, path string) {
+	rel, ok := c.Rel(path)
+	if !ok || obj.GetResourceVersion() == "" {
+		return
+	}
+	id := bundle.ObjectID(gvr, obj.GetNamespace(), obj.GetName())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.manifest.Objects[id] = bundle.ObjectEntry{ResourceVersion: obj.GetResourceVersion(), Path: rel}
+}
+
+// Save writes the manifest in the working directory
+func (c *Collector) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.manifest.Write(c.workdir); err != nil {
+		return fmt.Errorf("incremental: failed to save manifest: %w", err)
+	}
+	return nil
+}
+
+// Manifest returns a copy of the manifest recorded so far
+func (c *Collector) Manifest() bundle.Manifest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	manifest := *c.manifest
+	manifest.Files = make(map[string]bundle.FileEntry, len(c.manifest.Files))
+	for name, entry := range c.manifest.Files {
+		manifest.Files[name] = entry
+	}
+	manifest.Objects = make(map[string]bundle.ObjectEntry, len(c.manifest.Objects))
+	for id, entry := range c.manifest.Objects {
+		manifest.Objects[id] = entry
+	}
+	return manifest
+}
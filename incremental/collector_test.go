@@ -0,0 +1,161 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package incremental
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/vmware-tanzu/crash-diagnostics/bundle"
+)
+
+const testPodList = `{"apiVersion":"v1","kind":"PodList","metadata":{},"items":[
+{"apiVersion":"v1","kind":"Pod","metadata":{"name":"etcd","namespace":"kube-system","resourceVersion":"10"}},
+{"apiVersion":"v1","kind":"Pod","metadata":{"name":"coredns","namespace":"kube-system","resourceVersion":"20"}}
+]}`
+
+var podsGVR = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+func writeTestFile(t *testing.T, root, name, content string, mtime time.Time) string {
+	t.Helper()
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0744); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func makeTestPod(name, resourceVersion string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("Pod")
+	obj.SetNamespace("kube-system")
+	obj.SetName(name)
+	obj.SetResourceVersion(resourceVersion)
+	return obj
+}
+
+func TestParseRemoteFiles(t *testing.T) {
+	sum := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	output := sum + "  /var/log/syslog\n" +
+		sum + "  /var/log/kern log\n" +
+		listSeparator + "\n" +
+		"1600000000 42 /var/log/syslog\n" +
+		"1600000001 0 /var/log/kern log\n" +
+		"1600000002 7 /var/log/unreadable\n"
+
+	files, err := ParseRemoteFiles(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("unexpected files: %+v", files)
+	}
+	if files[0].Path != "/var/log/kern log" || files[0].Entry.ModTime != 1600000001 {
+		t.Errorf("unexpected file: %+v", files[0])
+	}
+	if files[1].Path != "/var/log/syslog" || files[1].Entry.Size != 42 || files[1].Entry.SHA256 != sum {
+		t.Errorf("unexpected file: %+v", files[1])
+	}
+
+	if _, err := ParseRemoteFiles("find: /var/log/missing: No such file or directory"); err == nil {
+		t.Error("expecting error for output without separator")
+	}
+	if _, err := ParseRemoteFiles("abc /var/log/syslog\n" + listSeparator + "\n"); err == nil {
+		t.Error("expecting error for invalid checksum")
+	}
+}
+
+func TestCollector(t *testing.T) {
+	mtime := time.Unix(1600000000, 0)
+	base := t.TempDir()
+	syslog := writeTestFile(t, base, "node/var/log/syslog", "syslog", mtime)
+	writeTestFile(t, base, "kubecapture/core_v1/kube-system/pods-2020-01-01T01-00-00Z.0000.json", testPodList, mtime)
+	syslogEntry, err := fileEntry(syslog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the base has no manifest, its content is scanned
+	workdir := t.TempDir()
+	collector, err := New(base, workdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !collector.UnchangedFile(filepath.Join(workdir, "node/var/log/syslog"), syslogEntry) {
+		t.Error("expecting unchanged syslog")
+	}
+	changed := syslogEntry
+	changed.ModTime++
+	if collector.UnchangedFile(filepath.Join(workdir, "node/var/log/syslog"), changed) {
+		t.Error("expecting changed syslog when its mtime changed")
+	}
+	kern := bundle.FileEntry{SHA256: "abc", ModTime: 1, Size: 3}
+	if collector.UnchangedFile(filepath.Join(workdir, "node/var/log/kern.log"), kern) {
+		t.Error("expecting new file to be changed")
+	}
+	collector.AddFile(filepath.Join(workdir, "node/var/log/kern.log"), kern)
+	collector.AddFile(filepath.Join(t.TempDir(), "outside.log"), kern)
+
+	if !collector.Skip(podsGVR, makeTestPod("etcd", "10")) {
+		t.Error("expecting unchanged etcd pod to be skipped")
+	}
+	coredns := makeTestPod("coredns", "21")
+	if collector.Skip(podsGVR, coredns) {
+		t.Error("expecting changed coredns pod to be saved")
+	}
+	collector.Saved(podsGVR, coredns, filepath.Join(workdir, "kubecapture/core_v1/kube-system/pods.json"))
+
+	if err := collector.Save(); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := bundle.ReadManifest(workdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != 2 || manifest.Files["node/var/log/syslog"].Ref != base || manifest.Files["node/var/log/kern.log"].Ref != "" {
+		t.Errorf("unexpected files: %+v", manifest.Files)
+	}
+	etcd := manifest.Objects[bundle.ObjectID(podsGVR, "kube-system", "etcd")]
+	if len(manifest.Objects) != 2 || etcd.Ref != base || etcd.ResourceVersion != "10" {
+		t.Errorf("unexpected objects: %+v", manifest.Objects)
+	}
+
+	// the next collection compares to the manifest, and references the bundles holding the content
+	next, err := New(workdir, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !next.UnchangedFile(filepath.Join(next.workdir, "node/var/log/kern.log"), kern) {
+		t.Error("expecting unchanged kern.log")
+	}
+	if !next.Skip(podsGVR, makeTestPod("etcd", "10")) || !next.Skip(podsGVR, makeTestPod("coredns", "21")) {
+		t.Error("expecting unchanged pods to be skipped")
+	}
+	refs := next.Manifest()
+	if refs.Files["node/var/log/kern.log"].Ref != workdir || refs.Objects[bundle.ObjectID(podsGVR, "kube-system", "etcd")].Ref != base {
+		t.Errorf("unexpected references: %+v", refs)
+	}
+}
+
+func TestNewMissingBase(t *testing.T) {
+	collector, err := New(filepath.Join(t.TempDir(), "missing.tar.gz"), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collector.Skip(podsGVR, makeTestPod("etcd", "10")) {
+		t.Error("expecting everything to be collected without base")
+	}
+}
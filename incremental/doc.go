@@ -0,0 +1,6 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package incremental supports collections that only save the content changed since
+// a base bundle, referencing the unchanged content in the manifest of the bundle.
+package incremental
@@ -0,0 +1,90 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package incremental
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vmware-tanzu/crash-diagnostics/bundle"
+)
+
+// RemoteFile is a file listed on a host
+type RemoteFile struct {
+	Path  string
+	Entry bundle.FileEntry
+}
+
+// listSeparator separates the checksums from the stats in the output of ListCmd
+const listSeparator = "--crashd-stat--"
+
+// ListCmd returns the shell command listing the sha256 checksum, modification time and
+// size of the files at path on a host. The path can be a file, a directory, or contain
+// shell wildcards. Both listings are produced by one command so they run in one session.
+func ListCmd(path string) string {
+	return fmt.Sprintf("find %[1]s -type f -exec sha256sum {} + ; echo %[2]s ; find %[1]s -type f -exec stat -c '%%Y %%s %%n' {} +", path, listSeparator)
+}
+
+// ParseRemoteFiles returns the files listed in the output of the command returned
+// by ListCmd, sorted by path
+func ParseRemoteFiles(output string) ([]RemoteFile, error) {
+	checksums, stats, ok := strings.Cut(output, listSeparator+"\n")
+	if !ok {
+		checksums, stats, ok = strings.Cut(output, listSeparator)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unexpected file listing: %s", output)
+	}
+	return parseRemoteFiles(checksums, stats)
+}
+
+// parseRemoteFiles returns the files listed in the checksum and stat listings.
+// Files missing from either listing are ignored.
+func parseRemoteFiles(checksums, stats string) ([]RemoteFile, error) {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(checksums))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		// sha256sum prints the checksum and the path separated by two characters
+		sum, path, ok := strings.Cut(line, " ")
+		if !ok || len(sum) != 64 || len(path) < 2 {
+			return nil, fmt.Errorf("unexpected checksum line: %s", line)
+		}
+		sums[path[1:]] = sum
+	}
+
+	var files []RemoteFile
+	scanner = bufio.NewScanner(strings.NewReader(stats))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected stat line: %s", line)
+		}
+		mtime, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected stat line: %s", line)
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected stat line: %s", line)
+		}
+		sum, ok := sums[fields[2]]
+		if !ok {
+			continue
+		}
+		files = append(files, RemoteFile{Path: fields[2], Entry: bundle.FileEntry{SHA256: sum, ModTime: mtime, Size: size}})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
@@ -1,27 +1,65 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
 package k8s
 
 import (
+	"context"
 	"fmt"
-	"strings"
 
-	"github.com/vladimirvivien/gexe"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
-func FetchBastionIpAddress(clusterName, namespace, kubeConfigPath string) (string, error) {
+// awsClusterGroupKind identifies the infrastructure objects of the Cluster API AWS provider (CAPA)
+var awsClusterGroupKind = schema.GroupKind{Group: "infrastructure.cluster.x-k8s.io", Kind: "AWSCluster"}
+
+// FetchBastionIpAddress returns the public IP address of the bastion host of a CAPA
+// cluster using the management cluster kubeconfig at kubeConfigPath
+func FetchBastionIpAddress(ctx context.Context, clusterName, namespace, kubeConfigPath string) (string, error) {
+	client, err := New(kubeConfigPath)
+	if err != nil {
+		return "", fmt.Errorf("could not initialize client: %w", err)
+	}
+	return client.FetchBastionIpAddress(ctx, clusterName, namespace)
+}
+
+// FetchBastionIpAddress returns the public IP address of the bastion host of the CAPA
+// cluster, read from the status of its AWSCluster object. It returns an error wrapping
+// ErrClusterNotFound if the AWSCluster does not exist, or ErrBastionDisabled if the
+// cluster is not configured with a bastion host.
+func (k8sc *Client) FetchBastionIpAddress(ctx context.Context, clusterName, namespace string) (string, error) {
 	if namespace == "" {
 		namespace = "default"
 	}
-	p := gexe.RunProc(fmt.Sprintf(
-		`kubectl get awscluster -l cluster.x-k8s.io/cluster-name=%s -o jsonpath='{.status.bastion.publicIp}' --namespace %s --kubeconfig %s`,
-		clusterName,
-		namespace,
-		kubeConfigPath,
-	))
-
-	if p.Err() != nil {
-		return "", fmt.Errorf("kubectl get awscluster failed: %s: %s", p.Err(), p.Result())
+
+	mapping, err := k8sc.Mapper.RESTMapping(awsClusterGroupKind)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return "", fmt.Errorf("%w: %s/%s: %s", ErrClusterNotFound, namespace, clusterName, err)
+		}
+		return "", fmt.Errorf("failed to find awscluster resource: %w", err)
 	}
 
-	result := strings.TrimSpace(p.Result())
-	return strings.ReplaceAll(result, "'", ""), nil
+	list, err := k8sc.Client.Resource(mapping.Resource).Namespace(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", ClusterNameLabel, clusterName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get awscluster: %w", err)
+	}
+	if len(list.Items) == 0 {
+		return "", fmt.Errorf("%w: no awscluster for cluster %s/%s", ErrClusterNotFound, namespace, clusterName)
+	}
+
+	awsCluster := list.Items[0].Object
+	ipAddr, _, _ := unstructured.NestedString(awsCluster, "status", "bastion", "publicIp")
+	if ipAddr != "" {
+		return ipAddr, nil
+	}
+	if enabled, _, _ := unstructured.NestedBool(awsCluster, "spec", "bastion", "enabled"); !enabled {
+		return "", fmt.Errorf("%w: cluster %s/%s", ErrBastionDisabled, namespace, clusterName)
+	}
+	return "", fmt.Errorf("bastion of cluster %s/%s has no public IP address", namespace, clusterName)
 }
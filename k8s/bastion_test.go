@@ -0,0 +1,143 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+var (
+	testClusterGVR    = schema.GroupVersionResource{Group: ClusterAPIGroup, Version: "v1beta1", Resource: "clusters"}
+	testMachineGVR    = schema.GroupVersionResource{Group: ClusterAPIGroup, Version: "v1beta1", Resource: "machines"}
+	testAWSClusterGVR = schema.GroupVersionResource{Group: "infrastructure.cluster.x-k8s.io", Version: "v1beta2", Resource: "awsclusters"}
+
+	testCAPIKinds = map[schema.GroupVersionResource]string{
+		testClusterGVR:    "Cluster",
+		testMachineGVR:    "Machine",
+		testAWSClusterGVR: "AWSCluster",
+	}
+)
+
+// newFakeCAPIClient returns a client for a fake management cluster serving
+// Cluster API clusters and machines, and AWS clusters
+func newFakeCAPIClient(objs []runtime.Object, typedObjs ...runtime.Object) *Client {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{testClusterGVR.GroupVersion(), testAWSClusterGVR.GroupVersion()})
+	listKinds := make(map[schema.GroupVersionResource]string)
+	for gvr, kind := range testCAPIKinds {
+		mapper.Add(gvr.GroupVersion().WithKind(kind), meta.RESTScopeNamespace)
+		listKinds[gvr] = kind + "List"
+	}
+
+	dynClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objs...)
+	return &Client{Client: dynClient, Typed: kubefake.NewSimpleClientset(typedObjs...), Mapper: mapper}
+}
+
+func newTestObject(gvr schema.GroupVersionResource, kind, namespace, name string, fields map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: fields}
+	obj.SetAPIVersion(gvr.GroupVersion().String())
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetLabels(map[string]string{ClusterNameLabel: name})
+	return obj
+}
+
+func TestFetchBastionIpAddress(t *testing.T) {
+	client := newFakeCAPIClient([]runtime.Object{
+		newTestObject(testAWSClusterGVR, "AWSCluster", "default", "with-bastion", map[string]interface{}{
+			"spec":   map[string]interface{}{"bastion": map[string]interface{}{"enabled": true}},
+			"status": map[string]interface{}{"bastion": map[string]interface{}{"publicIp": "10.0.0.10"}},
+		}),
+		newTestObject(testAWSClusterGVR, "AWSCluster", "default", "no-bastion", map[string]interface{}{}),
+		newTestObject(testAWSClusterGVR, "AWSCluster", "default", "pending-bastion", map[string]interface{}{
+			"spec": map[string]interface{}{"bastion": map[string]interface{}{"enabled": true}},
+		}),
+	})
+
+	tests := []struct {
+		cluster string
+		ipAddr  string
+		err     error
+	}{
+		{cluster: "with-bastion", ipAddr: "10.0.0.10"},
+		{cluster: "no-bastion", err: ErrBastionDisabled},
+		{cluster: "missing", err: ErrClusterNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.cluster, func(t *testing.T) {
+			ipAddr, err := client.FetchBastionIpAddress(context.TODO(), test.cluster, "")
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ipAddr != test.ipAddr {
+				t.Errorf("unexpected bastion address: %s", ipAddr)
+			}
+		})
+	}
+
+	_, err := client.FetchBastionIpAddress(context.TODO(), "pending-bastion", "default")
+	if err == nil || errors.Is(err, ErrBastionDisabled) {
+		t.Errorf("expected bastion address error for pending bastion, got %v", err)
+	}
+}
+
+func TestWorkloadKubeConfig(t *testing.T) {
+	client := newFakeCAPIClient(
+		[]runtime.Object{
+			newTestObject(testClusterGVR, "Cluster", "prod", "wl-0", map[string]interface{}{
+				"status": map[string]interface{}{"phase": "Provisioned"},
+			}),
+			newTestObject(testClusterGVR, "Cluster", "prod", "wl-1", map[string]interface{}{}),
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "prod", Name: "wl-0-kubeconfig"},
+			Data:       map[string][]byte{"value": []byte("apiVersion: v1\nkind: Config\n")},
+		},
+	)
+
+	cluster, err := client.GetWorkloadCluster(context.TODO(), "wl-0", "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cluster.Phase != "Provisioned" {
+		t.Errorf("unexpected cluster phase: %s", cluster.Phase)
+	}
+	path, err := client.WriteWorkloadKubeConfig(context.TODO(), cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "apiVersion: v1\nkind: Config\n" {
+		t.Errorf("unexpected kubeconfig: %s", data)
+	}
+
+	if _, err := client.GetWorkloadCluster(context.TODO(), "wl-0", "default"); !errors.Is(err, ErrClusterNotFound) {
+		t.Errorf("expected cluster not found, got %v", err)
+	}
+	if _, err := client.WriteWorkloadKubeConfig(context.TODO(), WorkloadCluster{Name: "wl-1", Namespace: "prod"}); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("expected secret not found, got %v", err)
+	}
+}
@@ -25,7 +25,6 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/restmapper"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/portforward"
 	"k8s.io/client-go/transport/spdy"
 )
@@ -60,18 +59,23 @@ func New(kubeconfig string, clusterContextOptions ...string) (*Client, error) {
 		clusterCtxName = clusterContextOptions[0]
 	}
 
+	return NewForOptions(ConfigOptions{Path: kubeconfig, Context: clusterCtxName})
+}
+
+// NewForOptions returns a *Client for the cluster configured by opts
+func NewForOptions(opts ConfigOptions) (*Client, error) {
 	// creating cfg for each client type because each
 	// setup needs its own cfg default which may not be compatible
-	dynCfg, err := makeRESTConfig(kubeconfig, clusterCtxName)
+	dynCfg, err := opts.RESTConfig()
 	if err != nil {
 		return nil, err
 	}
-	discoCfg, err := makeRESTConfig(kubeconfig, clusterCtxName)
+	discoCfg, err := opts.RESTConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	restCfg, err := makeRESTConfig(kubeconfig, clusterCtxName)
+	restCfg, err := opts.RESTConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -111,23 +115,7 @@ func newClient(dynCfg, discoCfg, restCfg *rest.Config) (*Client, error) {
 
 // makeRESTConfig creates a new *rest.Config with a k8s context name if one is provided.
 func makeRESTConfig(fileName, contextName string) (*rest.Config, error) {
-	if fileName == "" {
-		return nil, errors.New("kubeconfig file path required")
-	}
-
-	if contextName != "" {
-		// create the config object from k8s config path and context
-		return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-			&clientcmd.ClientConfigLoadingRules{ExplicitPath: fileName},
-			&clientcmd.ConfigOverrides{
-				CurrentContext: contextName,
-			}).ClientConfig()
-	}
-
-	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		&clientcmd.ClientConfigLoadingRules{ExplicitPath: fileName},
-		&clientcmd.ConfigOverrides{},
-	).ClientConfig()
+	return ConfigOptions{Path: fileName, Context: contextName}.RESTConfig()
 }
 
 func NewPortForwarder(kubeconfigPath, portForwardNS, portForwardPodName string, localPort, targetPort int, stopCh <-chan struct{}, readyCh chan struct{}) (*portforward.PortForwarder, error) {
@@ -135,6 +123,10 @@ func NewPortForwarder(kubeconfigPath, portForwardNS, portForwardPodName string,
 	if err != nil {
 		return nil, err
 	}
+	return newPortForwarder(restConfig, portForwardNS, portForwardPodName, localPort, targetPort, stopCh, readyCh)
+}
+
+func newPortForwarder(restConfig *rest.Config, portForwardNS, portForwardPodName string, localPort, targetPort int, stopCh <-chan struct{}, readyCh chan struct{}) (*portforward.PortForwarder, error) {
 	portForwardPath := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/portforward",
 		portForwardNS, portForwardPodName)
 	hostIP := strings.TrimPrefix(restConfig.Host, "https://")
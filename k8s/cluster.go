@@ -5,14 +5,25 @@ package k8s
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"sort"
 
-	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 type Config interface {
 	GetClusterName() (string, error)
 	GetCurrentContext() string
+	GetCertificates() ([]ConfigCertificate, error)
+}
+
+// ConfigCertificate is a PEM certificate of a kubeconfig, a cluster certificate authority
+// or a user client certificate
+type ConfigCertificate struct {
+	// Source locates the certificate, i.e. cluster/kind-kind or user/kind-kind
+	Source string
+	Data   []byte
 }
 
 type KubeConfig struct {
@@ -32,10 +43,49 @@ func (kcfg *KubeConfig) GetCurrentContext() string {
 	return kcfg.config.CurrentContext
 }
 
+// LoadKubeCfg loads the kubeconfig files listed in kubeConfigPath, merged as with KUBECONFIG
 func LoadKubeCfg(kubeConfigPath string) (Config, error) {
-	cfg, err := clientcmd.LoadFromFile(kubeConfigPath)
+	cfg, err := LoadKubeConfigFiles(kubeConfigPath)
 	if err != nil {
 		return nil, err
 	}
 	return &KubeConfig{config: cfg}, nil
 }
+
+// GetCertificates returns the certificate authorities of the clusters and the client
+// certificates of the users of the kubeconfig, embedded or read from their files
+func (kcfg *KubeConfig) GetCertificates() ([]ConfigCertificate, error) {
+	var certs []ConfigCertificate
+	var errs []error
+	add := func(source string, data []byte, path string) {
+		if len(data) == 0 && path != "" {
+			var err error
+			if data, err = os.ReadFile(path); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", source, err))
+				return
+			}
+		}
+		if len(data) > 0 {
+			certs = append(certs, ConfigCertificate{Source: source, Data: data})
+		}
+	}
+
+	for _, name := range sortedKeys(kcfg.config.Clusters) {
+		cluster := kcfg.config.Clusters[name]
+		add("cluster/"+name, cluster.CertificateAuthorityData, cluster.CertificateAuthority)
+	}
+	for _, name := range sortedKeys(kcfg.config.AuthInfos) {
+		user := kcfg.config.AuthInfos[name]
+		add("user/"+name, user.ClientCertificateData, user.ClientCertificate)
+	}
+	return certs, errors.Join(errs...)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
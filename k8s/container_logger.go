@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 
 	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -37,7 +38,8 @@ func (c ContainerLogsImpl) Fetch(ctx context.Context, restApi rest.Interface) (i
 	return stream, err
 }
 
-func (c ContainerLogsImpl) Write(reader io.ReadCloser, rootDir string) error {
+// Write saves the container log read from reader in rootDir, truncated to stay within limit
+func (c ContainerLogsImpl) Write(reader io.ReadCloser, rootDir string, limit *budget.Budget) error {
 	containerLogDir := filepath.Join(rootDir, c.container.Name)
 	if err := os.MkdirAll(containerLogDir, 0744); err != nil && !os.IsExist(err) {
 		return fmt.Errorf("error creating container log dir: %s", err)
@@ -53,7 +55,7 @@ func (c ContainerLogsImpl) Write(reader io.ReadCloser, rootDir string) error {
 	defer file.Close()
 
 	defer reader.Close()
-	if _, err := io.Copy(file, reader); err != nil {
+	if _, err := limit.Copy(path, file, reader); err != nil {
 		cpErr := fmt.Errorf("failed to copy container log:\n%s", err)
 		if wErr := writeError(cpErr, file); wErr != nil {
 			return fmt.Errorf("failed to write previous err [%s] to file: %s", err, wErr)
@@ -0,0 +1,17 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import "errors"
+
+// Errors returned when looking up Cluster API objects on a management cluster.
+// They are wrapped with details and can be tested with errors.Is.
+var (
+	// ErrClusterNotFound is returned when a cluster, or its infrastructure object, does not exist
+	ErrClusterNotFound = errors.New("cluster not found")
+	// ErrBastionDisabled is returned when the infrastructure of a cluster has no bastion host
+	ErrBastionDisabled = errors.New("bastion disabled")
+	// ErrSecretNotFound is returned when a secret, or the expected key of a secret, is missing
+	ErrSecretNotFound = errors.New("secret not found")
+)
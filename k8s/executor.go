@@ -5,15 +5,14 @@ package k8s
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"io"
 	"os"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/remotecommand"
 )
 
@@ -32,11 +31,18 @@ type ExecOptions struct {
 	Timeout       time.Duration
 }
 
-func NewExecutor(kubeconfig string, clusterCtxName string, opts ExecOptions) (*Executor, error) {
-	restCfg, err := restConfig(kubeconfig, clusterCtxName)
+// NewExecutor returns an Executor for the command of opts in the cluster of config
+func NewExecutor(config ConfigOptions, opts ExecOptions) (*Executor, error) {
+	restCfg, err := config.RESTConfig()
 	if err != nil {
 		return nil, err
 	}
+	return NewExecutorForConfig(restCfg, opts)
+}
+
+// NewExecutorForConfig returns an Executor for the command of opts using the REST configuration restConfig
+func NewExecutorForConfig(restConfig *rest.Config, opts ExecOptions) (*Executor, error) {
+	restCfg := rest.CopyConfig(restConfig)
 	setCoreDefaultConfig(restCfg)
 	restc, err := rest.RESTClientFor(restCfg)
 	if err != nil {
@@ -63,27 +69,6 @@ func NewExecutor(kubeconfig string, clusterCtxName string, opts ExecOptions) (*E
 	return &Executor{Executor: executor}, nil
 }
 
-// makeRESTConfig creates a new *rest.Config with a k8s context name if one is provided.
-func restConfig(fileName, contextName string) (*rest.Config, error) {
-	if fileName == "" {
-		return nil, errors.New("kubeconfig file path required")
-	}
-
-	if contextName != "" {
-		// create the config object from k8s config path and context
-		return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-			&clientcmd.ClientConfigLoadingRules{ExplicitPath: fileName},
-			&clientcmd.ConfigOverrides{
-				CurrentContext: contextName,
-			}).ClientConfig()
-	}
-
-	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		&clientcmd.ClientConfigLoadingRules{ExplicitPath: fileName},
-		&clientcmd.ConfigOverrides{},
-	).ClientConfig()
-}
-
 // ExecCommand executes a command inside a specified Kubernetes pod using the SPDYExecutor.
 func (k8sc *Executor) ExecCommand(ctx context.Context, outputFilePath string, execOptions ExecOptions) error {
 	ctx, cancel := context.WithTimeout(ctx, execOptions.Timeout)
@@ -96,10 +81,7 @@ func (k8sc *Executor) ExecCommand(ctx context.Context, outputFilePath string, ex
 	defer file.Close()
 
 	// Execute the command and stream the stdout and stderr to the file. Some commands are using stderr.
-	err = k8sc.Executor.StreamWithContext(ctx, remotecommand.StreamOptions{
-		Stdout: file,
-		Stderr: file,
-	})
+	err = k8sc.Stream(ctx, file, file)
 	if err != nil {
 		if err == context.DeadlineExceeded {
 			return fmt.Errorf("command execution timed out. command:%s", execOptions.Command)
@@ -109,3 +91,11 @@ func (k8sc *Executor) ExecCommand(ctx context.Context, outputFilePath string, ex
 
 	return nil
 }
+
+// Stream executes the command and streams its standard output and error to stdout and stderr
+func (k8sc *Executor) Stream(ctx context.Context, stdout, stderr io.Writer) error {
+	return k8sc.Executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// InfraProvider describes the infrastructure objects of a Cluster API infrastructure
+// provider and where to find the bastion host of its clusters, if it supports one
+type InfraProvider struct {
+	Name string
+	// ClusterKind is the kind of the infrastructure cluster objects, i.e. AWSCluster
+	ClusterKind string
+	// BastionAddress is the path of the bastion host address in the infrastructure cluster object
+	BastionAddress []string
+	// BastionEnabled is the path of the field enabling the bastion host in the infrastructure cluster object
+	BastionEnabled []string
+	// BastionUser is the default user name used to log in the bastion host
+	BastionUser string
+}
+
+// InfraProviders lists the known Cluster API infrastructure providers
+var InfraProviders = []InfraProvider{
+	{
+		Name:           "aws",
+		ClusterKind:    "AWSCluster",
+		BastionAddress: []string{"status", "bastion", "publicIp"},
+		BastionEnabled: []string{"spec", "bastion", "enabled"},
+		BastionUser:    "ubuntu",
+	},
+	{Name: "azure", ClusterKind: "AzureCluster"},
+	{Name: "docker", ClusterKind: "DockerCluster"},
+	{Name: "gcp", ClusterKind: "GCPCluster"},
+	{Name: "metal3", ClusterKind: "Metal3Cluster"},
+	{
+		Name:           "openstack",
+		ClusterKind:    "OpenStackCluster",
+		BastionAddress: []string{"status", "bastion", "floatingIP"},
+		BastionEnabled: []string{"spec", "bastion", "enabled"},
+	},
+	{Name: "vsphere", ClusterKind: "VSphereCluster"},
+}
+
+// Bastion is the host used to reach the machines of a cluster
+type Bastion struct {
+	Address string
+	User    string
+}
+
+// LookupInfraProvider returns the infrastructure provider with the given
+// name (i.e. aws) or the given infrastructure cluster kind (i.e. AWSCluster)
+func LookupInfraProvider(nameOrKind string) (InfraProvider, bool) {
+	for _, p := range InfraProviders {
+		if strings.EqualFold(p.Name, nameOrKind) || p.ClusterKind == nameOrKind {
+			return p, true
+		}
+	}
+	return InfraProvider{}, false
+}
+
+// GetClusterBastion returns the bastion host of the cluster, if its infrastructure provider
+// declares one. The provider is selected by name with infra, or, if infra is empty, from the
+// infrastructure reference of the cluster. Unknown providers are assumed to have no bastion host.
+func (k8sc *Client) GetClusterBastion(ctx context.Context, cluster WorkloadCluster, infra string) (Bastion, error) {
+	ref := cluster.InfrastructureRef
+	if infra == "" {
+		infra = ref.Kind
+	}
+	provider, ok := LookupInfraProvider(infra)
+	if !ok {
+		logrus.Debugf("unknown infrastructure provider %q: no bastion lookup for cluster %s/%s", infra, cluster.Namespace, cluster.Name)
+		return Bastion{}, nil
+	}
+	if ref.Kind != "" && ref.Kind != provider.ClusterKind {
+		return Bastion{}, fmt.Errorf("cluster %s/%s uses infrastructure %s, not %s", cluster.Namespace, cluster.Name, ref.Kind, provider.Name)
+	}
+	if len(provider.BastionAddress) == 0 || ref.Kind == "" {
+		return Bastion{}, nil
+	}
+
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return Bastion{}, fmt.Errorf("cluster %s/%s: invalid infrastructure reference: %w", cluster.Namespace, cluster.Name, err)
+	}
+	mapping, err := k8sc.Mapper.RESTMapping(gv.WithKind(ref.Kind).GroupKind(), gv.Version)
+	if err != nil {
+		return Bastion{}, fmt.Errorf("failed to find %s resource: %w", ref.Kind, err)
+	}
+	obj, err := k8sc.Client.Resource(mapping.Resource).Namespace(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return Bastion{}, fmt.Errorf("%w: %s %s/%s", ErrClusterNotFound, ref.Kind, ref.Namespace, ref.Name)
+		}
+		return Bastion{}, fmt.Errorf("failed to get %s %s/%s: %w", ref.Kind, ref.Namespace, ref.Name, err)
+	}
+
+	address, _, _ := unstructured.NestedString(obj.Object, provider.BastionAddress...)
+	if address == "" {
+		if enabled, _, _ := unstructured.NestedBool(obj.Object, provider.BastionEnabled...); enabled {
+			return Bastion{}, fmt.Errorf("bastion of cluster %s/%s has no address", cluster.Namespace, cluster.Name)
+		}
+		return Bastion{}, nil
+	}
+	return Bastion{Address: address, User: provider.BastionUser}, nil
+}
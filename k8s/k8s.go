@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
 	"k8s.io/client-go/rest"
 )
 
@@ -12,7 +13,7 @@ const BaseDirname = "kubecapture"
 
 type Container interface {
 	Fetch(context.Context, rest.Interface) (io.ReadCloser, error)
-	Write(io.ReadCloser, string) error
+	Write(io.ReadCloser, string, *budget.Budget) error
 }
 
 func writeError(errStr error, w io.Writer) error {
@@ -4,33 +4,22 @@
 package k8s
 
 import (
-	"encoding/base64"
+	"context"
 	"fmt"
-	"io"
-	"os"
-
-	"github.com/vladimirvivien/gexe"
 )
 
-// FetchWorkloadConfig...
-func FetchWorkloadConfig(clusterName, clusterNamespace, mgmtKubeConfigPath string) (string, error) {
-	var filePath string
-	cmdStr := fmt.Sprintf(`kubectl get secrets/%s-kubeconfig --template '{{.data.value}}' --namespace=%s --kubeconfig %s`, clusterName, clusterNamespace, mgmtKubeConfigPath)
-	p := gexe.RunProc(cmdStr)
-	if p.Err() != nil {
-		return filePath, fmt.Errorf("kubectl get secrets failed: %s: %s", p.Err(), p.Result())
-	}
-
-	f, err := os.CreateTemp(os.TempDir(), fmt.Sprintf("%s-workload-config", clusterName))
+// FetchWorkloadConfig saves the kubeconfig of a workload cluster, read from the management
+// cluster at mgmtKubeConfigPath, to a temporary file and returns its path. It returns an error
+// wrapping ErrClusterNotFound or ErrSecretNotFound if the cluster or its kubeconfig secret are missing.
+func FetchWorkloadConfig(ctx context.Context, clusterName, clusterNamespace, mgmtKubeConfigPath string) (string, error) {
+	client, err := New(mgmtKubeConfigPath)
 	if err != nil {
-		return filePath, fmt.Errorf("Cannot create temporary file: %w", err)
+		return "", fmt.Errorf("could not initialize client: %w", err)
 	}
-	filePath = f.Name()
-	defer f.Close()
 
-	base64Dec := base64.NewDecoder(base64.StdEncoding, p.Out())
-	if _, err := io.Copy(f, base64Dec); err != nil {
-		return filePath, fmt.Errorf("error decoding workload kubeconfig: %w", err)
+	cluster, err := client.GetWorkloadCluster(ctx, clusterName, clusterNamespace)
+	if err != nil {
+		return "", err
 	}
-	return filePath, nil
+	return client.WriteWorkloadKubeConfig(ctx, cluster)
 }
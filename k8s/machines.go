@@ -0,0 +1,80 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var machineGroupKind = schema.GroupKind{Group: ClusterAPIGroup, Kind: "Machine"}
+
+// DefaultMachineAddressTypes is the order in which the addresses of a machine are selected
+var DefaultMachineAddressTypes = []string{"InternalIP", "ExternalIP", "InternalDNS", "ExternalDNS", "Hostname"}
+
+// GetMachineAddresses returns an address for each Cluster API machine of the cluster, read
+// from Machine.status.addresses. Machines can be filtered by their name or the name of their
+// node, and by label selectors. The first address found in the order of addressTypes
+// (default DefaultMachineAddressTypes) is selected. Machines without address are skipped.
+func (k8sc *Client) GetMachineAddresses(ctx context.Context, cluster WorkloadCluster, names, labels, addressTypes []string) ([]string, error) {
+	mapping, err := k8sc.Mapper.RESTMapping(machineGroupKind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find machine resource: %w", err)
+	}
+
+	selector := append([]string{fmt.Sprintf("%s=%s", ClusterNameLabel, cluster.Name)}, labels...)
+	list, err := k8sc.Client.Resource(mapping.Resource).Namespace(cluster.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: strings.Join(selector, ","),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get machines of cluster %s/%s: %w", cluster.Namespace, cluster.Name, err)
+	}
+
+	if len(addressTypes) == 0 {
+		addressTypes = DefaultMachineAddressTypes
+	}
+
+	machines := list.Items
+	sort.Slice(machines, func(i, j int) bool { return machines[i].GetName() < machines[j].GetName() })
+
+	var addresses []string
+	for _, machine := range machines {
+		nodeName, _, _ := unstructured.NestedString(machine.Object, "status", "nodeRef", "name")
+		if len(names) > 0 && !sliceContains(names, machine.GetName()) && !sliceContains(names, nodeName) {
+			continue
+		}
+		address := getMachineAddress(&machine, addressTypes)
+		if address == "" {
+			logrus.Debugf("machine %s/%s has no address of type %v, skipping", machine.GetNamespace(), machine.GetName(), addressTypes)
+			continue
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+func getMachineAddress(machine *unstructured.Unstructured, addressTypes []string) string {
+	addrs, _, _ := unstructured.NestedSlice(machine.Object, "status", "addresses")
+	for _, addrType := range addressTypes {
+		for _, a := range addrs {
+			addr, ok := a.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if addr["type"] == addrType {
+				if address, ok := addr["address"].(string); ok && address != "" {
+					return address
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newTestMachine(cluster, name, node string, addresses ...string) runtime.Object {
+	var addrs []interface{}
+	for i := 0; i+1 < len(addresses); i += 2 {
+		addrs = append(addrs, map[string]interface{}{"type": addresses[i], "address": addresses[i+1]})
+	}
+	machine := newTestObject(testMachineGVR, "Machine", "prod", name, map[string]interface{}{
+		"status": map[string]interface{}{
+			"nodeRef":   map[string]interface{}{"name": node},
+			"addresses": addrs,
+		},
+	})
+	machine.SetLabels(map[string]string{ClusterNameLabel: cluster})
+	return machine
+}
+
+func TestGetMachineAddresses(t *testing.T) {
+	client := newFakeCAPIClient([]runtime.Object{
+		newTestMachine("wl-0", "wl-0-md-1", "node-1", "Hostname", "node-1", "ExternalIP", "34.0.0.1", "InternalIP", "10.0.0.1"),
+		newTestMachine("wl-0", "wl-0-md-0", "node-0", "InternalIP", "10.0.0.0"),
+		newTestMachine("wl-0", "wl-0-md-2", "node-2"),
+		newTestMachine("wl-1", "wl-1-md-0", "node-3", "InternalIP", "10.0.1.0"),
+	})
+	cluster := WorkloadCluster{Name: "wl-0", Namespace: "prod"}
+
+	tests := []struct {
+		name         string
+		names        []string
+		addressTypes []string
+		expected     []string
+	}{
+		{name: "all machines", expected: []string{"10.0.0.0", "10.0.0.1"}},
+		{name: "by node name", names: []string{"node-1"}, expected: []string{"10.0.0.1"}},
+		{name: "by machine name", names: []string{"wl-0-md-0"}, expected: []string{"10.0.0.0"}},
+		{name: "external addresses", addressTypes: []string{"ExternalIP"}, expected: []string{"34.0.0.1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			addresses, err := client.GetMachineAddresses(context.TODO(), cluster, test.names, nil, test.addressTypes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(addresses, test.expected) {
+				t.Errorf("unexpected addresses: %v", addresses)
+			}
+		})
+	}
+}
+
+func TestGetClusterBastion(t *testing.T) {
+	client := newFakeCAPIClient([]runtime.Object{
+		newTestObject(testAWSClusterGVR, "AWSCluster", "prod", "aws-0", map[string]interface{}{
+			"status": map[string]interface{}{"bastion": map[string]interface{}{"publicIp": "34.0.0.10"}},
+		}),
+	})
+	awsRef := corev1.ObjectReference{APIVersion: testAWSClusterGVR.GroupVersion().String(), Kind: "AWSCluster", Namespace: "prod", Name: "aws-0"}
+
+	tests := []struct {
+		name     string
+		cluster  WorkloadCluster
+		infra    string
+		expected Bastion
+		fails    bool
+	}{
+		{
+			name:     "infra from reference",
+			cluster:  WorkloadCluster{Name: "aws-0", Namespace: "prod", InfrastructureRef: awsRef},
+			expected: Bastion{Address: "34.0.0.10", User: "ubuntu"},
+		},
+		{
+			name:    "provider without bastion",
+			cluster: WorkloadCluster{Name: "docker-0", Namespace: "prod", InfrastructureRef: corev1.ObjectReference{Kind: "DockerCluster"}},
+			infra:   "docker",
+		},
+		{
+			name:    "unknown provider",
+			cluster: WorkloadCluster{Name: "other-0", Namespace: "prod", InfrastructureRef: corev1.ObjectReference{Kind: "OtherCluster"}},
+		},
+		{
+			name:    "mismatched provider",
+			cluster: WorkloadCluster{Name: "aws-0", Namespace: "prod", InfrastructureRef: awsRef},
+			infra:   "azure",
+			fails:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bastion, err := client.GetClusterBastion(context.TODO(), test.cluster, test.infra)
+			if test.fails {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bastion != test.expected {
+				t.Errorf("unexpected bastion: %+v", bastion)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// DefaultNodeDebugImage is the image of the node debug pods. It must provide sh, chroot, and tar.
+	DefaultNodeDebugImage = "busybox:1.36"
+	// NodeDebugLabel labels the node debug pods created by crashd
+	NodeDebugLabel = "crashd.vmware-tanzu.io/node-debug"
+
+	nodeDebugContainer      = "debug"
+	nodeDebugHostPath       = "/host"
+	defaultNodeDebugTimeout = 2 * time.Minute
+)
+
+// NodeDebugOptions configures the pods used to access nodes
+type NodeDebugOptions struct {
+	// Namespace of the debug pods, default "default"
+	Namespace string
+	// Image of the debug pods, default DefaultNodeDebugImage
+	Image string
+	// Timeout bounds the lifetime of a debug pod, including its startup, default 2 minutes
+	Timeout time.Duration
+}
+
+// NodeDebugger runs commands and copies files on the nodes of a cluster without SSH access.
+// Each operation runs in a privileged pod created on the node, sharing the host PID and
+// network namespaces and mounting the host root filesystem at /host. The pod is deleted
+// when the operation completes.
+type NodeDebugger struct {
+	client *Client
+	opts   NodeDebugOptions
+}
+
+// NewNodeDebugger returns a NodeDebugger creating pods with client
+func NewNodeDebugger(client *Client, opts NodeDebugOptions) *NodeDebugger {
+	if opts.Namespace == "" {
+		opts.Namespace = "default"
+	}
+	if opts.Image == "" {
+		opts.Image = DefaultNodeDebugImage
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultNodeDebugTimeout
+	}
+	return &NodeDebugger{client: client, opts: opts}
+}
+
+// Run runs shell command cmdStr on node, in the host root filesystem, and streams its
+// standard output and error to stdout and stderr
+func (d *NodeDebugger) Run(ctx context.Context, node, cmdStr string, stdout, stderr io.Writer) error {
+	return d.withPod(ctx, node, func(ctx context.Context, pod *corev1.Pod) error {
+		cmd := []string{"chroot", nodeDebugHostPath, "/bin/sh", "-c", cmdStr}
+		return d.client.ExecInContainer(ctx, pod.Namespace, pod.Name, nodeDebugContainer, cmd, stdout, stderr)
+	})
+}
+
+// CopyFrom copies path, which may contain shell wildcards, from the filesystem of node into
+// directory destDir under its full path (i.e. /var/log/syslog is saved in <destDir>/var/log/syslog)
+func (d *NodeDebugger) CopyFrom(ctx context.Context, node, path, destDir string) error {
+	return d.withPod(ctx, node, func(ctx context.Context, pod *corev1.Pod) error {
+		relPath := strings.TrimLeft(path, "/")
+		if relPath == "" {
+			relPath = "."
+		}
+		cmd := []string{"/bin/sh", "-c", fmt.Sprintf("cd %s && tar cf - %s", nodeDebugHostPath, relPath)}
+		return d.client.copyFromContainer(ctx, pod.Namespace, pod.Name, nodeDebugContainer, cmd, destDir)
+	})
+}
+
+// withPod creates a debug pod on node, waits for it to run, calls fn, and deletes the pod
+func (d *NodeDebugger) withPod(ctx context.Context, node string, fn func(context.Context, *corev1.Pod) error) error {
+	ctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	defer cancel()
+
+	pods := d.client.Typed.CoreV1().Pods(d.opts.Namespace)
+	pod, err := pods.Create(ctx, newNodeDebugPod(node, d.opts), metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create debug pod on node %s: %w", node, err)
+	}
+	logrus.Debugf("created debug pod %s/%s on node %s", pod.Namespace, pod.Name, node)
+
+	defer func() {
+		// delete the pod even if ctx expired
+		zero := int64(0)
+		delCtx, delCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer delCancel()
+		if err := pods.Delete(delCtx, pod.Name, metav1.DeleteOptions{GracePeriodSeconds: &zero}); err != nil {
+			logrus.Warnf("failed to delete debug pod %s/%s: %s", pod.Namespace, pod.Name, err)
+			return
+		}
+		logrus.Debugf("deleted debug pod %s/%s", pod.Namespace, pod.Name)
+	}()
+
+	if err := d.waitForPod(ctx, pod); err != nil {
+		return fmt.Errorf("debug pod on node %s: %w", node, err)
+	}
+	return fn(ctx, pod)
+}
+
+// waitForPod waits until the pod runs
+func (d *NodeDebugger) waitForPod(ctx context.Context, pod *corev1.Pod) error {
+	var phase corev1.PodPhase
+	err := wait.PollUntilContextCancel(ctx, time.Second, true, func(ctx context.Context) (bool, error) {
+		current, err := d.client.Typed.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		phase = current.Status.Phase
+		switch phase {
+		case corev1.PodRunning:
+			return true, nil
+		case corev1.PodFailed, corev1.PodSucceeded:
+			return false, fmt.Errorf("pod %s/%s stopped: %s", pod.Namespace, pod.Name, phase)
+		}
+		return false, nil
+	})
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("pod %s/%s not running (phase %q): %w", pod.Namespace, pod.Name, phase, err)
+	}
+	return err
+}
+
+func newNodeDebugPod(node string, opts NodeDebugOptions) *corev1.Pod {
+	privileged := true
+	// the pod is stopped by the kubelet if crashd exits before deleting it
+	deadline := int64(opts.Timeout.Seconds()) + 60
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "crashd-node-debug-",
+			Namespace:    opts.Namespace,
+			Labels:       map[string]string{NodeDebugLabel: "true"},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:              node,
+			HostPID:               true,
+			HostNetwork:           true,
+			HostIPC:               true,
+			RestartPolicy:         corev1.RestartPolicyNever,
+			ActiveDeadlineSeconds: &deadline,
+			Tolerations:           []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			Containers: []corev1.Container{{
+				Name:    nodeDebugContainer,
+				Image:   opts.Image,
+				Command: []string{"sleep", fmt.Sprintf("%d", deadline)},
+				SecurityContext: &corev1.SecurityContext{
+					Privileged: &privileged,
+				},
+				VolumeMounts: []corev1.VolumeMount{{Name: "host-root", MountPath: nodeDebugHostPath}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name: "host-root",
+				VolumeSource: corev1.VolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{Path: "/"},
+				},
+			}},
+		},
+	}
+}
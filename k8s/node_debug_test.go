@@ -0,0 +1,71 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestNewNodeDebugPod(t *testing.T) {
+	pod := newNodeDebugPod("node-0", NodeDebugOptions{Namespace: "debug", Image: "alpine", Timeout: time.Minute})
+
+	if pod.Namespace != "debug" || pod.Labels[NodeDebugLabel] != "true" {
+		t.Errorf("unexpected pod metadata: %+v", pod.ObjectMeta)
+	}
+	if pod.Spec.NodeName != "node-0" || !pod.Spec.HostPID || !pod.Spec.HostNetwork {
+		t.Errorf("pod not scheduled on host namespaces of node-0: %+v", pod.Spec)
+	}
+	if *pod.Spec.ActiveDeadlineSeconds != 120 {
+		t.Errorf("unexpected deadline: %d", *pod.Spec.ActiveDeadlineSeconds)
+	}
+	container := pod.Spec.Containers[0]
+	if container.Image != "alpine" || !*container.SecurityContext.Privileged {
+		t.Errorf("unexpected container: %+v", container)
+	}
+	if pod.Spec.Volumes[0].HostPath.Path != "/" || container.VolumeMounts[0].MountPath != nodeDebugHostPath {
+		t.Errorf("host root not mounted: %+v", pod.Spec.Volumes)
+	}
+}
+
+func TestNodeDebuggerDeletesPod(t *testing.T) {
+	typed := kubefake.NewSimpleClientset()
+	// the fake client does not generate names nor run pods
+	typed.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+		pod.Name = pod.GenerateName + "0"
+		pod.Status.Phase = corev1.PodFailed
+		return false, nil, nil
+	})
+	debugger := NewNodeDebugger(&Client{Typed: typed}, NodeDebugOptions{Timeout: 10 * time.Second})
+
+	called := false
+	err := debugger.withPod(context.TODO(), "node-0", func(context.Context, *corev1.Pod) error {
+		called = true
+		return errors.New("unexpected call")
+	})
+	if err == nil || !strings.Contains(err.Error(), "stopped") {
+		t.Fatalf("expected stopped pod error, got %v", err)
+	}
+	if called {
+		t.Error("function called for a failed pod")
+	}
+
+	pods, err := typed.CoreV1().Pods("default").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods.Items) != 0 {
+		t.Errorf("debug pods not deleted: %d", len(pods.Items))
+	}
+}
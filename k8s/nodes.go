@@ -11,13 +11,18 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// GetNodeAddresses returns the internal IP addresses of the nodes of the cluster of kubeconfigPath
 func GetNodeAddresses(ctx context.Context, kubeconfigPath string, names, labels []string) ([]string, error) {
 	client, err := New(kubeconfigPath)
 	if err != nil {
 		return nil, fmt.Errorf("could not initialize search client: %w", err)
 	}
+	return client.GetNodeAddresses(ctx, names, labels)
+}
 
-	nodes, err := getNodes(ctx, client, names, labels)
+// GetNodeAddresses returns the internal IP addresses of the cluster nodes, optionally filtered by names and label selectors
+func (k8sc *Client) GetNodeAddresses(ctx context.Context, names, labels []string) ([]string, error) {
+	nodes, err := getNodes(ctx, k8sc, names, labels)
 	if err != nil {
 		return nil, fmt.Errorf("could not fetch nodes: %w", err)
 	}
@@ -63,3 +68,17 @@ func getNodeInternalIP(node *coreV1.Node) (ipAddr string) {
 	}
 	return
 }
+
+// GetNodeNames returns the names of the cluster nodes, optionally filtered by names and label selectors
+func (k8sc *Client) GetNodeNames(ctx context.Context, names, labels []string) ([]string, error) {
+	nodes, err := getNodes(ctx, k8sc, names, labels)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch nodes: %w", err)
+	}
+
+	var nodeNames []string
+	for _, node := range nodes {
+		nodeNames = append(nodeNames, node.Name)
+	}
+	return nodeNames, nil
+}
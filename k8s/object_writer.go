@@ -7,14 +7,26 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/printers"
 )
 
+// ObjectFilter selects the objects saved by an ObjectWriter, i.e. to skip
+// the objects unchanged since a previous capture
+type ObjectFilter interface {
+	// Skip returns true if obj must not be saved
+	Skip(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) bool
+	// Saved is called for each object saved, with the path of its file
+	Saved(gvr schema.GroupVersionResource, obj *unstructured.Unstructured, path string)
+}
+
 type ObjectWriter struct {
 	writeDir   string
 	printer    printers.ResourcePrinter
 	singleFile bool
+	filter     ObjectFilter
 }
 
 func (w *ObjectWriter) Write(result SearchResult) (string, error) {
@@ -41,30 +53,60 @@ func (w *ObjectWriter) Write(result SearchResult) (string, error) {
 		extension = "yaml"
 	}
 
+	list := w.filterList(result)
 	if w.singleFile {
 		if err := os.MkdirAll(w.writeDir, 0744); err != nil && !os.IsExist(err) {
 			return "", fmt.Errorf("failed to create search result dir: %s", err)
 		}
+		if w.filter != nil && len(list.Items) == 0 {
+			return w.writeDir, nil
+		}
 		path := filepath.Join(w.writeDir, fmt.Sprintf("%s-%s.%s", result.ResourceName, now, extension))
-		return w.writeDir, w.writeFile(result.List, path)
+		if err := w.writeFile(list, path); err != nil {
+			return w.writeDir, err
+		}
+		for i := range list.Items {
+			w.saved(result, &list.Items[i], path)
+		}
 	} else {
 		w.writeDir = filepath.Join(w.writeDir, result.ResourceName)
 		if err := os.MkdirAll(w.writeDir, 0744); err != nil && !os.IsExist(err) {
 			return "", fmt.Errorf("failed to create search result dir: %s", err)
 		}
 
-		for i := range result.List.Items {
-			u := &result.List.Items[i]
+		for i := range list.Items {
+			u := &list.Items[i]
 			path := filepath.Join(w.writeDir, fmt.Sprintf("%s-%s.%s", u.GetName(), now, extension))
 			if err := w.writeFile(u, path); err != nil {
 				return "", err
 			}
+			w.saved(result, u, path)
 		}
 	}
 
 	return w.writeDir, nil
 }
 
+// filterList returns the list of the objects of result to save
+func (w *ObjectWriter) filterList(result SearchResult) *unstructured.UnstructuredList {
+	if w.filter == nil {
+		return result.List
+	}
+	list := &unstructured.UnstructuredList{Object: result.List.Object}
+	for i := range result.List.Items {
+		if !w.filter.Skip(result.GroupVersionResource, &result.List.Items[i]) {
+			list.Items = append(list.Items, result.List.Items[i])
+		}
+	}
+	return list
+}
+
+func (w *ObjectWriter) saved(result SearchResult, obj *unstructured.Unstructured, path string) {
+	if w.filter != nil {
+		w.filter.Saved(result.GroupVersionResource, obj, path)
+	}
+}
+
 func (w *ObjectWriter) writeFile(o runtime.Object, path string) error {
 	file, err := os.Create(path)
 	if err != nil {
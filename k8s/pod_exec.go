@@ -0,0 +1,62 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/vmware-tanzu/crash-diagnostics/archiver"
+)
+
+// ExecInContainer runs command cmd in a container of a pod and streams its standard
+// output and error to stdout and stderr
+func (k8sc *Client) ExecInContainer(ctx context.Context, namespace, pod, container string, cmd []string, stdout, stderr io.Writer) error {
+	executor, err := NewExecutorForConfig(&k8sc.RestConfig, ExecOptions{
+		Namespace:     namespace,
+		Podname:       pod,
+		ContainerName: container,
+		Command:       cmd,
+	})
+	if err != nil {
+		return fmt.Errorf("could not initialize executor: %w", err)
+	}
+	return executor.Stream(ctx, stdout, stderr)
+}
+
+// CopyFromContainer copies path from a container of a pod into directory destDir, under
+// its full path (i.e. /etc/config is saved in <destDir>/etc/config). The files are streamed
+// as a tar archive, which requires the tar program in the container.
+func (k8sc *Client) CopyFromContainer(ctx context.Context, namespace, pod, container, path, destDir string) error {
+	return k8sc.copyFromContainer(ctx, namespace, pod, container, []string{"tar", "cf", "-", path}, destDir)
+}
+
+// copyFromContainer extracts, into destDir, the tar stream written by command tarCmd
+func (k8sc *Client) copyFromContainer(ctx context.Context, namespace, pod, container string, tarCmd []string, destDir string) error {
+	reader, writer := io.Pipe()
+	var stderr bytes.Buffer
+	execErr := make(chan error, 1)
+	go func() {
+		err := k8sc.ExecInContainer(ctx, namespace, pod, container, tarCmd, writer, &stderr)
+		writer.CloseWithError(err)
+		execErr <- err
+	}()
+
+	untarErr := archiver.UntarStream(reader, destDir)
+	// unblock the command if the extraction stopped early
+	reader.CloseWithError(io.ErrClosedPipe)
+	if err := <-execErr; err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("copy from %s/%s failed: %w: %s", namespace, pod, err, msg)
+		}
+		return fmt.Errorf("copy from %s/%s failed: %w", namespace, pod, err)
+	}
+	if untarErr != nil {
+		return fmt.Errorf("copy from %s/%s failed: %w", namespace, pod, untarErr)
+	}
+	return nil
+}
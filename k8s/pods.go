@@ -0,0 +1,150 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// maxOwnerDepth bounds the owner chain walked from a pod (i.e. pod -> replicaset -> deployment)
+const maxOwnerDepth = 4
+
+// PodSelector selects running pods by namespaces, labels, and owner
+type PodSelector struct {
+	// Namespaces of the pods, default "default"
+	Namespaces []string
+	// Labels are label selectors, i.e. "app=nginx"
+	Labels []string
+	// Owner selects the pods owned, directly or through intermediate objects, by the
+	// object <kind>/<name> in the namespace of the pod, i.e. "deployment/nginx"
+	Owner string
+}
+
+// SelectPods returns the running pods matching selector, sorted by namespace and name
+func (k8sc *Client) SelectPods(ctx context.Context, selector PodSelector) ([]corev1.Pod, error) {
+	var ownerKind, ownerName string
+	if selector.Owner != "" {
+		parts := strings.SplitN(selector.Owner, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid owner %q: expecting <kind>/<name>", selector.Owner)
+		}
+		ownerKind, ownerName = parts[0], parts[1]
+	}
+
+	namespaces := selector.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{"default"}
+	}
+
+	owners := newOwnerResolver(k8sc)
+	var pods []corev1.Pod
+	for _, ns := range namespaces {
+		list, err := k8sc.Typed.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{LabelSelector: strings.Join(selector.Labels, ",")})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods in namespace %s: %w", ns, err)
+		}
+		for _, pod := range list.Items {
+			if pod.Status.Phase != corev1.PodRunning {
+				continue
+			}
+			if ownerKind != "" {
+				owned, err := owners.ownedBy(ctx, ns, pod.OwnerReferences, ownerKind, ownerName, maxOwnerDepth)
+				if err != nil {
+					return nil, err
+				}
+				if !owned {
+					continue
+				}
+			}
+			pods = append(pods, pod)
+		}
+	}
+
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		return pods[i].Name < pods[j].Name
+	})
+	return pods, nil
+}
+
+// ownerResolver walks owner references, caching the owners of the objects it fetched
+type ownerResolver struct {
+	client *Client
+	owners map[types.UID][]metav1.OwnerReference
+}
+
+func newOwnerResolver(client *Client) *ownerResolver {
+	return &ownerResolver{client: client, owners: make(map[types.UID][]metav1.OwnerReference)}
+}
+
+// ownedBy returns true if one of refs, or one of their owners up to depth levels, is object kind/name
+func (r *ownerResolver) ownedBy(ctx context.Context, namespace string, refs []metav1.OwnerReference, kind, name string, depth int) (bool, error) {
+	if depth == 0 {
+		return false, nil
+	}
+	for _, ref := range refs {
+		if strings.EqualFold(ref.Kind, kind) && ref.Name == name {
+			return true, nil
+		}
+		owners, err := r.ownerRefs(ctx, namespace, ref)
+		if err != nil {
+			return false, err
+		}
+		owned, err := r.ownedBy(ctx, namespace, owners, kind, name, depth-1)
+		if err != nil || owned {
+			return owned, err
+		}
+	}
+	return false, nil
+}
+
+// ownerRefs returns the owner references of the object referenced by ref
+func (r *ownerResolver) ownerRefs(ctx context.Context, namespace string, ref metav1.OwnerReference) ([]metav1.OwnerReference, error) {
+	if owners, ok := r.owners[ref.UID]; ok {
+		return owners, nil
+	}
+
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("owner %s/%s: %w", ref.Kind, ref.Name, err)
+	}
+	mapping, err := r.client.Mapper.RESTMapping(gv.WithKind(ref.Kind).GroupKind(), gv.Version)
+	if err != nil {
+		// owners of unknown kinds are not walked
+		if meta.IsNoMatchError(err) {
+			r.owners[ref.UID] = nil
+			return nil, nil
+		}
+		return nil, fmt.Errorf("owner %s/%s: %w", ref.Kind, ref.Name, err)
+	}
+	var resource dynamic.ResourceInterface = r.client.Client.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		resource = r.client.Client.Resource(mapping.Resource).Namespace(namespace)
+	}
+	obj, err := resource.Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			r.owners[ref.UID] = nil
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get owner %s/%s: %w", ref.Kind, ref.Name, err)
+	}
+
+	owners := obj.GetOwnerReferences()
+	r.owners[ref.UID] = owners
+	return owners, nil
+}
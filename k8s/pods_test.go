@@ -0,0 +1,93 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestOwnerRef(kind, name string) metav1.OwnerReference {
+	return metav1.OwnerReference{APIVersion: "apps/v1", Kind: kind, Name: name, UID: types.UID(kind + "/" + name)}
+}
+
+func newTestPod(name string, phase corev1.PodPhase, labels map[string]string, owners ...metav1.OwnerReference) runtime.Object {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels, OwnerReferences: owners},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+}
+
+func TestSelectPods(t *testing.T) {
+	appsV1 := schema.GroupVersion{Group: "apps", Version: "v1"}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{appsV1})
+	mapper.Add(appsV1.WithKind("ReplicaSet"), meta.RESTScopeNamespace)
+	mapper.Add(appsV1.WithKind("StatefulSet"), meta.RESTScopeNamespace)
+
+	replicaSet := &unstructured.Unstructured{}
+	replicaSet.SetAPIVersion("apps/v1")
+	replicaSet.SetKind("ReplicaSet")
+	replicaSet.SetNamespace("default")
+	replicaSet.SetName("nginx-5d")
+	replicaSet.SetOwnerReferences([]metav1.OwnerReference{newTestOwnerRef("Deployment", "nginx")})
+
+	client := &Client{
+		Client: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), replicaSet),
+		Typed: kubefake.NewSimpleClientset(
+			newTestPod("nginx-5d-b", corev1.PodRunning, map[string]string{"app": "nginx"}, newTestOwnerRef("ReplicaSet", "nginx-5d")),
+			newTestPod("nginx-5d-a", corev1.PodRunning, map[string]string{"app": "nginx"}, newTestOwnerRef("ReplicaSet", "nginx-5d")),
+			newTestPod("nginx-5d-c", corev1.PodPending, map[string]string{"app": "nginx"}, newTestOwnerRef("ReplicaSet", "nginx-5d")),
+			newTestPod("db-0", corev1.PodRunning, map[string]string{"app": "db"}, newTestOwnerRef("StatefulSet", "db")),
+		),
+		Mapper: mapper,
+	}
+
+	tests := []struct {
+		name     string
+		selector PodSelector
+		expected string
+		err      string
+	}{
+		{name: "all running pods", selector: PodSelector{}, expected: "db-0,nginx-5d-a,nginx-5d-b"},
+		{name: "labels", selector: PodSelector{Labels: []string{"app=nginx"}}, expected: "nginx-5d-a,nginx-5d-b"},
+		{name: "direct owner", selector: PodSelector{Owner: "statefulset/db"}, expected: "db-0"},
+		{name: "owner of owner", selector: PodSelector{Owner: "deployment/nginx"}, expected: "nginx-5d-a,nginx-5d-b"},
+		{name: "unknown owner", selector: PodSelector{Owner: "deployment/db"}, expected: ""},
+		{name: "other namespace", selector: PodSelector{Namespaces: []string{"kube-system"}}, expected: ""},
+		{name: "invalid owner", selector: PodSelector{Owner: "nginx"}, err: "invalid owner"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pods, err := client.SelectPods(context.TODO(), test.selector)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, pod := range pods {
+				names = append(names, pod.Name)
+			}
+			if strings.Join(names, ",") != test.expected {
+				t.Errorf("unexpected pods: %v", names)
+			}
+		})
+	}
+}
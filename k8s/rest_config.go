@@ -0,0 +1,95 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	// register the oidc auth provider used by kubeconfigs with short-lived tokens
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+)
+
+// ConfigOptions configures the access to a cluster from a kubeconfig, the in-cluster
+// service account, or a server address, with optional credential overrides
+type ConfigOptions struct {
+	// Path of the kubeconfig. It can list several files separated by the OS path
+	// list separator, as the KUBECONFIG environment variable, which are merged.
+	Path string
+	// Context is the kubeconfig context to use, default the current context
+	Context string
+	// Server overrides the API server address
+	Server string
+	// Token is a bearer token overriding the kubeconfig credentials
+	Token string
+	// TokenFile is a file containing a bearer token, read again when it changes
+	TokenFile string
+	// ImpersonateUser and ImpersonateGroups set the user and groups to act as
+	ImpersonateUser   string
+	ImpersonateGroups []string
+	// InCluster uses the service account of the pod running crashd instead of a kubeconfig
+	InCluster bool
+}
+
+// RESTConfig returns the REST configuration of the options. Exec credential plugins
+// and auth providers configured in the kubeconfig run without interactive prompts.
+func (o ConfigOptions) RESTConfig() (*rest.Config, error) {
+	var cfg *rest.Config
+	var err error
+	switch {
+	case o.InCluster:
+		if cfg, err = rest.InClusterConfig(); err != nil {
+			return nil, fmt.Errorf("in-cluster config: %w", err)
+		}
+	case o.Path != "":
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: o.Context}
+		overrides.ClusterInfo.Server = o.Server
+		if cfg, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(kubeConfigLoadingRules(o.Path), overrides).ClientConfig(); err != nil {
+			return nil, err
+		}
+	case o.Server != "" && (o.Token != "" || o.TokenFile != ""):
+		cfg = &rest.Config{Host: o.Server}
+	default:
+		return nil, errors.New("kubeconfig file path required")
+	}
+
+	if o.Server != "" {
+		cfg.Host = o.Server
+	}
+	if o.Token != "" || o.TokenFile != "" {
+		// the token replaces the credentials of the kubeconfig
+		cfg.BearerToken, cfg.BearerTokenFile = o.Token, o.TokenFile
+		cfg.Username, cfg.Password = "", ""
+		cfg.ExecProvider, cfg.AuthProvider = nil, nil
+		cfg.CertFile, cfg.KeyFile, cfg.CertData, cfg.KeyData = "", "", nil, nil
+	}
+	if cfg.ExecProvider != nil {
+		// never prompt for credentials, i.e. when crashd runs in a CI job
+		cfg.ExecProvider.StdinUnavailable = true
+		cfg.ExecProvider.StdinUnavailableMessage = "crashd runs exec credential plugins non-interactively"
+	}
+	if o.ImpersonateUser != "" || len(o.ImpersonateGroups) > 0 {
+		cfg.Impersonate = rest.ImpersonationConfig{UserName: o.ImpersonateUser, Groups: o.ImpersonateGroups}
+	}
+	return cfg, nil
+}
+
+// LoadKubeConfigFiles loads and merges the kubeconfig files listed in path
+func LoadKubeConfigFiles(path string) (*clientcmdapi.Config, error) {
+	return kubeConfigLoadingRules(path).Load()
+}
+
+// kubeConfigLoadingRules returns the rules loading the kubeconfig files listed in path
+func kubeConfigLoadingRules(path string) *clientcmd.ClientConfigLoadingRules {
+	paths := filepath.SplitList(path)
+	if len(paths) == 1 {
+		return &clientcmd.ClientConfigLoadingRules{ExplicitPath: paths[0]}
+	}
+	return &clientcmd.ClientConfigLoadingRules{Precedence: paths}
+}
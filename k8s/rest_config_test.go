@@ -0,0 +1,178 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestKubeConfig(t *testing.T, dir, name, server string) string {
+	t.Helper()
+	config := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: %[1]s
+  cluster:
+    server: %[2]s
+users:
+- name: %[1]s
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: get-token
+      interactiveMode: IfAvailable
+contexts:
+- name: %[1]s
+  context:
+    cluster: %[1]s
+    user: %[1]s
+current-context: %[1]s
+`, name, server)
+	path := filepath.Join(dir, name+".conf")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigOptionsRESTConfig(t *testing.T) {
+	dir := t.TempDir()
+	first := writeTestKubeConfig(t, dir, "first", "https://first:6443")
+	second := writeTestKubeConfig(t, dir, "second", "https://second:6443")
+	merged := strings.Join([]string{first, second}, string(filepath.ListSeparator))
+
+	tests := []struct {
+		name   string
+		opts   ConfigOptions
+		host   string
+		token  string
+		exec   bool
+		user   string
+		groups []string
+		err    bool
+	}{
+		{name: "kubeconfig", opts: ConfigOptions{Path: first}, host: "https://first:6443", exec: true},
+		{name: "merged kubeconfigs", opts: ConfigOptions{Path: merged}, host: "https://first:6443", exec: true},
+		{name: "merged kubeconfigs context", opts: ConfigOptions{Path: merged, Context: "second"}, host: "https://second:6443", exec: true},
+		{name: "server override", opts: ConfigOptions{Path: first, Server: "https://lb:443"}, host: "https://lb:443", exec: true},
+		{name: "token override", opts: ConfigOptions{Path: first, Token: "abc"}, host: "https://first:6443", token: "abc"},
+		{name: "server and token", opts: ConfigOptions{Server: "https://lb:443", Token: "abc"}, host: "https://lb:443", token: "abc"},
+		{
+			name:   "impersonation",
+			opts:   ConfigOptions{Path: first, ImpersonateUser: "ci", ImpersonateGroups: []string{"viewers"}},
+			host:   "https://first:6443",
+			exec:   true,
+			user:   "ci",
+			groups: []string{"viewers"},
+		},
+		{name: "server without credentials", opts: ConfigOptions{Server: "https://lb:443"}, err: true},
+		{name: "missing kubeconfig", opts: ConfigOptions{Path: filepath.Join(dir, "missing.conf")}, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, err := test.opts.RESTConfig()
+			if test.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Host != test.host {
+				t.Errorf("unexpected host: %s", cfg.Host)
+			}
+			if cfg.BearerToken != test.token {
+				t.Errorf("unexpected token: %s", cfg.BearerToken)
+			}
+			if (cfg.ExecProvider != nil) != test.exec {
+				t.Errorf("unexpected exec provider: %v", cfg.ExecProvider)
+			}
+			if cfg.ExecProvider != nil && !cfg.ExecProvider.StdinUnavailable {
+				t.Error("exec provider should not be interactive")
+			}
+			if cfg.Impersonate.UserName != test.user || strings.Join(cfg.Impersonate.Groups, ",") != strings.Join(test.groups, ",") {
+				t.Errorf("unexpected impersonation: %+v", cfg.Impersonate)
+			}
+		})
+	}
+}
+
+func TestLoadKubeCfgMerged(t *testing.T) {
+	dir := t.TempDir()
+	first := writeTestKubeConfig(t, dir, "first", "https://first:6443")
+	second := writeTestKubeConfig(t, dir, "second", "https://second:6443")
+
+	cfg, err := LoadKubeCfg(strings.Join([]string{first, second}, string(filepath.ListSeparator)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetCurrentContext() != "first" {
+		t.Errorf("unexpected current context: %s", cfg.GetCurrentContext())
+	}
+	if name, err := cfg.GetClusterName(); err != nil || name != "first" {
+		t.Errorf("unexpected cluster name %s: %v", name, err)
+	}
+}
+
+func TestKubeConfigCertificates(t *testing.T) {
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(caFile, []byte("file-ca"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: embedded
+  cluster:
+    server: https://embedded:6443
+    certificate-authority-data: %s
+- name: file
+  cluster:
+    server: https://file:6443
+    certificate-authority: %s
+users:
+- name: admin
+  user:
+    client-certificate-data: %s
+- name: missing
+  user:
+    client-certificate: %s
+contexts:
+- name: embedded
+  context:
+    cluster: embedded
+    user: admin
+current-context: embedded
+`, base64.StdEncoding.EncodeToString([]byte("embedded-ca")), caFile,
+		base64.StdEncoding.EncodeToString([]byte("admin-cert")), filepath.Join(dir, "missing.crt"))
+	path := filepath.Join(dir, "config")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadKubeCfg(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := cfg.GetCertificates()
+	if err == nil || !strings.Contains(err.Error(), "user/missing") {
+		t.Errorf("expected error for missing certificate file, got %v", err)
+	}
+	var found []string
+	for _, cert := range certs {
+		found = append(found, fmt.Sprintf("%s=%s", cert.Source, cert.Data))
+	}
+	if strings.Join(found, ",") != "cluster/embedded=embedded-ca,cluster/file=file-ca,user/admin=admin-cert" {
+		t.Errorf("unexpected certificates: %v", found)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"sync"
 
 	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/cli-runtime/pkg/printers"
 	"k8s.io/client-go/rest"
@@ -20,6 +21,8 @@ type ResultWriter struct {
 	restApi    rest.Interface
 	printer    printers.ResourcePrinter
 	singleFile bool
+	budget     *budget.Budget
+	filter     ObjectFilter
 }
 
 func NewResultWriter(workdir, what, outputFormat, outputMode string, restApi rest.Interface) (*ResultWriter, error) {
@@ -52,6 +55,16 @@ func NewResultWriter(workdir, what, outputFormat, outputMode string, restApi res
 	}, err
 }
 
+// SetBudget sets the budget limiting the size of the container logs written
+func (w *ResultWriter) SetBudget(limit *budget.Budget) {
+	w.budget = limit
+}
+
+// SetFilter sets the filter selecting the objects written
+func (w *ResultWriter) SetFilter(filter ObjectFilter) {
+	w.filter = filter
+}
+
 func (w *ResultWriter) GetResultDir() string {
 	return w.workdir
 }
@@ -72,6 +85,7 @@ func (w *ResultWriter) Write(ctx context.Context, searchResults []SearchResult)
 			writeDir:   w.workdir,
 			printer:    w.printer,
 			singleFile: w.singleFile,
+			filter:     w.filter,
 		}
 		writeDir, err := objWriter.Write(result)
 		if err != nil {
@@ -104,7 +118,7 @@ func (w *ResultWriter) Write(ctx context.Context, searchResults []SearchResult)
 							logrus.Errorf("Failed to fetch container logs for pod %s: %s", pod.GetName(), e)
 							return
 						}
-						e = logger.Write(reader, logDir)
+						e = logger.Write(reader, logDir, w.budget)
 						if e != nil {
 							logrus.Errorf("Failed to write container logs for pod %s: %s", pod.GetName(), e)
 							return
@@ -57,6 +57,12 @@ func (sr SearchResult) ToStarlarkValue() *starlarkstruct.Struct {
 	return starlarkstruct.FromStringDict(starlark.String("search_result"), dict)
 }
 
+// ObjectToStarlark returns a starlark struct constructed from the contents of obj,
+// as found in the objects of a search result.
+func ObjectToStarlark(obj unstructured.Unstructured) starlark.Value {
+	return convertToStruct(obj)
+}
+
 // convertToStruct returns a starlark struct constructed from the contents of the input.
 func convertToStruct(obj unstructured.Unstructured) starlark.Value {
 	return convertToStarlarkPrimitive(obj.Object)
@@ -0,0 +1,234 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	defaultTunnelHealthInterval = 5 * time.Second
+	maxTunnelReconnectBackoff   = 30 * time.Second
+	tunnelRequestTimeout        = 10 * time.Second
+)
+
+// TunnelOptions configures a port-forward tunnel
+type TunnelOptions struct {
+	Namespace string
+	// Service selects the pod backing the tunnel each time it connects. When empty, Pod is used.
+	Service string
+	Pod     string
+	// LocalPort is the port of the tunnel on localhost, forwarded to TargetPort of the pod
+	LocalPort  int
+	TargetPort int
+	// HealthInterval is the interval between the checks of the backing pod, default 5 seconds
+	HealthInterval time.Duration
+}
+
+// Tunnel forwards a local port to a pod. The pod is checked periodically and, when it
+// is gone or the connection is lost, the tunnel reconnects, to a new pod of the
+// service if one was specified, until it is stopped.
+type Tunnel struct {
+	client *Client
+	opts   TunnelOptions
+
+	stopCh   chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// StartTunnel starts a tunnel and waits until it accepts connections
+func (k8sc *Client) StartTunnel(ctx context.Context, opts TunnelOptions) (*Tunnel, error) {
+	if opts.Service == "" && opts.Pod == "" {
+		return nil, errors.New("tunnel: service or pod required")
+	}
+	if opts.HealthInterval == 0 {
+		opts.HealthInterval = defaultTunnelHealthInterval
+	}
+
+	t := &Tunnel{client: k8sc, opts: opts, stopCh: make(chan struct{}), done: make(chan struct{})}
+	ready := make(chan error, 1)
+	go t.run(ready)
+
+	select {
+	case err := <-ready:
+		if err != nil {
+			t.Stop()
+			return nil, err
+		}
+	case <-ctx.Done():
+		t.Stop()
+		return nil, ctx.Err()
+	}
+	return t, nil
+}
+
+// LocalPort returns the port of the tunnel on localhost
+func (t *Tunnel) LocalPort() int {
+	return t.opts.LocalPort
+}
+
+// Stop stops the tunnel and waits for its port to be released
+func (t *Tunnel) Stop() {
+	t.stopOnce.Do(func() { close(t.stopCh) })
+	<-t.done
+}
+
+// run forwards the port until the tunnel is stopped. The result of the first
+// connection is sent to ready, which ends the tunnel if it failed.
+func (t *Tunnel) run(ready chan<- error) {
+	defer close(t.done)
+
+	connected := false
+	backoff := time.Second
+	for {
+		err := t.forward(func() {
+			if !connected {
+				connected = true
+				ready <- nil
+			}
+			backoff = time.Second
+		})
+		if !connected {
+			ready <- err
+			return
+		}
+
+		select {
+		case <-t.stopCh:
+			return
+		default:
+		}
+		logrus.Warnf("tunnel localhost:%d: %v: reconnecting in %s", t.opts.LocalPort, err, backoff)
+		select {
+		case <-t.stopCh:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < maxTunnelReconnectBackoff {
+			backoff *= 2
+		}
+	}
+}
+
+// forward forwards the port to a pod until the tunnel is stopped, the connection
+// is lost, or the pod is gone. It calls onReady once the port accepts connections.
+func (t *Tunnel) forward(onReady func()) error {
+	pod, err := t.resolvePod()
+	if err != nil {
+		return err
+	}
+
+	fwStop, readyCh := make(chan struct{}), make(chan struct{})
+	fw, err := newPortForwarder(&t.client.RestConfig, t.opts.Namespace, pod, t.opts.LocalPort, t.opts.TargetPort, fwStop, readyCh)
+	if err != nil {
+		return err
+	}
+	errCh := make(chan error, 1)
+	go func() { errCh <- fw.ForwardPorts() }()
+
+	stopForwarder := func() {
+		close(fwStop)
+		<-errCh
+	}
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("port forward to %s/%s failed: %w", t.opts.Namespace, pod, err)
+	case <-t.stopCh:
+		stopForwarder()
+		return nil
+	case <-readyCh:
+	}
+	logrus.Debugf("tunnel localhost:%d forwarded to %s/%s:%d", t.opts.LocalPort, t.opts.Namespace, pod, t.opts.TargetPort)
+	onReady()
+
+	ticker := time.NewTicker(t.opts.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-errCh:
+			if err == nil {
+				err = errors.New("port forward stopped")
+			}
+			return fmt.Errorf("port forward to %s/%s: %w", t.opts.Namespace, pod, err)
+		case <-t.stopCh:
+			stopForwarder()
+			return nil
+		case <-ticker.C:
+			if err := t.checkPod(pod); err != nil {
+				stopForwarder()
+				return err
+			}
+		}
+	}
+}
+
+// resolvePod returns the name of the pod backing the tunnel, a running pod of the service if one was specified
+func (t *Tunnel) resolvePod() (string, error) {
+	if t.opts.Service == "" {
+		return t.opts.Pod, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tunnelRequestTimeout)
+	defer cancel()
+	svc, err := t.client.Typed.CoreV1().Services(t.opts.Namespace).Get(ctx, t.opts.Service, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("could not get service: %w", err)
+	}
+	selector := labels.SelectorFromSet(svc.Spec.Selector)
+	pods, err := t.client.Typed.CoreV1().Pods(svc.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return "", fmt.Errorf("could not list pods: %w", err)
+	}
+
+	var running string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if isPodReady(&pod) {
+			return pod.Name, nil
+		}
+		if running == "" {
+			running = pod.Name
+		}
+	}
+	if running == "" {
+		return "", fmt.Errorf("no running pod for service %s/%s", t.opts.Namespace, t.opts.Service)
+	}
+	return running, nil
+}
+
+// checkPod returns an error if the pod is not running anymore
+func (t *Tunnel) checkPod(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), tunnelRequestTimeout)
+	defer cancel()
+	pod, err := t.client.Typed.CoreV1().Pods(t.opts.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("pod %s/%s: %w", t.opts.Namespace, name, err)
+	}
+	if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+		return fmt.Errorf("pod %s/%s not running", t.opts.Namespace, name)
+	}
+	return nil
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
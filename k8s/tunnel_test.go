@@ -0,0 +1,98 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestServicePod(name string, phase corev1.PodPhase, ready bool) runtime.Object {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kcp", Labels: map[string]string{"app": "kcp"}},
+		Status: corev1.PodStatus{
+			Phase:      phase,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+func TestTunnelResolvePod(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "kcp", Namespace: "kcp"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "kcp"}},
+	}
+
+	tests := []struct {
+		name     string
+		pods     []runtime.Object
+		expected string
+	}{
+		{
+			name:     "ready pod",
+			pods:     []runtime.Object{newTestServicePod("kcp-0", corev1.PodFailed, false), newTestServicePod("kcp-1", corev1.PodRunning, false), newTestServicePod("kcp-2", corev1.PodRunning, true)},
+			expected: "kcp-2",
+		},
+		{
+			name:     "running pod",
+			pods:     []runtime.Object{newTestServicePod("kcp-0", corev1.PodPending, false), newTestServicePod("kcp-1", corev1.PodRunning, false)},
+			expected: "kcp-1",
+		},
+		{
+			name: "no running pod",
+			pods: []runtime.Object{newTestServicePod("kcp-0", corev1.PodPending, false)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &Client{Typed: kubefake.NewSimpleClientset(append(test.pods, svc)...)}
+			tunnel := &Tunnel{client: client, opts: TunnelOptions{Namespace: "kcp", Service: "kcp"}}
+
+			pod, err := tunnel.resolvePod()
+			if test.expected == "" {
+				if err == nil {
+					t.Fatalf("expected error, got pod %s", pod)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pod != test.expected {
+				t.Errorf("unexpected pod: %s", pod)
+			}
+			if err := tunnel.checkPod(pod); err != nil {
+				t.Errorf("unexpected health check error: %s", err)
+			}
+		})
+	}
+}
+
+func TestTunnelCheckPod(t *testing.T) {
+	client := &Client{Typed: kubefake.NewSimpleClientset(newTestServicePod("kcp-0", corev1.PodSucceeded, false))}
+	tunnel := &Tunnel{client: client, opts: TunnelOptions{Namespace: "kcp", Pod: "kcp-0"}}
+
+	if err := tunnel.checkPod("kcp-0"); err == nil {
+		t.Error("expected error for stopped pod")
+	}
+	if err := tunnel.checkPod("kcp-1"); err == nil {
+		t.Error("expected error for missing pod")
+	}
+}
+
+func TestStartTunnelArgs(t *testing.T) {
+	if _, err := (&Client{}).StartTunnel(context.TODO(), TunnelOptions{LocalPort: 8080, TargetPort: 80}); err == nil {
+		t.Error("expected error without service or pod")
+	}
+}
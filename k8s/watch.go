@@ -0,0 +1,368 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
+)
+
+// WatchDirname is the directory, in the working directory, where Watch saves its records
+const WatchDirname = "kubewatch"
+
+// WatchParams selects the objects recorded by Watch
+type WatchParams struct {
+	// Kinds are resource names or kinds, i.e. "pods", "Pod" or "deployments.apps"
+	Kinds []string
+	// Namespaces are the namespaces watched, all namespaces if empty
+	Namespaces []string
+	Duration   time.Duration
+	// FollowLogs follows the logs of the containers of the pods watched
+	FollowLogs bool
+}
+
+// WatchEvent is a change of an object recorded by Watch
+type WatchEvent struct {
+	Time time.Time       `json:"time"`
+	Type watch.EventType `json:"type"`
+	// Initial is set for the objects listed when the watch started
+	Initial bool                       `json:"initial,omitempty"`
+	Object  *unstructured.Unstructured `json:"object"`
+}
+
+// watchResource is a resource resolved from WatchParams.Kinds
+type watchResource struct {
+	gvr        schema.GroupVersionResource
+	namespaced bool
+}
+
+// Watch records every change of the objects selected by params during params.Duration,
+// or until ctx is done. The events of each resource are saved as JSON lines, one
+// WatchEvent per line, in <workdir>/kubewatch/<group>_<version>/<resource>.jsonl.
+// Followed container logs are saved, with timestamps, in
+// <workdir>/kubewatch/logs/<namespace>/<pod>/<container>.log. It returns the files written.
+func (k8sc *Client) Watch(ctx context.Context, params WatchParams, workdir string, limit *budget.Budget) ([]string, error) {
+	if params.Duration <= 0 {
+		return nil, errors.New("watch: duration must be positive")
+	}
+	resources, err := k8sc.watchResources(params.Kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &watcher{
+		client:  k8sc,
+		dir:     filepath.Join(workdir, WatchDirname),
+		limit:   limit,
+		started: time.Now(),
+		follow:  params.FollowLogs,
+		logs:    make(map[string]bool),
+	}
+	watchCtx, cancel := context.WithTimeout(ctx, params.Duration)
+	defer cancel()
+	w.ctx = watchCtx
+
+	namespaces := params.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	factories := make(map[string]dynamicinformer.DynamicSharedInformerFactory)
+	factoryFor := func(namespace string) dynamicinformer.DynamicSharedInformerFactory {
+		if factories[namespace] == nil {
+			factories[namespace] = dynamicinformer.NewFilteredDynamicSharedInformerFactory(k8sc.Client, 0, namespace, nil)
+		}
+		return factories[namespace]
+	}
+
+	for _, res := range resources {
+		recorder, err := w.newRecorder(res.gvr)
+		if err != nil {
+			w.close()
+			return nil, err
+		}
+		resNamespaces := namespaces
+		if !res.namespaced {
+			resNamespaces = []string{metav1.NamespaceAll}
+		}
+		for _, ns := range resNamespaces {
+			informer := factoryFor(ns).ForResource(res.gvr).Informer()
+			if _, err := informer.AddEventHandler(w.handler(recorder, res.gvr)); err != nil {
+				w.close()
+				return nil, fmt.Errorf("watch: %s: %w", res.gvr.Resource, err)
+			}
+		}
+	}
+
+	logrus.Debugf("watch: recording %d resource(s) for %s", len(resources), params.Duration)
+	for _, factory := range factories {
+		factory.Start(watchCtx.Done())
+	}
+	<-watchCtx.Done()
+	for _, factory := range factories {
+		factory.Shutdown()
+	}
+	w.logsWg.Wait()
+
+	files, err := w.close()
+	if err != nil {
+		return files, err
+	}
+	// the watch ends normally when its duration elapses
+	return files, ctx.Err()
+}
+
+// watchResources resolves kinds into the resources to watch
+func (k8sc *Client) watchResources(kinds []string) ([]watchResource, error) {
+	if len(kinds) == 0 {
+		return nil, errors.New("watch: at least one kind is required")
+	}
+	var resources []watchResource
+	seen := make(map[schema.GroupVersionResource]bool)
+	for _, kind := range kinds {
+		gvr, namespaced, err := k8sc.ResolveKind(kind)
+		if err != nil {
+			return nil, fmt.Errorf("watch: %w", err)
+		}
+		if seen[gvr] {
+			continue
+		}
+		seen[gvr] = true
+		resources = append(resources, watchResource{gvr: gvr, namespaced: namespaced})
+	}
+	return resources, nil
+}
+
+// ResolveKind returns the resource of kind, a resource name or a kind (i.e. "pods", "Pod"
+// or "deployments.apps"), in its preferred version, and whether it is namespaced
+func (k8sc *Client) ResolveKind(kind string) (schema.GroupVersionResource, bool, error) {
+	gvr, err := k8sc.Mapper.ResourceFor(schema.ParseGroupResource(strings.ToLower(kind)).WithVersion(""))
+	if err != nil {
+		return gvr, false, fmt.Errorf("unknown kind %s: %w", kind, err)
+	}
+	gvk, err := k8sc.Mapper.KindFor(gvr)
+	if err != nil {
+		return gvr, false, fmt.Errorf("unknown kind %s: %w", kind, err)
+	}
+	mapping, err := k8sc.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return gvr, false, fmt.Errorf("unknown kind %s: %w", kind, err)
+	}
+	return gvr, mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+}
+
+// watcher holds the state of a Watch call
+type watcher struct {
+	client  *Client
+	ctx     context.Context
+	dir     string
+	limit   *budget.Budget
+	started time.Time
+	follow  bool
+
+	recorders []*watchRecorder
+
+	logsMu sync.Mutex
+	logs   map[string]bool // container instances followed
+	logsWg sync.WaitGroup
+}
+
+func (w *watcher) handler(recorder *watchRecorder, gvr schema.GroupVersionResource) cache.ResourceEventHandler {
+	followLogs := w.follow && gvr.Group == "" && gvr.Resource == "pods"
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, initial bool) {
+			recorder.record(watch.Added, obj, initial)
+			if followLogs {
+				w.followLogs(obj)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// relists deliver objects unchanged since the last event
+			if oldObj.(metav1.Object).GetResourceVersion() == newObj.(metav1.Object).GetResourceVersion() {
+				return
+			}
+			recorder.record(watch.Modified, newObj, false)
+			if followLogs {
+				w.followLogs(newObj)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			recorder.record(watch.Deleted, obj, false)
+		},
+	}
+}
+
+func (w *watcher) newRecorder(gvr schema.GroupVersionResource) (*watchRecorder, error) {
+	grp := gvr.Group
+	if grp == "" {
+		grp = "core"
+	}
+	dir := filepath.Join(w.dir, fmt.Sprintf("%s_%s", grp, gvr.Version))
+	if err := os.MkdirAll(dir, 0744); err != nil {
+		return nil, fmt.Errorf("watch: failed to create dir: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s.jsonl", gvr.Resource))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("watch: %w", err)
+	}
+	limited := w.limit.NewWriter(path, file)
+	recorder := &watchRecorder{path: path, file: file, limited: limited, enc: json.NewEncoder(limited)}
+	w.recorders = append(w.recorders, recorder)
+	return recorder, nil
+}
+
+// close closes the recorders and returns their files
+func (w *watcher) close() ([]string, error) {
+	var files []string
+	var errs []error
+	for _, recorder := range w.recorders {
+		files = append(files, recorder.path)
+		if err := recorder.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	sort.Strings(files)
+	return files, errors.Join(errs...)
+}
+
+// followLogs starts following the logs of the running containers of pod obj
+// not followed yet. A restarted container is followed again.
+func (w *watcher) followLogs(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	pod := new(corev1.Pod)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, pod); err != nil {
+		logrus.Debugf("watch: failed to convert pod %s: %s", u.GetName(), err)
+		return
+	}
+
+	var statuses []corev1.ContainerStatus
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	statuses = append(statuses, pod.Status.EphemeralContainerStatuses...)
+	for _, status := range statuses {
+		if status.State.Running == nil {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s/%s/%d", pod.Namespace, pod.Name, status.Name, status.RestartCount)
+		w.logsMu.Lock()
+		followed := w.logs[key]
+		w.logs[key] = true
+		w.logsMu.Unlock()
+		if followed {
+			continue
+		}
+
+		w.logsWg.Add(1)
+		go func(namespace, name, container string) {
+			defer w.logsWg.Done()
+			if err := w.streamLogs(namespace, name, container); err != nil && w.ctx.Err() == nil {
+				logrus.Errorf("watch: failed to follow logs of %s/%s container %s: %s", namespace, name, container, err)
+			}
+		}(pod.Namespace, pod.Name, status.Name)
+	}
+}
+
+// streamLogs appends the log of a container, since the watch started, to its file until the container stops or the watch ends
+func (w *watcher) streamLogs(namespace, pod, container string) error {
+	opts := &corev1.PodLogOptions{
+		Container:  container,
+		Follow:     true,
+		Timestamps: true,
+		SinceTime:  &metav1.Time{Time: w.started},
+	}
+	stream, err := w.client.CoreRest.Get().Namespace(namespace).Name(pod).Resource("pods").SubResource("log").VersionedParams(opts, scheme.ParameterCodec).Stream(w.ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	dir := filepath.Join(w.dir, "logs", namespace, pod)
+	if err := os.MkdirAll(dir, 0744); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s.log", container))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	logrus.Debugf("watch: following logs of %s/%s container %s", namespace, pod, container)
+	limited := w.limit.NewWriter(path, file)
+	_, err = io.Copy(limited, stream)
+	if closeErr := limited.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// watchRecorder writes the events of a resource to its file
+type watchRecorder struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	limited *budget.Writer
+	enc     *json.Encoder
+	err     error
+}
+
+func (r *watchRecorder) record(eventType watch.EventType, obj interface{}, initial bool) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		logrus.Debugf("watch: unexpected object %T", obj)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	if err := r.enc.Encode(WatchEvent{Time: time.Now().UTC(), Type: eventType, Initial: initial, Object: u}); err != nil {
+		logrus.Errorf("watch: failed to record %s: %s", r.path, err)
+		r.err = err
+	}
+}
+
+func (r *watchRecorder) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.limited.Close()
+	if fileErr := r.file.Close(); err == nil {
+		err = fileErr
+	}
+	if r.err != nil {
+		return r.err
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+var (
+	testPodsGVR  = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	testNodesGVR = schema.GroupVersionResource{Version: "v1", Resource: "nodes"}
+)
+
+func newTestWatchClient(objs ...runtime.Object) *Client {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Node"}, meta.RESTScopeRoot)
+	dynamic := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		testPodsGVR:  "PodList",
+		testNodesGVR: "NodeList",
+	}, objs...)
+	return &Client{Client: dynamic, Mapper: mapper}
+}
+
+func newTestWatchPod(namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("Pod")
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetResourceVersion("1")
+	return obj
+}
+
+func readWatchEvents(t *testing.T, path string) []WatchEvent {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	var events []WatchEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event WatchEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid event %s: %s", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestWatch(t *testing.T) {
+	client := newTestWatchClient(newTestWatchPod("default", "etcd"), newTestWatchPod("other", "ignored"))
+	workdir := t.TempDir()
+	podsFile := filepath.Join(workdir, WatchDirname, "core_v1", "pods.jsonl")
+
+	type result struct {
+		files []string
+		err   error
+	}
+	done := make(chan result)
+	go func() {
+		files, err := client.Watch(context.TODO(), WatchParams{Kinds: []string{"Pod", "nodes"}, Namespaces: []string{"default"}, Duration: 3 * time.Second}, workdir, nil)
+		done <- result{files, err}
+	}()
+
+	// wait for the initial list to be recorded before changing objects
+	deadline := time.Now().Add(2 * time.Second)
+	for len(readWatchEvents(t, podsFile)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("initial list not recorded")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	pods := client.Client.Resource(testPodsGVR).Namespace("default")
+	updated := newTestWatchPod("default", "etcd")
+	updated.SetResourceVersion("2")
+	updated.SetLabels(map[string]string{"restarted": "true"})
+	if _, err := pods.Update(context.TODO(), updated, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pods.Delete(context.TODO(), "etcd", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	res := <-done
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if len(res.files) != 2 || !strings.HasSuffix(res.files[0], filepath.Join("core_v1", "nodes.jsonl")) || res.files[1] != podsFile {
+		t.Errorf("unexpected files: %v", res.files)
+	}
+
+	events := readWatchEvents(t, podsFile)
+	var types []string
+	for _, event := range events {
+		if event.Object.GetName() != "etcd" || event.Time.IsZero() {
+			t.Errorf("unexpected event: %+v", event)
+		}
+		types = append(types, string(event.Type))
+	}
+	if strings.Join(types, ",") != strings.Join([]string{string(watch.Added), string(watch.Modified), string(watch.Deleted)}, ",") {
+		t.Errorf("unexpected events: %v", types)
+	}
+	if len(events) == 3 && (!events[0].Initial || events[1].Initial || events[1].Object.GetLabels()["restarted"] != "true") {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
+
+func TestWatchErrors(t *testing.T) {
+	client := newTestWatchClient()
+	tests := []WatchParams{
+		{Kinds: []string{"pods"}},
+		{Duration: time.Second},
+		{Kinds: []string{"widgets"}, Duration: time.Second},
+	}
+	for _, params := range tests {
+		if _, err := client.Watch(context.TODO(), params, t.TempDir(), nil); err == nil {
+			t.Errorf("expecting error for %+v", params)
+		}
+	}
+}
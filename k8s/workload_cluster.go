@@ -0,0 +1,143 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// ClusterAPIGroup is the API group of the Cluster API objects
+	ClusterAPIGroup = "cluster.x-k8s.io"
+	// ClusterNameLabel is the label set by Cluster API on the objects of a cluster
+	ClusterNameLabel = "cluster.x-k8s.io/cluster-name"
+)
+
+var clusterGroupKind = schema.GroupKind{Group: ClusterAPIGroup, Kind: "Cluster"}
+
+// WorkloadCluster is a workload cluster declared by a Cluster API
+// Cluster object on a management cluster
+type WorkloadCluster struct {
+	Name      string
+	Namespace string
+	Phase     string
+	// InfrastructureRef references the infrastructure provider object of the cluster, i.e. an AWSCluster
+	InfrastructureRef corev1.ObjectReference
+}
+
+func newWorkloadCluster(obj *unstructured.Unstructured) WorkloadCluster {
+	cluster := WorkloadCluster{Name: obj.GetName(), Namespace: obj.GetNamespace()}
+	cluster.Phase, _, _ = unstructured.NestedString(obj.Object, "status", "phase")
+	if ref, found, _ := unstructured.NestedMap(obj.Object, "spec", "infrastructureRef"); found {
+		_ = runtime.DefaultUnstructuredConverter.FromUnstructured(ref, &cluster.InfrastructureRef)
+	}
+	if cluster.InfrastructureRef.Namespace == "" {
+		cluster.InfrastructureRef.Namespace = cluster.Namespace
+	}
+	return cluster
+}
+
+// ListWorkloadClusters returns the Cluster API clusters found on the management
+// cluster, optionally filtered by namespaces, names, and label selectors.
+// The clusters are sorted by namespace and name.
+func (k8sc *Client) ListWorkloadClusters(ctx context.Context, namespaces, names, labels []string) ([]WorkloadCluster, error) {
+	results, err := k8sc.Search(ctx, SearchParams{
+		Groups:     []string{ClusterAPIGroup},
+		Kinds:      []string{"clusters"},
+		Namespaces: namespaces,
+		Names:      names,
+		Labels:     labels,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("workload clusters: %w", err)
+	}
+
+	// the same clusters are returned for each served version of the group
+	seen := make(map[string]bool)
+	var clusters []WorkloadCluster
+	for _, result := range results {
+		if result.List == nil {
+			continue
+		}
+		for i := range result.List.Items {
+			item := &result.List.Items[i]
+			key := item.GetNamespace() + "/" + item.GetName()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			clusters = append(clusters, newWorkloadCluster(item))
+		}
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Namespace != clusters[j].Namespace {
+			return clusters[i].Namespace < clusters[j].Namespace
+		}
+		return clusters[i].Name < clusters[j].Name
+	})
+	return clusters, nil
+}
+
+// GetWorkloadCluster returns the Cluster API cluster with the given name and namespace.
+// It returns an error wrapping ErrClusterNotFound if the cluster does not exist.
+func (k8sc *Client) GetWorkloadCluster(ctx context.Context, name, namespace string) (WorkloadCluster, error) {
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	mapping, err := k8sc.Mapper.RESTMapping(clusterGroupKind)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return WorkloadCluster{}, fmt.Errorf("%w: %s/%s: %s", ErrClusterNotFound, namespace, name, err)
+		}
+		return WorkloadCluster{}, fmt.Errorf("failed to find cluster resource: %w", err)
+	}
+
+	obj, err := k8sc.Client.Resource(mapping.Resource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return WorkloadCluster{}, fmt.Errorf("%w: %s/%s", ErrClusterNotFound, namespace, name)
+		}
+		return WorkloadCluster{}, fmt.Errorf("failed to get cluster %s/%s: %w", namespace, name, err)
+	}
+
+	return newWorkloadCluster(obj), nil
+}
+
+// WriteWorkloadKubeConfig saves the kubeconfig of the workload cluster, read from the
+// <cluster>-kubeconfig secret created by Cluster API, to a temporary file and returns its path.
+// It returns an error wrapping ErrSecretNotFound if the secret or its kubeconfig are missing.
+func (k8sc *Client) WriteWorkloadKubeConfig(ctx context.Context, cluster WorkloadCluster) (string, error) {
+	secretName := fmt.Sprintf("%s-kubeconfig", cluster.Name)
+	data, err := GetSecretData(ctx, k8sc.Typed, cluster.Namespace, secretName, []string{"value"})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s/%s: %w", cluster.Namespace, secretName, err)
+	}
+	if len(data["value"]) == 0 {
+		return "", fmt.Errorf("%w: %s/%s", ErrSecretNotFound, cluster.Namespace, secretName)
+	}
+
+	f, err := os.CreateTemp(os.TempDir(), fmt.Sprintf("%s-workload-config", cluster.Name))
+	if err != nil {
+		return "", fmt.Errorf("Cannot create temporary file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data["value"]); err != nil {
+		return "", fmt.Errorf("failed to save workload kubeconfig: %w", err)
+	}
+	return f.Name(), nil
+}
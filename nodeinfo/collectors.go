@@ -0,0 +1,79 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package nodeinfo
+
+// collectors are the collectors, in the order they run
+var collectors = []Collector{
+	{
+		Name: "kubelet",
+		Commands: []Command{
+			{Name: "version", Cmd: "kubelet --version", Requires: "kubelet"},
+			{Name: "status", Cmd: "systemctl status kubelet --no-pager", Requires: "systemctl", Sudo: true},
+			{Name: "journal", Cmd: "journalctl -u kubelet --no-pager --since '-24h'", Requires: "journalctl", Sudo: true},
+			file("config", "/var/lib/kubelet/config.yaml"),
+			file("flags", "/var/lib/kubelet/kubeadm-flags.env"),
+			{Name: "static_pods", Cmd: "ls -la /etc/kubernetes/manifests", Path: "/etc/kubernetes/manifests", Sudo: true},
+		},
+	},
+	{
+		Name: "containerd",
+		Commands: []Command{
+			{Name: "version", Cmd: "containerd --version", Requires: "containerd"},
+			{Name: "status", Cmd: "systemctl status containerd --no-pager", Requires: "systemctl", Sudo: true},
+			{Name: "journal", Cmd: "journalctl -u containerd --no-pager --since '-24h'", Requires: "journalctl", Sudo: true},
+			file("config", "/etc/containerd/config.toml"),
+			{Name: "containers", Cmd: "crictl ps -a", Requires: "crictl", Sudo: true},
+			{Name: "pods", Cmd: "crictl pods", Requires: "crictl", Sudo: true},
+			{Name: "images", Cmd: "crictl images", Requires: "crictl", Sudo: true},
+			{Name: "info", Cmd: "crictl info", Requires: "crictl", Sudo: true},
+		},
+	},
+	{
+		Name: "network",
+		Commands: []Command{
+			{Name: "addresses", Cmd: "ip addr", Requires: "ip"},
+			{Name: "routes", Cmd: "ip route show table all", Requires: "ip"},
+			{Name: "links", Cmd: "ip -s link", Requires: "ip"},
+			{Name: "iptables", Cmd: "iptables-save", Requires: "iptables-save", Sudo: true},
+			{Name: "sockets", Cmd: "ss -tulpan", Requires: "ss", Sudo: true},
+			file("resolv_conf", "/etc/resolv.conf"),
+			file("hosts", "/etc/hosts"),
+			{Name: "cni", Cmd: "for f in /etc/cni/net.d/*; do echo \"==> $f <==\"; cat \"$f\"; done", Path: "/etc/cni/net.d", Sudo: true},
+		},
+	},
+	{
+		Name: "disk",
+		Commands: []Command{
+			{Name: "df", Cmd: "df -h"},
+			{Name: "df_inodes", Cmd: "df -i"},
+			{Name: "mounts", Cmd: "mount"},
+			{Name: "block_devices", Cmd: "lsblk", Requires: "lsblk"},
+			{Name: "usage", Cmd: "du -sh /var/lib/containerd /var/lib/kubelet /var/log 2>/dev/null", Sudo: true},
+		},
+	},
+	{
+		Name: "kernel",
+		Commands: []Command{
+			{Name: "uname", Cmd: "uname -a"},
+			file("os_release", "/etc/os-release"),
+			{Name: "uptime", Cmd: "uptime"},
+			file("meminfo", "/proc/meminfo"),
+			{Name: "dmesg", Cmd: "dmesg -T", Requires: "dmesg", Sudo: true},
+			{Name: "modules", Cmd: "lsmod", Requires: "lsmod"},
+			{Name: "sysctl", Cmd: "sysctl -a", Requires: "sysctl", Sudo: true},
+		},
+	},
+	{
+		Name: "certs",
+		Commands: []Command{
+			{Name: "files", Cmd: "ls -laR /etc/kubernetes/pki /var/lib/kubelet/pki 2>/dev/null", Sudo: true},
+			{
+				Name:     "expiration",
+				Cmd:      "for f in /etc/kubernetes/pki/*.crt /etc/kubernetes/pki/etcd/*.crt /var/lib/kubelet/pki/*.crt; do [ -f \"$f\" ] && echo \"$f: $(openssl x509 -noout -subject -enddate -in \"$f\" | tr '\\n' ' ')\"; done",
+				Requires: "openssl",
+				Sudo:     true,
+			},
+		},
+	},
+}
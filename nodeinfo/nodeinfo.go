@@ -0,0 +1,97 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package nodeinfo defines the collectors of node system information: bundles of commands
+// whose outputs are saved in a stable layout, <host>/node_info/<collector>/<command>.txt.
+package nodeinfo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vmware-tanzu/crash-diagnostics/ssh"
+)
+
+// Dirname is the directory, in the directory of a host, where the outputs of the collectors are saved
+const Dirname = "node_info"
+
+// Command is a command of a collector
+type Command struct {
+	// Name is the name of the file saving the output, without extension
+	Name string
+	Cmd  string
+	// Requires is the program needed by Cmd, the command is skipped if it is not installed
+	Requires string
+	// Path is the file needed by Cmd, the command is skipped if it does not exist
+	Path string
+	// Sudo runs Cmd as root, with sudo when the user is not root and sudo is installed
+	Sudo bool
+}
+
+// FileName returns the name of the file saving the output of c
+func (c Command) FileName() string {
+	return c.Name + ".txt"
+}
+
+// Script returns the shell script running c on a node. The script succeeds, printing
+// why, when the program or file required by c is missing.
+func (c Command) Script() string {
+	var script strings.Builder
+	if c.Requires != "" {
+		fmt.Fprintf(&script, `if ! command -v %s >/dev/null 2>&1; then echo "%s: not installed, skipped"; exit 0; fi; `, c.Requires, c.Requires)
+	}
+	if c.Path != "" {
+		fmt.Fprintf(&script, `if [ ! -e %s ]; then echo "%s: not found, skipped"; exit 0; fi; `, c.Path, c.Path)
+	}
+	if !c.Sudo {
+		script.WriteString(c.Cmd)
+		return script.String()
+	}
+	script.WriteString(`if [ "$(id -u)" != "0" ] && command -v sudo >/dev/null 2>&1; then sudo -n sh -c ` + ssh.Quote(c.Cmd) + `; else sh -c ` + ssh.Quote(c.Cmd) + `; fi`)
+	return script.String()
+}
+
+// Collector is a named bundle of commands
+type Collector struct {
+	Name     string
+	Commands []Command
+}
+
+// Get returns the collectors of names, all the collectors if names is empty
+func Get(names ...string) ([]Collector, error) {
+	if len(names) == 0 {
+		return collectors, nil
+	}
+	var result []Collector
+	for _, name := range names {
+		collector, ok := find(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown collector %q, expecting one of %s", name, strings.Join(Names(), ", "))
+		}
+		result = append(result, collector)
+	}
+	return result, nil
+}
+
+// Names returns the names of the collectors
+func Names() []string {
+	names := make([]string, len(collectors))
+	for i, collector := range collectors {
+		names[i] = collector.Name
+	}
+	return names
+}
+
+func find(name string) (Collector, bool) {
+	for _, collector := range collectors {
+		if collector.Name == name {
+			return collector, true
+		}
+	}
+	return Collector{}, false
+}
+
+// file returns a command saving the content of the file at path
+func file(name, path string) Command {
+	return Command{Name: name, Cmd: "cat " + path, Path: path, Sudo: true}
+}
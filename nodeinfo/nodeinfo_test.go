@@ -0,0 +1,100 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package nodeinfo
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	all, err := Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(Names()) {
+		t.Errorf("unexpected collectors: %d", len(all))
+	}
+
+	selected, err := Get("disk", "kubelet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 2 || selected[0].Name != "disk" || selected[1].Name != "kubelet" {
+		t.Errorf("unexpected collectors: %v", selected)
+	}
+
+	if _, err := Get("gpu"); err == nil || !strings.Contains(err.Error(), "unknown collector") {
+		t.Errorf("expected unknown collector error, got %v", err)
+	}
+}
+
+func TestCollectorNames(t *testing.T) {
+	// the names are the stable layout of the outputs
+	for _, collector := range collectors {
+		seen := make(map[string]bool)
+		for _, cmd := range collector.Commands {
+			if cmd.Name == "" || filepath.Base(cmd.FileName()) != cmd.FileName() {
+				t.Errorf("%s: invalid command name %q", collector.Name, cmd.Name)
+			}
+			if seen[cmd.Name] {
+				t.Errorf("%s: duplicate command name %q", collector.Name, cmd.Name)
+			}
+			seen[cmd.Name] = true
+		}
+	}
+}
+
+func TestCommandScript(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		cmd    Command
+		output string
+	}{
+		{
+			name:   "command",
+			cmd:    Command{Cmd: "echo 'hello world'"},
+			output: "hello world",
+		},
+		{
+			name:   "missing program",
+			cmd:    Command{Cmd: "crashd-missing-program", Requires: "crashd-missing-program"},
+			output: "crashd-missing-program: not installed, skipped",
+		},
+		{
+			name:   "missing file",
+			cmd:    file("missing", filepath.Join(dir, "missing.conf")),
+			output: filepath.Join(dir, "missing.conf") + ": not found, skipped",
+		},
+		{
+			name:   "file",
+			cmd:    file("hosts", "/etc/hosts"),
+			output: "127.0.0.1",
+		},
+		{
+			name:   "sudo",
+			cmd:    Command{Cmd: "echo 'it''s' | tr a-z A-Z", Sudo: true, Requires: "tr"},
+			output: "ITS",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.cmd.Sudo && os.Geteuid() != 0 {
+				t.Skip("sudo may require a password")
+			}
+			out, err := exec.Command("sh", "-c", test.cmd.Script()).CombinedOutput()
+			if err != nil {
+				t.Fatalf("%s: %s", err, out)
+			}
+			if got := strings.TrimSpace(string(out)); !strings.Contains(got, test.output) {
+				t.Errorf("unexpected output %q", got)
+			}
+		})
+	}
+}
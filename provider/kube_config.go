@@ -4,6 +4,7 @@
 package provider
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/vmware-tanzu/crash-diagnostics/k8s"
@@ -11,7 +12,7 @@ import (
 
 // KubeConfig returns the kubeconfig that needs to be used by the provider.
 // The path of the management kubeconfig file gets returned if the workload cluster name is empty
-func KubeConfig(mgmtKubeConfigPath, workloadClusterName, workloadClusterNamespace string) (string, error) {
+func KubeConfig(ctx context.Context, mgmtKubeConfigPath, workloadClusterName, workloadClusterNamespace string) (string, error) {
 	var err error
 
 	if workloadClusterNamespace == "" {
@@ -19,7 +20,7 @@ func KubeConfig(mgmtKubeConfigPath, workloadClusterName, workloadClusterNamespac
 	}
 	kubeConfigPath := mgmtKubeConfigPath
 	if len(workloadClusterName) != 0 {
-		kubeConfigPath, err = k8s.FetchWorkloadConfig(workloadClusterName, workloadClusterNamespace, mgmtKubeConfigPath)
+		kubeConfigPath, err = k8s.FetchWorkloadConfig(ctx, workloadClusterName, workloadClusterNamespace, mgmtKubeConfigPath)
 		if err != nil {
 			err = fmt.Errorf("could not fetch kubeconfig for workload cluster %s: %w", workloadClusterName, err)
 		}
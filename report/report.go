@@ -0,0 +1,374 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/vmware-tanzu/crash-diagnostics/analyzer"
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
+	"github.com/vmware-tanzu/crash-diagnostics/bundle"
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+)
+
+// FileName is the name of the report file saved in the working directory
+const FileName = "index.html"
+
+// maxEvents is the maximum number of warning events listed in the report
+const maxEvents = 200
+
+// failedOutput matches the line written by capture() and capture_local()
+// after the output of a failed command
+var failedOutput = regexp.MustCompile(`(: failed$)|(^capture_local error: )`)
+
+// failedTailSize is the size of the end of a file searched for the error of a failed command
+const failedTailSize = 4096
+
+// Options configures the generated report
+type Options struct {
+	Title string
+	// Uploads lists the files uploaded by the script
+	Uploads []Upload
+	// Truncations lists the files cut to stay within the crashd_config byte budgets
+	Truncations []budget.Truncation
+}
+
+// Upload is a file uploaded to object storage
+type Upload struct {
+	File string
+	URL  string
+}
+
+// Table is a table of objects rendered in the report
+type Table struct {
+	Columns []string
+	Rows    []Row
+}
+
+// Row is a table row with a link to the file the object was read from
+type Row struct {
+	Cells []string
+	Link  string
+}
+
+// Artifact is a file collected by crashd
+type Artifact struct {
+	Path string
+	Size string
+	// Error is the error message saved in place of the output of a failed command
+	Error string
+}
+
+// Host groups the files collected from a compute resource
+type Host struct {
+	Name      string
+	Artifacts []Artifact
+}
+
+// Summary is the content of the report
+type Summary struct {
+	Title     string
+	Source    string
+	Generated string
+
+	NodeCount      int
+	ReadyNodeCount int
+	PodCount       int
+	PodPhases      map[string]int
+	EventCount     int
+
+	Nodes          Table
+	UnhealthyPods  Table
+	WarningEvents  Table
+	EventsOmitted  int
+	Findings       []analyzer.Finding
+	Hosts          []Host
+	FailedCommands []Artifact
+	Artifacts      []Artifact
+	Uploads        []Upload
+	Truncations    []budget.Truncation
+}
+
+// Generate writes the report for the content of directory workdir
+// to <workdir>/index.html and returns the path of the report
+func Generate(workdir string, opts Options) (string, error) {
+	b, err := bundle.Open(workdir)
+	if err != nil {
+		return "", err
+	}
+	defer b.Close()
+
+	summary, err := Summarize(b, opts)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(b.Root(), FileName)
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("report: %w", err)
+	}
+	defer file.Close()
+
+	if err := summary.Write(file); err != nil {
+		return "", err
+	}
+	logrus.Debugf("report: saved %s", path)
+	return path, nil
+}
+
+// Summarize reads the content of bundle b to build a report summary
+func Summarize(b *bundle.Bundle, opts Options) (*Summary, error) {
+	summary := &Summary{
+		Title:       opts.Title,
+		Source:      b.Source(),
+		Generated:   time.Now().UTC().Format(time.RFC1123),
+		PodPhases:   make(map[string]int),
+		Uploads:     opts.Uploads,
+		Truncations: opts.Truncations,
+	}
+	if summary.Title == "" {
+		summary.Title = "Crash Diagnostics Report"
+	}
+
+	input, err := analyzer.NewInput(b)
+	if err != nil {
+		return nil, fmt.Errorf("report: %w", err)
+	}
+	summary.summarizeObjects(input)
+
+	rules, err := analyzer.Rules()
+	if err != nil {
+		return nil, err
+	}
+	summary.Findings = analyzer.Analyze(b.Source(), input, rules...).Findings
+
+	if err := summary.summarizeFiles(b.Root()); err != nil {
+		return nil, fmt.Errorf("report: %w", err)
+	}
+	return summary, nil
+}
+
+func (s *Summary) summarizeObjects(in *analyzer.Input) {
+	nodes := in.Select(bundle.Query{Resource: "nodes"})
+	s.NodeCount = len(nodes)
+	for _, node := range nodes {
+		if strings.HasPrefix(bundle.NodeStatus(node.Object), "Ready") {
+			s.ReadyNodeCount++
+		}
+	}
+	s.Nodes = newTable(nodes, false)
+
+	pods := in.Select(bundle.Query{Resource: "pods"})
+	s.PodCount = len(pods)
+	var unhealthy []*bundle.Object
+	for _, pod := range pods {
+		status := bundle.PodStatus(pod.Object)
+		s.PodPhases[status]++
+		if !podHealthy(pod.Object, status) {
+			unhealthy = append(unhealthy, pod)
+		}
+	}
+	s.UnhealthyPods = newTable(unhealthy, true)
+
+	events := in.Select(bundle.Query{Resource: "events"})
+	s.EventCount = len(events)
+	var warnings []*bundle.Object
+	for _, event := range events {
+		if eventType, _, _ := unstructured.NestedString(event.Object.Object, "type"); eventType == "Warning" {
+			warnings = append(warnings, event)
+		}
+	}
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return eventTime(warnings[i].Object) > eventTime(warnings[j].Object)
+	})
+	if len(warnings) > maxEvents {
+		s.EventsOmitted = len(warnings) - maxEvents
+		warnings = warnings[:maxEvents]
+	}
+	s.WarningEvents = newTable(warnings, true)
+}
+
+// podHealthy returns true if a pod completed or runs with all its containers ready
+func podHealthy(pod *unstructured.Unstructured, status string) bool {
+	switch status {
+	case "Completed", "Succeeded":
+		return true
+	case "Running":
+	default:
+		return false
+	}
+	statuses, _, _ := unstructured.NestedSlice(pod.Object, "status", "containerStatuses")
+	for _, s := range statuses {
+		if cs, ok := s.(map[string]interface{}); ok && cs["ready"] != true {
+			return false
+		}
+	}
+	return true
+}
+
+func eventTime(event *unstructured.Unstructured) string {
+	for _, field := range []string{"lastTimestamp", "eventTime", "firstTimestamp"} {
+		if t, _, _ := unstructured.NestedString(event.Object, field); t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+// newTable renders the default columns of bundle.Table for objs
+func newTable(objs []*bundle.Object, withNamespace bool) Table {
+	source := bundle.Table(objs)
+	table := Table{}
+	if withNamespace {
+		table.Columns = append(table.Columns, "Namespace")
+	}
+	for _, col := range source.ColumnDefinitions {
+		if col.Priority == 0 {
+			table.Columns = append(table.Columns, col.Name)
+		}
+	}
+	for i, r := range source.Rows {
+		row := Row{Link: objs[i].Path}
+		if withNamespace {
+			row.Cells = append(row.Cells, objs[i].Key.Namespace)
+		}
+		for j, cell := range r.Cells {
+			if source.ColumnDefinitions[j].Priority == 0 {
+				row.Cells = append(row.Cells, fmt.Sprint(cell))
+			}
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table
+}
+
+// summarizeFiles lists all collected files. Files in the top level directories,
+// other than the kube_capture output, are grouped by host.
+func (s *Summary) summarizeFiles(root string) error {
+	hosts := make(map[string]*Host)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == FileName {
+			return nil
+		}
+		artifact := Artifact{Path: filepath.ToSlash(rel), Size: formatSize(info.Size())}
+
+		parts := strings.Split(artifact.Path, "/")
+		if parts[0] != k8s.BaseDirname {
+			artifact.Error = failedCommandError(path)
+			if artifact.Error != "" {
+				s.FailedCommands = append(s.FailedCommands, artifact)
+			}
+
+			hostName := "local"
+			if len(parts) > 1 {
+				hostName = parts[0]
+			}
+			host, ok := hosts[hostName]
+			if !ok {
+				host = &Host{Name: hostName}
+				hosts[hostName] = host
+			}
+			host.Artifacts = append(host.Artifacts, artifact)
+		}
+		s.Artifacts = append(s.Artifacts, artifact)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, host := range hosts {
+		s.Hosts = append(s.Hosts, *host)
+	}
+	sort.Slice(s.Hosts, func(i, j int) bool { return s.Hosts[i].Name < s.Hosts[j].Name })
+	return nil
+}
+
+// failedCommandError returns the error saved in file path by a failed command, if any
+func failedCommandError(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return ""
+	}
+	tail := make([]byte, min(info.Size(), failedTailSize))
+	if _, err := file.ReadAt(tail, info.Size()-int64(len(tail))); err != nil && err != io.EOF {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSpace(string(tail)), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	// capture() saves the error on the line following "<cmd>: failed"
+	if len(lines) > 1 {
+		if prev := strings.TrimSpace(lines[len(lines)-2]); strings.HasSuffix(prev, ": failed") && last != "" {
+			return fmt.Sprintf("%s: %s", strings.TrimSuffix(prev, ": failed"), last)
+		}
+	}
+	if failedOutput.MatchString(last) {
+		return last
+	}
+	return ""
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// fileLink returns a URL, relative to the report, for file path
+func fileLink(path string) template.URL {
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return template.URL(strings.Join(parts, "/"))
+}
+
+// Write renders the summary as an HTML document to w
+func (s *Summary) Write(w io.Writer) error {
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		"link":  fileLink,
+		"lower": strings.ToLower,
+		"size":  formatSize,
+	}).Parse(reportTemplate)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, s)
+}
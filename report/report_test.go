@@ -0,0 +1,147 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
+)
+
+const (
+	testPodList = `{"apiVersion":"v1","kind":"PodList","metadata":{},"items":[
+{"apiVersion":"v1","kind":"Pod","metadata":{"name":"etcd","namespace":"kube-system"},
+ "spec":{"containers":[{"name":"etcd"}]},
+ "status":{"phase":"Running","containerStatuses":[{"name":"etcd","ready":true,"restartCount":0,"state":{"running":{}}}]}},
+{"apiVersion":"v1","kind":"Pod","metadata":{"name":"coredns","namespace":"kube-system"},
+ "spec":{"containers":[{"name":"coredns"}]},
+ "status":{"phase":"Running","containerStatuses":[{"name":"coredns","ready":false,"restartCount":7,"state":{"waiting":{"reason":"CrashLoopBackOff"}}}]}}
+]}`
+
+	testNodeList = `{"apiVersion":"v1","kind":"NodeList","metadata":{},"items":[
+{"apiVersion":"v1","kind":"Node","metadata":{"name":"node-0"},"status":{"conditions":[{"type":"Ready","status":"True"}],"nodeInfo":{"kubeletVersion":"v1.32.1"}}}
+]}`
+
+	testEventList = `{"apiVersion":"v1","kind":"EventList","metadata":{},"items":[
+{"apiVersion":"v1","kind":"Event","metadata":{"name":"coredns.1","namespace":"kube-system"},"type":"Warning","reason":"BackOff",
+ "lastTimestamp":"2020-01-01T00:59:00Z","involvedObject":{"kind":"Pod","name":"coredns"},"message":"Back-off restarting failed container"},
+{"apiVersion":"v1","kind":"Event","metadata":{"name":"etcd.1","namespace":"kube-system"},"type":"Normal","reason":"Pulled",
+ "involvedObject":{"kind":"Pod","name":"etcd"},"message":"image pulled"}
+]}`
+)
+
+func makeTestWorkdir(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"kubecapture/core_v1/kube-system/pods-2020-01-01T01-00-00Z.0000.json":   testPodList,
+		"kubecapture/core_v1/kube-system/events-2020-01-01T01-00-00Z.0000.json": testEventList,
+		"kubecapture/core_v1/nodes-2020-01-01T01-00-00Z.0000.json":              testNodeList,
+		"10_0_0_1/uptime.txt":                 "up 2 days",
+		"10_0_0_1/sudo_crictl_info.txt":       "sudo crictl info: failed\nexit status 127: crictl: command not found\n",
+		"10_0_0_1/journalctl.txt":             "-- Logs begin --\nkubelet started\njournalctl: failed\nexit status 1\n",
+		"10_0_0_1/var/log/kube-apiserver.log": "started",
+		"uname_-a.txt":                        "capture_local error: exit status 1: uname: not found",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0744); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestGenerate(t *testing.T) {
+	workdir := makeTestWorkdir(t)
+	path, err := Generate(workdir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(workdir, FileName) {
+		t.Errorf("unexpected report path: %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(data)
+
+	for _, expected := range []string{
+		"<title>Crash Diagnostics Report</title>",
+		"1/1 ready",
+		"CrashLoopBackOff: 1",
+		"Back-off restarting failed container",
+		`<a href="10_0_0_1/var/log/kube-apiserver.log">`,
+		"sudo crictl info: exit status 127: crictl: command not found",
+		"journalctl: exit status 1",
+		"capture_local error: exit status 1: uname: not found",
+		`<h3 id="host-local">local</h3>`,
+		"pod-crashloop-backoff",
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("report does not contain %q", expected)
+		}
+	}
+	for _, unexpected := range []string{"Pulled", "http://", "https://", "<script"} {
+		if strings.Contains(html, unexpected) {
+			t.Errorf("report should not contain %q", unexpected)
+		}
+	}
+}
+
+func TestSummarizeRegenerate(t *testing.T) {
+	workdir := makeTestWorkdir(t)
+	if _, err := Generate(workdir, Options{Title: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Generate(workdir, Options{Title: "second"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(workdir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `href="index.html"`) {
+		t.Error("report should not list itself")
+	}
+}
+
+func TestGenerateUploads(t *testing.T) {
+	workdir := makeTestWorkdir(t)
+	uploads := []Upload{{File: "/tmp/crashd.tar.gz", URL: "https://diagnostics.s3.us-east-1.amazonaws.com/crashd.tar.gz"}}
+	path, err := Generate(workdir, Options{Uploads: uploads})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `<a href="https://diagnostics.s3.us-east-1.amazonaws.com/crashd.tar.gz">`) {
+		t.Error("report does not list the upload")
+	}
+}
+
+func TestGenerateTruncations(t *testing.T) {
+	workdir := makeTestWorkdir(t)
+	truncations := []budget.Truncation{{File: "/tmp/crashd/kubelet.log", Reason: "max_file_bytes=1048576", Size: 5 << 20, Kept: 1 << 20}}
+	path, err := Generate(workdir, Options{Truncations: truncations})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "<td>/tmp/crashd/kubelet.log</td><td>5.0 MiB</td><td>1.0 MiB</td><td>max_file_bytes=1048576 exceeded</td>") {
+		t.Error("report does not list the truncated file")
+	}
+}
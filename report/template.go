@@ -0,0 +1,136 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package report
+
+// reportTemplate renders a Summary. The document embeds its style and
+// does not reference any external resource so it can be viewed offline.
+const reportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { margin-bottom: 0; }
+h2 { border-bottom: 1px solid #ccc; padding-bottom: 0.2em; margin-top: 2em; }
+table { border-collapse: collapse; margin: 1em 0; font-size: 0.9em; }
+th, td { border: 1px solid #ddd; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f3f3f3; }
+code, .mono { font-family: monospace; }
+.meta { color: #666; }
+.critical { color: #b00020; font-weight: bold; }
+.warning { color: #a15c00; font-weight: bold; }
+.info { color: #005a9c; }
+.failed { color: #b00020; }
+ul.toc li { margin: 0.2em 0; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">Source: <code>{{.Source}}</code> &middot; Generated: {{.Generated}}</p>
+
+<h2 id="contents">Contents</h2>
+<ul class="toc">
+<li><a href="#overview">Cluster overview</a></li>
+<li><a href="#findings">Findings</a> ({{len .Findings}})</li>
+<li><a href="#hosts">Hosts</a> ({{len .Hosts}})</li>
+<li><a href="#failed">Failed commands</a> ({{len .FailedCommands}})</li>
+<li><a href="#artifacts">All artifacts</a> ({{len .Artifacts}})</li>
+{{if .Truncations}}<li><a href="#truncated">Truncated files</a> ({{len .Truncations}})</li>{{end}}
+{{if .Uploads}}<li><a href="#uploads">Uploads</a> ({{len .Uploads}})</li>{{end}}
+</ul>
+
+<h2 id="overview">Cluster overview</h2>
+<table>
+<tr><th>Nodes</th><td>{{.ReadyNodeCount}}/{{.NodeCount}} ready</td></tr>
+<tr><th>Pods</th><td>{{.PodCount}}{{range $status, $count := .PodPhases}} &middot; {{$status}}: {{$count}}{{end}}</td></tr>
+<tr><th>Events</th><td>{{.EventCount}}</td></tr>
+</table>
+
+<h3>Nodes</h3>
+{{template "table" .Nodes}}
+
+<h3>Unhealthy pods</h3>
+{{template "table" .UnhealthyPods}}
+
+<h3>Warning events</h3>
+{{template "table" .WarningEvents}}
+{{if .EventsOmitted}}<p class="meta">{{.EventsOmitted}} older warning events not shown</p>{{end}}
+
+<h2 id="findings">Findings</h2>
+{{if .Findings}}
+<table>
+<tr><th>Severity</th><th>Rule</th><th>Resource</th><th>Message</th><th>Evidence</th></tr>
+{{range .Findings}}
+<tr>
+<td class="{{lower (print .Severity)}}">{{.Severity}}</td>
+<td>{{.Rule}}</td>
+<td>{{.Resource}}</td>
+<td>{{.Message}}</td>
+<td>{{range .Evidence}}<a href="{{link .}}">{{.}}</a><br>{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}<p>No findings.</p>{{end}}
+
+<h2 id="hosts">Hosts</h2>
+{{range .Hosts}}
+<h3 id="host-{{.Name}}">{{.Name}}</h3>
+<table>
+<tr><th>File</th><th>Size</th><th>Status</th></tr>
+{{range .Artifacts}}
+<tr><td><a href="{{link .Path}}">{{.Path}}</a></td><td>{{.Size}}</td><td>{{if .Error}}<span class="failed">failed</span>{{else}}ok{{end}}</td></tr>
+{{end}}
+</table>
+{{else}}<p>No host outputs.</p>{{end}}
+
+<h2 id="failed">Failed commands</h2>
+{{if .FailedCommands}}
+<table>
+<tr><th>File</th><th>Error</th></tr>
+{{range .FailedCommands}}
+<tr><td><a href="{{link .Path}}">{{.Path}}</a></td><td class="mono">{{.Error}}</td></tr>
+{{end}}
+</table>
+{{else}}<p>No failed commands.</p>{{end}}
+
+<h2 id="artifacts">All artifacts</h2>
+<table>
+<tr><th>File</th><th>Size</th></tr>
+{{range .Artifacts}}
+<tr><td><a href="{{link .Path}}">{{.Path}}</a></td><td>{{.Size}}</td></tr>
+{{end}}
+</table>
+{{if .Truncations}}
+<h2 id="truncated">Truncated files</h2>
+<table>
+<tr><th>File</th><th>Size</th><th>Kept</th><th>Reason</th></tr>
+{{range .Truncations}}
+<tr><td>{{.File}}</td><td>{{size .Size}}</td><td>{{size .Kept}}</td><td>{{.Reason}} exceeded</td></tr>
+{{end}}
+</table>
+{{end}}
+{{if .Uploads}}
+<h2 id="uploads">Uploads</h2>
+<table>
+<tr><th>File</th><th>URL</th></tr>
+{{range .Uploads}}
+<tr><td>{{.File}}</td><td><a href="{{.URL}}">{{.URL}}</a></td></tr>
+{{end}}
+</table>
+{{end}}
+</body>
+</html>
+
+{{define "table"}}
+{{if .Rows}}
+<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}<th>Source</th></tr>
+{{range .Rows}}
+<tr>{{range .Cells}}<td>{{.}}</td>{{end}}<td>{{if .Link}}<a href="{{link .Link}}">view</a>{{end}}</td></tr>
+{{end}}
+</table>
+{{else}}<p>None.</p>{{end}}
+{{end}}
+`
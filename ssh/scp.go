@@ -4,8 +4,11 @@
 package ssh
 
 import (
+	"archive/tar"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,13 +16,20 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/vladimirvivien/gexe"
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/utils/net"
 )
 
 // CopyFrom copies one or more files using SCP from remote host
 // and returns the paths of files that were successfully copied.
-func CopyFrom(args SSHArgs, agent Agent, rootDir string, sourcePath string) error {
+// With a limited budget, the files are streamed as a tar archive over ssh and
+// written within the limit as they are received, see copyFromStream.
+func CopyFrom(args SSHArgs, agent Agent, rootDir string, sourcePath string, limit *budget.Budget) error {
+	if limit.Limited() {
+		return copyFromStream(args, agent, rootDir, sourcePath, limit)
+	}
+
 	e := gexe.New()
 	prog := e.Prog().Avail("scp")
 	if len(prog) == 0 {
@@ -71,6 +81,136 @@ func CopyFrom(args SSHArgs, agent Agent, rootDir string, sourcePath string) erro
 	return nil
 }
 
+// copyFromStream copies sourcePath, like CopyFrom, from the tar archive of the remote files
+// read over ssh. The files are written in rootDir within limit, so the bytes over budget are
+// never written to disk. Symbolic links are followed, as scp does.
+func copyFromStream(args SSHArgs, agent Agent, rootDir, sourcePath string, limit *budget.Budget) error {
+	e := gexe.New()
+	prog := e.Prog().Avail("ssh")
+	if len(prog) == 0 {
+		return errors.New("ssh program not found")
+	}
+	sshCmd, err := makeSSHCmdStr(prog, args)
+	if err != nil {
+		return fmt.Errorf("scp: copyFrom: failed to build command string: %s", err)
+	}
+	// the source path is not quoted, so the remote shell expands its wildcards
+	effectiveCmd := fmt.Sprintf(`%s "%s"`, sshCmd, EncodeScript("tar -chf - "+sourcePath))
+	logrus.Debugf("scp: copyFrom: cmd: [%s]", effectiveCmd)
+
+	if agent != nil {
+		logrus.Debugf("scp: copyFrom: adding agent info: %s", agent.GetEnvVariables())
+		e = e.Envs(agent.GetEnvVariables()...)
+	}
+
+	maxRetries := args.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = 10
+	}
+	var stderr bytes.Buffer
+	retries := wait.Backoff{Steps: maxRetries, Duration: time.Millisecond * 80, Jitter: 0.1}
+	if err := wait.ExponentialBackoff(retries, func() (bool, error) {
+		stderr.Reset()
+		reader, writer := io.Pipe()
+		received := &countingReader{r: reader}
+		extracted := make(chan error, 1)
+		go func() {
+			err := untarWithin(received, rootDir, limit)
+			if err == nil {
+				// read the padding after the end of the archive
+				_, err = io.Copy(io.Discard, received)
+			}
+			// unblock the remote tar if the extraction stopped early
+			reader.CloseWithError(err)
+			extracted <- err
+		}()
+
+		p := e.NewProc(effectiveCmd)
+		p.SetStdout(writer)
+		p.SetStderr(&stderr)
+		runErr := p.Run().Err()
+		writer.Close()
+		extractErr := <-extracted
+
+		if extractErr != nil {
+			return false, extractErr
+		}
+		if runErr != nil {
+			if p.ExitCode() == sshConnectionError && received.n == 0 {
+				logrus.Warn(fmt.Sprintf("scp: copyFrom: failed to connect to %s:%s '%s %s': retrying connection", args.Host, args.Port, runErr, strings.TrimSpace(stderr.String())))
+				return false, nil
+			}
+			return false, fmt.Errorf("%s: %s", runErr, strings.TrimSpace(stderr.String()))
+		}
+		return true, nil
+	}); err != nil {
+		return fmt.Errorf("scp: copyFrom: failed after %d attempt(s): %s", maxRetries, err)
+	}
+
+	logrus.Debugf("scp: copyFrom: copied %s", sourcePath)
+	return nil
+}
+
+// untarWithin extracts the directories and regular files of the tar stream source in
+// rootDir, writing the content of the files within limit
+func untarWithin(source io.Reader, rootDir string, limit *budget.Budget) error {
+	tr := tar.NewReader(source)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(rootDir, filepath.Clean("/"+hdr.Name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0744); err != nil && !os.IsExist(err) {
+				return err
+			}
+		case tar.TypeReg:
+			if err := untarFileWithin(tr, target, hdr, limit); err != nil {
+				return err
+			}
+		default:
+			logrus.Debugf("scp: copyFrom: skipping %s of type %c", hdr.Name, hdr.Typeflag)
+		}
+	}
+}
+
+func untarFileWithin(source io.Reader, target string, hdr *tar.Header, limit *budget.Budget) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0744); err != nil && !os.IsExist(err) {
+		return err
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hdr.FileInfo().Mode().Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := limit.Copy(target, file, source); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	// preserve the modification time, like scp -p
+	return os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
 // CopyTo copies one or more files using SCP from local machine to
 // remote host.
 func CopyTo(args SSHArgs, agent Agent, sourcePath, targetPath string) error {
@@ -4,12 +4,26 @@
 package ssh
 
 import (
+	"archive/tar"
+	"bytes"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
 )
 
+func newTestBudget(t *testing.T, opts budget.Options) *budget.Budget {
+	t.Helper()
+	b, err := budget.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
 func TestCopyFrom(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -17,6 +31,7 @@ func TestCopyFrom(t *testing.T) {
 		remoteFiles map[string]string
 		srcFile     string
 		fileContent string
+		limit       *budget.Budget
 	}{
 		{
 			name:        "copy single file",
@@ -60,6 +75,29 @@ func TestCopyFrom(t *testing.T) {
 			srcFile:     "bar/",
 			fileContent: "FooBar",
 		},
+		{
+			name:        "copy single file within budget",
+			sshArgs:     testSSHArgs,
+			remoteFiles: map[string]string{"foo/bar.txt": "FooBar"},
+			srcFile:     "foo/bar.txt",
+			fileContent: "FooBar",
+			limit:       newTestBudget(t, budget.Options{MaxFileBytes: 100}),
+		},
+		{
+			name:        "copy dir within budget",
+			sshArgs:     testSSHArgs,
+			remoteFiles: map[string]string{"bar/foo.csv": "FooBar", "bar/bar.txt": "BarBar"},
+			srcFile:     "bar/",
+			limit:       newTestBudget(t, budget.Options{MaxTotalBytes: 100}),
+		},
+		{
+			name:        "copy single file over budget",
+			sshArgs:     testSSHArgs,
+			remoteFiles: map[string]string{"foo.txt": "FooBar"},
+			srcFile:     "foo.txt",
+			fileContent: "Foo\n... [crashd: truncated 3 bytes, max_file_bytes=3 exceeded] ...\n",
+			limit:       newTestBudget(t, budget.Options{MaxFileBytes: 3}),
+		},
 	}
 
 	for _, test := range tests {
@@ -75,7 +113,7 @@ func TestCopyFrom(t *testing.T) {
 				MakeRemoteTestSSHFile(t, test.sshArgs, file, content)
 			}
 
-			if err := CopyFrom(test.sshArgs, nil, support.TmpDirRoot(), test.srcFile); err != nil {
+			if err := CopyFrom(test.sshArgs, nil, support.TmpDirRoot(), test.srcFile, test.limit); err != nil {
 				t.Fatal(err)
 			}
 
@@ -103,6 +141,106 @@ func TestCopyFrom(t *testing.T) {
 	}
 }
 
+func TestUntarWithin(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	entries := []struct {
+		hdr  tar.Header
+		data string
+	}{
+		{hdr: tar.Header{Name: "var/log/", Typeflag: tar.TypeDir, Mode: 0755}},
+		{hdr: tar.Header{Name: "var/log/kubelet.log", Typeflag: tar.TypeReg, Mode: 0600, ModTime: modTime}, data: "0123456789"},
+		{hdr: tar.Header{Name: "var/log/syslog", Typeflag: tar.TypeReg, Mode: 0644, ModTime: modTime}, data: "ab"},
+		{hdr: tar.Header{Name: "../../escaped", Typeflag: tar.TypeReg, Mode: 0644}, data: "x"},
+		{hdr: tar.Header{Name: "var/log/latest", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}},
+	}
+	for _, e := range entries {
+		e.hdr.Size = int64(len(e.data))
+		if err := tw.WriteHeader(&e.hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	root := filepath.Join(t.TempDir(), "root")
+	limit := newTestBudget(t, budget.Options{MaxFileBytes: 4})
+	if err := untarWithin(&buf, root, limit); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "var", "log", "kubelet.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "0123\n... [crashd: truncated 6 bytes") {
+		t.Errorf("unexpected content %q", data)
+	}
+	info, err := os.Stat(filepath.Join(root, "var", "log", "kubelet.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("unexpected modification time %s", info.ModTime())
+	}
+	if data := getTestFileContent(t, filepath.Join(root, "var", "log", "syslog")); data != "ab" {
+		t.Errorf("unexpected content %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(root, "escaped")); err != nil {
+		t.Errorf("entry out of root not extracted in root: %s", err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "var", "log", "latest")); !os.IsNotExist(err) {
+		t.Error("symbolic link should not be extracted")
+	}
+	if len(limit.Truncations()) != 1 {
+		t.Errorf("unexpected truncations: %+v", limit.Truncations())
+	}
+}
+
+func TestCopyFromStream(t *testing.T) {
+	// fake ssh running the remote command locally
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "ssh"), []byte("#!/bin/sh\nfor cmd; do :; done\nexec sh -c \"$cmd\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	remote := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(remote, "logs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"a.log": "0123456789", "b.log": "ab", "c.txt": "c"} {
+		if err := os.WriteFile(filepath.Join(remote, "logs", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root := t.TempDir()
+	limit := newTestBudget(t, budget.Options{MaxFileBytes: 4})
+	if err := CopyFrom(SSHArgs{User: "test", Host: "localhost", MaxRetries: 1}, nil, root, filepath.Join(remote, "logs", "*.log"), limit); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(root, remote, "logs")
+	if data := getTestFileContent(t, filepath.Join(dir, "a.log")); !strings.HasPrefix(data, "0123\n... [crashd: truncated 6 bytes") {
+		t.Errorf("unexpected content %q", data)
+	}
+	if data := getTestFileContent(t, filepath.Join(dir, "b.log")); data != "ab" {
+		t.Errorf("unexpected content %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "c.txt")); !os.IsNotExist(err) {
+		t.Error("file not matching the source path copied")
+	}
+
+	if err := CopyFrom(SSHArgs{User: "test", Host: "localhost", MaxRetries: 1}, nil, root, filepath.Join(remote, "missing"), limit); err == nil {
+		t.Error("expected error for missing remote file")
+	}
+}
+
 func TestCopyTo(t *testing.T) {
 	tests := []struct {
 		name        string
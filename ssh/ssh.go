@@ -5,15 +5,16 @@ package ssh
 
 import (
 	"bytes"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/vladimirvivien/gexe"
-	"github.com/vladimirvivien/gexe/exec"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
@@ -33,32 +34,52 @@ type SSHArgs struct {
 
 // Run runs a command over SSH and returns the result as a string
 func Run(args SSHArgs, agent Agent, cmd string) (string, error) {
-	reader, err := sshRunProc(args, agent, cmd)
-	if err != nil {
-		return "", err
-	}
 	var result bytes.Buffer
-	if _, err := result.ReadFrom(reader); err != nil {
+	if err := RunStream(args, agent, cmd, &result); err != nil {
 		return "", err
 	}
 	return strings.TrimSpace(result.String()), nil
 }
 
-// RunRead runs a command over SSH and returns an io.Reader for stdout/stderr
+// EncodeScript returns a command running shell script on the remote host. The script
+// is base64 encoded, so its quotes and variables (i.e. "$f") reach the remote shell
+// unaltered by the parsing and variable expansion of the local ssh command line. The
+// remote host needs the base64 program of coreutils or busybox.
+func EncodeScript(script string) string {
+	return fmt.Sprintf("echo %s | base64 -d | sh", base64.StdEncoding.EncodeToString([]byte(script)))
+}
+
+// Quote returns s single-quoted for a POSIX shell, to pass it as one word in a script
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// RunRead runs a command over SSH and returns an io.Reader streaming its stdout/stderr.
+// The reader must be read to its end, it returns the error of the command, if any.
 func RunRead(args SSHArgs, agent Agent, cmd string) (io.Reader, error) {
-	return sshRunProc(args, agent, cmd)
+	if _, err := makeSSHCmdStr("ssh", args); err != nil {
+		return nil, err
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(RunStream(args, agent, cmd, writer))
+	}()
+	return reader, nil
 }
 
-func sshRunProc(args SSHArgs, agent Agent, cmd string) (io.Reader, error) {
+// RunStream runs a command over SSH and writes its stdout/stderr to out as they are
+// produced, so the output is never held in memory. Connection failures are retried
+// as long as the command has not written any output.
+func RunStream(args SSHArgs, agent Agent, cmd string, out io.Writer) error {
 	e := gexe.New()
 	prog := e.Prog().Avail("ssh")
 	if len(prog) == 0 {
-		return nil, errors.New("ssh program not found")
+		return errors.New("ssh program not found")
 	}
 
 	sshCmd, err := makeSSHCmdStr(prog, args)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	effectiveCmd := fmt.Sprintf(`%s "%s"`, sshCmd, cmd)
 	logrus.Debug("ssh.run: ", effectiveCmd)
@@ -68,32 +89,109 @@ func sshRunProc(args SSHArgs, agent Agent, cmd string) (io.Reader, error) {
 		e = e.Envs(agent.GetEnvVariables()...)
 	}
 
-	var proc *exec.Proc
+	var attempt *attemptWriter
 	maxRetries := args.MaxRetries
 	if maxRetries == 0 {
 		maxRetries = 10
 	}
 	retries := wait.Backoff{Steps: maxRetries, Duration: time.Millisecond * 80, Jitter: 0.1}
 	if err := wait.ExponentialBackoff(retries, func() (bool, error) {
-		p := e.RunProc(effectiveCmd)
-		if p.Err() != nil {
-			logrus.Warn(fmt.Sprintf("ssh: failed to connect to %s: error '%s %s': retrying connection", args.Host, p.Err(), p.Result()))
-			return false, p.Err()
+		attempt = &attemptWriter{out: out}
+		p := e.NewProc(effectiveCmd)
+		p.SetStdout(attemptStdout{attempt})
+		p.SetStderr(attemptStderr{attempt})
+		if err := p.Run().Err(); err != nil {
+			if p.ExitCode() == sshConnectionError && !attempt.hasOutput() {
+				logrus.Warn(fmt.Sprintf("ssh: failed to connect to %s: error '%s %s': retrying connection", args.Host, err, strings.TrimSpace(attempt.held.String())))
+				return false, nil
+			}
+			return false, err
 		}
-		proc = p
 		return true, nil // worked
 	}); err != nil {
+		if attempt != nil {
+			_ = attempt.flush()
+		}
 		logrus.Debugf("ssh.run failed after %d tries", maxRetries)
-		return nil, fmt.Errorf("ssh: failed after %d attempt(s): %s", maxRetries, err)
+		return fmt.Errorf("ssh: failed after %d attempt(s): %s", maxRetries, err)
+	}
+
+	return attempt.flush()
+}
+
+// sshConnectionError is the exit code of ssh when it fails to connect
+const sshConnectionError = 255
+
+// maxHeldBytes is the size of the error output held back by an attemptWriter
+const maxHeldBytes = 64 * 1024
+
+// attemptWriter forwards the output of an ssh attempt to out. The error output written
+// before the command output is held back, up to maxHeldBytes, so that the messages of
+// a failed connection are not written to out when the connection is retried.
+type attemptWriter struct {
+	mu      sync.Mutex
+	out     io.Writer
+	held    bytes.Buffer
+	written bool
+	err     error
+}
+
+func (w *attemptWriter) writeOut(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.release(); err != nil {
+		return 0, err
 	}
+	return w.out.Write(p)
+}
 
-	if proc == nil {
-		return nil, errors.New("ssh.run: did get process result")
+func (w *attemptWriter) writeErr(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.written && w.held.Len()+len(p) <= maxHeldBytes {
+		return w.held.Write(p)
+	}
+	if err := w.release(); err != nil {
+		return 0, err
 	}
+	return w.out.Write(p)
+}
 
-	return proc.Out(), nil
+// release writes the held output to out, once
+func (w *attemptWriter) release() error {
+	if w.written {
+		return w.err
+	}
+	w.written = true
+	if w.held.Len() > 0 {
+		_, w.err = w.out.Write(w.held.Bytes())
+		w.held.Reset()
+	}
+	return w.err
 }
 
+func (w *attemptWriter) hasOutput() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.written
+}
+
+func (w *attemptWriter) flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.release()
+}
+
+// attemptStdout and attemptStderr are the standard output and error of an attempt,
+// distinct writers so that the process writes to both concurrently
+type attemptStdout struct{ w *attemptWriter }
+
+func (o attemptStdout) Write(p []byte) (int, error) { return o.w.writeOut(p) }
+
+type attemptStderr struct{ w *attemptWriter }
+
+func (o attemptStderr) Write(p []byte) (int, error) { return o.w.writeErr(p) }
+
 func makeSSHCmdStr(progName string, args SSHArgs) (string, error) {
 	if args.User == "" {
 		return "", errors.New("SSH: user is required")
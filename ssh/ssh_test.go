@@ -5,8 +5,12 @@ package ssh
 
 import (
 	"bytes"
+	"fmt"
+	"os/exec"
 	"strings"
 	"testing"
+
+	"github.com/vladimirvivien/gexe"
 )
 
 func TestRun(t *testing.T) {
@@ -76,6 +80,66 @@ func TestRunRead(t *testing.T) {
 	}
 }
 
+func TestRunStream(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RunStream(testSSHArgs, nil, "seq 1 100000", &buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 100000 || lines[0] != "1" || lines[len(lines)-1] != "100000" {
+		t.Fatalf("unexpected output of %d lines", len(lines))
+	}
+}
+
+func TestAttemptWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := &attemptWriter{out: &out}
+	if _, err := (attemptStderr{w}).Write([]byte("ssh: connect to host refused\n")); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 || w.hasOutput() {
+		t.Fatalf("error output written before the command output: %q", out.String())
+	}
+	if _, err := (attemptStdout{w}).Write([]byte("result\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (attemptStderr{w}).Write([]byte("warning\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "ssh: connect to host refused\nresult\nwarning\n"; out.String() != expected {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+}
+
+func TestEncodeScript(t *testing.T) {
+	script := `for f in one "two words"; do echo "$f: $(echo 'ok')"; done`
+	// the command goes through the same local parsing as the ssh command line
+	p := gexe.New().NewProc(fmt.Sprintf(`sh -c "%s"`, EncodeScript(script)))
+	var out bytes.Buffer
+	p.SetStdout(&out)
+	if err := p.Run().Err(); err != nil {
+		t.Fatalf("%s: %s", err, out.String())
+	}
+	if expected := "one: ok\ntwo words: ok\n"; out.String() != expected {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for _, word := range []string{"plain", "two words", "it's", `$HOME "x" \n`} {
+		out, err := exec.Command("sh", "-c", "printf %s "+Quote(word)).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != word {
+			t.Errorf("unexpected word %q, expecting %q", out, word)
+		}
+	}
+}
+
 func TestSSHRunMakeCmdStr(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -0,0 +1,219 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/vmware-tanzu/crash-diagnostics/analyzer"
+	"github.com/vmware-tanzu/crash-diagnostics/bundle"
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+)
+
+// AnalyzeFn is the Starlark built-in that applies analyzer rules to the content
+// collected in the working directory, or to the objects returned by kube_get().
+// Rules include the built-in rules, the rules registered with analyzer_rule(), and
+// the rules loaded from rules_dir. The findings are saved to a report file and the
+// function returns a struct containing the report file, the findings, and an error message, if any.
+// Starlark format: analyze([workdir=<path>, output_format="text"|"json", rules=["node-not-ready"], rules_dir=<path>, objects=kube_get().objs])
+func AnalyzeFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var workdir, outputFormat, rulesDir string
+	var rules, objects *starlark.List
+
+	if err := starlark.UnpackArgs(
+		identifiers.analyze, args, kwargs,
+		"workdir?", &workdir,
+		"output_format?", &outputFormat,
+		"rules?", &rules,
+		"rules_dir?", &rulesDir,
+		"objects?", &objects,
+	); err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.analyze, err)
+	}
+
+	if len(workdir) == 0 {
+		dir, err := getWorkdirFromThread(thread)
+		if err != nil {
+			return starlark.None, err
+		}
+		workdir = dir
+	}
+
+	outputFormat = strings.ToLower(outputFormat)
+	switch outputFormat {
+	case "":
+		outputFormat = "text"
+	case "text", "json":
+	default:
+		return starlark.None, fmt.Errorf("%s: unsupported output_format: %s", identifiers.analyze, outputFormat)
+	}
+
+	ruleList, err := analyzeRules(thread, rulesDir, toSlice(rules))
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.analyze, err)
+	}
+
+	var input *analyzer.Input
+	if objects != nil {
+		index, err := objectIndexFromSearchResults(objects)
+		if err != nil {
+			return starlark.None, fmt.Errorf("%s: %s", identifiers.analyze, err)
+		}
+		input = &analyzer.Input{Root: workdir, Objects: index}
+	}
+
+	report, file, err := analyzeWorkdir(workdir, outputFormat, input, ruleList)
+	return newAnalyzeResult(report, file, err), nil
+}
+
+// analyzeRules returns the built-in rules along with the rules registered in
+// the thread and loaded from rulesDir, filtered by names
+func analyzeRules(thread *starlark.Thread, rulesDir string, names []string) ([]analyzer.Rule, error) {
+	rules, err := analyzer.Rules()
+	if err != nil {
+		return nil, err
+	}
+	custom := getAnalyzerRulesFromThread(thread)
+	if len(rulesDir) > 0 {
+		loaded, err := LoadAnalyzerRules(rulesDir)
+		if err != nil {
+			return nil, err
+		}
+		if custom, err = appendRules(custom, loaded...); err != nil {
+			return nil, err
+		}
+	}
+	return analyzer.Select(append(rules, custom...), names...)
+}
+
+// objectIndexFromSearchResults indexes the objects of a list of search results returned by kube_get()
+func objectIndexFromSearchResults(list *starlark.List) (*bundle.ObjectIndex, error) {
+	var results []k8s.SearchResult
+	for i := 0; i < list.Len(); i++ {
+		sr, err := searchResultFromStarlark(list.Index(i))
+		if err != nil {
+			return nil, fmt.Errorf("objects: %w", err)
+		}
+		results = append(results, sr)
+	}
+	return bundle.NewObjectIndex(results), nil
+}
+
+// searchResultFromStarlark converts a value created by k8s.SearchResult.ToStarlarkValue
+func searchResultFromStarlark(val starlark.Value) (k8s.SearchResult, error) {
+	goVal, err := StarlarkToGoValue(val)
+	if err != nil {
+		return k8s.SearchResult{}, err
+	}
+	fields, ok := goVal.(map[string]interface{})
+	if !ok {
+		return k8s.SearchResult{}, fmt.Errorf("unexpected search result type %s", val.Type())
+	}
+
+	sr := k8s.SearchResult{List: &unstructured.UnstructuredList{Object: map[string]interface{}{}}}
+	sr.ListKind, _ = fields["ListKind"].(string)
+	sr.ResourceName, _ = fields["ResourceName"].(string)
+	sr.ResourceKind, _ = fields["ResourceKind"].(string)
+	sr.Namespaced, _ = fields["Namespaced"].(bool)
+	sr.Namespace, _ = fields["Namespace"].(string)
+	if gvr, ok := fields["GroupVersionResource"].(map[string]interface{}); ok {
+		sr.GroupVersionResource.Group, _ = gvr["Group"].(string)
+		sr.GroupVersionResource.Version, _ = gvr["Version"].(string)
+		sr.GroupVersionResource.Resource, _ = gvr["Resource"].(string)
+	}
+	if list, ok := fields["List"].(map[string]interface{}); ok {
+		items, _ := list["Items"].([]interface{})
+		for _, item := range items {
+			if obj, ok := item.(map[string]interface{}); ok {
+				sr.List.Items = append(sr.List.Items, unstructured.Unstructured{Object: obj})
+			}
+		}
+	}
+	return sr, nil
+}
+
+// analyzeWorkdir applies rules to input, or to the content of workdir when input
+// is nil, and saves the report in workdir
+func analyzeWorkdir(workdir, outputFormat string, input *analyzer.Input, rules []analyzer.Rule) (*analyzer.Report, string, error) {
+	if input == nil {
+		b, err := bundle.Open(workdir)
+		if err != nil {
+			return nil, "", err
+		}
+		defer b.Close()
+
+		if input, err = analyzer.NewInput(b); err != nil {
+			return nil, "", err
+		}
+	}
+	report := analyzer.Analyze(workdir, input, rules...)
+
+	if err := os.MkdirAll(workdir, 0744); err != nil {
+		return report, "", fmt.Errorf("failed to create report: %w", err)
+	}
+	ext := "txt"
+	if outputFormat == "json" {
+		ext = "json"
+	}
+	path := filepath.Join(workdir, fmt.Sprintf("%s.%s", analyzer.ReportFileName, ext))
+	file, err := os.Create(path)
+	if err != nil {
+		return report, "", fmt.Errorf("failed to create report: %w", err)
+	}
+	defer file.Close()
+
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.WriteText(file)
+	}
+	if err != nil {
+		return report, "", fmt.Errorf("failed to write report: %w", err)
+	}
+	logrus.Debugf("%s: wrote %d finding(s) to %s", identifiers.analyze, len(report.Findings), path)
+	return report, path, nil
+}
+
+func newAnalyzeResult(report *analyzer.Report, file string, err error) starlark.Value {
+	var findings []starlark.Value
+	if report != nil {
+		for _, f := range report.Findings {
+			var evidence []starlark.Value
+			for _, e := range f.Evidence {
+				evidence = append(evidence, starlark.String(e))
+			}
+			findings = append(findings, starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+				"rule":     starlark.String(f.Rule),
+				"severity": starlark.String(f.Severity),
+				"resource": starlark.String(f.Resource),
+				"message":  starlark.String(f.Message),
+				"evidence": starlark.NewList(evidence),
+			}))
+		}
+	}
+
+	return starlarkstruct.FromStringDict(
+		starlark.String(identifiers.analyze),
+		starlark.StringDict{
+			"file":     starlark.String(file),
+			"findings": starlark.NewList(findings),
+			"error": func() starlark.String {
+				if err != nil {
+					return starlark.String(err.Error())
+				}
+				return ""
+			}(),
+		})
+}
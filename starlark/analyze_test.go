@@ -0,0 +1,108 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+const testAnalyzeNodeList = `{"apiVersion":"v1","kind":"NodeList","metadata":{},"items":[
+{"apiVersion":"v1","kind":"Node","metadata":{"name":"node-0"},"status":{"conditions":[{"type":"Ready","status":"False"}]}}
+]}`
+
+func makeAnalyzeWorkdir(t *testing.T) string {
+	t.Helper()
+	workdir := t.TempDir()
+	path := filepath.Join(workdir, "kubecapture", "core_v1", "nodes-2020-01-01T01-00-00Z.0000.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0744); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(testAnalyzeNodeList), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return workdir
+}
+
+func TestAnalyzeScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		script func(workdir string) string
+		eval   func(t *testing.T, workdir string, result *starlarkstruct.Struct)
+	}{
+		{
+			name: "analyze with default rules",
+			script: func(workdir string) string {
+				return fmt.Sprintf(`result = analyze(workdir=%q)`, workdir)
+			},
+			eval: func(t *testing.T, workdir string, result *starlarkstruct.Struct) {
+				if errVal, _ := result.Attr("error"); errVal.(starlark.String) != "" {
+					t.Fatalf("unexpected error: %s", errVal)
+				}
+				file, _ := result.Attr("file")
+				if string(file.(starlark.String)) != filepath.Join(workdir, "analysis.txt") {
+					t.Errorf("unexpected report file: %s", file)
+				}
+				data, err := os.ReadFile(filepath.Join(workdir, "analysis.txt"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(string(data), "node-not-ready: nodes node-0") {
+					t.Errorf("unexpected report:\n%s", data)
+				}
+
+				findings, _ := result.Attr("findings")
+				list := findings.(*starlark.List)
+				if list.Len() != 1 {
+					t.Fatalf("expected 1 finding, got %d", list.Len())
+				}
+				severity, _ := list.Index(0).(*starlarkstruct.Struct).Attr("severity")
+				if severity.(starlark.String) != "critical" {
+					t.Errorf("unexpected severity: %s", severity)
+				}
+			},
+		},
+		{
+			name: "analyze with json output and selected rules",
+			script: func(workdir string) string {
+				return fmt.Sprintf(`result = analyze(workdir=%q, output_format="json", rules=["pvc-pending"])`, workdir)
+			},
+			eval: func(t *testing.T, workdir string, result *starlarkstruct.Struct) {
+				file, _ := result.Attr("file")
+				if string(file.(starlark.String)) != filepath.Join(workdir, "analysis.json") {
+					t.Errorf("unexpected report file: %s", file)
+				}
+				findings, _ := result.Attr("findings")
+				if findings.(*starlark.List).Len() != 0 {
+					t.Errorf("expected no findings, got %s", findings)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			workdir := makeAnalyzeWorkdir(t)
+			exe := New()
+			if err := exe.Exec("test.star", strings.NewReader(test.script(workdir))); err != nil {
+				t.Fatal(err)
+			}
+			resultVal := exe.result["result"]
+			if resultVal == nil {
+				t.Fatal("analyze() should be assigned to a variable for test")
+			}
+			result, ok := resultVal.(*starlarkstruct.Struct)
+			if !ok {
+				t.Fatalf("analyze() should return a struct, got %T", resultVal)
+			}
+			test.eval(t, workdir, result)
+		})
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+
+	"github.com/vmware-tanzu/crash-diagnostics/analyzer"
+)
+
+// AnalyzerRuleFn is the Starlark built-in that registers a user-defined analyzer rule.
+// Function fn is called as fn(objects, files) where objects is a list of search results,
+// as returned by kube_get(), and files maps the path of each collected file to its content.
+// It returns a list of findings, each a struct or dict with fields severity, resource,
+// message, and evidence. Registered rules are applied by analyze().
+// Starlark format: analyzer_rule(name="rule-name", fn=function [, description="rule description"])
+func AnalyzerRuleFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name, description string
+	var fn starlark.Callable
+
+	if err := starlark.UnpackArgs(
+		identifiers.analyzerRule, args, kwargs,
+		"name", &name,
+		"fn", &fn,
+		"description?", &description,
+	); err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.analyzerRule, err)
+	}
+
+	if len(name) == 0 {
+		return starlark.None, fmt.Errorf("%s: rule name required", identifiers.analyzerRule)
+	}
+
+	rules, err := appendRules(getAnalyzerRulesFromThread(thread), &starlarkRule{
+		name:        name,
+		description: description,
+		fn:          fn,
+		thread:      thread,
+	})
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.analyzerRule, err)
+	}
+	thread.SetLocal(identifiers.analyzerRule, rules)
+
+	return starlarkstruct.FromStringDict(
+		starlark.String(identifiers.analyzerRule),
+		starlark.StringDict{
+			"name":        starlark.String(name),
+			"description": starlark.String(description),
+		}), nil
+}
+
+// LoadAnalyzerRules executes the Starlark files (*.star) found in directory dir
+// and returns the rules they register with analyzer_rule()
+func LoadAnalyzerRules(dir string) ([]analyzer.Rule, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("analyzer rules: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.star"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var rules []analyzer.Rule
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		thread := &starlark.Thread{Name: "analyzer-rules"}
+		_, err = starlark.ExecFileOptions(syntax.LegacyFileOptions(), thread, path, file, newAnalyzerRulePredeclareds())
+		file.Close()
+		if err != nil {
+			var evalErr *starlark.EvalError
+			if errors.As(err, &evalErr) {
+				err = errors.New(evalErr.Backtrace())
+			}
+			return nil, fmt.Errorf("analyzer rules: %s: %w", path, err)
+		}
+
+		loaded := getAnalyzerRulesFromThread(thread)
+		logrus.Debugf("analyzer rules: loaded %d rule(s) from %s", len(loaded), path)
+		if rules, err = appendRules(rules, loaded...); err != nil {
+			return nil, fmt.Errorf("analyzer rules: %s: %w", path, err)
+		}
+	}
+	return rules, nil
+}
+
+// newAnalyzerRulePredeclareds returns the only built-ins available to rule files: rules
+// analyze the data already collected, they cannot run commands or collect more
+func newAnalyzerRulePredeclareds() starlark.StringDict {
+	return starlark.StringDict{
+		identifiers.analyzerRule: starlark.NewBuiltin(identifiers.analyzerRule, AnalyzerRuleFn),
+		identifiers.log:          starlark.NewBuiltin(identifiers.log, logFunc),
+	}
+}
+
+func getAnalyzerRulesFromThread(thread *starlark.Thread) []analyzer.Rule {
+	rules, _ := thread.Local(identifiers.analyzerRule).([]analyzer.Rule)
+	return rules
+}
+
+// appendRules appends more to rules, ensuring that rule names are unique
+// and do not replace a built-in rule
+func appendRules(rules []analyzer.Rule, more ...analyzer.Rule) ([]analyzer.Rule, error) {
+	builtins, err := analyzer.Rules()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for _, r := range append(builtins, rules...) {
+		names[r.Name()] = true
+	}
+	for _, r := range more {
+		if names[r.Name()] {
+			return nil, fmt.Errorf("duplicate analyzer rule: %s", r.Name())
+		}
+		names[r.Name()] = true
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// starlarkRule is an analyzer rule implemented by a Starlark function
+type starlarkRule struct {
+	name        string
+	description string
+	fn          starlark.Callable
+	thread      *starlark.Thread
+}
+
+func (r *starlarkRule) Name() string        { return r.name }
+func (r *starlarkRule) Description() string { return r.description }
+
+func (r *starlarkRule) Analyze(in *analyzer.Input) ([]analyzer.Finding, error) {
+	var objects []starlark.Value
+	if in.Objects != nil {
+		for _, sr := range in.Objects.SearchResults() {
+			objects = append(objects, sr.ToStarlarkValue())
+		}
+	}
+	files := newCaptureFiles(in.Root, in.Files())
+
+	result, err := starlark.Call(r.thread, r.fn, starlark.Tuple{starlark.NewList(objects), files}, nil)
+	if err != nil {
+		var evalErr *starlark.EvalError
+		if errors.As(err, &evalErr) {
+			return nil, errors.New(evalErr.Backtrace())
+		}
+		return nil, err
+	}
+	return toFindings(result)
+}
+
+// toFindings converts the list of findings returned by a Starlark rule
+func toFindings(val starlark.Value) ([]analyzer.Finding, error) {
+	if val == starlark.None {
+		return nil, nil
+	}
+	if _, ok := val.(starlark.Iterable); !ok {
+		return nil, fmt.Errorf("rule returned %s, expecting a list of findings", val.Type())
+	}
+	goVal, err := StarlarkToGoValue(val)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := goVal.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("rule returned %s, expecting a list of findings", val.Type())
+	}
+
+	var findings []analyzer.Finding
+	for _, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected finding %v: expecting a struct or dict", item)
+		}
+		finding := analyzer.Finding{}
+		finding.Rule, _ = fields["rule"].(string)
+		severity, _ := fields["severity"].(string)
+		finding.Severity = analyzer.Severity(severity)
+		finding.Resource, _ = fields["resource"].(string)
+		finding.Message, _ = fields["message"].(string)
+		if len(finding.Message) == 0 {
+			return nil, fmt.Errorf("finding %v: message required", item)
+		}
+		evidence, _ := fields["evidence"].([]interface{})
+		for _, e := range evidence {
+			if path, ok := e.(string); ok {
+				finding.Evidence = append(finding.Evidence, path)
+			}
+		}
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+// captureFiles is a read-only Starlark mapping of collected file paths, relative
+// to the analyzed directory, to their content. Files are read when accessed.
+type captureFiles struct {
+	root  string
+	paths []string
+	index map[string]bool
+}
+
+var (
+	_ starlark.Mapping  = (*captureFiles)(nil)
+	_ starlark.Iterable = (*captureFiles)(nil)
+	_ starlark.Sequence = (*captureFiles)(nil)
+)
+
+func newCaptureFiles(root string, paths []string) *captureFiles {
+	index := make(map[string]bool)
+	for _, p := range paths {
+		index[p] = true
+	}
+	return &captureFiles{root: root, paths: paths, index: index}
+}
+
+func (f *captureFiles) String() string        { return fmt.Sprintf("capture_files(%d)", len(f.paths)) }
+func (f *captureFiles) Type() string          { return "capture_files" }
+func (f *captureFiles) Freeze()               {}
+func (f *captureFiles) Truth() starlark.Bool  { return len(f.paths) > 0 }
+func (f *captureFiles) Hash() (uint32, error) { return 0, fmt.Errorf("unhashable type: %s", f.Type()) }
+func (f *captureFiles) Len() int              { return len(f.paths) }
+
+func (f *captureFiles) Iterate() starlark.Iterator {
+	keys := make(starlark.Tuple, len(f.paths))
+	for i, p := range f.paths {
+		keys[i] = starlark.String(p)
+	}
+	return keys.Iterate()
+}
+
+func (f *captureFiles) Get(key starlark.Value) (starlark.Value, bool, error) {
+	path, ok := key.(starlark.String)
+	if !ok {
+		return starlark.None, false, fmt.Errorf("%s: file path must be a string, got %s", f.Type(), key.Type())
+	}
+	if !f.index[string(path)] {
+		return starlark.None, false, nil
+	}
+	data, err := os.ReadFile(filepath.Join(f.root, string(path)))
+	if err != nil {
+		return starlark.None, false, err
+	}
+	return starlark.String(data), true, nil
+}
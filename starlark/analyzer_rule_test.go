@@ -0,0 +1,187 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+)
+
+const testAnalyzerRule = `
+def check_nodes(objects, files):
+    findings = []
+    for sr in objects:
+        if sr.ResourceKind != "Node":
+            continue
+        for node in sr.List.Items:
+            findings.append({"severity": "info", "resource": "nodes " + node.metadata.name, "message": "node found"})
+    for path in files:
+        if "panic" in files[path]:
+            findings.append({"severity": "warning", "resource": path, "message": "panic found", "evidence": [path]})
+    return findings
+
+analyzer_rule(name="custom-nodes", fn=check_nodes, description="reports nodes and panics")
+`
+
+func TestAnalyzerRuleScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		script func(t *testing.T, workdir string) string
+		setup  func(exe *Executor)
+		eval   func(t *testing.T, findings []*starlarkstruct.Struct)
+	}{
+		{
+			name: "rule registered in script",
+			script: func(t *testing.T, workdir string) string {
+				return testAnalyzerRule + fmt.Sprintf(`result = analyze(workdir=%q, rules=["custom-nodes"])`, workdir)
+			},
+			eval: func(t *testing.T, findings []*starlarkstruct.Struct) {
+				if len(findings) != 2 {
+					t.Fatalf("expected 2 findings, got %d", len(findings))
+				}
+				if resource, _ := findings[0].Attr("resource"); resource.(starlark.String) != "app/app.log" {
+					t.Errorf("unexpected resource: %s", resource)
+				}
+				if rule, _ := findings[1].Attr("rule"); rule.(starlark.String) != "custom-nodes" {
+					t.Errorf("unexpected rule: %s", rule)
+				}
+			},
+		},
+		{
+			name: "rule loaded from rules_dir",
+			script: func(t *testing.T, workdir string) string {
+				rulesDir := t.TempDir()
+				if err := os.WriteFile(filepath.Join(rulesDir, "nodes.star"), []byte(testAnalyzerRule), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return fmt.Sprintf(`result = analyze(workdir=%q, rules_dir=%q)`, workdir, rulesDir)
+			},
+			eval: func(t *testing.T, findings []*starlarkstruct.Struct) {
+				// built-in node-not-ready finding along with the custom findings
+				if len(findings) != 3 {
+					t.Fatalf("expected 3 findings, got %d", len(findings))
+				}
+				if rule, _ := findings[0].Attr("rule"); rule.(starlark.String) != "node-not-ready" {
+					t.Errorf("unexpected rule: %s", rule)
+				}
+			},
+		},
+		{
+			name: "rule applied to kube_get objects",
+			setup: func(exe *Executor) {
+				list := &unstructured.UnstructuredList{Object: map[string]interface{}{"kind": "NodeList"}}
+				list.Items = append(list.Items, unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "Node",
+					"metadata":   map[string]interface{}{"name": "live-node"},
+				}})
+				sr := k8s.SearchResult{
+					ListKind:             "NodeList",
+					ResourceName:         "nodes",
+					ResourceKind:         "Node",
+					GroupVersionResource: schema.GroupVersionResource{Version: "v1", Resource: "nodes"},
+					List:                 list,
+				}
+				exe.AddPredeclared("objs", starlark.NewList([]starlark.Value{sr.ToStarlarkValue()}))
+			},
+			script: func(t *testing.T, workdir string) string {
+				return testAnalyzerRule + fmt.Sprintf(`result = analyze(workdir=%q, rules=["custom-nodes", "node-not-ready"], objects=objs)`, workdir)
+			},
+			eval: func(t *testing.T, findings []*starlarkstruct.Struct) {
+				// the live node has no Ready condition, it is reported as NotReady without evidence
+				if len(findings) != 3 {
+					t.Fatalf("expected 3 findings, got %d", len(findings))
+				}
+				if resource, _ := findings[0].Attr("resource"); resource.(starlark.String) != "nodes live-node" {
+					t.Errorf("unexpected resource: %s", resource)
+				}
+				if evidence, _ := findings[0].Attr("evidence"); evidence.(*starlark.List).Len() != 0 {
+					t.Errorf("unexpected evidence: %s", evidence)
+				}
+				if resource, _ := findings[2].Attr("resource"); resource.(starlark.String) != "nodes live-node" {
+					t.Errorf("unexpected resource: %s", resource)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			workdir := makeAnalyzeWorkdir(t)
+			logFile := filepath.Join(workdir, "app", "app.log")
+			if err := os.MkdirAll(filepath.Dir(logFile), 0744); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(logFile, []byte("panic: nil pointer"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			exe := New()
+			if test.setup != nil {
+				test.setup(exe)
+			}
+			if err := exe.Exec("test.star", strings.NewReader(test.script(t, workdir))); err != nil {
+				t.Fatal(err)
+			}
+			result, ok := exe.result["result"].(*starlarkstruct.Struct)
+			if !ok {
+				t.Fatalf("analyze() should return a struct, got %T", exe.result["result"])
+			}
+			if errVal, _ := result.Attr("error"); errVal.(starlark.String) != "" {
+				t.Fatalf("unexpected error: %s", errVal)
+			}
+			findingsVal, _ := result.Attr("findings")
+			var findings []*starlarkstruct.Struct
+			list := findingsVal.(*starlark.List)
+			for i := 0; i < list.Len(); i++ {
+				findings = append(findings, list.Index(i).(*starlarkstruct.Struct))
+			}
+			test.eval(t, findings)
+		})
+	}
+}
+
+func TestAnalyzerRuleDuplicate(t *testing.T) {
+	script := `analyzer_rule(name="node-not-ready", fn=lambda objects, files: [])`
+	if err := New().Exec("test.star", strings.NewReader(script)); err == nil {
+		t.Fatal("expected error when replacing a built-in rule")
+	}
+}
+
+func TestLoadAnalyzerRules(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "nodes.star"), []byte(testAnalyzerRule), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadAnalyzerRules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].Name() != "custom-nodes" {
+		t.Fatalf("unexpected rules: %v", rules)
+	}
+
+	// rule files cannot collect data or run commands
+	marker := filepath.Join(t.TempDir(), "ran")
+	script := fmt.Sprintf("run_local(\"touch %s\")\n", marker)
+	if err := os.WriteFile(filepath.Join(dir, "side_effect.star"), []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAnalyzerRules(dir); err == nil || !strings.Contains(err.Error(), "undefined: run_local") {
+		t.Errorf("expected undefined run_local, got %v", err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("rule file ran a command")
+	}
+}
@@ -4,21 +4,32 @@
 package starlark
 
 import (
+	"context"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 	"github.com/vmware-tanzu/crash-diagnostics/logging"
 	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
 
 	"github.com/vmware-tanzu/crash-diagnostics/archiver"
 )
 
 // archiveFunc is a built-in starlark function that bundles specified directories into
-// an arhive format (i.e. tar.gz)
-// Starlark format: archive(output_file=<file name> ,source_paths=list, includeLogs?=[True|False], includeScript?=[True|False])
+// an arhive format (i.e. tar.gz, tar.zst, tar.xz, zip), selected by format or the name of the
+// file. With max_volume_size, the archive is split into volumes and the list of their paths is
+// returned. With recipients or a passphrase, the archive is encrypted as it is written, and
+// named with extension .age. With a destination, the archive is also uploaded and the URL of
+// the uploaded archive (or list of URLs of the volumes) is returned instead of its path.
+// Starlark format: archive(output_file=<file name> ,source_paths=list, includeLogs?=[True|False], includeScript?=[True|False], format?=<format>, compression_level?=<level>, max_volume_size?=<size>, recipients?=list, passphrase?=<passphrase>, destination?="s3://bucket/prefix", s3_config?=s3_config())
 func archiveFunc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-	var outputFile string
-	var paths *starlark.List
+	var outputFile, format, destination, passphrase string
+	var paths, recipients *starlark.List
+	var s3Config *starlarkstruct.Struct
+	var level int
+	var maxVolumeSize starlark.Value = starlark.None
 
 	// Default to true so that it helps users in debugging.
 	includeLogs := true
@@ -30,12 +41,36 @@ func archiveFunc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tup
 		"source_paths", &paths,
 		"includeLogs?", &includeLogs,
 		"includeScript?", &includeScript,
+		"format?", &format,
+		"compression_level?", &level,
+		"max_volume_size?", &maxVolumeSize,
+		"recipients?", &recipients,
+		"passphrase?", &passphrase,
+		"destination?", &destination,
+		"s3_config?", &s3Config,
 	); err != nil {
 		return starlark.None, fmt.Errorf("%s: %s", identifiers.archive, err)
 	}
 
+	opts := archiver.Options{Level: level}
+	if len(format) > 0 {
+		f, err := archiver.ParseFormat(format)
+		if err != nil {
+			return starlark.None, fmt.Errorf("%s: %s", identifiers.archive, err)
+		}
+		opts.Format = f
+	}
+	volumeSize, err := getByteSize(maxVolumeSize)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: max_volume_size: %s", identifiers.archive, err)
+	}
+	opts.MaxVolumeSize = volumeSize
+
 	if len(outputFile) == 0 {
 		outputFile = "archive.tar.gz"
+		if len(opts.Format) > 0 {
+			outputFile = "archive" + opts.Format.Ext()
+		}
 	}
 
 	// Always include the script executed and the logs.
@@ -57,11 +92,44 @@ func archiveFunc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tup
 		return starlark.None, fmt.Errorf("%s: one or more paths required", identifiers.archive)
 	}
 
-	if err := archiver.Tar(outputFile, getPathElements(paths)...); err != nil {
+	if (recipients != nil && recipients.Len() > 0) || len(passphrase) > 0 {
+		opts.Encryption = &archiver.Encryption{Passphrase: passphrase}
+		if recipients != nil {
+			opts.Encryption.Recipients = getPathElements(recipients)
+		}
+		if !strings.HasSuffix(outputFile, archiver.EncryptedExt) {
+			outputFile += archiver.EncryptedExt
+		}
+	}
+
+	files, err := archiver.Archive(outputFile, opts, getPathElements(paths)...)
+	if err != nil {
 		return starlark.None, fmt.Errorf("%s failed: %s", identifiers.archive, err)
 	}
 
-	return starlark.String(outputFile), nil
+	if len(destination) > 0 {
+		ctx, ok := thread.Local(identifiers.scriptCtx).(context.Context)
+		if !ok || ctx == nil {
+			return starlark.None, fmt.Errorf("script context not found")
+		}
+		for i, file := range files {
+			result, err := uploadFile(ctx, thread, file, destination, s3Config)
+			if err != nil {
+				return starlark.None, fmt.Errorf("%s: upload failed: %s", identifiers.archive, err)
+			}
+			files[i] = result.URL
+		}
+	}
+
+	// a list of the volumes when the archive is split
+	if opts.MaxVolumeSize > 0 {
+		volumes := make([]starlark.Value, len(files))
+		for i, file := range files {
+			volumes[i] = starlark.String(file)
+		}
+		return starlark.NewList(volumes), nil
+	}
+	return starlark.String(files[0]), nil
 }
 
 func getPathElements(paths *starlark.List) []string {
@@ -73,3 +141,42 @@ func getPathElements(paths *starlark.List) []string {
 	}
 	return pathElems
 }
+
+// getByteSize returns the size in bytes of val, a number of bytes or a string
+// of a number followed by a unit (i.e. 500MB, 2GiB), 0 for None
+func getByteSize(val starlark.Value) (int64, error) {
+	switch v := val.(type) {
+	case starlark.NoneType:
+		return 0, nil
+	case starlark.Int:
+		size, ok := v.Int64()
+		if !ok || size < 0 {
+			return 0, fmt.Errorf("invalid size %s", v)
+		}
+		return size, nil
+	case starlark.String:
+		return parseByteSize(string(v))
+	}
+	return 0, fmt.Errorf("invalid size %s: expecting a number of bytes or a string such as 100MB", val)
+}
+
+// byteSizeUnits are the multipliers of the size units, decimal and binary
+var byteSizeUnits = map[string]int64{
+	"": 1, "b": 1,
+	"k": 1e3, "kb": 1e3, "m": 1e6, "mb": 1e6, "g": 1e9, "gb": 1e9, "t": 1e12, "tb": 1e12,
+	"ki": 1 << 10, "kib": 1 << 10, "mi": 1 << 20, "mib": 1 << 20, "gi": 1 << 30, "gib": 1 << 30, "ti": 1 << 40, "tib": 1 << 40,
+}
+
+func parseByteSize(size string) (int64, error) {
+	size = strings.TrimSpace(size)
+	i := strings.IndexFunc(size, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(size)
+	}
+	number, err := strconv.ParseFloat(size[:i], 64)
+	multiplier, ok := byteSizeUnits[strings.ToLower(strings.TrimSpace(size[i:]))]
+	if err != nil || !ok || number < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return int64(number * float64(multiplier)), nil
+}
@@ -4,11 +4,15 @@
 package starlark
 
 import (
+	"crypto/rand"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"go.starlark.net/starlark"
+
+	"github.com/vmware-tanzu/crash-diagnostics/archiver"
 )
 
 func TestArchiveFunc(t *testing.T) {
@@ -97,3 +101,91 @@ func TestArchiveScript(t *testing.T) {
 		})
 	}
 }
+
+func TestArchiveEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "kubelet.log"), []byte("node logs"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(t.TempDir(), "out.tar.gz")
+	kwargs := []starlark.Tuple{
+		{starlark.String("output_file"), starlark.String(output)},
+		{starlark.String("source_paths"), starlark.NewList([]starlark.Value{starlark.String(dir)})},
+		{starlark.String("passphrase"), starlark.String("secret")},
+		{starlark.String("includeLogs"), starlark.False},
+		{starlark.String("includeScript"), starlark.False},
+	}
+	val, err := archiveFunc(newTestThreadLocal(t), nil, nil, kwargs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val != starlark.String(output+archiver.EncryptedExt) {
+		t.Fatalf("unexpected result: %s", val)
+	}
+	data, err := os.ReadFile(output + archiver.EncryptedExt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !archiver.IsEncrypted(data) {
+		t.Error("archive is not encrypted")
+	}
+}
+
+func TestArchiveFormatAndVolumes(t *testing.T) {
+	dir := t.TempDir()
+	// content that does not compress
+	data := make([]byte, 8192)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "kubelet.log"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(t.TempDir(), "out.zip")
+	kwargs := []starlark.Tuple{
+		{starlark.String("output_file"), starlark.String(output)},
+		{starlark.String("source_paths"), starlark.NewList([]starlark.Value{starlark.String(dir)})},
+		{starlark.String("format"), starlark.String("zip")},
+		{starlark.String("compression_level"), starlark.MakeInt(1)},
+		{starlark.String("max_volume_size"), starlark.String("1KiB")},
+		{starlark.String("includeLogs"), starlark.False},
+		{starlark.String("includeScript"), starlark.False},
+	}
+	val, err := archiveFunc(newTestThreadLocal(t), nil, nil, kwargs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	volumes, ok := val.(*starlark.List)
+	if !ok || volumes.Len() < 2 {
+		t.Fatalf("expected a list of volumes, got %s", val)
+	}
+	if volumes.Index(0) != starlark.String(output+".001") {
+		t.Errorf("unexpected first volume: %s", volumes.Index(0))
+	}
+}
+
+func TestGetByteSize(t *testing.T) {
+	tests := []struct {
+		val      starlark.Value
+		expected int64
+		err      bool
+	}{
+		{val: starlark.None, expected: 0},
+		{val: starlark.MakeInt(2048), expected: 2048},
+		{val: starlark.String("100MB"), expected: 100e6},
+		{val: starlark.String("1.5 GiB"), expected: 3 << 29},
+		{val: starlark.String("10k"), expected: 10000},
+		{val: starlark.String("10 parsecs"), err: true},
+		{val: starlark.MakeInt(-1), err: true},
+		{val: starlark.True, err: true},
+	}
+	for _, test := range tests {
+		size, err := getByteSize(test.val)
+		if (err != nil) != test.err {
+			t.Errorf("%s: unexpected error: %v", test.val, err)
+		}
+		if size != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.val, test.expected, size)
+		}
+	}
+}
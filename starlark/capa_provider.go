@@ -9,7 +9,6 @@ import (
 	"fmt"
 
 	"github.com/vmware-tanzu/crash-diagnostics/k8s"
-	"github.com/vmware-tanzu/crash-diagnostics/provider"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
 )
@@ -47,65 +46,45 @@ func CapaProviderFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.
 	if mgmtKubeConfig == nil {
 		mgmtKubeConfig = thread.Local(identifiers.kubeCfg).(*starlarkstruct.Struct)
 	}
-	mgmtKubeConfigPath, err := getKubeConfigPathFromStruct(mgmtKubeConfig)
+	mgmtOpts, err := getKubeConfigOptionsFromStruct(mgmtKubeConfig)
 	if err != nil {
 		return starlark.None, fmt.Errorf("failed to extract management kubeconfig: %w", err)
 	}
+	mgmtClient, err := k8s.NewForOptions(mgmtOpts)
+	if err != nil {
+		return starlark.None, fmt.Errorf("could not initialize client: %w", err)
+	}
 
 	// if workload cluster is not supplied, then the resources for the management cluster
 	// should be enumerated
 	clusterName := workloadCluster
 	if clusterName == "" {
-		config, err := k8s.LoadKubeCfg(mgmtKubeConfigPath)
-		if err != nil {
-			return starlark.None, fmt.Errorf("failed to load kube config: %w", err)
-		}
-		clusterName, err = config.GetClusterName()
+		clusterName, err = kubeConfigClusterName(mgmtOpts)
 		if err != nil {
 			return starlark.None, fmt.Errorf("cannot find cluster with name %s: %w", workloadCluster, err)
 		}
 	}
 
-	bastionIpAddr, err := k8s.FetchBastionIpAddress(clusterName, namespace, mgmtKubeConfigPath)
-	if err != nil {
+	// clusters without a bastion host are reached directly
+	bastionIpAddr, err := mgmtClient.FetchBastionIpAddress(ctx, clusterName, namespace)
+	if err != nil && !errors.Is(err, k8s.ErrBastionDisabled) {
 		return starlark.None, fmt.Errorf("could not fetch jump host addresses: %w", err)
 	}
 
-	providerConfigPath, err := provider.KubeConfig(mgmtKubeConfigPath, clusterName, namespace)
+	client, providerConfigPath, err := workloadClusterClient(ctx, thread, mgmtClient, mgmtOpts, clusterName, namespace)
 	if err != nil {
 		return starlark.None, err
 	}
 
-	nodeAddresses, err := k8s.GetNodeAddresses(ctx, providerConfigPath, toSlice(names), toSlice(labels))
+	nodeAddresses, err := client.GetNodeAddresses(ctx, toSlice(names), toSlice(labels))
 	if err != nil {
 		return starlark.None, fmt.Errorf("could not fetch host addresses: %w", err)
 	}
 
-	// dictionary for capa provider struct
-	capaProviderDict := starlark.StringDict{
-		"kind":        starlark.String(identifiers.capaProvider),
-		"transport":   starlark.String("ssh"),
-		"kube_config": starlark.String(providerConfigPath),
-	}
-
-	// add node info to dictionary
-	var nodeIps []starlark.Value
-	for _, node := range nodeAddresses {
-		nodeIps = append(nodeIps, starlark.String(node))
-	}
-	capaProviderDict["hosts"] = starlark.NewList(nodeIps)
-
-	sshConfigDict := starlark.StringDict{}
-	sshConfig.ToStringDict(sshConfigDict)
-
 	// modify ssh config jump credentials, if not specified
-	if _, err := sshConfig.Attr("jump_host"); err != nil {
-		sshConfigDict["jump_host"] = starlark.String(bastionIpAddr)
-	}
-	if _, err := sshConfig.Attr("jump_user"); err != nil {
-		sshConfigDict["jump_user"] = starlark.String("ubuntu")
-	}
-	capaProviderDict[identifiers.sshCfg] = starlarkstruct.FromStringDict(starlark.String(identifiers.sshCfg), sshConfigDict)
+	sshConfig = sshConfigWithJumpHost(sshConfig, k8s.Bastion{Address: bastionIpAddr, User: "ubuntu"})
 
-	return starlarkstruct.FromStringDict(starlark.String(identifiers.capaProvider), capaProviderDict), nil
+	return newSSHHostsProvider(identifiers.capaProvider, nodeAddresses, sshConfig, starlark.StringDict{
+		"kube_config": starlark.String(providerConfigPath),
+	}), nil
 }
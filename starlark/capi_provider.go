@@ -0,0 +1,100 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// CapiProviderFn is a built-in starlark function that enumerates the machines of a cluster managed by
+// any Cluster API infrastructure provider. Host addresses are read from Machine.status.addresses on the
+// management cluster and the bastion host of the cluster, if its infrastructure provider declares one,
+// is used as SSH jump host.
+// Starlark format: capi_provider(ssh_config=ssh_config(), mgmt_kube_config=kube_config() [, infra="azure", workload_cluster=<name>, namespace=<namespace>, nodes=["foo", "bar"], labels=["bar", "baz"], address_types=["InternalIP"]])
+func CapiProviderFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+
+	var (
+		infra, workloadCluster, namespace string
+		names, labels, addressTypes       *starlark.List
+		sshConfig, mgmtKubeConfig         *starlarkstruct.Struct
+	)
+
+	err := starlark.UnpackArgs(identifiers.capiProvider, args, kwargs,
+		"ssh_config?", &sshConfig,
+		"mgmt_kube_config?", &mgmtKubeConfig,
+		"infra?", &infra,
+		"workload_cluster?", &workloadCluster,
+		"namespace?", &namespace,
+		"labels?", &labels,
+		"nodes?", &names,
+		"address_types?", &addressTypes)
+	if err != nil {
+		return starlark.None, fmt.Errorf("failed to unpack input arguments: %w", err)
+	}
+
+	ctx, ok := thread.Local(identifiers.scriptCtx).(context.Context)
+	if !ok || ctx == nil {
+		return starlark.None, errors.New("script context not found")
+	}
+
+	if sshConfig == nil {
+		sshConfig = thread.Local(identifiers.sshCfg).(*starlarkstruct.Struct)
+	}
+	if mgmtKubeConfig == nil {
+		mgmtKubeConfig = thread.Local(identifiers.kubeCfg).(*starlarkstruct.Struct)
+	}
+	mgmtOpts, err := getKubeConfigOptionsFromStruct(mgmtKubeConfig)
+	if err != nil {
+		return starlark.None, fmt.Errorf("failed to extract management kubeconfig: %w", err)
+	}
+
+	// if workload cluster is not supplied, then the resources for the management cluster
+	// should be enumerated
+	clusterName := workloadCluster
+	if clusterName == "" {
+		clusterName, err = kubeConfigClusterName(mgmtOpts)
+		if err != nil {
+			return starlark.None, fmt.Errorf("cannot find cluster with name %s: %w", workloadCluster, err)
+		}
+	}
+
+	client, err := newClientFromKubeConfig(mgmtKubeConfig)
+	if err != nil {
+		return starlark.None, err
+	}
+
+	cluster, err := client.GetWorkloadCluster(ctx, clusterName, namespace)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", identifiers.capiProvider, err)
+	}
+
+	bastion, err := client.GetClusterBastion(ctx, cluster, infra)
+	if err != nil {
+		return starlark.None, fmt.Errorf("could not fetch jump host address: %w", err)
+	}
+
+	nodeAddresses, err := client.GetMachineAddresses(ctx, cluster, toSlice(names), toSlice(labels), toSlice(addressTypes))
+	if err != nil {
+		return starlark.None, fmt.Errorf("could not fetch host addresses: %w", err)
+	}
+
+	if infra == "" {
+		infra = strings.ToLower(cluster.InfrastructureRef.Kind)
+		if p, ok := k8s.LookupInfraProvider(cluster.InfrastructureRef.Kind); ok {
+			infra = p.Name
+		}
+	}
+
+	return newSSHHostsProvider(identifiers.capiProvider, nodeAddresses, sshConfigWithJumpHost(sshConfig, bastion), starlark.StringDict{
+		"infra":            starlark.String(infra),
+		"workload_cluster": starlark.String(cluster.Name),
+	}), nil
+}
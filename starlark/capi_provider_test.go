@@ -0,0 +1,88 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+)
+
+func TestCapiProviderEnum(t *testing.T) {
+	tests := []struct {
+		name      string
+		sshConfig starlark.StringDict
+		bastion   k8s.Bastion
+		jumpHost  string
+		jumpUser  string
+	}{
+		{
+			name:      "no bastion",
+			sshConfig: starlark.StringDict{"username": starlark.String("capi")},
+		},
+		{
+			name:      "bastion with user",
+			sshConfig: starlark.StringDict{"username": starlark.String("capi")},
+			bastion:   k8s.Bastion{Address: "34.0.0.10", User: "ubuntu"},
+			jumpHost:  "34.0.0.10",
+			jumpUser:  "ubuntu",
+		},
+		{
+			name:      "bastion without user",
+			sshConfig: starlark.StringDict{"username": starlark.String("capi")},
+			bastion:   k8s.Bastion{Address: "34.0.0.10"},
+			jumpHost:  "34.0.0.10",
+			jumpUser:  "capi",
+		},
+		{
+			name: "jump host from ssh_config",
+			sshConfig: starlark.StringDict{
+				"username":  starlark.String("capi"),
+				"jump_host": starlark.String("jump.example.com"),
+				"jump_user": starlark.String("admin"),
+			},
+			bastion:  k8s.Bastion{Address: "34.0.0.10", User: "ubuntu"},
+			jumpHost: "jump.example.com",
+			jumpUser: "admin",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sshConfig := starlarkstruct.FromStringDict(starlark.String(identifiers.sshCfg), test.sshConfig)
+			provider := newSSHHostsProvider(identifiers.capiProvider, []string{"10.0.0.0", "10.0.0.1"}, sshConfigWithJumpHost(sshConfig, test.bastion), nil)
+
+			resources, err := enum(provider)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resources.Len() != 2 {
+				t.Fatalf("unexpected number of resources: %d", resources.Len())
+			}
+			for i := 0; i < resources.Len(); i++ {
+				res := resources.Index(i).(*starlarkstruct.Struct)
+				cfgVal, err := res.Attr(identifiers.sshCfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cfg := cfgVal.(*starlarkstruct.Struct)
+				for attr, expected := range map[string]string{identifiers.jumpHost: test.jumpHost, identifiers.jumpUser: test.jumpUser} {
+					val, err := cfg.Attr(attr)
+					if expected == "" {
+						if err == nil {
+							t.Errorf("unexpected %s: %s", attr, val)
+						}
+						continue
+					}
+					if err != nil || string(val.(starlark.String)) != expected {
+						t.Errorf("unexpected %s: %v", attr, val)
+					}
+				}
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@
 package starlark
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -12,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
 	"github.com/vmware-tanzu/crash-diagnostics/ssh"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
@@ -21,10 +23,12 @@ import (
 // captures the result of the command in a specified file stored in workdir.
 // If resources and workdir are not provided, captureFunc uses defaults from starlark thread generated
 // by previous calls to resources() and crashd_config().
-// Starlark format: capture(command-string, cmd="command" [,resources=resources][,workdir=path][,file_name=name][,desc=description])
+// The output is streamed to the file, compressed with gzip if compress is True.
+// Starlark format: capture(command-string, cmd="command" [,resources=resources][,workdir=path][,file_name=name][,desc=description][,compress=False])
 func captureFunc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var cmdStr, workdir, fileName, desc string
 	var resources *starlark.List
+	var compress bool
 
 	if err := starlark.UnpackArgs(
 		identifiers.capture, args, kwargs,
@@ -33,6 +37,7 @@ func captureFunc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tup
 		"workdir?", &workdir,
 		"file_name?", &fileName,
 		"desc?", &desc,
+		"compress?", &compress,
 	); err != nil {
 		return starlark.None, fmt.Errorf("%s: %s", identifiers.capture, err)
 	}
@@ -67,7 +72,12 @@ func captureFunc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tup
 		}
 	}
 
-	results, err := execCapture(cmdStr, workdir, fileName, desc, agent, resources)
+	ctx, ok := thread.Local(identifiers.scriptCtx).(context.Context)
+	if !ok || ctx == nil {
+		return starlark.None, errors.New("script context not found")
+	}
+
+	results, err := execCapture(ctx, cmdStr, workdir, fileName, desc, compress, agent, resources, getBudgetFromThread(thread, identifiers.capture))
 	if err != nil {
 		return starlark.None, fmt.Errorf("%s: %s", identifiers.capture, err)
 	}
@@ -83,7 +93,7 @@ func captureFunc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tup
 	return starlark.NewList(resultList), nil
 }
 
-func execCapture(cmdStr, rootPath, fileName, desc string, agent ssh.Agent, resources *starlark.List) ([]commandResult, error) {
+func execCapture(ctx context.Context, cmdStr, rootPath, fileName, desc string, compress bool, agent ssh.Agent, resources *starlark.List, limit *budget.Budget) ([]commandResult, error) {
 	if resources == nil {
 		return nil, fmt.Errorf("%s: missing resources", identifiers.capture)
 	}
@@ -118,13 +128,20 @@ func execCapture(cmdStr, rootPath, fileName, desc string, agent ssh.Agent, resou
 
 			switch {
 			case string(kind) == identifiers.hostResource && string(transport) == "ssh":
-				result, err := execCaptureSSH(host, cmdStr, rootDir, fileName, desc, agent, res)
+				result, err := execCaptureSSH(host, cmdStr, rootDir, fileName, desc, compress, agent, res, limit)
+				if err != nil {
+					logrus.Errorf("%s failed: cmd=[%s]: %s", identifiers.capture, cmdStr, err)
+				}
+				resultsChannel <- result
+			case string(kind) == identifiers.hostResource && string(transport) == kubeDebugTransport:
+				result, err := execCaptureKubeDebug(ctx, host, cmdStr, rootDir, fileName, desc, compress, res, limit)
 				if err != nil {
 					logrus.Errorf("%s failed: cmd=[%s]: %s", identifiers.capture, cmdStr, err)
 				}
 				resultsChannel <- result
 			default:
 				logrus.Errorf("%s: unsupported or invalid resource kind: %s", identifiers.capture, kind)
+				resultsChannel <- commandResult{resource: host, err: fmt.Errorf("unsupported resource kind: %s", kind)}
 			}
 		}(i)
 	}
@@ -141,7 +158,7 @@ func execCapture(cmdStr, rootPath, fileName, desc string, agent ssh.Agent, resou
 	return results, nil
 }
 
-func execCaptureSSH(host, cmdStr, rootDir, fileName, desc string, agent ssh.Agent, res *starlarkstruct.Struct) (commandResult, error) {
+func execCaptureSSH(host, cmdStr, rootDir, fileName, desc string, compress bool, agent ssh.Agent, res *starlarkstruct.Struct, limit *budget.Budget) (commandResult, error) {
 	sshCfg := starlarkstruct.FromKeywords(starlarkstruct.Default, makeDefaultSSHConfig())
 	if val, err := res.Attr(identifiers.sshCfg); err == nil {
 		if cfg, ok := val.(*starlarkstruct.Struct); ok {
@@ -161,57 +178,40 @@ func execCaptureSSH(host, cmdStr, rootDir, fileName, desc string, agent ssh.Agen
 	}
 	logrus.Debugf("%s: created capture dir: %s", identifiers.capture, rootDir)
 
-	if len(fileName) == 0 {
-		fileName = fmt.Sprintf("%s.txt", sanitizeStr(cmdStr))
-	}
-	filePath := filepath.Join(rootDir, fileName)
+	filePath := filepath.Join(rootDir, captureFileName(cmdStr, fileName, compress))
 
 	logrus.Debugf("%s: capturing output of [cmd=%s] => [%s] from %s using ssh", identifiers.capture, cmdStr, filePath, args.Host)
 
-	reader, err := ssh.RunRead(args, agent, cmdStr)
-	if err != nil {
-		logrus.Errorf("%s failed: %s", identifiers.capture, err)
-		if err := captureOutput(strings.NewReader(err.Error()), filePath, fmt.Sprintf("%s: failed", cmdStr), false); err != nil {
-			logrus.Errorf("%s output failed: %s", identifiers.capture, err)
-			return commandResult{resource: args.Host, result: filePath, err: err}, err
-		}
-	}
-
-	if err := captureOutput(reader, filePath, desc, false); err != nil {
+	var runErr error
+	if err := captureCmd(filePath, desc, false, compress, limit, func(w io.Writer) error {
+		runErr = ssh.RunStream(args, agent, cmdStr, w)
+		return runErr
+	}, capturedFailure(cmdStr)); err != nil {
 		logrus.Errorf("%s output failed: %s", identifiers.capture, err)
 		return commandResult{resource: args.Host, result: filePath, err: err}, err
 	}
-
-	return commandResult{resource: args.Host, result: filePath, err: err}, nil
-}
-
-func captureOutput(source io.Reader, filePath, desc string, append bool) error {
-	if source == nil {
-		return errors.New("source reader is nill")
+	if runErr != nil {
+		logrus.Errorf("%s failed: %s", identifiers.capture, runErr)
 	}
 
-	flag := os.O_CREATE | os.O_WRONLY
-	if append {
-		flag |= os.O_APPEND
-	} else {
-		flag |= os.O_TRUNC
-	}
+	return commandResult{resource: args.Host, result: filePath, err: runErr}, nil
+}
 
-	file, err := os.OpenFile(filePath, flag, 0644)
-	if err != nil {
-		return err
+// captureFileName returns the name of the file saving the output of cmdStr,
+// fileName if set, with the extension of compressed files if compress is true
+func captureFileName(cmdStr, fileName string, compress bool) string {
+	if len(fileName) == 0 {
+		fileName = fmt.Sprintf("%s.txt", sanitizeStr(cmdStr))
 	}
-	defer file.Close()
-
-	if len(desc) > 0 {
-		if _, err := file.WriteString(fmt.Sprintf("%s\n", desc)); err != nil {
-			return err
-		}
+	if compress && !strings.HasSuffix(fileName, compressedExt) {
+		fileName += compressedExt
 	}
+	return fileName
+}
 
-	if _, err := io.Copy(file, source); err != nil {
-		return err
+// capturedFailure returns the lines following the output of failed command cmdStr
+func capturedFailure(cmdStr string) func(error) string {
+	return func(err error) string {
+		return fmt.Sprintf("%s: failed\n%s", cmdStr, err)
 	}
-
-	return nil
 }
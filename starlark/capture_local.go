@@ -5,9 +5,9 @@ package starlark
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/vladimirvivien/gexe"
 	"go.starlark.net/starlark"
@@ -15,10 +15,11 @@ import (
 
 // captureLocalFunc is a built-in starlark function that runs a provided command on the local machine.
 // The output of the command is stored in a file at a specified location under the workdir directory.
-// Starlark format: capture_local(cmd=<command> [,workdir=path][,file_name=name][,desc=description][,append=append])
+// The output is streamed to the file, compressed with gzip if compress is True.
+// Starlark format: capture_local(cmd=<command> [,workdir=path][,file_name=name][,desc=description][,append=append][,compress=False])
 func captureLocalFunc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var cmdStr, workdir, fileName, desc string
-	var append bool
+	var append, compress bool
 	if err := starlark.UnpackArgs(
 		identifiers.captureLocal, args, kwargs,
 		"cmd", &cmdStr,
@@ -26,6 +27,7 @@ func captureLocalFunc(thread *starlark.Thread, b *starlark.Builtin, args starlar
 		"file_name?", &fileName,
 		"desc?", &desc,
 		"append?", &append,
+		"compress?", &compress,
 	); err != nil {
 		return starlark.None, fmt.Errorf("%s: %s", identifiers.captureLocal, err)
 	}
@@ -37,28 +39,22 @@ func captureLocalFunc(thread *starlark.Thread, b *starlark.Builtin, args starlar
 		}
 		workdir = dir
 	}
-	if len(fileName) == 0 {
-		fileName = fmt.Sprintf("%s.txt", sanitizeStr(cmdStr))
-	}
-
-	filePath := filepath.Join(workdir, fileName)
+	filePath := filepath.Join(workdir, captureFileName(cmdStr, fileName, compress))
 	if err := os.MkdirAll(workdir, 0744); err != nil && !os.IsExist(err) {
 		msg := fmt.Sprintf("%s error: %s", identifiers.captureLocal, err)
 		return starlark.String(msg), nil
 	}
 
-	p := gexe.RunProc(cmdStr)
-	// upon error, write error in file, return filepath
-	if p.Err() != nil {
-		msg := fmt.Sprintf("%s error: %s: %s", identifiers.captureLocal, p.Err(), p.Result())
-		if err := captureOutput(strings.NewReader(msg), filePath, desc, append); err != nil {
-			msg := fmt.Sprintf("%s error: %s", identifiers.captureLocal, err)
-			return starlark.String(msg), nil
-		}
-		return starlark.String(filePath), nil
-	}
-
-	if err := captureOutput(p.Out(), filePath, desc, append); err != nil {
+	// upon error, the file ends with the error, return filepath
+	limit := getBudgetFromThread(thread, identifiers.captureLocal)
+	if err := captureCmd(filePath, desc, append, compress, limit, func(w io.Writer) error {
+		p := gexe.NewProc(cmdStr)
+		p.SetStdout(w)
+		p.SetStderr(w)
+		return p.Run().Err()
+	}, func(err error) string {
+		return fmt.Sprintf("%s error: %s", identifiers.captureLocal, err)
+	}); err != nil {
 		msg := fmt.Sprintf("%s error: %s", identifiers.captureLocal, err)
 		return starlark.String(msg), nil
 	}
@@ -0,0 +1,118 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
+)
+
+// compressedExt is appended to the name of the files captured with compress=True
+const compressedExt = ".gz"
+
+// captureWriter writes a captured output to its file as it is produced, through the
+// stages of the capture: the byte budget, then the gzip compression if enabled.
+// It is safe to write stdout and stderr to it concurrently.
+type captureWriter struct {
+	mu      sync.Mutex
+	path    string
+	limit   *budget.Budget
+	file    *os.File
+	dst     io.Writer // file, or gz if compressed
+	limited *budget.Writer
+	gz      *gzip.Writer
+	last    byte // last byte written
+}
+
+func newCaptureWriter(path string, append, compress bool, limit *budget.Budget) (*captureWriter, error) {
+	flag := os.O_CREATE | os.O_WRONLY
+	if append {
+		flag |= os.O_APPEND
+	} else {
+		flag |= os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &captureWriter{path: path, limit: limit, file: file, dst: file, last: '\n'}
+	if compress {
+		w.gz = gzip.NewWriter(file)
+		w.dst = w.gz
+	}
+	w.limited = limit.NewWriter(path, w.dst)
+	return w, nil
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(p) > 0 {
+		w.last = p[len(p)-1]
+	}
+	return w.limited.Write(p)
+}
+
+// appendText ends the captured output, writing its truncation marker if any, and
+// writes the lines of text after it. The text counts against the budget, but not
+// against the file limit already spent by the output.
+func (w *captureWriter) appendText(text string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.limited.Close(); err != nil {
+		return err
+	}
+	w.limited = w.limit.NewWriter(w.path, w.dst)
+	if w.last != '\n' {
+		text = "\n" + text
+	}
+	_, err := fmt.Fprintln(w.limited, text)
+	return err
+}
+
+// Close writes the truncation marker, if any, and closes the stages and the file
+func (w *captureWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.limited.Close()
+	if w.gz != nil {
+		if gzErr := w.gz.Close(); err == nil {
+			err = gzErr
+		}
+	}
+	if fileErr := w.file.Close(); err == nil {
+		err = fileErr
+	}
+	return err
+}
+
+// captureCmd streams the output of run to path, preceded by desc. If run fails, the
+// content captured is followed by the lines returned by failure for the error,
+// such as "<cmd>: failed", so failed commands can be found by their last lines.
+func captureCmd(path, desc string, append, compress bool, limit *budget.Budget, run func(io.Writer) error, failure func(error) string) error {
+	w, err := newCaptureWriter(path, append, compress, limit)
+	if err != nil {
+		return err
+	}
+	if len(desc) > 0 {
+		if _, err := fmt.Fprintln(w, desc); err != nil {
+			w.Close()
+			return err
+		}
+	}
+	if runErr := run(w); runErr != nil {
+		if err := w.appendText(failure(runErr)); err != nil {
+			w.Close()
+			return err
+		}
+	}
+	return w.Close()
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
+)
+
+func TestCaptureCmd(t *testing.T) {
+	output := func(w io.Writer) error {
+		_, err := io.WriteString(w, "line1\nline2\n")
+		return err
+	}
+	failing := func(w io.Writer) error {
+		if err := output(w); err != nil {
+			return err
+		}
+		return errors.New("exit status 1")
+	}
+	failure := func(err error) string { return fmt.Sprintf("cmd: failed\n%s", err) }
+
+	tests := []struct {
+		name     string
+		existing string
+		append   bool
+		compress bool
+		limit    budget.Options
+		run      func(io.Writer) error
+		expected string
+	}{
+		{
+			name:     "output",
+			run:      output,
+			expected: "desc\nline1\nline2\n",
+		},
+		{
+			name:     "failed",
+			existing: "previous\n",
+			run:      failing,
+			expected: "desc\nline1\nline2\ncmd: failed\nexit status 1\n",
+		},
+		{
+			name:     "failed appended",
+			existing: "previous\n",
+			append:   true,
+			run:      failing,
+			expected: "previous\ndesc\nline1\nline2\ncmd: failed\nexit status 1\n",
+		},
+		{
+			name:     "compressed failed appended",
+			existing: "previous\n",
+			append:   true,
+			compress: true,
+			run:      failing,
+			expected: "previous\ndesc\nline1\nline2\ncmd: failed\nexit status 1\n",
+		},
+		{
+			name:     "truncated",
+			limit:    budget.Options{MaxFileBytes: 8},
+			run:      output,
+			expected: "desc\nlin\n... [crashd: truncated 9 bytes, max_file_bytes=8 exceeded] ...\n",
+		},
+		{
+			name:     "truncated failed",
+			limit:    budget.Options{MaxFileBytes: 8},
+			run:      failing,
+			expected: "desc\nlin\n... [crashd: truncated 9 bytes, max_file_bytes=8 exceeded] ...\ncmd: fai\n... [crashd: truncated 18 bytes, max_file_bytes=8 exceeded] ...\n",
+		},
+		{
+			name: "failed without newline",
+			run: func(w io.Writer) error {
+				io.WriteString(w, "partial")
+				return errors.New("exit status 1")
+			},
+			expected: "desc\npartial\ncmd: failed\nexit status 1\n",
+		},
+		{
+			name:     "failed over total budget",
+			limit:    budget.Options{MaxTotalBytes: 30},
+			run:      failing,
+			expected: "desc\nline1\nline2\ncmd: failed\ne\n... [crashd: truncated 13 bytes, max_total_bytes=30 exceeded] ...\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out.txt")
+			if test.existing != "" {
+				existing := []byte(test.existing)
+				if test.compress {
+					existing = gzipBytes(t, test.existing)
+				}
+				if err := os.WriteFile(path, existing, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			limit, err := budget.New(test.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := captureCmd(path, "desc", test.append, test.compress, limit, test.run, failure); err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			var reader io.Reader = file
+			if test.compress {
+				gz, err := gzip.NewReader(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				reader = gz
+			}
+			data, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.expected {
+				t.Errorf("unexpected content %q, expecting %q", data, test.expected)
+			}
+		})
+	}
+}
+
+func TestCaptureFileName(t *testing.T) {
+	tests := []struct {
+		cmd, fileName string
+		compress      bool
+		expected      string
+	}{
+		{cmd: "uname -a", expected: "uname__a.txt"},
+		{cmd: "uname -a", compress: true, expected: "uname__a.txt.gz"},
+		{cmd: "dmesg", fileName: "dmesg.log.gz", compress: true, expected: "dmesg.log.gz"},
+	}
+	for _, test := range tests {
+		if name := captureFileName(test.cmd, test.fileName, test.compress); name != test.expected {
+			t.Errorf("unexpected file name %s, expecting %s", name, test.expected)
+		}
+	}
+}
+
+func gzipBytes(t *testing.T, content string) []byte {
+	var buf strings.Builder
+	gz := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(gz, content); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return []byte(buf.String())
+}
@@ -9,7 +9,6 @@ import (
 	"fmt"
 
 	"github.com/vmware-tanzu/crash-diagnostics/k8s"
-	"github.com/vmware-tanzu/crash-diagnostics/provider"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
 )
@@ -47,41 +46,28 @@ func CapvProviderFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.
 	if mgmtKubeConfig == nil {
 		mgmtKubeConfig = thread.Local(identifiers.kubeCfg).(*starlarkstruct.Struct)
 	}
-	mgmtKubeConfigPath, err := getKubeConfigPathFromStruct(mgmtKubeConfig)
+	mgmtOpts, err := getKubeConfigOptionsFromStruct(mgmtKubeConfig)
 	if err != nil {
 		return starlark.None, fmt.Errorf("failed to extract management kubeconfig: %w", err)
 	}
+	mgmtClient, err := k8s.NewForOptions(mgmtOpts)
+	if err != nil {
+		return starlark.None, fmt.Errorf("could not initialize client: %w", err)
+	}
 
-	providerConfigPath, err := provider.KubeConfig(mgmtKubeConfigPath, workloadCluster, namespace)
+	client, providerConfigPath, err := workloadClusterClient(ctx, thread, mgmtClient, mgmtOpts, workloadCluster, namespace)
 	if err != nil {
 		return starlark.None, err
 	}
 
-	nodeAddresses, err := k8s.GetNodeAddresses(ctx, providerConfigPath, toSlice(names), toSlice(labels))
+	nodeAddresses, err := client.GetNodeAddresses(ctx, toSlice(names), toSlice(labels))
 	if err != nil {
 		return starlark.None, fmt.Errorf("could not fetch host addresses: %w", err)
 	}
 
-	// dictionary for capv provider struct
-	capvProviderDict := starlark.StringDict{
-		"kind":        starlark.String(identifiers.capvProvider),
-		"transport":   starlark.String("ssh"),
+	return newSSHHostsProvider(identifiers.capvProvider, nodeAddresses, sshConfig, starlark.StringDict{
 		"kube_config": starlark.String(providerConfigPath),
-	}
-
-	// add node info to dictionary
-	var nodeIps []starlark.Value
-	for _, node := range nodeAddresses {
-		nodeIps = append(nodeIps, starlark.String(node))
-	}
-	capvProviderDict["hosts"] = starlark.NewList(nodeIps)
-
-	// add ssh info to dictionary
-	if _, ok := capvProviderDict[identifiers.sshCfg]; !ok {
-		capvProviderDict[identifiers.sshCfg] = sshConfig
-	}
-
-	return starlarkstruct.FromStringDict(starlark.String(identifiers.capvProvider), capvProviderDict), nil
+	}), nil
 }
 
 // TODO: Needs to be moved to a single package
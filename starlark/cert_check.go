@@ -0,0 +1,330 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/vmware-tanzu/crash-diagnostics/certs"
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+	"github.com/vmware-tanzu/crash-diagnostics/nodeinfo"
+	"github.com/vmware-tanzu/crash-diagnostics/ssh"
+)
+
+const (
+	certSourceKubeConfig = "kubeconfig"
+	certSourceSecrets    = "secrets"
+	certSourceNodes      = "nodes"
+)
+
+// CertCheckFn is a starlark built-in that inspects the certificates of the cluster: the
+// certificate authorities and client certificates of the kubeconfig, the certificates of
+// the secrets of type kubernetes.io/tls, and the certificate files of the host resources,
+// read over ssh. It saves a summary of their expiration in <workdir>/cert_check.txt and
+// returns a list of the certificates, the first to expire first.
+// Starlark format: cert_check([sources=["kubeconfig","secrets","nodes"]][, kube_config=kube_config()][, namespaces=["kube-system"]][, resources=resources][, paths=["/etc/kubernetes/pki"]][, warn_days=30][, workdir=path])
+func CertCheckFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var sourceList, namespaces, resources, pathList *starlark.List
+	var kubeConfig *starlarkstruct.Struct
+	var workdir string
+	warnDays := 30
+
+	if err := starlark.UnpackArgs(
+		identifiers.certCheck, args, kwargs,
+		"sources?", &sourceList,
+		"kube_config?", &kubeConfig,
+		"namespaces?", &namespaces,
+		"resources?", &resources,
+		"paths?", &pathList,
+		"warn_days?", &warnDays,
+		"workdir?", &workdir,
+	); err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.certCheck, err)
+	}
+
+	if resources == nil {
+		if res, err := getResourcesFromThread(thread); err == nil {
+			resources = res
+		}
+	}
+	sources := toSlice(sourceList)
+	if len(sources) == 0 {
+		sources = []string{certSourceKubeConfig, certSourceSecrets}
+		if resources != nil {
+			sources = append(sources, certSourceNodes)
+		}
+	}
+	for _, source := range sources {
+		switch source {
+		case certSourceKubeConfig, certSourceSecrets:
+		case certSourceNodes:
+			if resources == nil {
+				return starlark.None, fmt.Errorf("%s: source %s: resources not found", identifiers.certCheck, source)
+			}
+		default:
+			return starlark.None, fmt.Errorf("%s: unknown source %q", identifiers.certCheck, source)
+		}
+	}
+	paths := toSlice(pathList)
+	if len(paths) == 0 {
+		paths = []string{"/etc/kubernetes/pki"}
+	}
+
+	if len(workdir) == 0 {
+		if dir, err := getWorkdirFromThread(thread); err == nil {
+			workdir = dir
+		}
+	}
+	if len(workdir) == 0 {
+		workdir = defaults.workdir
+	}
+
+	if kubeConfig == nil {
+		kubeConfig, _ = thread.Local(identifiers.kubeCfg).(*starlarkstruct.Struct)
+	}
+
+	ctx, ok := thread.Local(identifiers.scriptCtx).(context.Context)
+	if !ok || ctx == nil {
+		return starlark.None, errors.New("script context not found")
+	}
+
+	var agent ssh.Agent
+	if agentVal := thread.Local(identifiers.sshAgent); agentVal != nil {
+		agent, ok = agentVal.(ssh.Agent)
+		if !ok {
+			return starlark.None, errors.New("unable to fetch ssh-agent")
+		}
+	}
+
+	now := time.Now()
+	var found []certs.Certificate
+	var errs []error
+	collect := func(c []certs.Certificate, e []error) {
+		found = append(found, c...)
+		errs = append(errs, e...)
+	}
+	for _, source := range sources {
+		switch source {
+		case certSourceKubeConfig:
+			collect(kubeConfigCerts(kubeConfig, now))
+		case certSourceSecrets:
+			if kubeConfig == nil {
+				errs = append(errs, errors.New("secrets: kube_config not found"))
+				continue
+			}
+			client, err := newClientFromKubeConfig(kubeConfig)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("secrets: %w", err))
+				continue
+			}
+			collect(secretCerts(ctx, client.Typed, toSlice(namespaces), now))
+		case certSourceNodes:
+			collect(nodeCerts(resources, paths, agent, now))
+		}
+	}
+	certs.Sort(found)
+
+	for _, err := range errs {
+		logrus.Warnf("%s: %s", identifiers.certCheck, err)
+	}
+	if err := writeCertSummary(trimQuotes(workdir), found, errs, warnDays); err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", identifiers.certCheck, err)
+	}
+
+	certList := make([]starlark.Value, len(found))
+	for i, cert := range found {
+		certList[i] = certToStarlarkStruct(cert)
+	}
+	return starlark.NewList(certList), nil
+}
+
+// kubeConfigCerts returns the certificates of the kubeconfig files of kubeConfig
+func kubeConfigCerts(kubeConfig *starlarkstruct.Struct, now time.Time) ([]certs.Certificate, []error) {
+	if kubeConfig == nil {
+		return nil, []error{errors.New("kubeconfig: kube_config not found")}
+	}
+	path, err := getKubeConfigPathFromStruct(kubeConfig)
+	if err != nil {
+		return nil, []error{fmt.Errorf("kubeconfig: %w", err)}
+	}
+	if path == "" {
+		// in-cluster and token configurations have no kubeconfig file
+		return nil, nil
+	}
+	cfg, err := k8s.LoadKubeCfg(path)
+	if err != nil {
+		return nil, []error{fmt.Errorf("kubeconfig: %w", err)}
+	}
+
+	var found []certs.Certificate
+	var errs []error
+	configCerts, err := cfg.GetCertificates()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("kubeconfig: %w", err))
+	}
+	for _, configCert := range configCerts {
+		parsed, err := certs.Parse("kubeconfig:"+configCert.Source, configCert.Data, now)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		found = append(found, parsed...)
+	}
+	return found, errs
+}
+
+// secretCerts returns the certificates of the secrets of type kubernetes.io/tls in
+// namespaces, all namespaces if empty
+func secretCerts(ctx context.Context, client kubernetes.Interface, namespaces []string, now time.Time) ([]certs.Certificate, []error) {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	var found []certs.Certificate
+	var errs []error
+	for _, ns := range namespaces {
+		secrets, err := client.CoreV1().Secrets(ns).List(ctx, metav1.ListOptions{FieldSelector: "type=" + string(corev1.SecretTypeTLS)})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("secrets: %w", err))
+			continue
+		}
+		for _, secret := range secrets.Items {
+			if secret.Type != corev1.SecretTypeTLS {
+				continue
+			}
+			keys := []string{corev1.TLSCertKey, "ca.crt"}
+			data, err := k8s.GetSecretData(ctx, client, secret.Namespace, secret.Name, keys)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("secret:%s/%s: %w", secret.Namespace, secret.Name, err))
+				continue
+			}
+			for _, key := range keys {
+				if len(data[key]) == 0 {
+					continue
+				}
+				parsed, err := certs.Parse(fmt.Sprintf("secret:%s/%s:%s", secret.Namespace, secret.Name, key), data[key], now)
+				if err != nil {
+					errs = append(errs, err)
+				}
+				found = append(found, parsed...)
+			}
+		}
+	}
+	return found, errs
+}
+
+// nodeCerts returns the certificates of the files in paths on the host resources, read over ssh
+func nodeCerts(resources *starlark.List, paths []string, agent ssh.Agent, now time.Time) ([]certs.Certificate, []error) {
+	type hostResult struct {
+		certs []certs.Certificate
+		errs  []error
+	}
+	resultsChannel := make(chan hostResult)
+	readCmd := ssh.EncodeScript(nodeinfo.Command{Cmd: certs.ReadCmd(paths), Sudo: true}.Script())
+
+	for i := 0; i < resources.Len(); i++ {
+		go func(i int) {
+			res, ok := resources.Index(i).(*starlarkstruct.Struct)
+			if !ok {
+				resultsChannel <- hostResult{errs: []error{errors.New("nodes: unexpected resource type")}}
+				return
+			}
+			kind, transport, host, err := hostResourceAttrs(res)
+			if err != nil {
+				resultsChannel <- hostResult{errs: []error{fmt.Errorf("nodes: %w", err)}}
+				return
+			}
+			if kind != identifiers.hostResource || transport != "ssh" {
+				resultsChannel <- hostResult{errs: []error{fmt.Errorf("%s: unsupported resource: kind=%s, transport=%s", host, kind, transport)}}
+				return
+			}
+
+			sshCfg := starlarkstruct.FromKeywords(starlarkstruct.Default, makeDefaultSSHConfig())
+			if val, err := res.Attr(identifiers.sshCfg); err == nil {
+				if cfg, ok := val.(*starlarkstruct.Struct); ok {
+					sshCfg = cfg
+				}
+			}
+			args, err := getSSHArgsFromCfg(sshCfg)
+			if err != nil {
+				resultsChannel <- hostResult{errs: []error{fmt.Errorf("%s: %w", host, err)}}
+				return
+			}
+			args.Host = host
+
+			output, err := ssh.Run(args, agent, readCmd)
+			if err != nil {
+				resultsChannel <- hostResult{errs: []error{fmt.Errorf("%s: %w", host, err)}}
+				return
+			}
+			files, err := certs.ParseFiles(output)
+			if err != nil {
+				resultsChannel <- hostResult{errs: []error{fmt.Errorf("%s: %w", host, err)}}
+				return
+			}
+			var result hostResult
+			for _, file := range files {
+				parsed, err := certs.Parse(fmt.Sprintf("%s:%s", host, file.Path), file.Data, now)
+				if err != nil {
+					result.errs = append(result.errs, err)
+				}
+				result.certs = append(result.certs, parsed...)
+			}
+			resultsChannel <- result
+		}(i)
+	}
+
+	var found []certs.Certificate
+	var errs []error
+	for i := 0; i < resources.Len(); i++ {
+		result := <-resultsChannel
+		found = append(found, result.certs...)
+		errs = append(errs, result.errs...)
+	}
+	return found, errs
+}
+
+func writeCertSummary(workdir string, found []certs.Certificate, errs []error, warnDays int) error {
+	if err := os.MkdirAll(workdir, 0744); err != nil {
+		return fmt.Errorf("failed to create workdir: %w", err)
+	}
+	file, err := os.Create(filepath.Join(workdir, certs.SummaryFileName))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := certs.WriteSummary(file, found, errs, warnDays); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func certToStarlarkStruct(cert certs.Certificate) *starlarkstruct.Struct {
+	sans := make([]starlark.Value, len(cert.SANs))
+	for i, san := range cert.SANs {
+		sans[i] = starlark.String(san)
+	}
+	return starlarkstruct.FromStringDict(
+		starlark.String("certificate"),
+		starlark.StringDict{
+			"source":         starlark.String(cert.Source),
+			"subject":        starlark.String(cert.Subject),
+			"issuer":         starlark.String(cert.Issuer),
+			"sans":           starlark.NewList(sans),
+			"not_after":      starlark.String(cert.NotAfter.UTC().Format(time.RFC3339)),
+			"days_remaining": starlark.MakeInt(cert.DaysRemaining),
+		},
+	)
+}
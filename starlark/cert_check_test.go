@@ -0,0 +1,180 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vmware-tanzu/crash-diagnostics/certs"
+)
+
+// newTestCertPEM returns a self-signed PEM certificate for cn expiring at notAfter
+func newTestCertPEM(t *testing.T, cn string, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    notAfter.AddDate(-1, 0, 0),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestCertCheckKubeConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertPEM(t, "kubernetes", time.Now().Add(5*24*time.Hour+time.Hour))
+	client := newTestCertPEM(t, "kubernetes-admin", time.Now().Add(365*24*time.Hour))
+	kubeconfig := filepath.Join(dir, "config")
+	config := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://test:6443
+    certificate-authority-data: %s
+users:
+- name: admin
+  user:
+    client-certificate-data: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: admin
+current-context: test
+`, base64.StdEncoding.EncodeToString(ca), base64.StdEncoding.EncodeToString(client))
+	if err := os.WriteFile(kubeconfig, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	workdir := filepath.Join(dir, "workdir")
+
+	script := fmt.Sprintf(`
+result = cert_check(sources=["kubeconfig"], kube_config=kube_config(path="%s"), workdir="%s")
+first = result[0]
+`, kubeconfig, workdir)
+	executor := New()
+	if err := executor.Exec("test.cert.check", strings.NewReader(script)); err != nil {
+		t.Fatal(err)
+	}
+
+	result, ok := executor.result["result"].(*starlark.List)
+	if !ok || result.Len() != 2 {
+		t.Fatalf("unexpected result: %v", executor.result["result"])
+	}
+	first := executor.result["first"].(*starlarkstruct.Struct)
+	for attr, expected := range map[string]starlark.Value{
+		"source":         starlark.String("kubeconfig:cluster/test"),
+		"subject":        starlark.String("CN=kubernetes"),
+		"issuer":         starlark.String("CN=kubernetes"),
+		"days_remaining": starlark.MakeInt(5),
+	} {
+		val, err := first.Attr(attr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if eq, err := starlark.Equal(val, expected); err != nil || !eq {
+			t.Errorf("%s: expected %s, got %s", attr, expected, val)
+		}
+	}
+	sans, err := first.Attr("sans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sans.String() != `["kubernetes"]` {
+		t.Errorf("unexpected sans: %s", sans)
+	}
+
+	summary, err := os.ReadFile(filepath.Join(workdir, certs.SummaryFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(summary), "2 certificate(s): 0 expired, 1 expiring within 30 days") {
+		t.Errorf("unexpected summary:\n%s", summary)
+	}
+}
+
+func TestSecretCerts(t *testing.T) {
+	cert := newTestCertPEM(t, "web", time.Now().Add(-24*time.Hour))
+	client := fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-tls"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{corev1.TLSCertKey: cert, corev1.TLSPrivateKeyKey: []byte("key")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "invalid-tls"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{corev1.TLSCertKey: []byte("invalid")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "opaque"},
+			Data:       map[string][]byte{corev1.TLSCertKey: cert},
+		},
+	)
+
+	found, errs := secretCerts(context.TODO(), client, nil, time.Now())
+	if len(found) != 1 || found[0].Source != "secret:default/web-tls:tls.crt" || !found[0].Expired() {
+		t.Errorf("unexpected certificates: %+v", found)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "secret:default/invalid-tls") {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+func TestCertCheckArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{
+			name:   "unknown source",
+			script: `cert_check(sources=["etcd"])`,
+			err:    `unknown source "etcd"`,
+		},
+		{
+			name:   "nodes without resources",
+			script: `cert_check(sources=["nodes"])`,
+			err:    "resources not found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := New().Exec("test.cert.check", strings.NewReader(test.script))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
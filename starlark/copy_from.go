@@ -4,12 +4,15 @@
 package starlark
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
+	"github.com/vmware-tanzu/crash-diagnostics/incremental"
 	"github.com/vmware-tanzu/crash-diagnostics/ssh"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
@@ -22,6 +25,9 @@ import (
 // If resources and workdir are not provided, copyFromFunc uses defaults from starlark thread generated
 // by previous calls to resources(), ssh_config, and crashd_config().
 //
+// When crashd_config enables the incremental mode, only the files whose checksum or modification
+// time changed since the base bundle are copied.
+//
 // Starlark format: copy_from([<path>] [,path=<list>, resources=resources, workdir=path])
 func copyFromFunc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var sourcePath, workdir string
@@ -66,7 +72,12 @@ func copyFromFunc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tu
 		}
 	}
 
-	results, err := execCopyFrom(workdir, sourcePath, agent, resources)
+	ctx, ok := thread.Local(identifiers.scriptCtx).(context.Context)
+	if !ok || ctx == nil {
+		return starlark.None, errors.New("script context not found")
+	}
+
+	results, err := execCopyFrom(ctx, workdir, sourcePath, agent, resources, getBudgetFromThread(thread, identifiers.copyFrom), getIncrementalFromThread(thread))
 	if err != nil {
 		return starlark.None, fmt.Errorf("%s: %s", identifiers.copyFrom, err)
 	}
@@ -83,7 +94,7 @@ func copyFromFunc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tu
 	return starlark.NewList(resultList), nil
 }
 
-func execCopyFrom(rootPath string, path string, agent ssh.Agent, resources *starlark.List) ([]commandResult, error) {
+func execCopyFrom(ctx context.Context, rootPath string, path string, agent ssh.Agent, resources *starlark.List, limit *budget.Budget, inc *incremental.Collector) ([]commandResult, error) {
 	if resources == nil {
 		return nil, fmt.Errorf("%s: missing resources", identifiers.copyFrom)
 	}
@@ -117,7 +128,13 @@ func execCopyFrom(rootPath string, path string, agent ssh.Agent, resources *star
 
 		switch {
 		case string(kind) == identifiers.hostResource && string(transport) == "ssh":
-			result, err := execSCPCopyFrom(host, rootDir, path, agent, res)
+			result, err := execSCPCopyFrom(host, rootDir, path, agent, res, limit, inc)
+			if err != nil {
+				logrus.Errorf("%s: failed to copyFrom %s: %s", identifiers.copyFrom, path, err)
+			}
+			results = append(results, result)
+		case string(kind) == identifiers.hostResource && string(transport) == kubeDebugTransport:
+			result, err := execCopyFromKubeDebug(ctx, host, rootDir, path, res, limit, inc)
 			if err != nil {
 				logrus.Errorf("%s: failed to copyFrom %s: %s", identifiers.copyFrom, path, err)
 			}
@@ -131,7 +148,7 @@ func execCopyFrom(rootPath string, path string, agent ssh.Agent, resources *star
 	return results, nil
 }
 
-func execSCPCopyFrom(host, rootDir, path string, agent ssh.Agent, res *starlarkstruct.Struct) (commandResult, error) {
+func execSCPCopyFrom(host, rootDir, path string, agent ssh.Agent, res *starlarkstruct.Struct, limit *budget.Budget, inc *incremental.Collector) (commandResult, error) {
 	sshCfg := starlarkstruct.FromKeywords(starlarkstruct.Default, makeDefaultSSHConfig())
 	if val, err := res.Attr(identifiers.sshCfg); err == nil {
 		if cfg, ok := val.(*starlarkstruct.Struct); ok {
@@ -150,6 +167,59 @@ func execSCPCopyFrom(host, rootDir, path string, agent ssh.Agent, res *starlarks
 		return commandResult{}, err
 	}
 
-	err = ssh.CopyFrom(args, agent, rootDir, path)
+	if inc == nil {
+		err = ssh.CopyFrom(args, agent, rootDir, path, limit)
+	} else {
+		err = copyChanged(inc, rootDir, path, func(cmd string) (string, error) {
+			return ssh.Run(args, agent, cmd)
+		}, func(paths []string) error {
+			for _, p := range paths {
+				if err := ssh.CopyFrom(args, agent, rootDir, p, limit); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
 	return commandResult{resource: args.Host, result: filepath.Join(rootDir, path), err: err}, err
 }
+
+// copyChanged copies the files at path that changed since the base of incremental collection
+// inc into rootDir, and records them in its manifest. Command list runs a listing command on
+// the host and copy copies paths from it. Everything is copied if the files cannot be listed.
+func copyChanged(inc *incremental.Collector, rootDir, path string, list func(cmd string) (string, error), copy func(paths []string) error) error {
+	output, err := list(incremental.ListCmd(path))
+	var files []incremental.RemoteFile
+	if err == nil {
+		files, err = incremental.ParseRemoteFiles(output)
+	}
+	if err != nil {
+		logrus.Warnf("%s: failed to list %s, copying all files: %s", identifiers.copyFrom, path, err)
+		return copy([]string{path})
+	}
+
+	var changed []incremental.RemoteFile
+	var paths []string
+	for _, file := range files {
+		if !inc.UnchangedFile(filepath.Join(rootDir, file.Path), file.Entry) {
+			changed = append(changed, file)
+			paths = append(paths, file.Path)
+		}
+	}
+	logrus.Debugf("%s: %d of %d file(s) changed at %s", identifiers.copyFrom, len(changed), len(files), path)
+
+	switch {
+	case len(changed) == len(files):
+		// nothing to skip, path is copied as a whole
+		err = copy([]string{path})
+	case len(changed) > 0:
+		err = copy(paths)
+	}
+	if err != nil {
+		return err
+	}
+	for _, file := range changed {
+		inc.AddFile(filepath.Join(rootDir, file.Path), file.Entry)
+	}
+	return inc.Save()
+}
@@ -6,13 +6,17 @@ package starlark
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
 
+	"github.com/vmware-tanzu/crash-diagnostics/bundle"
+	"github.com/vmware-tanzu/crash-diagnostics/incremental"
 	"github.com/vmware-tanzu/crash-diagnostics/ssh"
 )
 
@@ -390,3 +394,93 @@ func TestCopyFromFuncSSHAll(t *testing.T) {
 		})
 	}
 }
+
+func TestCopyChanged(t *testing.T) {
+	// the local filesystem stands for the host
+	remoteDir := t.TempDir()
+	for _, name := range []string{"syslog", "kern.log"} {
+		if err := os.WriteFile(filepath.Join(remoteDir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list := func(cmd string) (string, error) {
+		out, err := exec.Command("sh", "-c", cmd).Output()
+		return string(out), err
+	}
+	collect := func(base string) (string, []string) {
+		workdir := t.TempDir()
+		inc, err := incremental.New(base, workdir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var copied []string
+		rootDir := filepath.Join(workdir, "localhost")
+		err = copyChanged(inc, rootDir, remoteDir, list, func(paths []string) error {
+			copied = append(copied, paths...)
+			for _, path := range paths {
+				err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+					if err != nil || info.IsDir() {
+						return err
+					}
+					data, err := os.ReadFile(path)
+					if err != nil {
+						return err
+					}
+					target := filepath.Join(rootDir, path)
+					if err := os.MkdirAll(filepath.Dir(target), 0744); err != nil {
+						return err
+					}
+					return os.WriteFile(target, data, 0644)
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return workdir, copied
+	}
+
+	first, copied := collect("")
+	if len(copied) != 1 || copied[0] != remoteDir {
+		t.Errorf("unexpected copied paths without base: %v", copied)
+	}
+
+	second, copied := collect(first)
+	if len(copied) != 0 {
+		t.Errorf("unexpected copied paths without changes: %v", copied)
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(remoteDir, "kern.log"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	third, copied := collect(second)
+	if len(copied) != 1 || copied[0] != filepath.Join(remoteDir, "kern.log") {
+		t.Errorf("unexpected copied paths after change: %v", copied)
+	}
+
+	manifest, err := bundle.ReadManifest(third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	syslog, err := filepath.Rel(third, filepath.Join(third, "localhost", remoteDir, "syslog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != 2 || manifest.Files[syslog].Ref != first {
+		t.Errorf("unexpected manifest files: %+v", manifest.Files)
+	}
+
+	b, err := bundle.Open(third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if data, err := os.ReadFile(filepath.Join(b.Workdir(), syslog)); err != nil || string(data) != "syslog" {
+		t.Errorf("unexpected restored syslog %q: %v", data, err)
+	}
+}
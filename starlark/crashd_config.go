@@ -8,6 +8,8 @@ import (
 	"os"
 
 	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
+	"github.com/vmware-tanzu/crash-diagnostics/incremental"
 	"github.com/vmware-tanzu/crash-diagnostics/ssh"
 	"github.com/vmware-tanzu/crash-diagnostics/util"
 	"go.starlark.net/starlark"
@@ -32,10 +34,14 @@ func addDefaultCrashdConf(thread *starlark.Thread) error {
 }
 
 // crashdConfigFn is built-in starlark function that saves and returns the kwargs as a struct value.
-// Starlark format: crashd_config(workdir=path, default_shell=shellpath, requires=["command0",...,"commandN"])
+// Starlark format: crashd_config(workdir=path, default_shell=shellpath, requires=["command0",...,"commandN"]
+// [, max_total_bytes=size, max_file_bytes=size, truncation_policy="head"|"head_tail", function_budgets={"capture":size}]
+// [, incremental=False, incremental_base=path])
 func crashdConfigFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-	var workdir, gid, uid, defaultShell string
-	var useSSHAgent bool
+	var workdir, gid, uid, defaultShell, truncationPolicy, incrementalBase string
+	var useSSHAgent, incrementalMode bool
+	var maxTotalBytes, maxFileBytes starlark.Value = starlark.None, starlark.None
+	var functionBudgets *starlark.Dict
 	requires := starlark.NewList([]starlark.Value{})
 
 	if err := starlark.UnpackArgs(
@@ -46,6 +52,12 @@ func crashdConfigFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.
 		"default_shell?", &defaultShell,
 		"requires?", &requires,
 		"use_ssh_agent?", &useSSHAgent,
+		"max_total_bytes?", &maxTotalBytes,
+		"max_file_bytes?", &maxFileBytes,
+		"truncation_policy?", &truncationPolicy,
+		"function_budgets?", &functionBudgets,
+		"incremental?", &incrementalMode,
+		"incremental_base?", &incrementalBase,
 	); err != nil {
 		return starlark.None, fmt.Errorf("%s: %s", identifiers.crashdCfg, err)
 	}
@@ -82,6 +94,27 @@ func crashdConfigFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.
 		return starlark.None, err
 	}
 
+	budgetOpts, err := getBudgetOptions(workdir, maxTotalBytes, maxFileBytes, truncationPolicy, functionBudgets)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.crashdCfg, err)
+	}
+	limit, err := budget.New(budgetOpts)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.crashdCfg, err)
+	}
+
+	if incrementalMode || incrementalBase != "" {
+		collector, err := incremental.New(incrementalBase, workdir)
+		if err != nil {
+			return starlark.None, fmt.Errorf("%s: %s", identifiers.crashdCfg, err)
+		}
+		// the manifest marks the working directory as an incremental collection
+		if err := collector.Save(); err != nil {
+			return starlark.None, fmt.Errorf("%s: %s", identifiers.crashdCfg, err)
+		}
+		thread.SetLocal(identifiers.incremental, collector)
+	}
+
 	cfgStruct := starlarkstruct.FromStringDict(starlark.String(identifiers.crashdCfg), starlark.StringDict{
 		"workdir":       starlark.String(workdir),
 		"gid":           starlark.String(gid),
@@ -92,10 +125,57 @@ func crashdConfigFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.
 
 	// save values to be used as default
 	thread.SetLocal(identifiers.crashdCfg, cfgStruct)
+	thread.SetLocal(identifiers.budget, limit)
 
 	return cfgStruct, nil
 }
 
+// getBudgetOptions returns the budget options from the crashd_config arguments,
+// recording the truncated files in workdir
+func getBudgetOptions(workdir string, maxTotalBytes, maxFileBytes starlark.Value, policy string, functionBudgets *starlark.Dict) (budget.Options, error) {
+	opts := budget.Options{Policy: budget.Policy(policy), RecordDir: workdir}
+	if opts.Policy == "" {
+		opts.Policy = budget.Head
+	}
+
+	var err error
+	if opts.MaxTotalBytes, err = getByteSize(maxTotalBytes); err != nil {
+		return opts, fmt.Errorf("max_total_bytes: %w", err)
+	}
+	if opts.MaxFileBytes, err = getByteSize(maxFileBytes); err != nil {
+		return opts, fmt.Errorf("max_file_bytes: %w", err)
+	}
+
+	if functionBudgets == nil {
+		return opts, nil
+	}
+	opts.Functions = make(map[string]int64)
+	for _, item := range functionBudgets.Items() {
+		name, ok := item[0].(starlark.String)
+		if !ok {
+			return opts, fmt.Errorf("function_budgets: expecting function names as keys, got %s", item[0].Type())
+		}
+		size, err := getByteSize(item[1])
+		if err != nil {
+			return opts, fmt.Errorf("function_budgets: %s: %w", name, err)
+		}
+		opts.Functions[string(name)] = size
+	}
+	return opts, nil
+}
+
+// getIncrementalFromThread returns the collector of the incremental mode set by crashd_config, nil if none
+func getIncrementalFromThread(thread *starlark.Thread) *incremental.Collector {
+	collector, _ := thread.Local(identifiers.incremental).(*incremental.Collector)
+	return collector
+}
+
+// getBudgetFromThread returns the budget of function set by crashd_config, nil if none
+func getBudgetFromThread(thread *starlark.Thread, function string) *budget.Budget {
+	limit, _ := thread.Local(identifiers.budget).(*budget.Budget)
+	return limit.For(function)
+}
+
 func makeCrashdWorkdir(path string) error {
 	if _, err := os.Stat(path); err != nil && !os.IsNotExist(err) {
 		return err
@@ -4,10 +4,14 @@
 package starlark
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
+	"github.com/vmware-tanzu/crash-diagnostics/bundle"
 	"github.com/vmware-tanzu/crash-diagnostics/ssh"
 	"go.starlark.net/starlarkstruct"
 )
@@ -138,3 +142,81 @@ func TestCrashdCfgAll(t *testing.T) {
 		})
 	}
 }
+
+func TestCrashdConfigBudget(t *testing.T) {
+	workdir := t.TempDir()
+	script := fmt.Sprintf(`
+crashd_config(workdir="%s", max_file_bytes="1KB", max_total_bytes=10000, truncation_policy="head_tail", function_budgets={"capture_local": "1.5kB"})
+first = capture_local(cmd="seq 1 1000", file_name="first.txt")
+second = capture_local(cmd="seq 1 1000", file_name="second.txt")
+`, workdir)
+	exe := New()
+	if err := exe.Exec("test.star", strings.NewReader(script)); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := os.ReadFile(filepath.Join(workdir, "first.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(first), "1\n2\n") || !strings.HasSuffix(string(first), "999\n1000\n") || !strings.Contains(string(first), "max_file_bytes=1000 exceeded") {
+		t.Errorf("unexpected head and tail truncation:\n%s", first)
+	}
+	second, err := os.ReadFile(filepath.Join(workdir, "second.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(second), "capture_local budget=1500 exceeded") {
+		t.Errorf("unexpected function budget truncation:\n%s", second)
+	}
+
+	limit := exe.thread.Local(identifiers.budget).(*budget.Budget)
+	if len(limit.Truncations()) != 2 || limit.Used() != 1500 {
+		t.Errorf("unexpected truncations %+v of %d bytes", limit.Truncations(), limit.Used())
+	}
+	if _, err := os.Stat(filepath.Join(workdir, budget.RecordFile)); err != nil {
+		t.Errorf("truncations not recorded: %s", err)
+	}
+}
+
+func TestCrashdConfigBudgetErrors(t *testing.T) {
+	for _, script := range []string{
+		`crashd_config(workdir="%s", max_file_bytes="lots")`,
+		`crashd_config(workdir="%s", max_total_bytes=-1)`,
+		`crashd_config(workdir="%s", truncation_policy="tail")`,
+		`crashd_config(workdir="%s", function_budgets={"capture": "1XB"})`,
+	} {
+		if err := New().Exec("test.star", strings.NewReader(fmt.Sprintf(script, t.TempDir()))); err == nil {
+			t.Errorf("expecting error for %s", script)
+		}
+	}
+}
+
+func TestCrashdConfigIncremental(t *testing.T) {
+	workdir := t.TempDir()
+	base := filepath.Join(t.TempDir(), "missing.tar.gz")
+	script := fmt.Sprintf(`crashd_config(workdir="%s", incremental_base="%s")`, workdir, base)
+	exe := New()
+	if err := exe.Exec("test.star", strings.NewReader(script)); err != nil {
+		t.Fatal(err)
+	}
+
+	if getIncrementalFromThread(exe.thread) == nil {
+		t.Fatal("incremental collector not set")
+	}
+	manifest, err := bundle.ReadManifest(workdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Base != base {
+		t.Errorf("unexpected manifest base %s", manifest.Base)
+	}
+
+	exe = New()
+	if err := exe.Exec("test.star", strings.NewReader(fmt.Sprintf(`crashd_config(workdir="%s")`, t.TempDir()))); err != nil {
+		t.Fatal(err)
+	}
+	if getIncrementalFromThread(exe.thread) != nil {
+		t.Error("unexpected incremental collector")
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
+	"github.com/vmware-tanzu/crash-diagnostics/etcd"
+	"github.com/vmware-tanzu/crash-diagnostics/nodeinfo"
+	"github.com/vmware-tanzu/crash-diagnostics/ssh"
+)
+
+// EtcdCaptureFn is a starlark built-in that collects the state of the etcd cluster of kubeadm
+// control planes from the host resources, over ssh: the member list, the status and health of
+// the endpoints, the alarms, and optionally a snapshot. It runs etcdctl with the client
+// certificate found in /etc/kubernetes/pki/etcd, unless cert and key are given. The output
+// of each etcdctl command is saved in <workdir>/<host>/etcd/<command>.json, the parsed status
+// in <workdir>/<host>/etcd/etcd_capture.json, and the snapshot in <workdir>/<host>/etcd/snapshot.db.
+// It returns a list of etcd_status structs, one per host.
+// Starlark format: etcd_capture([resources=resources][, snapshot=False][, endpoints="https://127.0.0.1:2379"][, cacert=path, cert=path, key=path][, workdir=path])
+func EtcdCaptureFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var resources *starlark.List
+	var snapshot bool
+	var opts etcd.Options
+	var workdir string
+
+	if err := starlark.UnpackArgs(
+		identifiers.etcdCapture, args, kwargs,
+		"resources?", &resources,
+		"snapshot?", &snapshot,
+		"endpoints?", &opts.Endpoints,
+		"cacert?", &opts.CACert,
+		"cert?", &opts.Cert,
+		"key?", &opts.Key,
+		"workdir?", &workdir,
+	); err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.etcdCapture, err)
+	}
+	if (opts.Cert == "") != (opts.Key == "") {
+		return starlark.None, fmt.Errorf("%s: cert and key must be set together", identifiers.etcdCapture)
+	}
+
+	if len(workdir) == 0 {
+		if dir, err := getWorkdirFromThread(thread); err == nil {
+			workdir = dir
+		}
+	}
+	if len(workdir) == 0 {
+		workdir = defaults.workdir
+	}
+
+	if resources == nil {
+		res, err := getResourcesFromThread(thread)
+		if err != nil {
+			return starlark.None, fmt.Errorf("%s: %s", identifiers.etcdCapture, err)
+		}
+		resources = res
+	}
+
+	var agent ssh.Agent
+	if agentVal := thread.Local(identifiers.sshAgent); agentVal != nil {
+		var ok bool
+		agent, ok = agentVal.(ssh.Agent)
+		if !ok {
+			return starlark.None, errors.New("unable to fetch ssh-agent")
+		}
+	}
+
+	statuses := execEtcdCapture(opts, snapshot, trimQuotes(workdir), agent, resources, getBudgetFromThread(thread, identifiers.etcdCapture))
+	statusList := make([]starlark.Value, len(statuses))
+	for i, status := range statuses {
+		statusList[i] = etcdStatusToStarlarkStruct(status.status, status.dir)
+	}
+	return starlark.NewList(statusList), nil
+}
+
+// etcdHostStatus is the status collected from a host, and the directory of its files
+type etcdHostStatus struct {
+	status etcd.Status
+	dir    string
+}
+
+func execEtcdCapture(opts etcd.Options, snapshot bool, rootPath string, agent ssh.Agent, resources *starlark.List, limit *budget.Budget) []etcdHostStatus {
+	resultsChannel := make(chan etcdHostStatus)
+	for i := 0; i < resources.Len(); i++ {
+		go func(i int) {
+			res, ok := resources.Index(i).(*starlarkstruct.Struct)
+			if !ok {
+				resultsChannel <- etcdHostStatus{status: etcd.Status{Errors: []string{"unexpected resource type"}}}
+				return
+			}
+			kind, transport, host, err := hostResourceAttrs(res)
+			if err != nil {
+				resultsChannel <- etcdHostStatus{status: etcd.Status{Errors: []string{err.Error()}}}
+				return
+			}
+			if kind != identifiers.hostResource || transport != "ssh" {
+				logrus.Errorf("%s: unsupported resource: kind=%s, transport=%s", identifiers.etcdCapture, kind, transport)
+				resultsChannel <- etcdHostStatus{status: etcd.Status{Resource: host, Errors: []string{fmt.Sprintf("unsupported resource: kind=%s, transport=%s", kind, transport)}}}
+				return
+			}
+			dir := filepath.Join(rootPath, sanitizeStr(host), etcd.Dirname)
+			resultsChannel <- etcdHostStatus{status: execEtcdCaptureSSH(opts, snapshot, host, dir, agent, res, limit), dir: dir}
+		}(i)
+	}
+
+	results := make([]etcdHostStatus, resources.Len())
+	for i := range results {
+		results[i] = <-resultsChannel
+	}
+	return results
+}
+
+// execEtcdCaptureSSH runs the etcdctl commands on host, saving their outputs and the status in dir
+func execEtcdCaptureSSH(opts etcd.Options, snapshot bool, host, dir string, agent ssh.Agent, res *starlarkstruct.Struct, limit *budget.Budget) etcd.Status {
+	status := etcd.Status{Resource: host}
+	fail := func(format string, a ...interface{}) {
+		msg := fmt.Sprintf(format, a...)
+		logrus.Warnf("%s: %s: %s", identifiers.etcdCapture, host, msg)
+		status.Errors = append(status.Errors, msg)
+	}
+
+	sshCfg := starlarkstruct.FromKeywords(starlarkstruct.Default, makeDefaultSSHConfig())
+	if val, err := res.Attr(identifiers.sshCfg); err == nil {
+		if cfg, ok := val.(*starlarkstruct.Struct); ok {
+			sshCfg = cfg
+		}
+	}
+	args, err := getSSHArgsFromCfg(sshCfg)
+	if err != nil {
+		fail("%s", err)
+		return status
+	}
+	args.Host = host
+
+	if err := os.MkdirAll(dir, 0744); err != nil {
+		fail("%s", err)
+		return status
+	}
+
+	for _, cmd := range etcd.Commands {
+		var output bytes.Buffer
+		// etcdctl prints the JSON of the reachable endpoints even when it fails for others
+		runErr := ssh.RunStream(args, agent, ssh.EncodeScript(nodeinfo.Command{Cmd: opts.Script(cmd.Args), Sudo: true}.Script()), &output)
+		data, messages := etcd.SplitOutput(output.String())
+		for _, msg := range messages {
+			fail("%s: %s", cmd.Name, msg)
+		}
+		if runErr != nil {
+			fail("%s: %s", cmd.Name, runErr)
+		}
+		if data == "" {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, cmd.Name+".json"), []byte(data+"\n"), 0644); err != nil {
+			fail("%s: %s", cmd.Name, err)
+		}
+		if err := status.Parse(cmd.Name, []byte(data)); err != nil {
+			fail("%s: %s", cmd.Name, err)
+		}
+	}
+
+	if snapshot {
+		path, err := captureEtcdSnapshot(opts, args, agent, dir, limit)
+		if err != nil {
+			fail("snapshot: %s", err)
+		}
+		status.Snapshot = path
+	}
+
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, etcd.StatusFileName), append(data, '\n'), 0644)
+	}
+	if err != nil {
+		fail("%s", err)
+	}
+	return status
+}
+
+// captureEtcdSnapshot saves a snapshot of etcd in a private temporary directory of the
+// host and copies it to dir/snapshot.db. A snapshot truncated by the budget is not kept.
+func captureEtcdSnapshot(opts etcd.Options, args ssh.SSHArgs, agent ssh.Agent, dir string, limit *budget.Budget) (string, error) {
+	out, err := ssh.Run(args, agent, etcd.SnapshotDirCmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	remoteDir := strings.TrimSpace(out)
+	if !strings.HasPrefix(remoteDir, "/tmp/crashd-etcd.") || strings.ContainsAny(remoteDir, " \n") {
+		return "", fmt.Errorf("failed to create snapshot directory: unexpected output %q", remoteDir)
+	}
+	defer func() {
+		if _, err := ssh.Run(args, agent, ssh.EncodeScript("rm -rf "+ssh.Quote(remoteDir))); err != nil {
+			logrus.Warnf("%s: %s: failed to remove %s: %s", identifiers.etcdCapture, args.Host, remoteDir, err)
+		}
+	}()
+
+	var output bytes.Buffer
+	if err := ssh.RunStream(args, agent, ssh.EncodeScript(nodeinfo.Command{Cmd: opts.SnapshotScript(remoteDir), Sudo: true}.Script()), &output); err != nil {
+		return "", fmt.Errorf("%w: %s", err, bytes.TrimSpace(output.Bytes()))
+	}
+
+	copyDir, err := os.MkdirTemp(dir, "snapshot")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(copyDir)
+	remotePath := path.Join(remoteDir, etcd.SnapshotFileName)
+	if err := ssh.CopyFrom(args, agent, copyDir, remotePath, limit); err != nil {
+		return "", err
+	}
+	copied := filepath.Join(copyDir, remotePath)
+	for _, truncation := range limit.Truncations() {
+		if truncation.File == copied {
+			// a partial snapshot cannot be restored
+			return "", fmt.Errorf("skipped, truncated to %d of %d bytes: %s exceeded", truncation.Kept, truncation.Size, truncation.Reason)
+		}
+	}
+	snapshotPath := filepath.Join(dir, etcd.SnapshotFileName)
+	if err := os.Rename(copied, snapshotPath); err != nil {
+		return "", err
+	}
+	return snapshotPath, nil
+}
+
+func etcdStatusToStarlarkStruct(status etcd.Status, dir string) *starlarkstruct.Struct {
+	members := make([]starlark.Value, len(status.Members))
+	for i, m := range status.Members {
+		members[i] = starlarkstruct.FromStringDict(starlark.String("etcd_member"), starlark.StringDict{
+			"id":          starlark.String(m.ID),
+			"name":        starlark.String(m.Name),
+			"peer_urls":   stringsToStarlarkList(m.PeerURLs),
+			"client_urls": stringsToStarlarkList(m.ClientURLs),
+			"is_learner":  starlark.Bool(m.IsLearner),
+		})
+	}
+	endpoints := make([]starlark.Value, len(status.Endpoints))
+	for i, ep := range status.Endpoints {
+		endpoints[i] = starlarkstruct.FromStringDict(starlark.String("etcd_endpoint"), starlark.StringDict{
+			"endpoint":       starlark.String(ep.Endpoint),
+			"member_id":      starlark.String(ep.MemberID),
+			"version":        starlark.String(ep.Version),
+			"db_size":        starlark.MakeInt64(ep.DBSize),
+			"db_size_in_use": starlark.MakeInt64(ep.DBSizeInUse),
+			"is_leader":      starlark.Bool(ep.IsLeader),
+			"raft_term":      starlark.MakeUint64(ep.RaftTerm),
+			"raft_index":     starlark.MakeUint64(ep.RaftIndex),
+			"errors":         stringsToStarlarkList(ep.Errors),
+		})
+	}
+	health := make([]starlark.Value, len(status.Health))
+	for i, h := range status.Health {
+		health[i] = starlarkstruct.FromStringDict(starlark.String("etcd_health"), starlark.StringDict{
+			"endpoint": starlark.String(h.Endpoint),
+			"healthy":  starlark.Bool(h.Healthy),
+			"took":     starlark.String(h.Took),
+			"error":    starlark.String(h.Error),
+		})
+	}
+	alarms := make([]starlark.Value, len(status.Alarms))
+	for i, a := range status.Alarms {
+		alarms[i] = starlarkstruct.FromStringDict(starlark.String("etcd_alarm"), starlark.StringDict{
+			"member_id": starlark.String(a.MemberID),
+			"alarm":     starlark.String(a.Alarm),
+		})
+	}
+
+	return starlarkstruct.FromStringDict(starlark.String("etcd_status"), starlark.StringDict{
+		"resource":  starlark.String(status.Resource),
+		"dir":       starlark.String(dir),
+		"healthy":   starlark.Bool(status.Healthy()),
+		"members":   starlark.NewList(members),
+		"endpoints": starlark.NewList(endpoints),
+		"health":    starlark.NewList(health),
+		"alarms":    starlark.NewList(alarms),
+		"snapshot":  starlark.String(status.Snapshot),
+		"errors":    stringsToStarlarkList(status.Errors),
+	})
+}
+
+func stringsToStarlarkList(strs []string) *starlark.List {
+	values := make([]starlark.Value, len(strs))
+	for i, str := range strs {
+		values[i] = starlark.String(str)
+	}
+	return starlark.NewList(values)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/vmware-tanzu/crash-diagnostics/etcd"
+)
+
+func TestEtcdCaptureArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{
+			name:   "cert without key",
+			script: `etcd_capture(cert="/etc/etcd/client.crt", resources=[])`,
+			err:    "cert and key must be set together",
+		},
+		{
+			name:   "invalid snapshot",
+			script: `etcd_capture(snapshot="yes", resources=[])`,
+			err:    "snapshot",
+		},
+		{
+			name:   "no resources",
+			script: `etcd_capture()`,
+			err:    "resources not found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := New().Exec("test.etcd.capture", strings.NewReader(test.script))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestEtcdCaptureUnsupportedResource(t *testing.T) {
+	resources := starlark.NewList([]starlark.Value{
+		starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+			"kind":      starlark.String(identifiers.hostResource),
+			"transport": starlark.String(kubeDebugTransport),
+			"host":      starlark.String("cp-0"),
+		}),
+	})
+
+	statuses := execEtcdCapture(etcd.Options{}, false, t.TempDir(), nil, resources, nil)
+	if len(statuses) != 1 {
+		t.Fatalf("expected 1 status, got %d", len(statuses))
+	}
+	status := statuses[0].status
+	if status.Resource != "cp-0" || len(status.Errors) != 1 || !strings.Contains(status.Errors[0], "unsupported resource") {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	val := etcdStatusToStarlarkStruct(status, statuses[0].dir)
+	healthy, err := val.Attr("healthy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if healthy != starlark.False {
+		t.Errorf("unexpected healthy: %s", healthy)
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+)
+
+// ForEachClusterFn is a Starlark built-in that calls function fn, in parallel, with each
+// kube_config struct in kube_configs (as returned by kube_configs()). Each call runs with
+// the cluster's kube_config as the default kubeconfig and with <workdir>/<cluster name>
+// as its working directory, so the outputs of the clusters are kept apart.
+// It returns a list of structs with fields cluster, workdir, result (the value returned by fn),
+// and error. When the calls run concurrently (max_parallel > 1), the globals of the script
+// are frozen before the calls, so that fn can read them but not modify them.
+// Starlark format: for_each_cluster(fn=function, kube_configs=list [,workdir=path] [,max_parallel=count])
+func ForEachClusterFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var fn starlark.Callable
+	var kubeConfigs *starlark.List
+	var workdir string
+	var maxParallel int
+
+	if err := starlark.UnpackArgs(
+		identifiers.forEachCluster, args, kwargs,
+		"fn", &fn,
+		"kube_configs", &kubeConfigs,
+		"workdir?", &workdir,
+		"max_parallel?", &maxParallel,
+	); err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.forEachCluster, err)
+	}
+
+	if len(workdir) == 0 {
+		dir, err := getWorkdirFromThread(thread)
+		if err != nil {
+			return starlark.None, err
+		}
+		workdir = dir
+	}
+
+	// resolve the cluster names first to fail early on invalid or duplicate entries
+	configs := make([]*starlarkstruct.Struct, kubeConfigs.Len())
+	names := make([]string, kubeConfigs.Len())
+	seen := make(map[string]bool)
+	for i := 0; i < kubeConfigs.Len(); i++ {
+		cfg, ok := kubeConfigs.Index(i).(*starlarkstruct.Struct)
+		if !ok {
+			return starlark.None, fmt.Errorf("%s: unexpected kube_configs element type %s", identifiers.forEachCluster, kubeConfigs.Index(i).Type())
+		}
+		name := clusterDirName(cfg, i)
+		if seen[name] {
+			return starlark.None, fmt.Errorf("%s: duplicate cluster name: %s", identifiers.forEachCluster, name)
+		}
+		seen[name] = true
+		configs[i] = cfg
+		names[i] = name
+	}
+
+	if maxParallel <= 0 || maxParallel > len(configs) {
+		maxParallel = len(configs)
+	}
+
+	fn.Freeze()
+	kubeConfigs.Freeze()
+	// concurrent calls share the globals of the script, which Starlark cannot rebind
+	// for each call: they are frozen so the calls can read them but not race on them
+	if maxParallel > 1 {
+		if function, ok := fn.(*starlark.Function); ok {
+			for _, val := range function.Globals() {
+				val.Freeze()
+			}
+		}
+	}
+
+	results := make([]starlark.Value, len(configs))
+	sem := make(chan struct{}, maxParallel)
+	var wg sync.WaitGroup
+	for i := range configs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			clusterWorkdir := filepath.Join(workdir, names[i])
+			logrus.Debugf("%s: running for cluster %s in %s", identifiers.forEachCluster, names[i], clusterWorkdir)
+			result, err := callForCluster(thread, fn, configs[i], names[i], clusterWorkdir)
+			if err != nil {
+				logrus.Errorf("%s: cluster %s: %s", identifiers.forEachCluster, names[i], err)
+			}
+			results[i] = newForEachClusterResult(names[i], clusterWorkdir, result, err)
+		}(i)
+	}
+	wg.Wait()
+
+	return starlark.NewList(results), nil
+}
+
+// callForCluster calls fn on a new thread set up with the cluster's kubeconfig and working directory
+func callForCluster(parent *starlark.Thread, fn starlark.Callable, kubeConfig *starlarkstruct.Struct, name, workdir string) (starlark.Value, error) {
+	if err := makeCrashdWorkdir(workdir); err != nil {
+		return starlark.None, err
+	}
+
+	thread := &starlark.Thread{Name: fmt.Sprintf("%s-%s", parent.Name, name), Print: parent.Print}
+	for _, key := range []string{
+		identifiers.scriptCtx,
+		identifiers.scriptName,
+		identifiers.log,
+		identifiers.logPath,
+		identifiers.sshCfg,
+		identifiers.resources,
+		identifiers.sshAgent,
+		identifiers.tunnels,
+		identifiers.uploads,
+		identifiers.tempFiles,
+		identifiers.budget,
+		identifiers.incremental,
+		identifiers.s3Cfg,
+		identifiers.analyzerRule,
+	} {
+		if val := parent.Local(key); val != nil {
+			thread.SetLocal(key, val)
+		}
+	}
+
+	cfgDict := starlark.StringDict{}
+	if cfg, ok := parent.Local(identifiers.crashdCfg).(*starlarkstruct.Struct); ok {
+		cfg.ToStringDict(cfgDict)
+	}
+	cfgDict["workdir"] = starlark.String(workdir)
+	thread.SetLocal(identifiers.crashdCfg, starlarkstruct.FromStringDict(starlark.String(identifiers.crashdCfg), cfgDict))
+	thread.SetLocal(identifiers.kubeCfg, kubeConfig)
+
+	result, err := starlark.Call(thread, fn, starlark.Tuple{kubeConfig}, nil)
+	if err != nil {
+		var evalErr *starlark.EvalError
+		if errors.As(err, &evalErr) {
+			return starlark.None, errors.New(evalErr.Backtrace())
+		}
+		return starlark.None, err
+	}
+	return result, nil
+}
+
+// clusterDirName returns the name of the directory holding the outputs of the
+// cluster: its cluster_namespace and cluster_name, cluster_context, or the cluster
+// of the current context of its kubeconfig file
+func clusterDirName(kubeConfig *starlarkstruct.Struct, index int) string {
+	var name string
+	if val, err := kubeConfig.Attr("cluster_name"); err == nil {
+		if str, ok := val.(starlark.String); ok {
+			name = string(str)
+		}
+	}
+	if len(name) > 0 {
+		// clusters of different namespaces may have the same name
+		if val, err := kubeConfig.Attr("cluster_namespace"); err == nil {
+			if ns, ok := val.(starlark.String); ok && len(ns) > 0 {
+				name = fmt.Sprintf("%s/%s", string(ns), name)
+			}
+		}
+	}
+	if len(name) == 0 {
+		name = getKubeConfigContextNameFromStruct(kubeConfig)
+	}
+	if len(name) == 0 {
+		if path, err := getKubeConfigPathFromStruct(kubeConfig); err == nil {
+			if cfg, err := k8s.LoadKubeCfg(path); err == nil {
+				name, _ = cfg.GetClusterName()
+			}
+		}
+	}
+	if len(name) == 0 {
+		return fmt.Sprintf("cluster-%d", index)
+	}
+	return strings.NewReplacer("/", "_", `\`, "_", ":", "_").Replace(name)
+}
+
+func newForEachClusterResult(name, workdir string, result starlark.Value, err error) starlark.Value {
+	errStr := ""
+	if err != nil {
+		errStr = err.Error()
+	}
+	if result == nil {
+		result = starlark.None
+	}
+	return starlarkstruct.FromStringDict(
+		starlark.String(identifiers.forEachCluster),
+		starlark.StringDict{
+			"cluster": starlark.String(name),
+			"workdir": starlark.String(workdir),
+			"result":  result,
+			"error":   starlark.String(errStr),
+		},
+	)
+}
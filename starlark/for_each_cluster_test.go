@@ -0,0 +1,193 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func TestForEachClusterScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		script func(workdir string) string
+		eval   func(t *testing.T, workdir string, exe *Executor)
+	}{
+		{
+			name: "capture in cluster workdirs",
+			script: func(workdir string) string {
+				return fmt.Sprintf(`
+def collect(cfg):
+    capture_local(cmd="echo " + cfg.path, file_name="path.txt")
+    return cfg.cluster_name
+
+configs = [struct(path="/tmp/wl-%%d.conf" %% i, cluster_context="", cluster_name="wl-%%d" %% i) for i in range(2)]
+result = for_each_cluster(collect, kube_configs=configs, workdir=%q, max_parallel=1)
+`, workdir)
+			},
+			eval: func(t *testing.T, workdir string, exe *Executor) {
+				results := exe.result["result"].(*starlark.List)
+				if results.Len() != 2 {
+					t.Fatalf("unexpected number of results: %d", results.Len())
+				}
+				for i := 0; i < results.Len(); i++ {
+					name := fmt.Sprintf("wl-%d", i)
+					result := results.Index(i).(*starlarkstruct.Struct)
+					if errVal, _ := result.Attr("error"); errVal.(starlark.String) != "" {
+						t.Fatalf("unexpected error: %s", errVal)
+					}
+					if val, _ := result.Attr("result"); string(val.(starlark.String)) != name {
+						t.Errorf("unexpected result: %s", val)
+					}
+					data, err := os.ReadFile(filepath.Join(workdir, name, "path.txt"))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !strings.Contains(string(data), fmt.Sprintf("/tmp/%s.conf", name)) {
+						t.Errorf("unexpected capture for %s: %s", name, data)
+					}
+				}
+			},
+		},
+		{
+			name: "cluster name from context",
+			script: func(workdir string) string {
+				return fmt.Sprintf(`
+def fail_on(cfg):
+    fail("no access to " + cfg.cluster_context)
+
+result = for_each_cluster(fail_on, kube_configs=[kube_config(path="/tmp/a.conf", cluster_context="ctx-a")], workdir=%q)
+`, workdir)
+			},
+			eval: func(t *testing.T, workdir string, exe *Executor) {
+				results := exe.result["result"].(*starlark.List)
+				result := results.Index(0).(*starlarkstruct.Struct)
+				if val, _ := result.Attr("cluster"); string(val.(starlark.String)) != "ctx-a" {
+					t.Errorf("unexpected cluster name: %s", val)
+				}
+				if errVal, _ := result.Attr("error"); !strings.Contains(string(errVal.(starlark.String)), "no access to ctx-a") {
+					t.Errorf("unexpected error: %s", errVal)
+				}
+			},
+		},
+		{
+			name: "clusters with the same name",
+			script: func(workdir string) string {
+				return fmt.Sprintf(`
+configs = [struct(path="/tmp/wl.conf", cluster_context="", cluster_name="wl", cluster_namespace=ns) for ns in ["prod", "dev"]]
+result = for_each_cluster(lambda cfg: None, kube_configs=configs, workdir=%q)
+`, workdir)
+			},
+			eval: func(t *testing.T, workdir string, exe *Executor) {
+				results := exe.result["result"].(*starlark.List)
+				for i, expected := range []string{"prod_wl", "dev_wl"} {
+					result := results.Index(i).(*starlarkstruct.Struct)
+					if val, _ := result.Attr("cluster"); string(val.(starlark.String)) != expected {
+						t.Errorf("unexpected cluster name: %s", val)
+					}
+					if _, err := os.Stat(filepath.Join(workdir, expected)); err != nil {
+						t.Error(err)
+					}
+				}
+			},
+		},
+		{
+			name: "globals modified by sequential calls",
+			script: func(workdir string) string {
+				return fmt.Sprintf(`
+names = []
+def collect(cfg):
+    names.append(cfg.cluster_name)
+
+configs = [struct(path="/tmp/wl.conf", cluster_context="", cluster_name="wl-%%d" %% i) for i in range(2)]
+result = for_each_cluster(collect, kube_configs=configs, workdir=%q, max_parallel=1)
+names.append("done")
+`, workdir)
+			},
+			eval: func(t *testing.T, workdir string, exe *Executor) {
+				results := exe.result["result"].(*starlark.List)
+				for i := 0; i < results.Len(); i++ {
+					if errVal, _ := results.Index(i).(*starlarkstruct.Struct).Attr("error"); errVal.(starlark.String) != "" {
+						t.Errorf("unexpected error: %s", errVal)
+					}
+				}
+				if names := exe.result["names"].(*starlark.List).String(); names != `["wl-0", "wl-1", "done"]` {
+					t.Errorf("unexpected names: %s", names)
+				}
+			},
+		},
+		{
+			name: "globals frozen by parallel calls",
+			script: func(workdir string) string {
+				return fmt.Sprintf(`
+names = []
+def collect(cfg):
+    names.append(cfg.cluster_name)
+
+configs = [struct(path="/tmp/wl.conf", cluster_context="", cluster_name="wl-%%d" %% i) for i in range(2)]
+result = for_each_cluster(collect, kube_configs=configs, workdir=%q)
+`, workdir)
+			},
+			eval: func(t *testing.T, workdir string, exe *Executor) {
+				results := exe.result["result"].(*starlark.List)
+				for i := 0; i < results.Len(); i++ {
+					if errVal, _ := results.Index(i).(*starlarkstruct.Struct).Attr("error"); !strings.Contains(string(errVal.(starlark.String)), "frozen list") {
+						t.Errorf("expected frozen list error, got %s", errVal)
+					}
+				}
+				if names := exe.result["names"].(*starlark.List); names.Len() != 0 {
+					t.Errorf("unexpected names: %s", names)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			workdir := t.TempDir()
+			exe := New()
+			exe.AddPredeclared("struct", starlark.NewBuiltin("struct", starlarkstruct.Make))
+			if err := exe.Exec("test.star", strings.NewReader(test.script(workdir))); err != nil {
+				t.Fatal(err)
+			}
+			test.eval(t, workdir, exe)
+		})
+	}
+}
+
+func TestForEachClusterDuplicate(t *testing.T) {
+	script := `
+configs = [kube_config(path="/tmp/a.conf", cluster_context="a"), kube_config(path="/tmp/b.conf", cluster_context="a")]
+for_each_cluster(lambda cfg: None, kube_configs=configs)
+`
+	exe := New()
+	err := exe.Exec("test.star", strings.NewReader(script))
+	if err == nil || !strings.Contains(err.Error(), "duplicate cluster name: a") {
+		t.Fatalf("expected duplicate cluster error, got %v", err)
+	}
+}
+
+func TestTempFilesRemoved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wl-workload-config")
+	if err := os.WriteFile(path, []byte("credentials"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	exe := New()
+	exe.AddPredeclared("add_temp_file", starlark.NewBuiltin("add_temp_file", func(thread *starlark.Thread, _ *starlark.Builtin, _ starlark.Tuple, _ []starlark.Tuple) (starlark.Value, error) {
+		thread.Local(identifiers.tempFiles).(*tempFiles).add(path)
+		return starlark.None, nil
+	}))
+	if err := exe.Exec("test.star", strings.NewReader("add_temp_file()")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("temporary file not removed: %v", err)
+	}
+}
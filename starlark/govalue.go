@@ -193,3 +193,74 @@ func GoToStarlarkValue(val interface{}) (starlark.Value, error) {
 		return starlark.None, fmt.Errorf("unable to assert Go type %T as Starlark type", val)
 	}
 }
+
+// StarlarkToGoValue converts Starlark value val to its Go value/type. Lists and
+// tuples are converted to []interface{}, while structs and dictionaries with
+// string keys are converted to map[string]interface{}.
+func StarlarkToGoValue(val starlark.Value) (interface{}, error) {
+	switch v := val.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.Int:
+		i, ok := v.Int64()
+		if !ok {
+			return nil, fmt.Errorf("int %s out of range", v)
+		}
+		return i, nil
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.String:
+		return string(v), nil
+	case *starlark.List:
+		return starlarkIterableToGo(v)
+	case starlark.Tuple:
+		return starlarkIterableToGo(v)
+	case *starlark.Dict:
+		result := make(map[string]interface{})
+		for _, item := range v.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("unable to convert dict with %s key", item[0].Type())
+			}
+			elem, err := StarlarkToGoValue(item[1])
+			if err != nil {
+				return nil, err
+			}
+			result[string(key)] = elem
+		}
+		return result, nil
+	case *starlarkstruct.Struct:
+		result := make(map[string]interface{})
+		for _, name := range v.AttrNames() {
+			attr, err := v.Attr(name)
+			if err != nil {
+				return nil, err
+			}
+			elem, err := StarlarkToGoValue(attr)
+			if err != nil {
+				return nil, err
+			}
+			result[name] = elem
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unable to assert Starlark type %s as Go type", val.Type())
+	}
+}
+
+func starlarkIterableToGo(val starlark.Iterable) ([]interface{}, error) {
+	result := []interface{}{}
+	iter := val.Iterate()
+	defer iter.Done()
+	var elem starlark.Value
+	for iter.Next(&elem) {
+		goElem, err := StarlarkToGoValue(elem)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, goElem)
+	}
+	return result, nil
+}
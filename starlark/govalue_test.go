@@ -4,9 +4,11 @@
 package starlark
 
 import (
+	"reflect"
 	"testing"
 
 	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
 )
 
 func TestGoValue_ToStringDict(t *testing.T) {
@@ -372,3 +374,38 @@ func TestGoValue_ToList(t *testing.T) {
 		})
 	}
 }
+
+func TestStarlarkToGoValue(t *testing.T) {
+	dict := starlark.NewDict(1)
+	if err := dict.SetKey(starlark.String("key"), starlark.NewList([]starlark.Value{starlark.MakeInt(1), starlark.True})); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		val      starlark.Value
+		expected interface{}
+	}{
+		{name: "none", val: starlark.None, expected: nil},
+		{name: "string", val: starlark.String("val"), expected: "val"},
+		{name: "int", val: starlark.MakeInt(12), expected: int64(12)},
+		{name: "float", val: starlark.Float(1.5), expected: 1.5},
+		{name: "tuple", val: starlark.Tuple{starlark.String("a")}, expected: []interface{}{"a"}},
+		{name: "dict", val: dict, expected: map[string]interface{}{"key": []interface{}{int64(1), true}}},
+		{
+			name:     "struct",
+			val:      starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{"name": starlark.String("n")}),
+			expected: map[string]interface{}{"name": "n"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := StarlarkToGoValue(test.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, actual)
+			}
+		})
+	}
+}
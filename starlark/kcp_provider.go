@@ -55,9 +55,7 @@ func KcpProviderFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.T
 		return starlark.None, fmt.Errorf("failed to kubeconfig: %w", err)
 	}
 
-	clusterCtxName := getKubeConfigContextNameFromStruct(kubeConfig)
-
-	clients, err := k8s.New(path, clusterCtxName)
+	clients, err := newClientFromKubeConfig(kubeConfig)
 	if err != nil {
 		return starlark.None, fmt.Errorf("could not initialize search client: %w", err)
 	}
@@ -78,48 +76,14 @@ func KcpProviderFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.T
 		logrus.Info("tunnel config is passed. running the port forwarder.")
 
 		var err error
-
-		portforwardStr, err := tunnelConfig.Attr("namespace")
-		if err != nil {
-			return starlark.None, fmt.Errorf("could not parse the pod namespace: %w", err)
-		}
-
-		portForwardNS := portforwardStr.(starlark.String)
-
-		podNameStr, err := tunnelConfig.Attr("pod_name")
-		if err != nil {
-			return starlark.None, fmt.Errorf("could not parse the pod name: %w", err)
-		}
-
-		portForwardPodName := podNameStr.(starlark.String)
-
 		localPort, err = parseLocalPort(tunnelConfig)
 		if err != nil {
 			return starlark.None, fmt.Errorf("could not parse the local port: %w", err)
 		}
 
-		targetPortInt, err := tunnelConfig.Attr("target_port")
-		if err != nil {
-			return starlark.None, fmt.Errorf("could not get set the roundtripper: %w", err)
-		}
-		targetPort := targetPortInt.(starlark.Int)
-
-		stopCh, readyCh := make(chan struct{}), make(chan struct{})
-
-		fw, err := k8s.NewPortForwarder(path, string(portForwardNS), string(portForwardPodName), int(localPort.BigInt().Int64()), int(targetPort.BigInt().Int64()), stopCh, readyCh)
-		if err != nil {
-			return starlark.None, err
+		if _, err := startTunnel(ctx, thread, tunnelConfig, kubeConfig); err != nil {
+			return starlark.None, fmt.Errorf("could not start tunnel: %w", err)
 		}
-
-		defer fw.Close()
-
-		go func() {
-			if err := fw.ForwardPorts(); err != nil {
-				logrus.Error("error in forwarding ports ", err)
-			}
-		}()
-
-		<-readyCh
 	}
 
 	for clusterName, clusterConfig := range kcpAdminKubeConfig.Clusters {
@@ -10,6 +10,8 @@ import (
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
+	"github.com/vmware-tanzu/crash-diagnostics/incremental"
 	"github.com/vmware-tanzu/crash-diagnostics/k8s"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
@@ -48,11 +50,6 @@ func KubeCaptureFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.T
 		return starlark.None, fmt.Errorf("failed to read args: %w", err)
 	}
 
-	writeLogs := what == "logs" || what == "all"
-	if writeLogs && tunnelConfig != nil {
-		return starlark.None, fmt.Errorf("tunnel_config unsupported for 'logs' and 'all' operations")
-	}
-
 	ctx, ok := thread.Local(identifiers.scriptCtx).(context.Context)
 	if !ok || ctx == nil {
 		return starlark.None, errors.New("script context not found")
@@ -61,17 +58,13 @@ func KubeCaptureFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.T
 	if kubeConfig == nil {
 		kubeConfig = thread.Local(identifiers.kubeCfg).(*starlarkstruct.Struct)
 	}
-	path, err := getKubeConfigPathFromStruct(kubeConfig)
-	if err != nil {
-		return starlark.None, fmt.Errorf("failed to get kubeconfig: %w", err)
-	}
-
 	var client *k8s.Client
+	var err error
 
 	clusterCtxName := getKubeConfigContextNameFromStruct(kubeConfig)
 
 	if tunnelConfig == nil {
-		if client, err = k8s.New(path, clusterCtxName); err != nil {
+		if client, err = newClientFromKubeConfig(kubeConfig); err != nil {
 			return starlark.None, fmt.Errorf("could not initialize search client: %w", err)
 		}
 	} else {
@@ -80,54 +73,15 @@ func KubeCaptureFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.T
 			return starlark.None, err
 		}
 
-		portforwardStr, err := tunnelConfig.Attr("namespace")
-		if err != nil {
-			return nil, fmt.Errorf("could not get set the roundtripper: %w", err)
-		}
-
-		portForwardNS := portforwardStr.(starlark.String)
-
-		podNameStr, err := tunnelConfig.Attr("pod_name")
-		if err != nil {
-			return nil, fmt.Errorf("could not get set the roundtripper: %w", err)
-		}
-
-		portForwardPodName := podNameStr.(starlark.String)
-
-		localPortInt, err := tunnelConfig.Attr("local_port")
-		if err != nil {
-			return nil, fmt.Errorf("could not get set the roundtripper: %w", err)
+		if _, err := startTunnel(ctx, thread, tunnelConfig, kubeConfig); err != nil {
+			return starlark.None, fmt.Errorf("could not start tunnel: %w", err)
 		}
-
-		localPort := localPortInt.(starlark.Int)
-
-		targetPortInt, err := tunnelConfig.Attr("target_port")
-		if err != nil {
-			return nil, fmt.Errorf("could not get set the roundtripper: %w", err)
-		}
-		targetPort := targetPortInt.(starlark.Int)
-
-		stopCh, readyCh := make(chan struct{}), make(chan struct{})
-
-		fw, err := k8s.NewPortForwarder(path, string(portForwardNS), string(portForwardPodName), int(localPort.BigInt().Int64()), int(targetPort.BigInt().Int64()), stopCh, readyCh)
-		if err != nil {
-			return starlark.None, err
-		}
-
-		defer fw.Close()
-		go func() {
-			if err := fw.ForwardPorts(); err != nil {
-				logrus.Error("error in forwarding ports ", err)
-			}
-		}()
-
-		<-readyCh
 	}
 
 	data := thread.Local(identifiers.crashdCfg)
 	cfg, _ := data.(*starlarkstruct.Struct)
 	workDirVal, _ := cfg.Attr("workdir")
-	resultDir, err := write(ctx, trimQuotes(workDirVal.String()), what, strings.ToLower(outputFormat), strings.ToLower(outputMode), client, k8s.SearchParams{
+	resultDir, err := write(ctx, trimQuotes(workDirVal.String()), what, strings.ToLower(outputFormat), strings.ToLower(outputMode), client, getBudgetFromThread(thread, identifiers.kubeCapture), getIncrementalFromThread(thread), k8s.SearchParams{
 		Groups:     toSlice(groups),
 		Categories: toSlice(categories),
 		Kinds:      toSlice(kinds),
@@ -151,7 +105,7 @@ func KubeCaptureFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.T
 		}), nil
 }
 
-func write(ctx context.Context, workdir, what, outputFormat, outputMode string, client *k8s.Client, params k8s.SearchParams) (string, error) {
+func write(ctx context.Context, workdir, what, outputFormat, outputMode string, client *k8s.Client, limit *budget.Budget, inc *incremental.Collector, params k8s.SearchParams) (string, error) {
 
 	logrus.Debugf("kube_capture(what=%s)", what)
 	switch what {
@@ -173,10 +127,20 @@ func write(ctx context.Context, workdir, what, outputFormat, outputMode string,
 	if err != nil {
 		return "", fmt.Errorf("failed to initialize writer: %w", err)
 	}
+	resultWriter.SetBudget(limit)
+	if inc != nil {
+		// objects unchanged since the base bundle are only recorded in the manifest
+		resultWriter.SetFilter(inc)
+	}
 	err = resultWriter.Write(ctx, searchResults)
 	if err != nil {
 		return "", fmt.Errorf("failed to write search results: %w", err)
 	}
+	if inc != nil {
+		if err := inc.Save(); err != nil {
+			return "", err
+		}
+	}
 	return resultWriter.GetResultDir(), nil
 }
 
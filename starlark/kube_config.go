@@ -4,9 +4,14 @@
 package starlark
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
 	"github.com/vmware-tanzu/crash-diagnostics/util"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
@@ -14,9 +19,12 @@ import (
 
 // KubeConfigFn is built-in starlark function that wraps the kwargs into a dictionary value.
 // The result is also added to the thread for other built-in to access.
-// Starlark: kube_config(path=kubecf/path, [cluster_context=context_name])
+// The path may list several kubeconfig files, separated as in KUBECONFIG, which are merged.
+// Starlark: kube_config(path=kubecf/path | capi_provider=provider | in_cluster=True | server=url, [cluster_context=context_name, token=token, token_file=path, impersonate_user=user, impersonate_groups=["group"]])
 func KubeConfigFn(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-	var path, clusterCtxName string
+	var path, clusterCtxName, server, token, tokenFile, impersonateUser string
+	var inCluster bool
+	var impersonateGroups *starlark.List
 	var provider *starlarkstruct.Struct
 
 	if err := starlark.UnpackArgs(
@@ -24,10 +32,31 @@ func KubeConfigFn(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple,
 		"cluster_context?", &clusterCtxName,
 		"path?", &path,
 		"capi_provider?", &provider,
+		"server?", &server,
+		"token?", &token,
+		"token_file?", &tokenFile,
+		"impersonate_user?", &impersonateUser,
+		"impersonate_groups?", &impersonateGroups,
+		"in_cluster?", &inCluster,
 	); err != nil {
 		return starlark.None, fmt.Errorf("%s: %s", identifiers.kubeCfg, err)
 	}
 
+	if token != "" && tokenFile != "" {
+		return starlark.None, errors.New("token and token_file cannot be used together")
+	}
+
+	// without a kubeconfig, the cluster is accessed with the in-cluster service account or a server address
+	if inCluster || (server != "" && len(path) == 0 && provider == nil) {
+		if len(path) != 0 || provider != nil {
+			return starlark.None, errors.New("in_cluster cannot be used with path or capi_provider")
+		}
+		if !inCluster && token == "" && tokenFile == "" {
+			return starlark.None, errors.New("server without path requires token or token_file")
+		}
+		return newKubeConfigStruct("", clusterCtxName, server, token, tokenFile, impersonateUser, impersonateGroups, inCluster), nil
+	}
+
 	// check if only one of the two options are present
 	if (len(path) == 0 && provider == nil) || (len(path) != 0 && provider != nil) {
 		return starlark.None, errors.New("need either path or capi_provider")
@@ -74,21 +103,45 @@ func KubeConfigFn(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple,
 
 	}
 
-	path, err := util.ExpandPath(path)
-	if err != nil {
-		return starlark.None, err
-	}
-
-	stringDictVal := starlark.StringDict{
-		"cluster_context": starlark.String(clusterCtxName),
-		"path":            starlark.String(path),
+	// expand each path of a KUBECONFIG list
+	paths := filepath.SplitList(path)
+	for i := range paths {
+		if paths[i] == "" {
+			continue
+		}
+		expanded, err := util.ExpandPath(paths[i])
+		if err != nil {
+			return starlark.None, err
+		}
+		paths[i] = expanded
 	}
+	path = strings.Join(paths, string(filepath.ListSeparator))
 
+	kubeConfig := newKubeConfigStruct(path, clusterCtxName, server, token, tokenFile, impersonateUser, impersonateGroups, false)
 	if isKCPProvider {
+		stringDictVal := starlark.StringDict{}
+		kubeConfig.ToStringDict(stringDictVal)
 		stringDictVal["kcp_kubeconfig"] = extraKubeConfigStr
+		kubeConfig = starlarkstruct.FromStringDict(starlark.String(identifiers.kubeCfg), stringDictVal)
 	}
 
-	return starlarkstruct.FromStringDict(starlark.String(identifiers.kubeCfg), stringDictVal), nil
+	return kubeConfig, nil
+}
+
+func newKubeConfigStruct(path, clusterCtxName, server, token, tokenFile, impersonateUser string, impersonateGroups *starlark.List, inCluster bool) *starlarkstruct.Struct {
+	if impersonateGroups == nil {
+		impersonateGroups = starlark.NewList(nil)
+	}
+	return starlarkstruct.FromStringDict(starlark.String(identifiers.kubeCfg), starlark.StringDict{
+		"cluster_context":    starlark.String(clusterCtxName),
+		"path":               starlark.String(path),
+		"server":             starlark.String(server),
+		"token":              starlark.String(token),
+		"token_file":         starlark.String(tokenFile),
+		"impersonate_user":   starlark.String(impersonateUser),
+		"impersonate_groups": impersonateGroups,
+		"in_cluster":         starlark.Bool(inCluster),
+	})
 }
 
 // addDefaultKubeConf initializes a Starlark Dict with default
@@ -97,6 +150,10 @@ func addDefaultKubeConf(thread *starlark.Thread) error {
 	args := []starlark.Tuple{
 		{starlark.String("path"), starlark.String(defaults.kubeconfig)},
 	}
+	// inside a pod without a kubeconfig file, i.e. crashd serve, use the pod service account
+	if _, err := os.Stat(defaults.kubeconfig); os.IsNotExist(err) && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		args = []starlark.Tuple{{starlark.String("in_cluster"), starlark.True}}
+	}
 
 	conf, err := KubeConfigFn(thread, nil, nil, args)
 	if err != nil {
@@ -118,6 +175,90 @@ func getKubeConfigPathFromStruct(kubeConfigStructVal *starlarkstruct.Struct) (st
 	return kvPathStrVal.GoString(), nil
 }
 
+// getKubeConfigOptionsFromStruct returns the cluster access options of the KubeConfig struct
+// provided. Fields missing from the struct, i.e. created by kube_configs(), are left empty.
+func getKubeConfigOptionsFromStruct(kubeConfigStructVal *starlarkstruct.Struct) (k8s.ConfigOptions, error) {
+	path, err := getKubeConfigPathFromStruct(kubeConfigStructVal)
+	if err != nil {
+		return k8s.ConfigOptions{}, err
+	}
+	opts := k8s.ConfigOptions{Path: path, Context: getKubeConfigContextNameFromStruct(kubeConfigStructVal)}
+	for attr, dest := range map[string]*string{
+		"server":           &opts.Server,
+		"token":            &opts.Token,
+		"token_file":       &opts.TokenFile,
+		"impersonate_user": &opts.ImpersonateUser,
+	} {
+		if val, err := kubeConfigStructVal.Attr(attr); err == nil {
+			if str, ok := val.(starlark.String); ok {
+				*dest = string(str)
+			}
+		}
+	}
+	if val, err := kubeConfigStructVal.Attr("impersonate_groups"); err == nil {
+		if groups, ok := val.(*starlark.List); ok {
+			opts.ImpersonateGroups = toSlice(groups)
+		}
+	}
+	if val, err := kubeConfigStructVal.Attr("in_cluster"); err == nil {
+		opts.InCluster = bool(val.Truth())
+	}
+	return opts, nil
+}
+
+// workloadClusterClient returns a client for workload cluster name, declared on the management
+// cluster of mgmtClient, and the path of its kubeconfig, read from the cluster secret and removed
+// when the script ends. If name is empty, it returns mgmtClient and its kubeconfig path, if any.
+func workloadClusterClient(ctx context.Context, thread *starlark.Thread, mgmtClient *k8s.Client, mgmtOpts k8s.ConfigOptions, name, namespace string) (*k8s.Client, string, error) {
+	if name == "" {
+		return mgmtClient, mgmtOpts.Path, nil
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	cluster, err := mgmtClient.GetWorkloadCluster(ctx, name, namespace)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not fetch kubeconfig for workload cluster %s: %w", name, err)
+	}
+	path, err := mgmtClient.WriteWorkloadKubeConfig(ctx, cluster)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not fetch kubeconfig for workload cluster %s: %w", name, err)
+	}
+	if files, ok := thread.Local(identifiers.tempFiles).(*tempFiles); ok {
+		files.add(path)
+	}
+	client, err := k8s.New(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not initialize client: %w", err)
+	}
+	return client, path, nil
+}
+
+// kubeConfigClusterName returns the cluster of the current context of the kubeconfig file of opts
+func kubeConfigClusterName(opts k8s.ConfigOptions) (string, error) {
+	if opts.Path == "" {
+		return "", errors.New("workload_cluster required when kube_config has no kubeconfig file")
+	}
+	config, err := k8s.LoadKubeCfg(opts.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to load kube config: %w", err)
+	}
+	return config.GetClusterName()
+}
+
+// newClientFromKubeConfig returns a client for the cluster of the KubeConfig struct provided
+func newClientFromKubeConfig(kubeConfig *starlarkstruct.Struct) (*k8s.Client, error) {
+	opts, err := getKubeConfigOptionsFromStruct(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig: %w", err)
+	}
+	client, err := k8s.NewForOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize client: %w", err)
+	}
+	return client, nil
+}
+
 // getKubeConfigContextNameFromStruct returns the cluster name from the KubeConfig struct
 // provided. If filed cluster_context not provided or unable to convert, it is returned
 // as an empty context.
@@ -129,4 +129,66 @@ var _ = Describe("KubeConfigFn", func() {
 			Expect(err).To(MatchError("unknown capi provider"))
 		})
 	})
+
+	Context("With credential options", func() {
+
+		It("returns the access options of the kubeconfig", func() {
+			val, err := KubeConfigFn(&starlark.Thread{Name: "test.kube.config.fn"}, nil, nil,
+				[]starlark.Tuple{
+					{starlark.String("path"), starlark.String("/foo/a.conf:/foo/b.conf")},
+					{starlark.String("token_file"), starlark.String("/var/run/token")},
+					{starlark.String("impersonate_user"), starlark.String("ci")},
+					{starlark.String("impersonate_groups"), starlark.NewList([]starlark.Value{starlark.String("viewers")})},
+				})
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := getKubeConfigOptionsFromStruct(val.(*starlarkstruct.Struct))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.Path).To(Equal("/foo/a.conf:/foo/b.conf"))
+			Expect(opts.TokenFile).To(Equal("/var/run/token"))
+			Expect(opts.ImpersonateUser).To(Equal("ci"))
+			Expect(opts.ImpersonateGroups).To(Equal([]string{"viewers"}))
+			Expect(opts.InCluster).To(BeFalse())
+		})
+
+		It("accepts in_cluster without path", func() {
+			val, err := KubeConfigFn(&starlark.Thread{Name: "test.kube.config.fn"}, nil, nil,
+				[]starlark.Tuple{{starlark.String("in_cluster"), starlark.True}})
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := getKubeConfigOptionsFromStruct(val.(*starlarkstruct.Struct))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.InCluster).To(BeTrue())
+			Expect(opts.Path).To(BeEmpty())
+		})
+
+		It("accepts server with token without path", func() {
+			val, err := KubeConfigFn(&starlark.Thread{Name: "test.kube.config.fn"}, nil, nil,
+				[]starlark.Tuple{
+					{starlark.String("server"), starlark.String("https://lb:443")},
+					{starlark.String("token"), starlark.String("abc")},
+				})
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := getKubeConfigOptionsFromStruct(val.(*starlarkstruct.Struct))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.Server).To(Equal("https://lb:443"))
+			Expect(opts.Token).To(Equal("abc"))
+		})
+
+		It("throws an error for server without credentials", func() {
+			_, err := KubeConfigFn(&starlark.Thread{Name: "test.kube.config.fn"}, nil, nil,
+				[]starlark.Tuple{{starlark.String("server"), starlark.String("https://lb:443")}})
+			Expect(err).To(MatchError("server without path requires token or token_file"))
+		})
+
+		It("throws an error for in_cluster with path", func() {
+			_, err := KubeConfigFn(&starlark.Thread{Name: "test.kube.config.fn"}, nil, nil,
+				[]starlark.Tuple{
+					{starlark.String("path"), starlark.String("/foo/bar")},
+					{starlark.String("in_cluster"), starlark.True},
+				})
+			Expect(err).To(MatchError("in_cluster cannot be used with path or capi_provider"))
+		})
+	})
 })
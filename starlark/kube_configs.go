@@ -0,0 +1,104 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// KubeConfigsFn is a Starlark built-in that lists the workload clusters declared by
+// Cluster API Cluster objects on the management cluster and returns a kube_config
+// struct for each. The kubeconfig of a cluster is read from its <cluster>-kubeconfig secret.
+// Clusters whose kubeconfig cannot be retrieved are logged and skipped. The kubeconfig
+// files are removed when the script ends.
+// Starlark format: kube_configs([kube_config=kube_config()] [,namespaces=list] [,names=list] [,labels=list])
+func KubeConfigsFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var namespaces, names, labels *starlark.List
+	var mgmtKubeConfig *starlarkstruct.Struct
+
+	if err := starlark.UnpackArgs(
+		identifiers.kubeConfigs, args, kwargs,
+		"kube_config?", &mgmtKubeConfig,
+		"namespaces?", &namespaces,
+		"names?", &names,
+		"labels?", &labels,
+	); err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.kubeConfigs, err)
+	}
+
+	ctx, ok := thread.Local(identifiers.scriptCtx).(context.Context)
+	if !ok || ctx == nil {
+		return starlark.None, errors.New("script context not found")
+	}
+
+	if mgmtKubeConfig == nil {
+		mgmtKubeConfig = thread.Local(identifiers.kubeCfg).(*starlarkstruct.Struct)
+	}
+	client, err := newClientFromKubeConfig(mgmtKubeConfig)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", identifiers.kubeConfigs, err)
+	}
+
+	clusters, err := client.ListWorkloadClusters(ctx, toSlice(namespaces), toSlice(names), toSlice(labels))
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", identifiers.kubeConfigs, err)
+	}
+
+	var configs []starlark.Value
+	for _, cluster := range clusters {
+		configPath, err := client.WriteWorkloadKubeConfig(ctx, cluster)
+		if err != nil {
+			logrus.Warnf("%s: skipping cluster %s/%s: %s", identifiers.kubeConfigs, cluster.Namespace, cluster.Name, err)
+			continue
+		}
+		// the kubeconfig holds the credentials of the cluster, it is removed after the script
+		if files, ok := thread.Local(identifiers.tempFiles).(*tempFiles); ok {
+			files.add(configPath)
+		}
+		configs = append(configs, starlarkstruct.FromStringDict(
+			starlark.String(identifiers.kubeCfg),
+			starlark.StringDict{
+				"path":              starlark.String(configPath),
+				"cluster_context":   starlark.String(""),
+				"cluster_name":      starlark.String(cluster.Name),
+				"cluster_namespace": starlark.String(cluster.Namespace),
+			},
+		))
+	}
+
+	return starlark.NewList(configs), nil
+}
+
+// tempFiles are the temporary files created by a script, removed when it ends
+type tempFiles struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (f *tempFiles) add(path string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paths = append(f.paths, path)
+}
+
+// removeAll removes the files added to f
+func (f *tempFiles) removeAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, path := range f.paths {
+		logrus.Debugf("removing temporary file %s", path)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logrus.Warnf("failed to remove temporary file %s: %s", path, err)
+		}
+	}
+	f.paths = nil
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// KubeCopyFromFn is a starlark built-in that copies a file or directory out of a container.
+// The path is streamed as a tar archive, which requires tar in the container, and saved
+// in <workdir>/<namespace>/<pod> under its full path.
+// Starlark format: kube_copy_from(pod="nginx", path="/etc/nginx" [,namespace="default", container="nginx", workdir=path, kube_config=kube_config(), timeout_in_seconds=120])
+func KubeCopyFromFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var namespace, pod, container, path, workdir string
+	var timeout int
+	var kubeConfig *starlarkstruct.Struct
+
+	if err := starlark.UnpackArgs(
+		identifiers.kubeCopyFrom, args, kwargs,
+		"pod", &pod,
+		"path", &path,
+		"namespace?", &namespace,
+		"container?", &container,
+		"workdir?", &workdir,
+		"kube_config?", &kubeConfig,
+		"timeout_in_seconds?", &timeout,
+	); err != nil {
+		return starlark.None, fmt.Errorf("failed to read args: %w", err)
+	}
+
+	if namespace == "" {
+		namespace = "default"
+	}
+	if timeout == 0 {
+		timeout = 120
+	}
+	if len(workdir) == 0 {
+		if dir, err := getWorkdirFromThread(thread); err == nil {
+			workdir = dir
+		}
+	}
+
+	ctx, ok := thread.Local(identifiers.scriptCtx).(context.Context)
+	if !ok || ctx == nil {
+		return starlark.None, errors.New("script context not found")
+	}
+
+	if kubeConfig == nil {
+		kubeConfig = thread.Local(identifiers.kubeCfg).(*starlarkstruct.Struct)
+	}
+	client, err := newClientFromKubeConfig(kubeConfig)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", identifiers.kubeCopyFrom, err)
+	}
+
+	return kubeCopyFrom(ctx, client, namespace, pod, container, path, trimQuotes(workdir), time.Duration(timeout)*time.Second), nil
+}
+
+// containerCopier copies files out of containers, i.e. k8s.Client
+type containerCopier interface {
+	CopyFromContainer(ctx context.Context, namespace, pod, container, path, destDir string) error
+}
+
+// kubeCopyFrom copies path from the container into <workdir>/<namespace>/<pod> and returns the kube_copy_from struct
+func kubeCopyFrom(ctx context.Context, copier containerCopier, namespace, pod, container, path, workdir string, timeout time.Duration) *starlarkstruct.Struct {
+	destDir := filepath.Join(workdir, namespace, pod)
+	err := os.MkdirAll(destDir, 0744)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		err = copier.CopyFromContainer(ctx, namespace, pod, container, path, destDir)
+	}
+
+	errStr := ""
+	if err != nil {
+		errStr = err.Error()
+	}
+	return starlarkstruct.FromStringDict(starlark.String(identifiers.kubeCopyFrom), starlark.StringDict{
+		"pod":       starlark.String(pod),
+		"namespace": starlark.String(namespace),
+		"path":      starlark.String(filepath.Join(destDir, path)),
+		"error":     starlark.String(errStr),
+	})
+}
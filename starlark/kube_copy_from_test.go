@@ -0,0 +1,73 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.starlark.net/starlark"
+)
+
+// fakeCopier writes the content of its files, by container path, as kubectl cp would
+type fakeCopier struct {
+	files    map[string]string
+	args     []string
+	deadline bool
+}
+
+func (c *fakeCopier) CopyFromContainer(ctx context.Context, namespace, pod, container, path, destDir string) error {
+	c.args = []string{namespace, pod, container, path}
+	_, c.deadline = ctx.Deadline()
+	data, ok := c.files[path]
+	if !ok {
+		return errors.New("tar: " + path + ": No such file or directory")
+	}
+	target := filepath.Join(destDir, path)
+	if err := os.MkdirAll(filepath.Dir(target), 0744); err != nil {
+		return err
+	}
+	return os.WriteFile(target, []byte(data), 0644)
+}
+
+func TestKubeCopyFrom(t *testing.T) {
+	workdir := t.TempDir()
+	copier := &fakeCopier{files: map[string]string{"/etc/nginx/nginx.conf": "worker_processes 1;"}}
+
+	result := kubeCopyFrom(context.TODO(), copier, "web", "nginx-0", "nginx", "/etc/nginx/nginx.conf", workdir, time.Minute)
+	if errVal, _ := result.Attr("error"); errVal.(starlark.String) != "" {
+		t.Fatalf("unexpected error: %s", errVal)
+	}
+	if strings.Join(copier.args, " ") != "web nginx-0 nginx /etc/nginx/nginx.conf" || !copier.deadline {
+		t.Errorf("unexpected copy: %v, deadline=%t", copier.args, copier.deadline)
+	}
+	expected := filepath.Join(workdir, "web", "nginx-0", "etc", "nginx", "nginx.conf")
+	if val, _ := result.Attr("path"); string(val.(starlark.String)) != expected {
+		t.Errorf("unexpected path: %s", val)
+	}
+	data, err := os.ReadFile(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "worker_processes 1;" {
+		t.Errorf("unexpected content: %s", data)
+	}
+
+	result = kubeCopyFrom(context.TODO(), copier, "web", "nginx-0", "", "/missing", workdir, time.Minute)
+	if errVal, _ := result.Attr("error"); !strings.Contains(string(errVal.(starlark.String)), "No such file") {
+		t.Errorf("expected copy error, got %s", errVal)
+	}
+}
+
+func TestKubeCopyFromArgs(t *testing.T) {
+	err := New().Exec("test.kube.copy.from", strings.NewReader(`kube_copy_from(path="/etc/hosts")`))
+	if err == nil || !strings.Contains(err.Error(), "missing argument for pod") {
+		t.Fatalf("expected missing pod error, got %v", err)
+	}
+}
@@ -7,38 +7,61 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/vmware-tanzu/crash-diagnostics/k8s"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
+	corev1 "k8s.io/api/core/v1"
 )
 
-// KubeExecFn is a starlark built-in for executing command in target K8s pods
+// KubeExecFn is a starlark built-in for executing command in target K8s pods.
+// When pod is not specified, the command runs in parallel in every running pod selected
+// by namespaces, labels, and owner, and a list of results, one per pod, is returned.
+// Starlark format: kube_exec(cmd=["ls"], pod="nginx" | [labels=["app=nginx"], namespaces=["default"], owner="deployment/nginx", max_parallel=10] [,namespace="default", container="nginx", workdir=path, output_file="out.txt", kube_config=kube_config(), timeout_in_seconds=120])
 func KubeExecFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-	var namespace, pod, container, workdir, outputfile string
-	var timeout int
-	var command *starlark.List
+	var namespace, pod, container, workdir, outputfile, owner string
+	var timeout, maxParallel int
+	var command, labels, namespaces *starlark.List
 	var kubeConfig *starlarkstruct.Struct
 
 	if err := starlark.UnpackArgs(
 		identifiers.kubeExec, args, kwargs,
 		"namespace?", &namespace,
-		"pod", &pod,
+		"pod?", &pod,
 		"container?", &container,
 		"cmd", &command,
 		"workdir?", &workdir,
 		"output_file?", &outputfile,
 		"kube_config?", &kubeConfig,
 		"timeout_in_seconds?", &timeout,
+		"labels?", &labels,
+		"namespaces?", &namespaces,
+		"owner?", &owner,
+		"max_parallel?", &maxParallel,
 	); err != nil {
 		return starlark.None, fmt.Errorf("failed to read args: %w", err)
 	}
 
+	selector := k8s.PodSelector{Namespaces: toSlice(namespaces), Labels: toSlice(labels), Owner: owner}
+	multiPod := len(selector.Labels) > 0 || selector.Owner != ""
+	if pod == "" && !multiPod {
+		return starlark.None, fmt.Errorf("%s: pod, labels, or owner required", identifiers.kubeExec)
+	}
+	if pod != "" && multiPod {
+		return starlark.None, fmt.Errorf("%s: pod cannot be used with labels or owner", identifiers.kubeExec)
+	}
+
 	if namespace == "" {
 		namespace = "default"
 	}
+	if len(selector.Namespaces) == 0 {
+		selector.Namespaces = []string{namespace}
+	}
 	if timeout == 0 {
 		//Default timeout if not specified is 2 Minutes
 		timeout = 120
@@ -59,11 +82,32 @@ func KubeExecFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tupl
 	if kubeConfig == nil {
 		kubeConfig = thread.Local(identifiers.kubeCfg).(*starlarkstruct.Struct)
 	}
-	path, err := getKubeConfigPathFromStruct(kubeConfig)
+
+	if multiPod {
+		client, err := newClientFromKubeConfig(kubeConfig)
+		if err != nil {
+			return starlark.None, fmt.Errorf("%s: %w", identifiers.kubeExec, err)
+		}
+		pods, err := client.SelectPods(ctx, selector)
+		if err != nil {
+			return starlark.None, fmt.Errorf("%s: %w", identifiers.kubeExec, err)
+		}
+		execOpts := k8s.ExecOptions{
+			ContainerName: container,
+			Command:       toSlice(command),
+			Timeout:       time.Duration(timeout) * time.Second,
+		}
+		return execInPods(ctx, client, pods, execOpts, trimQuotes(workdir), outputfile, maxParallel), nil
+	}
+
+	kubeConfigOpts, err := getKubeConfigOptionsFromStruct(kubeConfig)
 	if err != nil {
 		return starlark.None, fmt.Errorf("failed to get kubeconfig: %w", err)
 	}
-	clusterCtxName := getKubeConfigContextNameFromStruct(kubeConfig)
+	restConfig, err := kubeConfigOpts.RESTConfig()
+	if err != nil {
+		return starlark.None, fmt.Errorf("could not initialize search client: %w", err)
+	}
 
 	execOpts := k8s.ExecOptions{
 		Namespace:     namespace,
@@ -72,7 +116,7 @@ func KubeExecFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tupl
 		Command:       toSlice(command),
 		Timeout:       time.Duration(timeout) * time.Second,
 	}
-	executor, err := k8s.NewExecutor(path, clusterCtxName, execOpts)
+	executor, err := k8s.NewExecutorForConfig(restConfig, execOpts)
 	if err != nil {
 		return starlark.None, fmt.Errorf("could not initialize search client: %w", err)
 	}
@@ -95,3 +139,55 @@ func KubeExecFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tupl
 			}(),
 		}), nil
 }
+
+// execInPods runs the command of execOpts in pods, at most maxParallel at a time, and saves the
+// output of each pod in <workdir>/<namespace>/<pod>.out, or <workdir>/<namespace>/<pod>/<outputFile>
+func execInPods(ctx context.Context, client *k8s.Client, pods []corev1.Pod, execOpts k8s.ExecOptions, workdir, outputFile string, maxParallel int) *starlark.List {
+	if maxParallel <= 0 || maxParallel > len(pods) {
+		maxParallel = len(pods)
+	}
+
+	results := make([]starlark.Value, len(pods))
+	sem := make(chan struct{}, maxParallel)
+	var wg sync.WaitGroup
+	for i := range pods {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			pod := pods[i]
+			opts := execOpts
+			opts.Namespace = pod.Namespace
+			opts.Podname = pod.Name
+
+			outputFilePath := filepath.Join(workdir, pod.Namespace, pod.Name+".out")
+			if outputFile != "" {
+				outputFilePath = filepath.Join(workdir, pod.Namespace, pod.Name, outputFile)
+			}
+
+			err := os.MkdirAll(filepath.Dir(outputFilePath), 0744)
+			if err == nil {
+				var executor *k8s.Executor
+				executor, err = k8s.NewExecutorForConfig(&client.RestConfig, opts)
+				if err == nil {
+					err = executor.ExecCommand(ctx, outputFilePath, opts)
+				}
+			}
+
+			errStr := ""
+			if err != nil {
+				logrus.Errorf("%s: pod %s/%s: %s", identifiers.kubeExec, pod.Namespace, pod.Name, err)
+				errStr = err.Error()
+			}
+			results[i] = starlarkstruct.FromStringDict(starlark.String(identifiers.kubeCapture), starlark.StringDict{
+				"pod":       starlark.String(pod.Name),
+				"namespace": starlark.String(pod.Namespace),
+				"file":      starlark.String(outputFilePath),
+				"error":     starlark.String(errStr),
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	return starlark.NewList(results)
+}
@@ -132,3 +132,31 @@ kube_exec_output=kube_exec(pod="nginx", output_file="nginx.version",container="n
 		})
 	}
 }
+
+func TestKubeExecArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{
+			name:   "no target",
+			script: `kube_exec(cmd=["ls"])`,
+			err:    "pod, labels, or owner required",
+		},
+		{
+			name:   "pod and labels",
+			script: `kube_exec(pod="nginx", labels=["app=nginx"], cmd=["ls"])`,
+			err:    "pod cannot be used with labels or owner",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := New().Exec("test.kube.exec", strings.NewReader(test.script))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
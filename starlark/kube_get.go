@@ -42,13 +42,7 @@ func KubeGetFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple
 	if kubeConfig == nil {
 		kubeConfig = thread.Local(identifiers.kubeCfg).(*starlarkstruct.Struct)
 	}
-	path, err := getKubeConfigPathFromStruct(kubeConfig)
-	if err != nil {
-		return starlark.None, fmt.Errorf("failed to get kubeconfig: %w", err)
-	}
-	clusterCtxName := getKubeConfigContextNameFromStruct(kubeConfig)
-
-	client, err := k8s.New(path, clusterCtxName)
+	client, err := newClientFromKubeConfig(kubeConfig)
 	if err != nil {
 		return starlark.None, fmt.Errorf("could not initialize search client: %w", err)
 	}
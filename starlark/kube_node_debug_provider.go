@@ -0,0 +1,209 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
+	"github.com/vmware-tanzu/crash-diagnostics/incremental"
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+)
+
+// kubeDebugTransport is the transport of the resources enumerated by kube_node_debug_provider
+const kubeDebugTransport = "kube-debug"
+
+// KubeNodeDebugProviderFn is a built-in starlark function that enumerates the nodes of a k8s cluster
+// as compute resources accessed through privileged debug pods rather than SSH. Commands run by
+// run() and capture(), and files copied by copy_from(), go through a pod created on the node.
+// Starlark format: kube_node_debug_provider([kube_config=kube_config(), names=["foo", "bar"], labels=["bar", "baz"], namespace="default", image="busybox:1.36", timeout_in_seconds=120])
+func KubeNodeDebugProviderFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var namespace, image string
+	var timeout int
+	var names, labels *starlark.List
+	var kubeConfig *starlarkstruct.Struct
+
+	if err := starlark.UnpackArgs(
+		identifiers.kubeNodeDebugProvider, args, kwargs,
+		"names?", &names,
+		"labels?", &labels,
+		"kube_config?", &kubeConfig,
+		"namespace?", &namespace,
+		"image?", &image,
+		"timeout_in_seconds?", &timeout,
+	); err != nil {
+		return starlark.None, fmt.Errorf("failed to read args: %w", err)
+	}
+
+	ctx, ok := thread.Local(identifiers.scriptCtx).(context.Context)
+	if !ok || ctx == nil {
+		return starlark.None, errors.New("script context not found")
+	}
+
+	if kubeConfig == nil {
+		kubeConfig = thread.Local(identifiers.kubeCfg).(*starlarkstruct.Struct)
+	}
+	client, err := newClientFromKubeConfig(kubeConfig)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", identifiers.kubeNodeDebugProvider, err)
+	}
+
+	nodeNames, err := client.GetNodeNames(ctx, toSlice(names), toSlice(labels))
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", identifiers.kubeNodeDebugProvider, err)
+	}
+
+	if namespace == "" {
+		namespace = "default"
+	}
+	if image == "" {
+		image = k8s.DefaultNodeDebugImage
+	}
+	if timeout == 0 {
+		timeout = 120
+	}
+
+	var hosts []starlark.Value
+	for _, node := range nodeNames {
+		hosts = append(hosts, starlark.String(node))
+	}
+
+	return starlarkstruct.FromStringDict(starlark.String(identifiers.kubeNodeDebugProvider), starlark.StringDict{
+		"kind":               starlark.String(identifiers.kubeNodeDebugProvider),
+		"transport":          starlark.String(kubeDebugTransport),
+		"hosts":              starlark.NewList(hosts),
+		identifiers.kubeCfg:  kubeConfig,
+		"namespace":          starlark.String(namespace),
+		"image":              starlark.String(image),
+		"timeout_in_seconds": starlark.MakeInt(timeout),
+	}), nil
+}
+
+// nodeDebuggerFromResource returns a node debugger configured with the
+// settings of a resource enumerated by kube_node_debug_provider
+func nodeDebuggerFromResource(res *starlarkstruct.Struct) (*k8s.NodeDebugger, error) {
+	val, err := res.Attr(identifiers.kubeCfg)
+	if err != nil {
+		return nil, fmt.Errorf("resource.kube_config: %w", err)
+	}
+	kubeConfig, ok := val.(*starlarkstruct.Struct)
+	if !ok {
+		return nil, errors.New("resource.kube_config has unexpected type")
+	}
+	client, err := newClientFromKubeConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := k8s.NodeDebugOptions{}
+	if val, err := res.Attr("namespace"); err == nil {
+		if str, ok := val.(starlark.String); ok {
+			opts.Namespace = string(str)
+		}
+	}
+	if val, err := res.Attr("image"); err == nil {
+		if str, ok := val.(starlark.String); ok {
+			opts.Image = string(str)
+		}
+	}
+	if val, err := res.Attr("timeout_in_seconds"); err == nil {
+		if timeout, ok := val.(starlark.Int); ok {
+			opts.Timeout = time.Duration(timeout.BigInt().Int64()) * time.Second
+		}
+	}
+	return k8s.NewNodeDebugger(client, opts), nil
+}
+
+// execRunKubeDebug executes `run` command for a Host Resource using a debug pod
+func execRunKubeDebug(ctx context.Context, host, cmdStr string, res *starlarkstruct.Struct) (commandResult, error) {
+	debugger, err := nodeDebuggerFromResource(res)
+	if err != nil {
+		return commandResult{}, fmt.Errorf("%s: %w", identifiers.run, err)
+	}
+
+	logrus.Debugf("%s: executing command on %s using debug pod: [%s]", identifiers.run, host, cmdStr)
+	// the streams are copied concurrently, so each gets its own buffer
+	var stdout, stderr bytes.Buffer
+	err = debugger.Run(ctx, host, cmdStr, &stdout, &stderr)
+	if stderr.Len() > 0 && stdout.Len() > 0 && !bytes.HasSuffix(stdout.Bytes(), []byte("\n")) {
+		stdout.WriteByte('\n')
+	}
+	output := strings.TrimSpace(stdout.String() + stderr.String())
+	return commandResult{resource: host, result: output, err: err}, nil
+}
+
+// execCaptureKubeDebug executes `capture` command for a Host Resource using a debug pod
+func execCaptureKubeDebug(ctx context.Context, host, cmdStr, rootDir, fileName, desc string, compress bool, res *starlarkstruct.Struct, limit *budget.Budget) (commandResult, error) {
+	debugger, err := nodeDebuggerFromResource(res)
+	if err != nil {
+		return commandResult{resource: host, err: err}, err
+	}
+
+	if err := os.MkdirAll(rootDir, 0744); err != nil && !os.IsExist(err) {
+		return commandResult{resource: host, err: err}, err
+	}
+	filePath := filepath.Join(rootDir, captureFileName(cmdStr, fileName, compress))
+
+	logrus.Debugf("%s: capturing output of [cmd=%s] => [%s] from %s using debug pod", identifiers.capture, cmdStr, filePath, host)
+	if err := captureCmd(filePath, desc, false, compress, limit, func(w io.Writer) error {
+		if err := debugger.Run(ctx, host, cmdStr, w, w); err != nil {
+			logrus.Errorf("%s failed: %s", identifiers.capture, err)
+			return err
+		}
+		return nil
+	}, capturedFailure(cmdStr)); err != nil {
+		logrus.Errorf("%s output failed: %s", identifiers.capture, err)
+		return commandResult{resource: host, result: filePath, err: err}, err
+	}
+	return commandResult{resource: host, result: filePath}, nil
+}
+
+// execCopyFromKubeDebug executes `copy_from` command for a Host Resource using a debug pod
+func execCopyFromKubeDebug(ctx context.Context, host, rootDir, path string, res *starlarkstruct.Struct, limit *budget.Budget, inc *incremental.Collector) (commandResult, error) {
+	debugger, err := nodeDebuggerFromResource(res)
+	if err != nil {
+		return commandResult{resource: host, err: err}, err
+	}
+
+	if err := os.MkdirAll(rootDir, 0744); err != nil && !os.IsExist(err) {
+		return commandResult{resource: host, err: err}, err
+	}
+
+	copyPaths := func(paths []string) error {
+		// a debug pod is created per call, so the paths are copied at once, relative to the host root
+		relPaths := make([]string, len(paths))
+		for i, p := range paths {
+			relPaths[i] = strings.TrimLeft(p, "/")
+		}
+		before := budget.Stat(rootDir)
+		if err := debugger.CopyFrom(ctx, host, strings.Join(relPaths, " "), rootDir); err != nil {
+			return err
+		}
+		return limit.EnforceChanged(rootDir, before)
+	}
+	if inc == nil {
+		err = copyPaths([]string{path})
+	} else {
+		err = copyChanged(inc, rootDir, path, func(cmd string) (string, error) {
+			var stdout, stderr bytes.Buffer
+			if err := debugger.Run(ctx, host, cmd, &stdout, &stderr); err != nil {
+				return "", fmt.Errorf("%w: %s", err, stderr.String())
+			}
+			return stdout.String(), nil
+		}, copyPaths)
+	}
+	return commandResult{resource: host, result: filepath.Join(rootDir, path), err: err}, err
+}
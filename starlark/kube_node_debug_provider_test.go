@@ -0,0 +1,58 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func TestKubeNodeDebugProviderEnum(t *testing.T) {
+	kubeConfig := starlarkstruct.FromStringDict(starlark.String(identifiers.kubeCfg), starlark.StringDict{
+		"path": starlark.String("/tmp/kube.conf"),
+	})
+	provider := starlarkstruct.FromStringDict(starlark.String(identifiers.kubeNodeDebugProvider), starlark.StringDict{
+		"kind":               starlark.String(identifiers.kubeNodeDebugProvider),
+		"transport":          starlark.String(kubeDebugTransport),
+		"hosts":              starlark.NewList([]starlark.Value{starlark.String("node-0"), starlark.String("node-1")}),
+		identifiers.kubeCfg:  kubeConfig,
+		"namespace":          starlark.String("debug"),
+		"timeout_in_seconds": starlark.MakeInt(30),
+	})
+
+	resources, err := enum(provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resources.Len() != 2 {
+		t.Fatalf("unexpected number of resources: %d", resources.Len())
+	}
+	for i := 0; i < resources.Len(); i++ {
+		res := resources.Index(i).(*starlarkstruct.Struct)
+		expected := map[string]starlark.Value{
+			"kind":      starlark.String(identifiers.hostResource),
+			"provider":  starlark.String(identifiers.kubeNodeDebugProvider),
+			"transport": starlark.String(kubeDebugTransport),
+			"host":      starlark.String([]string{"node-0", "node-1"}[i]),
+			"namespace": starlark.String("debug"),
+		}
+		for attr, val := range expected {
+			actual, err := res.Attr(attr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != val {
+				t.Errorf("unexpected %s: %s", attr, actual)
+			}
+		}
+		if val, _ := res.Attr(identifiers.kubeCfg); val != kubeConfig {
+			t.Errorf("unexpected kube_config: %s", val)
+		}
+		if _, err := res.Attr("hosts"); err == nil {
+			t.Error("resource should not carry the provider hosts")
+		}
+	}
+}
@@ -39,43 +39,69 @@ func KubeNodesProviderFn(thread *starlark.Thread, _ *starlark.Builtin, args star
 	if kubeConfig == nil {
 		kubeConfig = thread.Local(identifiers.kubeCfg).(*starlarkstruct.Struct)
 	}
-	path, err := getKubeConfigPathFromStruct(kubeConfig)
+	client, err := newClientFromKubeConfig(kubeConfig)
 	if err != nil {
-		return starlark.None, fmt.Errorf("failed to kubeconfig: %w", err)
+		return starlark.None, fmt.Errorf("%s: %w", identifiers.kubeNodesProvider, err)
 	}
 
 	if sshConfig == nil {
 		sshConfig = thread.Local(identifiers.sshCfg).(*starlarkstruct.Struct)
 	}
 
-	return newKubeNodesProvider(ctx, path, sshConfig, toSlice(names), toSlice(labels))
+	return newKubeNodesProvider(ctx, client, sshConfig, toSlice(names), toSlice(labels))
 }
 
 // newKubeNodesProvider returns a struct with k8s cluster node provider info
-func newKubeNodesProvider(ctx context.Context, kubeconfig string, sshConfig *starlarkstruct.Struct, names, labels []string) (*starlarkstruct.Struct, error) {
-
-	searchParams := k8s.SearchParams{
-		Names:  names,
-		Labels: labels,
-	}
-	nodeAddresses, err := k8s.GetNodeAddresses(ctx, kubeconfig, searchParams.Names, searchParams.Labels)
+func newKubeNodesProvider(ctx context.Context, client *k8s.Client, sshConfig *starlarkstruct.Struct, names, labels []string) (*starlarkstruct.Struct, error) {
+	nodeAddresses, err := client.GetNodeAddresses(ctx, names, labels)
 	if err != nil {
 		return nil, fmt.Errorf("could not fetch node addresses: %w", err)
 	}
 
-	// dictionary for node provider struct
-	kubeNodesProviderDict := starlark.StringDict{
-		"kind":             starlark.String(identifiers.kubeNodesProvider),
+	return newSSHHostsProvider(identifiers.kubeNodesProvider, nodeAddresses, sshConfig, nil), nil
+}
+
+// newSSHHostsProvider returns the struct of a provider, of the given kind, enumerating
+// hosts reached over SSH. Additional provider fields can be passed with fields.
+func newSSHHostsProvider(kind string, hosts []string, sshConfig *starlarkstruct.Struct, fields starlark.StringDict) *starlarkstruct.Struct {
+	providerDict := starlark.StringDict{
+		"kind":             starlark.String(kind),
 		"transport":        starlark.String("ssh"),
 		identifiers.sshCfg: sshConfig,
 	}
+	for name, val := range fields {
+		providerDict[name] = val
+	}
 
 	// add node info to dictionary
-	var nodeIps []starlark.Value
-	for _, node := range nodeAddresses {
-		nodeIps = append(nodeIps, starlark.String(node))
+	var hostList []starlark.Value
+	for _, host := range hosts {
+		hostList = append(hostList, starlark.String(host))
 	}
-	kubeNodesProviderDict["hosts"] = starlark.NewList(nodeIps)
+	providerDict["hosts"] = starlark.NewList(hostList)
 
-	return starlarkstruct.FromStringDict(starlark.String(identifiers.kubeNodesProvider), kubeNodesProviderDict), nil
+	return starlarkstruct.FromStringDict(starlark.String(kind), providerDict)
+}
+
+// sshConfigWithJumpHost returns a copy of sshConfig that reaches hosts through the bastion,
+// unless sshConfig already declares a jump host or user. The jump user defaults to the
+// bastion's user, or else to the user name of sshConfig.
+func sshConfigWithJumpHost(sshConfig *starlarkstruct.Struct, bastion k8s.Bastion) *starlarkstruct.Struct {
+	if bastion.Address == "" {
+		return sshConfig
+	}
+	sshConfigDict := starlark.StringDict{}
+	sshConfig.ToStringDict(sshConfigDict)
+
+	if _, ok := sshConfigDict[identifiers.jumpHost]; !ok {
+		sshConfigDict[identifiers.jumpHost] = starlark.String(bastion.Address)
+	}
+	if _, ok := sshConfigDict[identifiers.jumpUser]; !ok {
+		if bastion.User != "" {
+			sshConfigDict[identifiers.jumpUser] = starlark.String(bastion.User)
+		} else if user, ok := sshConfigDict[identifiers.username]; ok {
+			sshConfigDict[identifiers.jumpUser] = user
+		}
+	}
+	return starlarkstruct.FromStringDict(starlark.String(identifiers.sshCfg), sshConfigDict)
 }
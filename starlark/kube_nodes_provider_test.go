@@ -5,7 +5,10 @@ package starlark
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"testing"
 
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
@@ -64,3 +67,46 @@ provider = kube_nodes_provider(kube_config=cfg, ssh_config = ssh_cfg)`, k8sconfi
 		Expect(sshCfg).NotTo(BeNil())
 	})
 })
+
+// newTestNodesAPIServer returns an API server listing one node, for clients using token
+func newTestNodesAPIServer(t *testing.T, token string) *httptest.Server {
+	responses := map[string]string{
+		"/api":          `{"kind":"APIVersions","versions":["v1"],"serverAddressByClientCIDRs":[]}`,
+		"/apis":         `{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`,
+		"/api/v1":       `{"kind":"APIResourceList","groupVersion":"v1","resources":[{"name":"nodes","singularName":"node","namespaced":false,"kind":"Node","verbs":["get","list"]}]}`,
+		"/api/v1/nodes": `{"kind":"NodeList","apiVersion":"v1","metadata":{},"items":[{"apiVersion":"v1","kind":"Node","metadata":{"name":"cp-0"},"status":{"addresses":[{"type":"InternalIP","address":"10.0.0.10"}]}}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestKubeNodesProviderServerToken(t *testing.T) {
+	server := newTestNodesAPIServer(t, "test-token")
+	script := fmt.Sprintf(`provider = kube_nodes_provider(kube_config=kube_config(server=%q, token="test-token"), ssh_config=ssh_config(username="capv"))`, server.URL)
+
+	exe := New()
+	if err := exe.Exec("test.kube.nodes.provider", strings.NewReader(script)); err != nil {
+		t.Fatal(err)
+	}
+	provider := exe.result["provider"].(*starlarkstruct.Struct)
+	hosts, err := provider.Attr("hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := hosts.(*starlark.List); list.Len() != 1 || list.Index(0) != starlark.String("10.0.0.10") {
+		t.Errorf("unexpected hosts: %s", hosts)
+	}
+}
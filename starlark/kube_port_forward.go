@@ -5,11 +5,12 @@ package starlark
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math/rand"
 	"net"
-	"time"
+	"sync"
 
+	"github.com/sirupsen/logrus"
 	"github.com/vmware-tanzu/crash-diagnostics/k8s"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -18,7 +19,9 @@ import (
 	"go.starlark.net/starlarkstruct"
 )
 
-// KubePortForwardrFn is a built-in starlark function that collects compute resources from a k8s cluster
+// KubePortForwardrFn is a built-in starlark function that configures a port-forward tunnel to a service.
+// The tunnel is started by the first function using it, health-checked and reconnected to another pod
+// of the service when its pod is gone, and stopped when the script ends.
 // Starlark format: kube_port_forward_config(service="bar", target_port=664, [namespace="foo"])
 func KubePortForwardrFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 
@@ -40,12 +43,7 @@ func KubePortForwardrFn(thread *starlark.Thread, _ *starlark.Builtin, args starl
 	}
 
 	kubeConfig := thread.Local(identifiers.kubeCfg).(*starlarkstruct.Struct)
-	kubeConfigPath, err := getKubeConfigPathFromStruct(kubeConfig)
-	if err != nil {
-		return starlark.None, fmt.Errorf("failed to kubeconfig: %w", err)
-	}
-
-	client, err := k8s.New(kubeConfigPath)
+	client, err := newClientFromKubeConfig(kubeConfig)
 	if err != nil {
 		return nil, fmt.Errorf("could not initialize search client: %w", err)
 	}
@@ -61,28 +59,103 @@ func KubePortForwardrFn(thread *starlark.Thread, _ *starlark.Builtin, args starl
 		return nil, fmt.Errorf("could not list pods: %w", err)
 	}
 
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	var randomPort int
-	var found bool
-
-	for !found {
-		// get a random port between 49152-65535
-		randomPort = r.Intn(16383) + 49152
-		address := fmt.Sprintf(":%d", randomPort)
-		listener, err := net.Listen("tcp", address)
-		if err != nil {
-			continue
-		}
-		defer listener.Close()
-		found = true
+	localPort, err := freeLocalPort()
+	if err != nil {
+		return starlark.None, fmt.Errorf("could not find a free local port: %w", err)
 	}
 
 	tunnelConfigDict := starlark.StringDict{
 		"namespace":   starlark.String(namespace),
+		"service":     starlark.String(service),
 		"pod_name":    starlark.String(pods.Items[0].Name),
 		"target_port": starlark.MakeInt(targetPort),
-		"local_port":  starlark.MakeInt(randomPort),
+		"local_port":  starlark.MakeInt(localPort),
 	}
 
 	return starlarkstruct.FromStringDict(starlark.String(identifiers.kubePortForwardConfig), tunnelConfigDict), nil
 }
+
+// freeLocalPort returns a port that was free on localhost
+func freeLocalPort() (int, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:0", TunnelHost))
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// tunnelRegistry tracks the tunnels started by a script, by local port, to share them
+// between the functions using the same tunnel_config and stop them when the script ends
+type tunnelRegistry struct {
+	mu      sync.Mutex
+	tunnels map[int]*k8s.Tunnel
+}
+
+func newTunnelRegistry() *tunnelRegistry {
+	return &tunnelRegistry{tunnels: make(map[int]*k8s.Tunnel)}
+}
+
+// stopAll stops the tunnels of the registry
+func (r *tunnelRegistry) stopAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for port, tunnel := range r.tunnels {
+		logrus.Debugf("stopping tunnel localhost:%d", port)
+		tunnel.Stop()
+		delete(r.tunnels, port)
+	}
+}
+
+// startTunnel returns the running tunnel of tunnelConfig, starting it on the
+// cluster of kubeConfig if the script did not start it yet
+func startTunnel(ctx context.Context, thread *starlark.Thread, tunnelConfig, kubeConfig *starlarkstruct.Struct) (*k8s.Tunnel, error) {
+	registry, ok := thread.Local(identifiers.tunnels).(*tunnelRegistry)
+	if !ok || registry == nil {
+		return nil, errors.New("tunnel registry not found")
+	}
+
+	opts := k8s.TunnelOptions{}
+	for attr, dest := range map[string]*string{"namespace": &opts.Namespace, "service": &opts.Service, "pod_name": &opts.Pod} {
+		if val, err := tunnelConfig.Attr(attr); err == nil {
+			if str, ok := val.(starlark.String); ok {
+				*dest = string(str)
+			}
+		}
+	}
+	for attr, dest := range map[string]*int{"local_port": &opts.LocalPort, "target_port": &opts.TargetPort} {
+		val, err := tunnelConfig.Attr(attr)
+		if err != nil {
+			return nil, fmt.Errorf("tunnel_config.%s: %w", attr, err)
+		}
+		port, ok := val.(starlark.Int)
+		if !ok {
+			return nil, fmt.Errorf("tunnel_config.%s: unexpected type %s", attr, val.Type())
+		}
+		*dest = int(port.BigInt().Int64())
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if tunnel, ok := registry.tunnels[opts.LocalPort]; ok {
+		return tunnel, nil
+	}
+
+	kubeConfigOpts, err := getKubeConfigOptionsFromStruct(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig: %w", err)
+	}
+	// the tunnel runs on the current context, the context of kubeConfig may name the tunneled cluster
+	kubeConfigOpts.Context = ""
+	client, err := k8s.NewForOptions(kubeConfigOpts)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize tunnel client: %w", err)
+	}
+	logrus.Infof("starting tunnel localhost:%d", opts.LocalPort)
+	tunnel, err := client.StartTunnel(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	registry.tunnels[opts.LocalPort] = tunnel
+	return tunnel, nil
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func TestStartTunnelShared(t *testing.T) {
+	port, err := freeLocalPort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tunnelConfig := starlarkstruct.FromStringDict(starlark.String(identifiers.kubePortForwardConfig), starlark.StringDict{
+		"namespace":   starlark.String("kcp"),
+		"service":     starlark.String("kcp"),
+		"pod_name":    starlark.String("kcp-0"),
+		"target_port": starlark.MakeInt(6443),
+		"local_port":  starlark.MakeInt(port),
+	})
+
+	kubeConfig := starlarkstruct.FromStringDict(starlark.String(identifiers.kubeCfg), starlark.StringDict{
+		"path": starlark.String("/tmp/kube.conf"),
+	})
+
+	thread := &starlark.Thread{}
+	if _, err := startTunnel(context.TODO(), thread, tunnelConfig, kubeConfig); err == nil {
+		t.Fatal("expected error without tunnel registry")
+	}
+
+	// a tunnel already started for the local port is returned without connecting
+	registry := newTunnelRegistry()
+	running := &k8s.Tunnel{}
+	registry.tunnels[port] = running
+	thread.SetLocal(identifiers.tunnels, registry)
+
+	tunnel, err := startTunnel(context.TODO(), thread, tunnelConfig, kubeConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tunnel != running {
+		t.Error("expected running tunnel")
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// KubeWatchFn is a starlark built-in that records the changes of Kubernetes objects, and
+// follows the logs of the pods watched, during a time window. It returns a struct with the
+// files written, one JSON Lines file per resource, and the error message, if any.
+// Starlark format: kube_watch(kinds=["pods", "events"], duration="10m" [, namespaces=["default"], follow_logs=True, workdir=path, kube_config=kube_config()])
+func KubeWatchFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var kinds, namespaces *starlark.List
+	var duration, workdir string
+	var kubeConfig *starlarkstruct.Struct
+	followLogs := true
+
+	if err := starlark.UnpackArgs(
+		identifiers.kubeWatch, args, kwargs,
+		"kinds", &kinds,
+		"duration", &duration,
+		"namespaces?", &namespaces,
+		"follow_logs?", &followLogs,
+		"workdir?", &workdir,
+		"kube_config?", &kubeConfig,
+	); err != nil {
+		return starlark.None, fmt.Errorf("failed to read args: %w", err)
+	}
+
+	window, err := time.ParseDuration(duration)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: invalid duration: %w", identifiers.kubeWatch, err)
+	}
+
+	if len(workdir) == 0 {
+		//Defaults to crashd_config.workdir or /tmp/crashd
+		if dir, err := getWorkdirFromThread(thread); err == nil {
+			workdir = dir
+		}
+	}
+
+	ctx, ok := thread.Local(identifiers.scriptCtx).(context.Context)
+	if !ok || ctx == nil {
+		return starlark.None, errors.New("script context not found")
+	}
+
+	if kubeConfig == nil {
+		kubeConfig = thread.Local(identifiers.kubeCfg).(*starlarkstruct.Struct)
+	}
+	client, err := newClientFromKubeConfig(kubeConfig)
+	if err != nil {
+		return starlark.None, fmt.Errorf("could not initialize watch client: %w", err)
+	}
+
+	params := k8s.WatchParams{
+		Kinds:      toSlice(kinds),
+		Namespaces: toSlice(namespaces),
+		Duration:   window,
+		FollowLogs: followLogs,
+	}
+	files, watchErr := client.Watch(ctx, params, trimQuotes(workdir), getBudgetFromThread(thread, identifiers.kubeWatch))
+
+	var fileList []starlark.Value
+	for _, file := range files {
+		fileList = append(fileList, starlark.String(file))
+	}
+	return starlarkstruct.FromStringDict(
+		starlark.String(identifiers.kubeWatch),
+		starlark.StringDict{
+			"files": starlark.NewList(fileList),
+			"error": func() starlark.String {
+				if watchErr != nil {
+					return starlark.String(watchErr.Error())
+				}
+				return ""
+			}(),
+		}), nil
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+)
+
+func TestKubeWatchScript(t *testing.T) {
+	k8sconfig := testSupport.KindKubeConfigFile()
+	workdir := t.TempDir()
+	script := fmt.Sprintf(`
+crashd_config(workdir="%s")
+set_defaults(kube_config(path="%s"))
+kube_data = kube_watch(kinds=["pods", "nodes"], namespaces=["kube-system"], duration="3s")
+`, workdir, k8sconfig)
+
+	executor := New()
+	if err := executor.Exec("test.kube.watch", strings.NewReader(script)); err != nil {
+		t.Fatalf("failed to exec: %s", err)
+	}
+	data, ok := executor.result["kube_data"].(*starlarkstruct.Struct)
+	if !ok {
+		t.Fatal("script result is not a struct")
+	}
+
+	errVal, err := data.Attr("error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resultErr := errVal.(starlark.String).GoString(); resultErr != "" {
+		t.Fatalf("starlark func failed: %s", resultErr)
+	}
+	filesVal, err := data.Attr("files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files := filesVal.(*starlark.List); files.Len() != 2 {
+		t.Errorf("unexpected files: %s", files)
+	}
+
+	pods, err := os.ReadFile(filepath.Join(workdir, k8s.WatchDirname, "core_v1", "pods.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(pods), `"type":"ADDED"`) {
+		t.Errorf("initial pods not recorded:\n%s", pods)
+	}
+	if _, err := os.Stat(filepath.Join(workdir, k8s.WatchDirname, "logs", "kube-system")); err != nil {
+		t.Errorf("pod logs not followed: %s", err)
+	}
+}
+
+func TestKubeWatchArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{
+			name:   "no duration",
+			script: `kube_watch(kinds=["pods"])`,
+			err:    "missing argument for duration",
+		},
+		{
+			name:   "invalid duration",
+			script: `kube_watch(kinds=["pods"], duration="10 minutes")`,
+			err:    "invalid duration",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := New().Exec("test.kube.watch", strings.NewReader(test.script))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
+	"github.com/vmware-tanzu/crash-diagnostics/nodeinfo"
+	"github.com/vmware-tanzu/crash-diagnostics/ssh"
+)
+
+// nodeInfoFunc is a built-in starlark function that runs the commands of the node information
+// collectors (kubelet, containerd, network, disk, kernel, certs) on the host resources, over
+// ssh. The output of each command is saved in <workdir>/<host>/node_info/<collector>/<command>.txt;
+// commands whose program or file is missing on a host save why they were skipped.
+// It returns a list of command results, one per host, with the directory of the host as result.
+// Starlark format: node_info([resources=resources][,collectors=["kubelet","disk"]][,workdir=path][,compress=False])
+func nodeInfoFunc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var workdir string
+	var resources, collectorList *starlark.List
+	var compress bool
+
+	if err := starlark.UnpackArgs(
+		identifiers.nodeInfo, args, kwargs,
+		"resources?", &resources,
+		"collectors?", &collectorList,
+		"workdir?", &workdir,
+		"compress?", &compress,
+	); err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.nodeInfo, err)
+	}
+
+	var names []string
+	if collectorList != nil {
+		for i := 0; i < collectorList.Len(); i++ {
+			name, ok := collectorList.Index(i).(starlark.String)
+			if !ok {
+				return starlark.None, fmt.Errorf("%s: collectors must be strings", identifiers.nodeInfo)
+			}
+			names = append(names, string(name))
+		}
+	}
+	collectors, err := nodeinfo.Get(names...)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.nodeInfo, err)
+	}
+
+	if len(workdir) == 0 {
+		if dir, err := getWorkdirFromThread(thread); err == nil {
+			workdir = dir
+		}
+	}
+	if len(workdir) == 0 {
+		workdir = defaults.workdir
+	}
+
+	if resources == nil {
+		res, err := getResourcesFromThread(thread)
+		if err != nil {
+			return starlark.None, fmt.Errorf("%s: %s", identifiers.nodeInfo, err)
+		}
+		resources = res
+	}
+
+	var agent ssh.Agent
+	if agentVal := thread.Local(identifiers.sshAgent); agentVal != nil {
+		var ok bool
+		agent, ok = agentVal.(ssh.Agent)
+		if !ok {
+			return starlark.None, errors.New("unable to fetch ssh-agent")
+		}
+	}
+
+	results, err := execNodeInfo(collectors, workdir, compress, agent, resources, getBudgetFromThread(thread, identifiers.nodeInfo))
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.nodeInfo, err)
+	}
+
+	resultList := make([]starlark.Value, len(results))
+	for i, result := range results {
+		resultList[i] = result.toStarlarkStruct()
+	}
+	return starlark.NewList(resultList), nil
+}
+
+func execNodeInfo(collectors []nodeinfo.Collector, rootPath string, compress bool, agent ssh.Agent, resources *starlark.List, limit *budget.Budget) ([]commandResult, error) {
+	resultsChannel := make(chan commandResult)
+	for i := 0; i < resources.Len(); i++ {
+		go func(i int) {
+			res, ok := resources.Index(i).(*starlarkstruct.Struct)
+			if !ok {
+				resultsChannel <- commandResult{err: errors.New("unexpected resource type")}
+				return
+			}
+			kind, transport, host, err := hostResourceAttrs(res)
+			if err != nil {
+				resultsChannel <- commandResult{err: err}
+				return
+			}
+			if kind != identifiers.hostResource || transport != "ssh" {
+				logrus.Errorf("%s: unsupported resource: kind=%s, transport=%s", identifiers.nodeInfo, kind, transport)
+				resultsChannel <- commandResult{resource: host, err: fmt.Errorf("unsupported resource: kind=%s, transport=%s", kind, transport)}
+				return
+			}
+			resultsChannel <- execNodeInfoSSH(collectors, host, filepath.Join(rootPath, sanitizeStr(host)), compress, agent, res, limit)
+		}(i)
+	}
+
+	results := make([]commandResult, resources.Len())
+	for i := range results {
+		results[i] = <-resultsChannel
+	}
+	return results, nil
+}
+
+// execNodeInfoSSH runs the commands of collectors on host, saving their outputs in rootDir/node_info
+func execNodeInfoSSH(collectors []nodeinfo.Collector, host, rootDir string, compress bool, agent ssh.Agent, res *starlarkstruct.Struct, limit *budget.Budget) commandResult {
+	dir := filepath.Join(rootDir, nodeinfo.Dirname)
+	sshCfg := starlarkstruct.FromKeywords(starlarkstruct.Default, makeDefaultSSHConfig())
+	if val, err := res.Attr(identifiers.sshCfg); err == nil {
+		if cfg, ok := val.(*starlarkstruct.Struct); ok {
+			sshCfg = cfg
+		}
+	}
+	args, err := getSSHArgsFromCfg(sshCfg)
+	if err != nil {
+		return commandResult{resource: host, err: err}
+	}
+	args.Host = host
+
+	var errs []error
+	for _, collector := range collectors {
+		collectorDir := filepath.Join(dir, collector.Name)
+		if err := os.MkdirAll(collectorDir, 0744); err != nil {
+			return commandResult{resource: host, result: dir, err: err}
+		}
+		for _, cmd := range collector.Commands {
+			filePath := filepath.Join(collectorDir, captureFileName(cmd.Cmd, cmd.FileName(), compress))
+			logrus.Debugf("%s: capturing output of [cmd=%s] => [%s] from %s using ssh", identifiers.nodeInfo, cmd.Cmd, filePath, host)
+
+			var runErr error
+			if err := captureCmd(filePath, "", false, compress, limit, func(w io.Writer) error {
+				runErr = ssh.RunStream(args, agent, ssh.EncodeScript(cmd.Script()), w)
+				return runErr
+			}, capturedFailure(cmd.Cmd)); err != nil && runErr == nil {
+				runErr = err
+			}
+			if runErr != nil {
+				// a failed command is saved with its output, the next commands may succeed
+				logrus.Warnf("%s: %s: %s/%s failed: %s", identifiers.nodeInfo, host, collector.Name, cmd.Name, runErr)
+				errs = append(errs, fmt.Errorf("%s/%s: %w", collector.Name, cmd.Name, runErr))
+			}
+		}
+	}
+	return commandResult{resource: host, result: dir, err: errors.Join(errs...)}
+}
+
+// hostResourceAttrs returns the kind, transport, and host of host resource res
+func hostResourceAttrs(res *starlarkstruct.Struct) (kind, transport, host string, err error) {
+	for name, attr := range map[string]*string{"kind": &kind, "transport": &transport, "host": &host} {
+		val, err := res.Attr(name)
+		if err != nil {
+			return "", "", "", fmt.Errorf("resource.%s: %s", name, err)
+		}
+		str, ok := val.(starlark.String)
+		if !ok {
+			return "", "", "", fmt.Errorf("resource.%s: unexpected type %s", name, val.Type())
+		}
+		*attr = string(str)
+	}
+	return kind, transport, host, nil
+}
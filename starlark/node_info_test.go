@@ -0,0 +1,71 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/vmware-tanzu/crash-diagnostics/nodeinfo"
+)
+
+func TestNodeInfoArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{
+			name:   "unknown collector",
+			script: `node_info(collectors=["gpu"], resources=[])`,
+			err:    `unknown collector "gpu"`,
+		},
+		{
+			name:   "invalid collector",
+			script: `node_info(collectors=[1], resources=[])`,
+			err:    "collectors must be strings",
+		},
+		{
+			name:   "no resources",
+			script: `node_info(collectors=["disk"])`,
+			err:    "resources not found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := New().Exec("test.node.info", strings.NewReader(test.script))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestNodeInfoUnsupportedResource(t *testing.T) {
+	collectors, err := nodeinfo.Get("disk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources := starlark.NewList([]starlark.Value{
+		starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+			"kind":      starlark.String(identifiers.hostResource),
+			"transport": starlark.String(kubeDebugTransport),
+			"host":      starlark.String("node-0"),
+		}),
+	})
+	results, err := execNodeInfo(collectors, t.TempDir(), false, nil, resources, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].resource != "node-0" {
+		t.Fatalf("unexpected results: %v", results)
+	}
+	if results[0].err == nil || !strings.Contains(results[0].err.Error(), "unsupported resource") {
+		t.Errorf("unexpected err: %v", results[0].err)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"errors"
+	"fmt"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// predicatePrevious is the name of the global values of the previous script in a predicate
+const predicatePrevious = "previous"
+
+// CheckPredicate returns an error if expr is not a valid Starlark expression
+func CheckPredicate(expr string) error {
+	if _, err := syntax.ParseExpr("predicate", expr, 0); err != nil {
+		return fmt.Errorf("invalid predicate: %w", err)
+	}
+	return nil
+}
+
+// Result returns the global values defined by the script executed by Exec
+func (e *Executor) Result() starlark.StringDict {
+	return e.result
+}
+
+// EvalPredicate returns the truth of Starlark expression expr, evaluated with the builtins
+// and the global values of the script executed by e. The global values of the script
+// executed by previous are bound to `previous` as a struct, or None if previous is nil.
+func (e *Executor) EvalPredicate(expr string, previous *Executor) (bool, error) {
+	env := make(starlark.StringDict, len(e.predecs)+len(e.result)+1)
+	for name, val := range e.predecs {
+		env[name] = val
+	}
+	for name, val := range e.result {
+		env[name] = val
+	}
+	env[predicatePrevious] = starlark.None
+	if previous != nil {
+		env[predicatePrevious] = starlarkstruct.FromStringDict(starlark.String(predicatePrevious), previous.result)
+	}
+
+	val, err := starlark.EvalOptions(syntax.LegacyFileOptions(), e.thread, "predicate", expr, env)
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return false, errors.New(evalErr.Backtrace())
+		}
+		return false, err
+	}
+	return bool(val.Truth()), nil
+}
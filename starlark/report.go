@@ -0,0 +1,59 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"fmt"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/vmware-tanzu/crash-diagnostics/budget"
+	"github.com/vmware-tanzu/crash-diagnostics/report"
+)
+
+// ReportFn is the Starlark built-in that generates an HTML summary (index.html) of the
+// files collected in the working directory. It returns a struct containing the path of
+// the report file and an error message, if any.
+// Starlark format: report([workdir=<path>, title=<title>])
+func ReportFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var workdir, title string
+
+	if err := starlark.UnpackArgs(
+		identifiers.report, args, kwargs,
+		"workdir?", &workdir,
+		"title?", &title,
+	); err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.report, err)
+	}
+
+	if len(workdir) == 0 {
+		dir, err := getWorkdirFromThread(thread)
+		if err != nil {
+			return starlark.None, err
+		}
+		workdir = dir
+	}
+
+	opts := report.Options{Title: title}
+	if uploads, ok := thread.Local(identifiers.uploads).(*uploadLog); ok {
+		opts.Uploads = uploads.list()
+	}
+	if limit, ok := thread.Local(identifiers.budget).(*budget.Budget); ok {
+		opts.Truncations = limit.Truncations()
+	}
+	file, err := report.Generate(workdir, opts)
+
+	return starlarkstruct.FromStringDict(
+		starlark.String(identifiers.report),
+		starlark.StringDict{
+			"file": starlark.String(file),
+			"error": func() starlark.String {
+				if err != nil {
+					return starlark.String(err.Error())
+				}
+				return ""
+			}(),
+		}), nil
+}
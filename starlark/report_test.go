@@ -0,0 +1,73 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func TestReportScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		script func(workdir string) string
+		eval   func(t *testing.T, workdir string, result *starlarkstruct.Struct)
+	}{
+		{
+			name: "report with title",
+			script: func(workdir string) string {
+				return fmt.Sprintf(`result = report(workdir=%q, title="Cluster prod-1")`, workdir)
+			},
+			eval: func(t *testing.T, workdir string, result *starlarkstruct.Struct) {
+				if errVal, _ := result.Attr("error"); errVal.(starlark.String) != "" {
+					t.Fatalf("unexpected error: %s", errVal)
+				}
+				file, _ := result.Attr("file")
+				expected := filepath.Join(workdir, "index.html")
+				if string(file.(starlark.String)) != expected {
+					t.Errorf("unexpected report file: %s", file)
+				}
+				data, err := os.ReadFile(expected)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(string(data), "<title>Cluster prod-1</title>") || !strings.Contains(string(data), "node-0") {
+					t.Errorf("unexpected report content:\n%s", data)
+				}
+			},
+		},
+		{
+			name: "report missing workdir",
+			script: func(workdir string) string {
+				return fmt.Sprintf(`result = report(workdir=%q)`, filepath.Join(workdir, "missing"))
+			},
+			eval: func(t *testing.T, workdir string, result *starlarkstruct.Struct) {
+				if errVal, _ := result.Attr("error"); errVal.(starlark.String) == "" {
+					t.Error("expected error for missing workdir")
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			workdir := makeAnalyzeWorkdir(t)
+			exe := New()
+			if err := exe.Exec("test.star", strings.NewReader(test.script(workdir))); err != nil {
+				t.Fatal(err)
+			}
+			result, ok := exe.result["result"].(*starlarkstruct.Struct)
+			if !ok {
+				t.Fatalf("report() should return a struct, got %T", exe.result["result"])
+			}
+			test.eval(t, workdir, result)
+		})
+	}
+}
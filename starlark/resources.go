@@ -66,7 +66,7 @@ func enum(provider *starlarkstruct.Struct) (*starlark.List, error) {
 	kind := trimQuotes(kindVal.String())
 
 	switch kind {
-	case identifiers.hostListProvider, identifiers.kubeNodesProvider, identifiers.capvProvider, identifiers.capaProvider:
+	case identifiers.hostListProvider, identifiers.kubeNodesProvider, identifiers.capvProvider, identifiers.capaProvider, identifiers.capiProvider:
 		hosts, err := provider.Attr("hosts")
 		if err != nil {
 			return nil, fmt.Errorf("hosts not found in %s", identifiers.hostListProvider)
@@ -97,6 +97,26 @@ func enum(provider *starlarkstruct.Struct) (*starlark.List, error) {
 			}
 			resources = append(resources, starlarkstruct.FromStringDict(starlark.String(identifiers.hostResource), dict))
 		}
+	case identifiers.kubeNodeDebugProvider:
+		hosts, err := provider.Attr("hosts")
+		if err != nil {
+			return nil, fmt.Errorf("hosts not found in %s", kind)
+		}
+		hostList, ok := hosts.(*starlark.List)
+		if !ok {
+			return nil, fmt.Errorf("%s: unexpected type for hosts: %T", kind, hosts)
+		}
+
+		// resources carry the debug pod settings of the provider
+		for i := 0; i < hostList.Len(); i++ {
+			dict := starlark.StringDict{}
+			provider.ToStringDict(dict)
+			delete(dict, "hosts")
+			dict["kind"] = starlark.String(identifiers.hostResource)
+			dict["provider"] = starlark.String(kind)
+			dict["host"] = hostList.Index(i)
+			resources = append(resources, starlarkstruct.FromStringDict(starlark.String(identifiers.hostResource), dict))
+		}
 	}
 
 	return starlark.NewList(resources), nil
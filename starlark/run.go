@@ -4,6 +4,7 @@
 package starlark
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -73,7 +74,12 @@ func runFunc(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple,
 		}
 	}
 
-	results, err := execRun(cmdStr, agent, resources)
+	ctx, ok := thread.Local(identifiers.scriptCtx).(context.Context)
+	if !ok || ctx == nil {
+		return starlark.None, errors.New("script context not found")
+	}
+
+	results, err := execRun(ctx, cmdStr, agent, resources)
 	if err != nil {
 		return starlark.None, err
 	}
@@ -90,7 +96,7 @@ func runFunc(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple,
 	return starlark.NewList(resultList), nil
 }
 
-func execRun(cmdStr string, agent ssh.Agent, resources *starlark.List) ([]commandResult, error) {
+func execRun(ctx context.Context, cmdStr string, agent ssh.Agent, resources *starlark.List) ([]commandResult, error) {
 	if resources == nil {
 		return nil, fmt.Errorf("%s: missing resources", identifiers.run)
 	}
@@ -124,6 +130,17 @@ func execRun(cmdStr string, agent ssh.Agent, resources *starlark.List) ([]comman
 				continue
 			}
 			results = append(results, result)
+		case string(kind) == identifiers.hostResource && string(transport) == kubeDebugTransport:
+			hVal, err := res.Attr("host")
+			if err != nil {
+				return nil, fmt.Errorf("%s: resource.host: %s", identifiers.run, err)
+			}
+			result, err := execRunKubeDebug(ctx, string(hVal.(starlark.String)), cmdStr, res)
+			if err != nil {
+				logrus.Error(err)
+				continue
+			}
+			results = append(results, result)
 		default:
 			logrus.Errorf("%s: unsupported or invalid resource kind: %s", identifiers.run, kind)
 			continue
@@ -0,0 +1,109 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"fmt"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/vmware-tanzu/crash-diagnostics/uploader"
+)
+
+// S3ConfigFn is the built-in starlark function that configures the access to an S3-compatible
+// object storage used by upload() and archive(destination=...). Empty credentials, region, and
+// endpoint are read from the AWS_* environment variables. The result is also added to the thread
+// as the default configuration of the functions that follow.
+// Starlark format: s3_config([endpoint, region, access_key, secret_key, session_token, sse, kms_key_id, path_style, part_size_mb])
+func S3ConfigFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var endpoint, region, accessKey, secretKey, sessionToken, sse, kmsKeyID string
+	var partSizeMB int
+	var pathStyle starlark.Value = starlark.None
+
+	if err := starlark.UnpackArgs(
+		identifiers.s3Cfg, args, kwargs,
+		"endpoint?", &endpoint,
+		"region?", &region,
+		"access_key?", &accessKey,
+		"secret_key?", &secretKey,
+		"session_token?", &sessionToken,
+		"sse?", &sse,
+		"kms_key_id?", &kmsKeyID,
+		"path_style?", &pathStyle,
+		"part_size_mb?", &partSizeMB,
+	); err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.s3Cfg, err)
+	}
+
+	if _, ok := pathStyle.(starlark.Bool); !ok && pathStyle != starlark.None {
+		return starlark.None, fmt.Errorf("%s: path_style must be a bool", identifiers.s3Cfg)
+	}
+	if partSizeMB < 0 || (partSizeMB > 0 && int64(partSizeMB)<<20 < uploader.MinPartSize) {
+		return starlark.None, fmt.Errorf("%s: part_size_mb must be at least %d", identifiers.s3Cfg, uploader.MinPartSize>>20)
+	}
+
+	conf := starlarkstruct.FromStringDict(starlark.String(identifiers.s3Cfg), starlark.StringDict{
+		"endpoint":      starlark.String(endpoint),
+		"region":        starlark.String(region),
+		"access_key":    starlark.String(accessKey),
+		"secret_key":    starlark.String(secretKey),
+		"session_token": starlark.String(sessionToken),
+		"sse":           starlark.String(sse),
+		"kms_key_id":    starlark.String(kmsKeyID),
+		"path_style":    pathStyle,
+		"part_size_mb":  starlark.MakeInt(partSizeMB),
+	})
+
+	// validate the options now rather than at the first upload
+	if _, err := newS3ClientFromConfig(conf); err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.s3Cfg, err)
+	}
+
+	thread.SetLocal(identifiers.s3Cfg, conf)
+	return conf, nil
+}
+
+// getS3OptionsFromStruct returns the object storage options of the s3_config struct provided
+func getS3OptionsFromStruct(conf *starlarkstruct.Struct) (uploader.S3Options, error) {
+	opts := uploader.S3Options{}
+	for attr, dest := range map[string]*string{
+		"endpoint":      &opts.Endpoint,
+		"region":        &opts.Region,
+		"access_key":    &opts.AccessKey,
+		"secret_key":    &opts.SecretKey,
+		"session_token": &opts.SessionToken,
+		"sse":           &opts.SSE,
+		"kms_key_id":    &opts.KMSKeyID,
+	} {
+		val, err := conf.Attr(attr)
+		if err != nil {
+			return uploader.S3Options{}, fmt.Errorf("%s.%s: %w", identifiers.s3Cfg, attr, err)
+		}
+		if str, ok := val.(starlark.String); ok {
+			*dest = string(str)
+		}
+	}
+
+	if val, err := conf.Attr("path_style"); err == nil {
+		if b, ok := val.(starlark.Bool); ok {
+			pathStyle := bool(b)
+			opts.PathStyle = &pathStyle
+		}
+	}
+	if val, err := conf.Attr("part_size_mb"); err == nil {
+		if size, ok := val.(starlark.Int); ok {
+			opts.PartSize = size.BigInt().Int64() << 20
+		}
+	}
+	return opts, nil
+}
+
+func newS3ClientFromConfig(conf *starlarkstruct.Struct) (*uploader.S3Client, error) {
+	opts, err := getS3OptionsFromStruct(conf)
+	if err != nil {
+		return nil, err
+	}
+	return uploader.NewS3Client(opts)
+}
@@ -57,11 +57,31 @@ func (e *Executor) Preload(name string, source io.Reader) error {
 }
 
 func (e *Executor) Exec(name string, source io.Reader) error {
+	return e.ExecContext(context.Background(), name, source)
+}
+
+// ExecContext executes the script like Exec, with ctx as the context of its built-ins.
+// The script is cancelled when ctx is done.
+func (e *Executor) ExecContext(ctx context.Context, name string, source io.Reader) error {
 	if err := setupLocalDefaults(e.thread); err != nil {
 		return fmt.Errorf("failed to setup defaults: %s", err)
 	}
+	e.thread.SetLocal(identifiers.scriptCtx, ctx)
 	e.thread.SetLocal(identifiers.scriptName, name)
 
+	// stop the script, between its built-in calls, when ctx is done
+	stop := context.AfterFunc(ctx, func() { e.thread.Cancel(ctx.Err().Error()) })
+	defer stop()
+
+	// stop the tunnels started by the script, even if it failed
+	if registry, ok := e.thread.Local(identifiers.tunnels).(*tunnelRegistry); ok {
+		defer registry.stopAll()
+	}
+	// remove the temporary files holding credentials, i.e. workload cluster kubeconfigs
+	if files, ok := e.thread.Local(identifiers.tempFiles).(*tempFiles); ok {
+		defer files.removeAll()
+	}
+
 	result, err := starlark.ExecFileOptions(syntax.LegacyFileOptions(), e.thread, name, source, e.predecs)
 	if err != nil {
 		if evalErr, ok := err.(*starlark.EvalError); ok {
@@ -100,6 +120,15 @@ func setupLocalDefaults(thread *starlark.Thread) error {
 	// add default logger
 	addDefaultLogger(thread)
 
+	// add registry of the port-forward tunnels
+	thread.SetLocal(identifiers.tunnels, newTunnelRegistry())
+
+	// add list of the temporary files removed after the script
+	thread.SetLocal(identifiers.tempFiles, &tempFiles{})
+
+	// add log of the uploaded files
+	thread.SetLocal(identifiers.uploads, &uploadLog{})
+
 	if err := addDefaultCrashdConf(thread); err != nil {
 		return err
 	}
@@ -132,19 +161,33 @@ func newPredeclareds(restrictedMode []bool) starlark.StringDict {
 		identifiers.progAvailLocal:        starlark.NewBuiltin(identifiers.progAvailLocal, progAvailLocalFunc),
 		identifiers.capture:               starlark.NewBuiltin(identifiers.capture, captureFunc),
 		identifiers.captureLocal:          starlark.NewBuiltin(identifiers.capture, captureLocalFunc),
+		identifiers.nodeInfo:              starlark.NewBuiltin(identifiers.nodeInfo, nodeInfoFunc),
+		identifiers.certCheck:             starlark.NewBuiltin(identifiers.certCheck, CertCheckFn),
+		identifiers.etcdCapture:           starlark.NewBuiltin(identifiers.etcdCapture, EtcdCaptureFn),
 		identifiers.copyFrom:              starlark.NewBuiltin(identifiers.copyFrom, copyFromFunc),
 		identifiers.copyTo:                starlark.NewBuiltin(identifiers.copyTo, copyToFunc),
 		identifiers.kubeCfg:               starlark.NewBuiltin(identifiers.kubeCfg, KubeConfigFn),
+		identifiers.kubeConfigs:           starlark.NewBuiltin(identifiers.kubeConfigs, KubeConfigsFn),
+		identifiers.forEachCluster:        starlark.NewBuiltin(identifiers.forEachCluster, ForEachClusterFn),
 		identifiers.kubeCapture:           starlark.NewBuiltin(identifiers.kubeGet, KubeCaptureFn),
 		identifiers.kubeGet:               starlark.NewBuiltin(identifiers.kubeGet, KubeGetFn),
+		identifiers.kubeWatch:             starlark.NewBuiltin(identifiers.kubeWatch, KubeWatchFn),
 		identifiers.kubeExec:              starlark.NewBuiltin(identifiers.kubeExec, KubeExecFn),
+		identifiers.kubeCopyFrom:          starlark.NewBuiltin(identifiers.kubeCopyFrom, KubeCopyFromFn),
 		identifiers.kubeNodesProvider:     starlark.NewBuiltin(identifiers.kubeNodesProvider, KubeNodesProviderFn),
+		identifiers.kubeNodeDebugProvider: starlark.NewBuiltin(identifiers.kubeNodeDebugProvider, KubeNodeDebugProviderFn),
 		identifiers.capvProvider:          starlark.NewBuiltin(identifiers.capvProvider, CapvProviderFn),
 		identifiers.capaProvider:          starlark.NewBuiltin(identifiers.capaProvider, CapaProviderFn),
+		identifiers.capiProvider:          starlark.NewBuiltin(identifiers.capiProvider, CapiProviderFn),
 		identifiers.kcpProvider:           starlark.NewBuiltin(identifiers.kcpProvider, KcpProviderFn),
 		identifiers.setDefaults:           starlark.NewBuiltin(identifiers.setDefaults, SetDefaultsFunc),
 		identifiers.kubePortForwardConfig: starlark.NewBuiltin(identifiers.kubePortForwardConfig, KubePortForwardrFn),
 		identifiers.log:                   starlark.NewBuiltin(identifiers.log, logFunc),
+		identifiers.analyze:               starlark.NewBuiltin(identifiers.analyze, AnalyzeFn),
+		identifiers.analyzerRule:          starlark.NewBuiltin(identifiers.analyzerRule, AnalyzerRuleFn),
+		identifiers.report:                starlark.NewBuiltin(identifiers.report, ReportFn),
+		identifiers.s3Cfg:                 starlark.NewBuiltin(identifiers.s3Cfg, S3ConfigFn),
+		identifiers.upload:                starlark.NewBuiltin(identifiers.upload, UploadFn),
 	}
 
 	if len(restrictedMode) > 0 && restrictedMode[0] {
@@ -4,9 +4,25 @@
 package starlark
 
 import (
+	"context"
+	"strings"
 	"testing"
 )
 
 func TestExec(t *testing.T) {
 
 }
+
+func TestExecContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := New().ExecContext(ctx, "test.exec.cancelled", strings.NewReader(`
+def loop():
+    for i in range(100000000):
+        pass
+loop()
+`))
+	if err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Errorf("expected cancelled script, got %v", err)
+	}
+}
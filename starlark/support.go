@@ -42,24 +42,45 @@ var (
 		progAvailLocal   string
 		capture          string
 		captureLocal     string
+		nodeInfo         string
+		certCheck        string
+		etcdCapture      string
 		copyFrom         string
 		copyTo           string
 		archive          string
 		os               string
 		setDefaults      string
 		log              string
+		analyze          string
+		analyzerRule     string
+		report           string
 		logPath          string
 
 		kubeCapture           string
+		kubeConfigs           string
+		forEachCluster        string
 		kubeGet               string
+		kubeWatch             string
 		kubeExec              string
+		kubeCopyFrom          string
 		kubeNodesProvider     string
+		kubeNodeDebugProvider string
 		capvProvider          string
 		capaProvider          string
+		capiProvider          string
 		kubePortForwardConfig string
 		kcpProvider           string
 
-		sshAgent string
+		s3Cfg  string
+		upload string
+
+		sshAgent  string
+		tunnels   string
+		uploads   string
+		tempFiles string
+		budget    string
+
+		incremental string
 	}{
 		scriptCtx: "script_context",
 
@@ -83,23 +104,42 @@ var (
 		progAvailLocal:   "prog_avail_local",
 		capture:          "capture",
 		captureLocal:     "capture_local",
+		nodeInfo:         "node_info",
+		certCheck:        "cert_check",
+		etcdCapture:      "etcd_capture",
 		copyFrom:         "copy_from",
 		copyTo:           "copy_to",
 		archive:          "archive",
 		os:               "os",
 		setDefaults:      "set_defaults",
 		log:              "log",
+		analyze:          "analyze",
+		analyzerRule:     "analyzer_rule",
+		report:           "report",
 		logPath:          "logPath",
 
 		kubeCapture:           "kube_capture",
+		kubeConfigs:           "kube_configs",
+		forEachCluster:        "for_each_cluster",
 		kubeGet:               "kube_get",
+		kubeWatch:             "kube_watch",
 		kubeExec:              "kube_exec",
+		kubeCopyFrom:          "kube_copy_from",
 		kubeNodesProvider:     "kube_nodes_provider",
+		kubeNodeDebugProvider: "kube_node_debug_provider",
 		capvProvider:          "capv_provider",
 		capaProvider:          "capa_provider",
+		capiProvider:          "capi_provider",
 		kubePortForwardConfig: "kube_port_forward_config",
 		kcpProvider:           "kcp_provider",
+		s3Cfg:                 "s3_config",
+		upload:                "upload",
 		sshAgent:              "crashd_ssh_agent",
+		tunnels:               "crashd_tunnels",
+		uploads:               "crashd_uploads",
+		tempFiles:             "crashd_temp_files",
+		budget:                "crashd_budget",
+		incremental:           "crashd_incremental",
 	}
 
 	defaults = struct {
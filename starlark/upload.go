@@ -0,0 +1,114 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/vmware-tanzu/crash-diagnostics/report"
+	"github.com/vmware-tanzu/crash-diagnostics/uploader"
+)
+
+// UploadFn is the built-in starlark function that uploads a file, i.e. the archive of the
+// script, to S3-compatible object storage under the destination prefix. It returns a struct
+// with the URL, size, and SHA-256 of the object, and an error message, if any.
+// Starlark format: upload(file, destination="s3://bucket/prefix", [s3_config])
+func UploadFn(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var file, destination string
+	var s3Config *starlarkstruct.Struct
+
+	if err := starlark.UnpackArgs(
+		identifiers.upload, args, kwargs,
+		"file", &file,
+		"destination", &destination,
+		"s3_config?", &s3Config,
+	); err != nil {
+		return starlark.None, fmt.Errorf("%s: %s", identifiers.upload, err)
+	}
+
+	ctx, ok := thread.Local(identifiers.scriptCtx).(context.Context)
+	if !ok || ctx == nil {
+		return starlark.None, errors.New("script context not found")
+	}
+
+	result, err := uploadFile(ctx, thread, file, destination, s3Config)
+	if err != nil {
+		return starlarkstruct.FromStringDict(starlark.String(identifiers.upload), starlark.StringDict{
+			"file":  starlark.String(file),
+			"url":   starlark.String(""),
+			"error": starlark.String(err.Error()),
+		}), nil
+	}
+
+	return starlarkstruct.FromStringDict(starlark.String(identifiers.upload), starlark.StringDict{
+		"file":   starlark.String(file),
+		"url":    starlark.String(result.URL),
+		"uri":    starlark.String(result.URI()),
+		"size":   starlark.MakeInt64(result.Size),
+		"sha256": starlark.String(result.SHA256),
+		"error":  starlark.String(""),
+	}), nil
+}
+
+// uploadFile uploads file to destination with s3Config, or the s3_config of the
+// thread if nil, and records the upload for the report of the script
+func uploadFile(ctx context.Context, thread *starlark.Thread, file, destination string, s3Config *starlarkstruct.Struct) (*uploader.Result, error) {
+	if !uploader.IsS3URL(destination) {
+		return nil, fmt.Errorf("unsupported destination %s: expecting s3://bucket/prefix", destination)
+	}
+	loc, err := uploader.ParseS3URL(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	if s3Config == nil {
+		s3Config, _ = thread.Local(identifiers.s3Cfg).(*starlarkstruct.Struct)
+	}
+	opts := uploader.S3Options{}
+	if s3Config != nil {
+		if opts, err = getS3OptionsFromStruct(s3Config); err != nil {
+			return nil, err
+		}
+	}
+	client, err := uploader.NewS3Client(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := client.Upload(ctx, file, loc)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("uploaded %s to %s", file, result.URL)
+
+	if uploads, ok := thread.Local(identifiers.uploads).(*uploadLog); ok {
+		uploads.add(report.Upload{File: file, URL: result.URL})
+	}
+	return result, nil
+}
+
+// uploadLog records the files uploaded by a script, listed by its report
+type uploadLog struct {
+	mu      sync.Mutex
+	uploads []report.Upload
+}
+
+func (l *uploadLog) add(upload report.Upload) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.uploads = append(l.uploads, upload)
+}
+
+func (l *uploadLog) list() []report.Upload {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]report.Upload(nil), l.uploads...)
+}
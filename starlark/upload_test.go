@@ -0,0 +1,124 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// newTestObjectStore returns a server storing the objects PUT, by path
+func newTestObjectStore(t *testing.T) (*httptest.Server, map[string][]byte) {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		objects[r.URL.Path] = body
+		mu.Unlock()
+		sum := md5.Sum(body)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	}))
+	t.Cleanup(server.Close)
+	return server, objects
+}
+
+func TestUploadScript(t *testing.T) {
+	server, objects := newTestObjectStore(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "crashd.tar.gz")
+	if err := os.WriteFile(file, []byte("archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	script := fmt.Sprintf(`
+s3_config(endpoint="%s", access_key="access", secret_key="secret")
+uploaded = upload(file="%s", destination="s3://diagnostics/cluster-a")
+failed = upload(file="%s", destination="/tmp/other")
+url = archive(output_file="%s", source_paths=["%s"], includeLogs=False, includeScript=False, destination="s3://diagnostics")
+`, server.URL, file, file, filepath.Join(dir, "out.tar.gz"), file)
+
+	exe := New()
+	if err := exe.Exec("test.star", strings.NewReader(script)); err != nil {
+		t.Fatal(err)
+	}
+
+	uploaded := exe.result["uploaded"].(*starlarkstruct.Struct)
+	if val, _ := uploaded.Attr("url"); val != starlark.String(server.URL+"/diagnostics/cluster-a/crashd.tar.gz") {
+		t.Errorf("unexpected url: %s", val)
+	}
+	if val, _ := uploaded.Attr("error"); val != starlark.String("") {
+		t.Errorf("unexpected error: %s", val)
+	}
+	if string(objects["/diagnostics/cluster-a/crashd.tar.gz"]) != "archive" {
+		t.Errorf("unexpected object: %q", objects["/diagnostics/cluster-a/crashd.tar.gz"])
+	}
+
+	failed := exe.result["failed"].(*starlarkstruct.Struct)
+	if val, _ := failed.Attr("error"); !strings.Contains(val.String(), "unsupported destination") {
+		t.Errorf("unexpected error: %s", val)
+	}
+
+	if url := exe.result["url"]; url != starlark.String(server.URL+"/diagnostics/out.tar.gz") {
+		t.Errorf("unexpected archive url: %s", url)
+	}
+	if _, ok := objects["/diagnostics/out.tar.gz"]; !ok {
+		t.Error("archive not uploaded")
+	}
+
+	uploads := exe.thread.Local(identifiers.uploads).(*uploadLog).list()
+	if len(uploads) != 2 {
+		t.Errorf("expected 2 uploads recorded, got %v", uploads)
+	}
+}
+
+func TestS3ConfigArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		kwargs []starlark.Tuple
+		err    string
+	}{
+		{
+			name:   "unsupported encryption",
+			kwargs: []starlark.Tuple{{starlark.String("access_key"), starlark.String("a")}, {starlark.String("secret_key"), starlark.String("s")}, {starlark.String("sse"), starlark.String("des")}},
+			err:    "unsupported server-side encryption",
+		},
+		{
+			name:   "small part size",
+			kwargs: []starlark.Tuple{{starlark.String("part_size_mb"), starlark.MakeInt(1)}},
+			err:    "part_size_mb must be at least 5",
+		},
+		{
+			name:   "kms key",
+			kwargs: []starlark.Tuple{{starlark.String("access_key"), starlark.String("a")}, {starlark.String("secret_key"), starlark.String("s")}, {starlark.String("sse"), starlark.String("aws:kms")}, {starlark.String("kms_key_id"), starlark.String("key")}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := S3ConfigFn(newTestThreadLocal(t), nil, nil, test.kwargs)
+			if test.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
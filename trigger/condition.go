@@ -0,0 +1,219 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package trigger
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+)
+
+// predicateObject is the name of the object tested by a Starlark predicate
+const predicateObject = "obj"
+
+// Condition selects the objects of a kind, with a field path or a Starlark predicate.
+//
+// A field path condition has the form <kind>.<path>(=|==|!=)<value>, where the
+// elements of the path are field names, i.e. pod.status.phase=Failed. The items of a
+// list field are selected by a field value, with <field>[<key>=<value>], i.e.
+// pod.status.containerStatuses[name=app].state.waiting.reason=CrashLoopBackOff;
+// without a selector, the condition is true if any item matches.
+//
+// A Starlark condition has the form <kind>: <expr>, where expr is evaluated with the
+// object bound to `obj`, i.e. pod: obj.status.containerStatuses[0].restartCount > 3.
+type Condition struct {
+	// Kind is a resource name or kind, i.e. "pods" or "Pod"
+	Kind string
+
+	expr      string
+	path      []pathElem
+	op        string
+	value     string
+	predicate string
+}
+
+// pathElem is a field of a field path, with an optional selector of list items
+type pathElem struct {
+	name     string
+	selector bool
+	key      string
+	value    string
+}
+
+// ParseCondition parses a field path or Starlark condition
+func ParseCondition(expr string) (*Condition, error) {
+	expr = strings.TrimSpace(expr)
+	i := strings.IndexAny(expr, ":.=![")
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid condition %q: missing kind", expr)
+	}
+	cond := &Condition{Kind: strings.TrimSpace(expr[:i]), expr: expr}
+
+	switch expr[i] {
+	case ':':
+		cond.predicate = strings.TrimSpace(expr[i+1:])
+		if cond.predicate == "" {
+			return nil, fmt.Errorf("invalid condition %q: missing predicate", expr)
+		}
+		if _, err := syntax.ParseExpr("trigger", cond.predicate, 0); err != nil {
+			return nil, fmt.Errorf("invalid condition %q: %w", expr, err)
+		}
+	case '.':
+		if err := cond.parsePath(expr[i+1:]); err != nil {
+			return nil, fmt.Errorf("invalid condition %q: %w", expr, err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid condition %q: missing field path", expr)
+	}
+	return cond, nil
+}
+
+// parsePath parses <path>(=|==|!=)<value>
+func (c *Condition) parsePath(expr string) error {
+	var fields []string
+	start, depth := 0, 0
+	for i := 0; i < len(expr); i++ {
+		switch expr[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '.':
+			if depth == 0 {
+				fields = append(fields, expr[start:i])
+				start = i + 1
+			}
+		case '=', '!':
+			if depth != 0 {
+				continue
+			}
+			fields = append(fields, expr[start:i])
+			switch {
+			case strings.HasPrefix(expr[i:], "=="), strings.HasPrefix(expr[i:], "!="):
+				c.op, c.value = expr[i:i+2], expr[i+2:]
+			case expr[i] == '=':
+				c.op, c.value = "=", expr[i+1:]
+			default:
+				return errors.New("missing operator")
+			}
+			c.value = unquote(strings.TrimSpace(c.value))
+			return c.parseFields(fields)
+		}
+	}
+	return errors.New("missing operator, expecting =, == or !=")
+}
+
+func (c *Condition) parseFields(fields []string) error {
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		elem := pathElem{name: field}
+		if open := strings.Index(field, "["); open >= 0 {
+			if !strings.HasSuffix(field, "]") {
+				return fmt.Errorf("invalid selector in %s", field)
+			}
+			key, value, ok := strings.Cut(field[open+1:len(field)-1], "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid selector in %s, expecting [key=value]", field)
+			}
+			elem = pathElem{name: field[:open], selector: true, key: key, value: unquote(value)}
+		}
+		if elem.name == "" {
+			return errors.New("empty field in path")
+		}
+		c.path = append(c.path, elem)
+	}
+	return nil
+}
+
+// String returns the condition as parsed
+func (c *Condition) String() string {
+	return c.expr
+}
+
+// Match returns true if obj matches the condition
+func (c *Condition) Match(obj *unstructured.Unstructured) (bool, error) {
+	if c.predicate != "" {
+		return c.matchPredicate(obj)
+	}
+
+	found := false
+	for _, val := range lookup(obj.Object, c.path) {
+		if fmt.Sprint(val) == c.value {
+			found = true
+			break
+		}
+	}
+	if c.op == "!=" {
+		return !found, nil
+	}
+	return found, nil
+}
+
+func (c *Condition) matchPredicate(obj *unstructured.Unstructured) (bool, error) {
+	env := starlark.StringDict{predicateObject: k8s.ObjectToStarlark(*obj)}
+	thread := &starlark.Thread{Name: "trigger"}
+	val, err := starlark.EvalOptions(syntax.LegacyFileOptions(), thread, "trigger", c.predicate, env)
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return false, errors.New(evalErr.Backtrace())
+		}
+		return false, err
+	}
+	return bool(val.Truth()), nil
+}
+
+// lookup returns the values found at path in val, the items of lists are searched in turn
+func lookup(val interface{}, path []pathElem) []interface{} {
+	if items, ok := val.([]interface{}); ok {
+		var values []interface{}
+		for _, item := range items {
+			values = append(values, lookup(item, path)...)
+		}
+		return values
+	}
+	if len(path) == 0 {
+		return []interface{}{val}
+	}
+
+	fields, ok := val.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	field, ok := fields[path[0].name]
+	if !ok {
+		return nil
+	}
+	if !path[0].selector {
+		return lookup(field, path[1:])
+	}
+
+	items, ok := field.([]interface{})
+	if !ok {
+		return nil
+	}
+	var values []interface{}
+	for _, item := range items {
+		itemFields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if key, ok := itemFields[path[0].key]; ok && fmt.Sprint(key) == path[0].value {
+			values = append(values, lookup(item, path[1:])...)
+		}
+	}
+	return values
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
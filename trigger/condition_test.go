@@ -0,0 +1,119 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package trigger
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newTestPod(phase string, statuses ...map[string]interface{}) *unstructured.Unstructured {
+	var containers []interface{}
+	for _, status := range statuses {
+		containers = append(containers, status)
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"namespace": "default", "name": "app"},
+		"status": map[string]interface{}{
+			"phase":             phase,
+			"containerStatuses": containers,
+		},
+	}}
+}
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		expr string
+		kind string
+		err  string
+	}{
+		{expr: "pod.status.phase=Failed", kind: "pod"},
+		{expr: "pods.status.phase != Running", kind: "pods"},
+		{expr: "Pod.status.containerStatuses[name=app].ready==false", kind: "Pod"},
+		{expr: `pod: obj.status.phase == "Failed"`, kind: "pod"},
+		{expr: "status.phase", err: "missing operator"},
+		{expr: "=Failed", err: "missing kind"},
+		{expr: "pod=Failed", err: "missing field path"},
+		{expr: "pod.status.containerStatuses[name].ready=false", err: "invalid selector"},
+		{expr: "pod..phase=Failed", err: "empty field"},
+		{expr: "pod:", err: "missing predicate"},
+		{expr: "pod: obj.status ==", err: "invalid condition"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			cond, err := ParseCondition(test.expr)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cond.Kind != test.kind {
+				t.Errorf("unexpected kind %s", cond.Kind)
+			}
+		})
+	}
+}
+
+func TestConditionMatch(t *testing.T) {
+	pod := newTestPod("Running",
+		map[string]interface{}{"name": "app", "ready": false, "restartCount": int64(4),
+			"state": map[string]interface{}{"waiting": map[string]interface{}{"reason": "CrashLoopBackOff"}}},
+		map[string]interface{}{"name": "sidecar", "ready": true, "restartCount": int64(0)},
+	)
+
+	tests := []struct {
+		expr    string
+		matches bool
+	}{
+		{expr: "pod.status.phase=Running", matches: true},
+		{expr: "pod.status.phase==Failed", matches: false},
+		{expr: "pod.status.phase!=Failed", matches: true},
+		{expr: `pod.status.phase="Running"`, matches: true},
+		{expr: "pod.status.containerStatuses[name=app].ready=false", matches: true},
+		{expr: "pod.status.containerStatuses[name=sidecar].ready=false", matches: false},
+		{expr: "pod.status.containerStatuses.ready=true", matches: true},
+		{expr: "pod.status.containerStatuses.state.waiting.reason=CrashLoopBackOff", matches: true},
+		{expr: "pod.status.containerStatuses[name=app].restartCount=4", matches: true},
+		{expr: "pod.status.reason=Evicted", matches: false},
+		{expr: "pod.status.reason!=Evicted", matches: true},
+		{expr: `pod: obj.status.phase == "Running"`, matches: true},
+		{expr: `pod: [s for s in obj.status.containerStatuses if s.restartCount > 3]`, matches: true},
+		{expr: `pod: obj.metadata.name == "other"`, matches: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			cond, err := ParseCondition(test.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			matches, err := cond.Match(pod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matches != test.matches {
+				t.Errorf("expected match %t, got %t", test.matches, matches)
+			}
+		})
+	}
+}
+
+func TestConditionMatchError(t *testing.T) {
+	cond, err := ParseCondition("pod: obj.status.unknown.field")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cond.Match(newTestPod("Running")); err == nil {
+		t.Fatal("expected evaluation error")
+	}
+}
@@ -0,0 +1,7 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package trigger watches the objects of a cluster and reports the objects that start
+// matching a condition, expressed as a field path (i.e. pod.status.phase=Failed) or as
+// a Starlark predicate (i.e. pod: obj.status.phase == "Failed").
+package trigger
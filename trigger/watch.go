@@ -0,0 +1,118 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package trigger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+)
+
+// Event reports an object that started matching a condition
+type Event struct {
+	Condition *Condition
+	Resource  schema.GroupVersionResource
+	Object    *unstructured.Unstructured
+	Time      time.Time
+}
+
+// Watch watches the objects of the kinds of conditions, in namespace or all namespaces
+// if empty, until ctx is done. It calls fire when an object is created matching a
+// condition, or is updated to match a condition it did not match. The objects existing
+// when the watch starts are not reported.
+func Watch(ctx context.Context, client *k8s.Client, conditions []*Condition, namespace string, fire func(Event)) error {
+	if len(conditions) == 0 {
+		return errors.New("trigger: at least one condition is required")
+	}
+
+	byResource := make(map[schema.GroupVersionResource][]*Condition)
+	scopes := make(map[schema.GroupVersionResource]bool)
+	for _, cond := range conditions {
+		gvr, namespaced, err := client.ResolveKind(cond.Kind)
+		if err != nil {
+			return fmt.Errorf("trigger: %w", err)
+		}
+		byResource[gvr] = append(byResource[gvr], cond)
+		scopes[gvr] = namespaced
+	}
+
+	factories := make(map[string]dynamicinformer.DynamicSharedInformerFactory)
+	for gvr, conds := range byResource {
+		ns := namespace
+		if !scopes[gvr] {
+			ns = metav1.NamespaceAll
+		}
+		if factories[ns] == nil {
+			factories[ns] = dynamicinformer.NewFilteredDynamicSharedInformerFactory(client.Client, 0, ns, nil)
+		}
+		informer := factories[ns].ForResource(gvr).Informer()
+		if _, err := informer.AddEventHandler(handler(gvr, conds, fire)); err != nil {
+			return fmt.Errorf("trigger: %s: %w", gvr.Resource, err)
+		}
+	}
+
+	logrus.Debugf("trigger: watching %d resource(s)", len(byResource))
+	for _, factory := range factories {
+		factory.Start(ctx.Done())
+	}
+	<-ctx.Done()
+	for _, factory := range factories {
+		factory.Shutdown()
+	}
+	return nil
+}
+
+func handler(gvr schema.GroupVersionResource, conds []*Condition, fire func(Event)) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, initial bool) {
+			if initial {
+				return
+			}
+			newObj, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				return
+			}
+			for _, cond := range conds {
+				if match(cond, newObj) {
+					fire(Event{Condition: cond, Resource: gvr, Object: newObj, Time: time.Now()})
+				}
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldU, ok := oldObj.(*unstructured.Unstructured)
+			if !ok {
+				return
+			}
+			newU, ok := newObj.(*unstructured.Unstructured)
+			if !ok || oldU.GetResourceVersion() == newU.GetResourceVersion() {
+				return
+			}
+			for _, cond := range conds {
+				if !match(cond, oldU) && match(cond, newU) {
+					fire(Event{Condition: cond, Resource: gvr, Object: newU, Time: time.Now()})
+				}
+			}
+		},
+	}
+}
+
+// match returns true if obj matches cond, an evaluation error is logged as a mismatch
+func match(cond *Condition, obj *unstructured.Unstructured) bool {
+	matched, err := cond.Match(obj)
+	if err != nil {
+		logrus.Warnf("trigger: %s: %s/%s: %s", cond, obj.GetNamespace(), obj.GetName(), err)
+		return false
+	}
+	return matched
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package trigger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"github.com/vmware-tanzu/crash-diagnostics/k8s"
+)
+
+var testPodsGVR = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+func newTestClient(objs ...runtime.Object) *k8s.Client {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, meta.RESTScopeNamespace)
+	dynamic := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		testPodsGVR: "PodList",
+	}, objs...)
+	return &k8s.Client{Client: dynamic, Mapper: mapper}
+}
+
+func TestWatch(t *testing.T) {
+	existing := newTestPod("Failed")
+	existing.SetName("existing")
+	existing.SetResourceVersion("1")
+	client := newTestClient(existing)
+
+	cond, err := ParseCondition("pod.status.phase=Failed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan Event, 10)
+	ctx, cancel := context.WithCancel(context.TODO())
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, client, []*Condition{cond}, "default", func(e Event) { events <- e })
+	}()
+
+	// let the informer list the existing pods
+	time.Sleep(200 * time.Millisecond)
+	pods := client.Client.Resource(testPodsGVR).Namespace("default")
+	pod := newTestPod("Running")
+	pod.SetResourceVersion("1")
+	if _, err := pods.Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	failed := newTestPod("Failed")
+	failed.SetResourceVersion("2")
+	if _, err := pods.Update(ctx, failed, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-events:
+		if e.Object.GetName() != "app" || e.Condition != cond || e.Resource != testPodsGVR {
+			t.Errorf("unexpected event %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("condition not fired")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("unexpected events: %d", len(events))
+	}
+}
+
+func TestWatchErrors(t *testing.T) {
+	client := newTestClient()
+	if err := Watch(context.TODO(), client, nil, "", func(Event) {}); err == nil {
+		t.Error("expected error without conditions")
+	}
+	cond, err := ParseCondition("widgets.status.phase=Failed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Watch(context.TODO(), client, []*Condition{cond}, "", func(Event) {}); err == nil {
+		t.Error("expected error for unknown kind")
+	}
+}
@@ -0,0 +1,5 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package uploader uploads files, such as crashd archives, to S3-compatible object storage.
+package uploader
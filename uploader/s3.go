@@ -0,0 +1,514 @@
+// Copyright (c) 2020 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultRegion is the region of the requests when none is configured
+	DefaultRegion = "us-east-1"
+	// DefaultPartSize is the size of the parts of multipart uploads; smaller files are uploaded with a single request
+	DefaultPartSize = 16 << 20
+	// MinPartSize is the minimum size of the parts, except the last one, accepted by S3
+	MinPartSize = 5 << 20
+	// maxParts is the maximum number of parts of a multipart upload
+	maxParts = 10000
+
+	// SSE-S3 and SSE-KMS values of the server-side encryption header
+	SSEAES256 = "AES256"
+	SSEKMS    = "aws:kms"
+
+	sseHeader      = "X-Amz-Server-Side-Encryption"
+	sseKMSKeyIDHdr = "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"
+	// sha256MetaHeader stores the SHA-256 of the uploaded file in the object metadata
+	sha256MetaHeader = "X-Amz-Meta-Sha256"
+)
+
+// S3Options configures the access to an S3-compatible object storage.
+// Empty credentials, region, and endpoint are read from the standard AWS
+// environment variables.
+type S3Options struct {
+	// Endpoint is the URL of the service, i.e. http://minio:9000, default AWS S3
+	Endpoint string
+	Region   string
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	// SSE is the server-side encryption of the objects: AES256 or aws:kms
+	SSE string
+	// KMSKeyID is the key of aws:kms encryption, default the AWS managed key
+	KMSKeyID string
+
+	// PathStyle addresses buckets as a path of the endpoint (default for custom endpoints)
+	// instead of a host name (default for AWS)
+	PathStyle *bool
+	PartSize  int64
+
+	// HTTPClient sends the requests, default http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// Location is the bucket and key of an object
+type Location struct {
+	Bucket string
+	Key    string
+}
+
+// ParseS3URL parses a destination s3://bucket[/prefix]
+func ParseS3URL(dest string) (Location, error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return Location{}, fmt.Errorf("invalid destination %s: %w", dest, err)
+	}
+	if u.Scheme != "s3" || u.Host == "" {
+		return Location{}, fmt.Errorf("invalid destination %s: expecting s3://bucket/prefix", dest)
+	}
+	return Location{Bucket: u.Host, Key: strings.Trim(u.Path, "/")}, nil
+}
+
+// IsS3URL returns true if dest is an s3:// URL
+func IsS3URL(dest string) bool {
+	return strings.HasPrefix(dest, "s3://")
+}
+
+// Result describes an uploaded object
+type Result struct {
+	Location
+	// URL is the HTTP(S) URL of the object
+	URL    string
+	Size   int64
+	SHA256 string
+	ETag   string
+	// Parts is the number of parts of a multipart upload, 0 for a single request
+	Parts int
+}
+
+// URI returns the s3://bucket/key URI of the object
+func (r *Result) URI() string {
+	return fmt.Sprintf("s3://%s/%s", r.Bucket, r.Key)
+}
+
+// S3Client uploads files to an S3-compatible object storage
+type S3Client struct {
+	endpoint  *url.URL
+	region    string
+	creds     credentials
+	sse       string
+	kmsKeyID  string
+	pathStyle bool
+	partSize  int64
+	http      *http.Client
+}
+
+// NewS3Client returns a client configured by opts
+func NewS3Client(opts S3Options) (*S3Client, error) {
+	region := firstNonEmpty(opts.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), DefaultRegion)
+	creds := credentials{
+		accessKey:    firstNonEmpty(opts.AccessKey, os.Getenv("AWS_ACCESS_KEY_ID")),
+		secretKey:    firstNonEmpty(opts.SecretKey, os.Getenv("AWS_SECRET_ACCESS_KEY")),
+		sessionToken: opts.SessionToken,
+	}
+	if opts.AccessKey == "" && opts.SecretKey == "" {
+		creds.sessionToken = firstNonEmpty(opts.SessionToken, os.Getenv("AWS_SESSION_TOKEN"))
+	}
+	if creds.accessKey == "" || creds.secretKey == "" {
+		return nil, errors.New("s3: access key and secret key required (args or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)")
+	}
+
+	pathStyle := true
+	rawEndpoint := firstNonEmpty(opts.Endpoint, os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL"))
+	if rawEndpoint == "" {
+		rawEndpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+		pathStyle = false
+	}
+	if opts.PathStyle != nil {
+		pathStyle = *opts.PathStyle
+	}
+	if !strings.Contains(rawEndpoint, "://") {
+		rawEndpoint = "https://" + rawEndpoint
+	}
+	endpoint, err := url.Parse(rawEndpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %s", rawEndpoint)
+	}
+
+	switch opts.SSE {
+	case "", SSEAES256, SSEKMS:
+	default:
+		return nil, fmt.Errorf("s3: unsupported server-side encryption %s (expecting %s or %s)", opts.SSE, SSEAES256, SSEKMS)
+	}
+	if opts.KMSKeyID != "" && opts.SSE != SSEKMS {
+		return nil, fmt.Errorf("s3: kms key id requires %s encryption", SSEKMS)
+	}
+
+	partSize := opts.PartSize
+	if partSize == 0 {
+		partSize = DefaultPartSize
+	}
+	if partSize < MinPartSize {
+		return nil, fmt.Errorf("s3: part size %d smaller than %d", partSize, MinPartSize)
+	}
+
+	client := opts.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &S3Client{
+		endpoint:  endpoint,
+		region:    region,
+		creds:     creds,
+		sse:       opts.SSE,
+		kmsKeyID:  opts.KMSKeyID,
+		pathStyle: pathStyle,
+		partSize:  partSize,
+		http:      client,
+	}, nil
+}
+
+// Upload uploads file to object <dest.Key>/<file name> of bucket dest.Bucket. Files larger than
+// the part size are sent with a multipart upload, aborted on error. The integrity of each request
+// is verified by the server with the MD5 and SHA-256 of its body, and the ETag returned is
+// compared with the expected one, unless the object is encrypted with aws:kms.
+func (c *S3Client) Upload(ctx context.Context, file string, dest Location) (*Result, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("s3: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("s3: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("s3: %s is a directory", file)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, fmt.Errorf("s3: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("s3: %w", err)
+	}
+
+	result := &Result{
+		Location: Location{Bucket: dest.Bucket, Key: path.Join(dest.Key, filepath.Base(file))},
+		Size:     info.Size(),
+		SHA256:   hex.EncodeToString(hash.Sum(nil)),
+	}
+	result.URL = c.objectURL(result.Location).String()
+
+	logrus.Debugf("s3: uploading %s (%d bytes) to %s", file, result.Size, result.URI())
+	if result.Size <= c.partSize {
+		err = c.putObject(ctx, f, result)
+	} else {
+		err = c.multipartUpload(ctx, f, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	logrus.Debugf("s3: uploaded %s to %s", file, result.URL)
+	return result, nil
+}
+
+func (c *S3Client) putObject(ctx context.Context, f io.Reader, result *Result) error {
+	body, err := io.ReadAll(f)
+	if err != nil {
+		return fmt.Errorf("s3: %w", err)
+	}
+	req, err := c.newRequest(ctx, http.MethodPut, result.Location, nil, body)
+	if err != nil {
+		return err
+	}
+	c.setObjectHeaders(req, result)
+
+	resp, err := c.do(req, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := c.checkEncryption(resp); err != nil {
+		return err
+	}
+
+	result.ETag = strings.Trim(resp.Header.Get("ETag"), `"`)
+	sum := md5.Sum(body)
+	return c.checkETag(result.ETag, hex.EncodeToString(sum[:]))
+}
+
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName xml.Name
+	ETag    string
+	Code    string
+	Message string
+}
+
+func (c *S3Client) multipartUpload(ctx context.Context, f io.Reader, result *Result) (err error) {
+	partSize := c.partSize
+	if (result.Size+partSize-1)/partSize > maxParts {
+		partSize = (result.Size + maxParts - 1) / maxParts
+	}
+
+	// initiate
+	req, err := c.newRequest(ctx, http.MethodPost, result.Location, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return err
+	}
+	c.setObjectHeaders(req, result)
+	resp, err := c.do(req, nil)
+	if err != nil {
+		return err
+	}
+	var initiated initiateMultipartUploadResult
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("s3: invalid multipart upload response: %w", err)
+	}
+	if err := c.checkEncryption(resp); err != nil {
+		return err
+	}
+	uploadID := initiated.UploadID
+
+	// abort the upload, to release the parts stored, if it does not complete
+	defer func() {
+		if err == nil {
+			return
+		}
+		abortCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if abortErr := c.abortMultipartUpload(abortCtx, result.Location, uploadID); abortErr != nil {
+			logrus.Warnf("s3: failed to abort upload %s: %s", uploadID, abortErr)
+		}
+	}()
+
+	// parts
+	complete := completeMultipartUpload{}
+	partSums := make([]byte, 0, md5.Size*int((result.Size+partSize-1)/partSize))
+	buf := make([]byte, partSize)
+	for number := 1; ; number++ {
+		n, readErr := io.ReadFull(f, buf)
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil && readErr != io.ErrUnexpectedEOF {
+			return fmt.Errorf("s3: %w", readErr)
+		}
+		etag, sum, err := c.uploadPart(ctx, result.Location, uploadID, number, buf[:n])
+		if err != nil {
+			return err
+		}
+		complete.Parts = append(complete.Parts, completedPart{PartNumber: number, ETag: `"` + etag + `"`})
+		partSums = append(partSums, sum...)
+		if readErr == io.ErrUnexpectedEOF {
+			break
+		}
+	}
+
+	// complete
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return fmt.Errorf("s3: %w", err)
+	}
+	req, err = c.newRequest(ctx, http.MethodPost, result.Location, url.Values{"uploadId": {uploadID}}, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	resp, err = c.do(req, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// the completion can fail after the 200 OK status, reported in the body
+	var completed completeMultipartUploadResult
+	if err := xml.NewDecoder(resp.Body).Decode(&completed); err != nil {
+		return fmt.Errorf("s3: invalid complete multipart upload response: %w", err)
+	}
+	if completed.XMLName.Local == "Error" {
+		return fmt.Errorf("s3: complete multipart upload failed: %s: %s", completed.Code, completed.Message)
+	}
+
+	result.Parts = len(complete.Parts)
+	result.ETag = strings.Trim(completed.ETag, `"`)
+	sum := md5.Sum(partSums)
+	return c.checkETag(result.ETag, fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), result.Parts))
+}
+
+// uploadPart uploads part number and returns its ETag and MD5
+func (c *S3Client) uploadPart(ctx context.Context, loc Location, uploadID string, number int, part []byte) (string, []byte, error) {
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+	req, err := c.newRequest(ctx, http.MethodPut, loc, query, part)
+	if err != nil {
+		return "", nil, err
+	}
+	resp, err := c.do(req, part)
+	if err != nil {
+		return "", nil, err
+	}
+	resp.Body.Close()
+
+	sum := md5.Sum(part)
+	etag := strings.Trim(resp.Header.Get("ETag"), `"`)
+	if err := c.checkETag(etag, hex.EncodeToString(sum[:])); err != nil {
+		return "", nil, fmt.Errorf("part %d: %w", number, err)
+	}
+	return etag, sum[:], nil
+}
+
+func (c *S3Client) abortMultipartUpload(ctx context.Context, loc Location, uploadID string) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, loc, url.Values{"uploadId": {uploadID}}, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// setObjectHeaders sets the headers of the requests creating an object
+func (c *S3Client) setObjectHeaders(req *http.Request, result *Result) {
+	contentType := "application/octet-stream"
+	if strings.HasSuffix(result.Key, ".gz") {
+		contentType = "application/gzip"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(sha256MetaHeader, result.SHA256)
+	if c.sse != "" {
+		req.Header.Set(sseHeader, c.sse)
+	}
+	if c.kmsKeyID != "" {
+		req.Header.Set(sseKMSKeyIDHdr, c.kmsKeyID)
+	}
+}
+
+// checkEncryption returns an error if the encryption requested was not applied
+func (c *S3Client) checkEncryption(resp *http.Response) error {
+	if c.sse == "" {
+		return nil
+	}
+	if applied := resp.Header.Get(sseHeader); applied != c.sse {
+		return fmt.Errorf("s3: server-side encryption %s requested, got %q", c.sse, applied)
+	}
+	return nil
+}
+
+// checkETag compares the ETag of an object or part with the expected one. The ETag
+// of objects encrypted with aws:kms is not derived from the MD5 of their content.
+func (c *S3Client) checkETag(etag, expected string) error {
+	if c.sse == SSEKMS {
+		return nil
+	}
+	if !strings.EqualFold(etag, expected) {
+		return fmt.Errorf("s3: checksum mismatch: expected ETag %s, got %s", expected, etag)
+	}
+	return nil
+}
+
+func (c *S3Client) objectURL(loc Location) *url.URL {
+	u := *c.endpoint
+	basePath := strings.TrimSuffix(u.Path, "/")
+	if c.pathStyle {
+		u.Path = basePath + "/" + loc.Bucket + "/" + loc.Key
+		u.RawPath = basePath + "/" + uriEncode(loc.Bucket) + "/" + escapePath(loc.Key)
+	} else {
+		u.Host = loc.Bucket + "." + u.Host
+		u.Path = basePath + "/" + loc.Key
+		u.RawPath = basePath + "/" + escapePath(loc.Key)
+	}
+	return &u
+}
+
+func (c *S3Client) newRequest(ctx context.Context, method string, loc Location, query url.Values, body []byte) (*http.Request, error) {
+	u := c.objectURL(loc)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("s3: %w", err)
+	}
+	req.ContentLength = int64(len(body))
+	if len(body) > 0 {
+		sum := md5.Sum(body)
+		req.Header.Set("Content-Md5", base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	return req, nil
+}
+
+// do signs and sends req, and returns an error for a response status other than 2xx
+func (c *S3Client) do(req *http.Request, body []byte) (*http.Response, error) {
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		payloadHash = hashHex(body)
+	}
+	sign(req, c.creds, c.region, payloadHash, time.Now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3: %w", err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var s3Err struct {
+		Code    string
+		Message string
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if xml.Unmarshal(data, &s3Err) != nil || s3Err.Code == "" {
+		s3Err.Code = resp.Status
+	}
+	return nil, fmt.Errorf("s3: %s %s: %s %s", req.Method, req.URL.Path, s3Err.Code, s3Err.Message)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}